@@ -31,6 +31,10 @@ type AccountCreationRequest struct {
 	Phone string `json:"phone,omitempty"`
 	// The password that the user wants to use
 	Password string `json:"password"`
+	// InvitationCode represents the code of a tenant invitation sent to the
+	// email or phone. If it's set the account joins the tenant right away,
+	// without needing a separate verification code.
+	InvitationCode string `json:"invitation_code,omitempty"`
 }
 
 // AccountCreationResponse represents the response that the account creation
@@ -143,6 +147,28 @@ func generateVerificationCode() (string, error) {
 		return fmt.Sprint(i), nil
 	}
 
+// normalizePhone converts a phone number to the international format, adding
+// the Romanian prefix for local numbers. It returns false if the result
+// doesn't have the right length.
+func normalizePhone(raw string) (string, bool) {
+	phone := strings.Map(func(r rune) rune {
+		if r == '+' || unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, raw)
+
+	hasPlus := strings.HasPrefix(phone, "+")
+	hasMobilePrefix := strings.HasPrefix(phone, "07")
+	hasTelephonePrefix := strings.HasPrefix(phone, "02")
+
+	if !hasPlus && (hasMobilePrefix || hasTelephonePrefix) {
+		phone = "+4" + phone
+	}
+
+	return phone, len(phone) == PhoneLength
+}
+
 
 // CreateAccount is the endpoint used for creating new user accounts.
 func (a *API) CreateAccount(w http.ResponseWriter, r *http.Request) {
@@ -206,52 +232,49 @@ func (a *API) CreateAccount(w http.ResponseWriter, r *http.Request) {
 			JsonError(w, http.StatusBadRequest, "email already used")
 			return
 		}
-		code, err := generateVerificationCode()
-		if err != nil {
-			JsonError(w, http.StatusInternalServerError, "not implemented")
-			return
-		}
-		cvcp := database.CreateVerificationCodeParams{
-			AccountID:        row.AccountID,
-			VerificationCode: code,
-			Scope:            database.VerificationScopeRegister,
-		}
-		err = queries.CreateVerificationCode(ctx, cvcp)
-		if err != nil {
-			JsonError(w, http.StatusInternalServerError, "not implemented")
-			return
-		}
 		resp.AccountID = row.AccountID
 		resp.Email = row.Email.String
 		resp.Phone = row.Phone.String
 
-		// assume that if the verification cannot be sended the user gave an
-		// invalid email
-		// TODO: refactor this so we also know when the verification service
-		// is down
-		err = a.emailVerifier.SendVerification(request.Email, code)
-		if err != nil {
-			JsonError(w, http.StatusBadRequest, "invalid email")
-			return
-		}
-		tx.Commit(ctx)
-	} else if request.Phone != "" {
-		phone := strings.Map(func(r rune) rune {
-			if r == '+' || unicode.IsDigit(r) {
-				return r
+		if request.InvitationCode != "" {
+			err = joinByInvitation(
+				ctx, queries, row.AccountID, request.InvitationCode,
+			)
+			if err != nil {
+				JsonError(w, http.StatusBadRequest, "invalid invitation")
+				return
+			}
+		} else {
+			code, err := generateVerificationCode()
+			if err != nil {
+				JsonError(w, http.StatusInternalServerError, "not implemented")
+				return
+			}
+			cvcp := database.CreateVerificationCodeParams{
+				AccountID:        row.AccountID,
+				VerificationCode: code,
+				Scope:            database.VerificationScopeRegister,
+			}
+			err = queries.CreateVerificationCode(ctx, cvcp)
+			if err != nil {
+				JsonError(w, http.StatusInternalServerError, "not implemented")
+				return
 			}
-			return -1
-		}, request.Phone)
-
-		hasPlus := strings.HasPrefix(phone, "+")
-		hasMobilePrefix := strings.HasPrefix(phone, "07")
-		hasTelephonePrefix := strings.HasPrefix(phone, "02")
 
-		if !hasPlus && (hasMobilePrefix || hasTelephonePrefix) {
-			phone = "+4" + phone
+			// assume that if the verification cannot be sended the user gave an
+			// invalid email
+			// TODO: refactor this so we also know when the verification service
+			// is down
+			err = a.emailVerifier.SendVerification(request.Email, code)
+			if err != nil {
+				JsonError(w, http.StatusBadRequest, "invalid email")
+				return
+			}
 		}
-
-		if len(phone) != PhoneLength {
+		tx.Commit(ctx)
+	} else if request.Phone != "" {
+		phone, ok := normalizePhone(request.Phone)
+		if !ok {
 			JsonError(w, http.StatusBadRequest, "phone too short/long")
 			return
 		}
@@ -274,35 +297,45 @@ func (a *API) CreateAccount(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		code, err := generateVerificationCode()
-		if err != nil {
-			JsonError(w, http.StatusInternalServerError, "not implemented")
-			return
-		}
-
-		cvcp := database.CreateVerificationCodeParams{
-			AccountID:        row.AccountID,
-			VerificationCode: code,
-			Scope:            database.VerificationScopeRegister,
-		}
-		err = queries.CreateVerificationCode(ctx, cvcp)
-		if err != nil {
-			JsonError(w, http.StatusInternalServerError, "not implemented")
-			return
-		}
-
 		resp.AccountID = row.AccountID
 		resp.Email = row.Email.String
 		resp.Phone = row.Phone.String
 
-		// Assume that if the verification cannot be sended the user gave an
-		// invalid email.
-		// TODO: refactor this so we also know when the verification service is
-		// down.
-		err = a.phoneVerifier.SendVerification(request.Phone, code)
-		if err != nil {
-			JsonError(w, http.StatusBadRequest, "invalid phone")
-			return
+		if request.InvitationCode != "" {
+			err = joinByInvitation(
+				ctx, queries, row.AccountID, request.InvitationCode,
+			)
+			if err != nil {
+				JsonError(w, http.StatusBadRequest, "invalid invitation")
+				return
+			}
+		} else {
+			code, err := generateVerificationCode()
+			if err != nil {
+				JsonError(w, http.StatusInternalServerError, "not implemented")
+				return
+			}
+
+			cvcp := database.CreateVerificationCodeParams{
+				AccountID:        row.AccountID,
+				VerificationCode: code,
+				Scope:            database.VerificationScopeRegister,
+			}
+			err = queries.CreateVerificationCode(ctx, cvcp)
+			if err != nil {
+				JsonError(w, http.StatusInternalServerError, "not implemented")
+				return
+			}
+
+			// Assume that if the verification cannot be sended the user gave
+			// an invalid email.
+			// TODO: refactor this so we also know when the verification
+			// service is down.
+			err = a.phoneVerifier.SendVerification(request.Phone, code)
+			if err != nil {
+				JsonError(w, http.StatusBadRequest, "invalid phone")
+				return
+			}
 		}
 
 		err = tx.Commit(ctx)
@@ -1,132 +1,389 @@
 package schedder
 
 import (
+	"errors"
 	"net/http"
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
 	"gitlab.com/vlad.anghel/schedder-api/database"
 )
 
 type CreateAppointmentRequest struct {
 	// Starting represents when the user wants to create an appointment.
 	Starting time.Time `json:"starting"`
+	// LocationID represents the location where the appointment takes place.
+	// It's required only for services offered at specific locations.
+	LocationID uuid.UUID `json:"location_id,omitempty"`
+	// PersonnelID represents the member chosen by the user, if it's missing
+	// any member free at that time is picked.
+	PersonnelID uuid.UUID `json:"personnel_id,omitempty"`
+	// VariantID represents the chosen variant, it's required if the service
+	// has variants.
+	VariantID uuid.UUID `json:"variant_id,omitempty"`
+	// AddOnIDs represents the chosen add-ons.
+	AddOnIDs []uuid.UUID `json:"addon_ids"`
+	// PurchaseID represents the package purchase whose credit pays for the
+	// service, the options are still paid.
+	PurchaseID uuid.UUID `json:"purchase_id,omitempty"`
 }
 
 type CreateAppointmentResponse struct {
 	Response
 	AppointmentID uuid.UUID `json:"appointment_id"`
+	// PersonnelID represents the member doing the appointment.
+	PersonnelID uuid.UUID `json:"personnel_id"`
+	// Price represents the price of the service and the chosen options,
+	// before VAT. The service isn't included if it's paid with a credit.
+	Price Money `json:"price"`
+	// Total represents the price with VAT.
+	Total Money `json:"total"`
+}
+
+// appointmentEntry represents an appointment of the customer. The name, price
+// and duration are the ones from when the appointment was booked.
+type appointmentEntry struct {
+	AppointmentID uuid.UUID     `json:"appointment_id"`
+	TenantID      uuid.UUID     `json:"tenant_id"`
+	ServiceID     uuid.UUID     `json:"service_id"`
+	PersonnelID   uuid.UUID     `json:"personnel_id"`
+	ServiceName   string        `json:"service_name"`
+	Price         Money         `json:"price"`
+	Duration      time.Duration `json:"duration"`
+	Starting      time.Time     `json:"starting"`
+	// Total represents the price with the VAT rate from when the appointment
+	// was booked.
+	Total Money `json:"total"`
+	// VATRate represents the VAT rate in basis points, 1900 for 19%.
+	VATRate int `json:"vat_rate"`
+	// Status represents the status, "pending", "cancelled" or "done".
+	Status string `json:"status"`
+	// LocationID represents the location, it's the nil UUID if the service
+	// isn't offered at specific locations.
+	LocationID uuid.UUID `json:"location_id"`
+	// Options represents the names of the chosen variant and add-ons.
+	Options []string `json:"options"`
+	// PurchaseID represents the package purchase whose credit was used, it's
+	// the nil UUID if the service wasn't booked with one.
+	PurchaseID uuid.UUID `json:"purchase_id"`
+	// Conflicting is set when the appointment is outside the working hours of
+	// the member after a schedule exception or a time off, the tenant should
+	// move or cancel it.
+	Conflicting bool `json:"conflicting"`
+}
+
+// AppointmentsResponse represents the response of the appointments endpoint.
+type AppointmentsResponse struct {
+	Response
+	Appointments []appointmentEntry `json:"appointments"`
 }
 
 type TimetableRequest struct {
-	// Date represents the date for which to get the timetable.
+	// Date represents the date for which to get the timetable, only the date
+	// in the time zone of the tenant is used.
 	Date time.Time `json:"date"`
+	// LocationID represents the location for which to get the timetable. It's
+	// required only for services offered at specific locations.
+	LocationID uuid.UUID `json:"location_id,omitempty"`
+	// PersonnelID represents the member for which to get the timetable, if
+	// it's missing the times when any member is free are returned.
+	PersonnelID uuid.UUID `json:"personnel_id,omitempty"`
+	// VariantID represents the chosen variant, it's required if the service
+	// has variants.
+	VariantID uuid.UUID `json:"variant_id,omitempty"`
+	// AddOnIDs represents the chosen add-ons, their durations are added to
+	// the one of the service.
+	AddOnIDs []uuid.UUID `json:"addon_ids"`
+}
+
+// timetableSlot represents a free starting time and the price of the
+// appointment starting then, with the pricing rules and the chosen options.
+type timetableSlot struct {
+	Starting time.Time `json:"starting"`
+	Price    Money     `json:"price"`
+	// Total represents the price with VAT.
+	Total Money `json:"total"`
 }
 
 type TimetableResponse struct {
 	Response
 	Times []time.Time `json:"times"`
+	// Slots represents the same times, on the date, with their prices.
+	Slots []timetableSlot `json:"slots"`
 }
 
 func (a *API) CreateAppointment(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
 	serviceID := ctx.Value(CtxServiceID).(uuid.UUID)
 	authenticatedID := ctx.Value(CtxAuthenticatedID).(uuid.UUID)
 	request := ctx.Value(CtxJSON).(*CreateAppointmentRequest)
 
-	serviceData, err := a.db.GetServiceDurationAndPersonnel(ctx, serviceID)
+	selection, err := a.selectOptions(
+		ctx, tenantID, serviceID, request.VariantID, request.AddOnIDs,
+	)
+	if isBookingError(err) {
+		JsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	if err != nil {
-		JsonError(w, http.StatusBadRequest, "invalid service")
+		JsonError(w, http.StatusInternalServerError, "couldn't check options")
 		return
 	}
 
-	timetableParams := database.GetTimetableForDateParams{
-		Weekday: request.Starting.Weekday(),
-		PersonnelID: serviceData.AccountID,
-		DesiredDate: request.Starting,
-	}
-	timetable, err := a.db.GetTimetableForDate(ctx, timetableParams)
+	tx, err := a.txlike.Begin(ctx)
 	if err != nil {
 		JsonError(w, http.StatusInternalServerError, "not implemented")
 		return
 	}
+	defer tx.Rollback(ctx)
+	queries := database.New(tx)
 
+	slots, err := freeSlots(
+		ctx, queries, tenantID, serviceID, request.PersonnelID, request.LocationID,
+		request.Starting, selection.duration,
+	)
+	if isBookingError(err) {
+		JsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "couldn't check availability")
+		return
+	}
 
-	ok := false
-	for _, value := range timetable {
-		year, m, day := request.Starting.Date()
-		t := value.Times.AddDate(year-2000, int(m)-1, day-1)
-		if t.Equal(request.Starting) {
-			ok = true
+	// The first member free at that time gets the appointment. The member is
+	// locked and their slots are checked again, a concurrent booking could
+	// have taken the time since.
+	var chosen *personnelSlots
+	for i := range slots {
+		if !slots[i].freeAt(request.Starting) {
+			continue
+		}
+		err := queries.LockSchedule(ctx, slots[i].personnelID)
+		if err != nil {
+			JsonError(w, http.StatusInternalServerError, "not implemented")
+			return
+		}
+		locked, err := freeSlots(
+			ctx, queries, tenantID, serviceID, slots[i].personnelID,
+			request.LocationID, request.Starting, selection.duration,
+		)
+		if isBookingError(err) {
+			continue
+		}
+		if err != nil {
+			JsonError(w, http.StatusInternalServerError, "couldn't check availability")
+			return
+		}
+		if locked[0].freeAt(request.Starting) {
+			chosen = &locked[0]
 			break
 		}
 	}
-
-	if !ok {
+	if chosen == nil {
 		JsonError(w, http.StatusBadRequest, "invalid time")
 		return
 	}
+	personnelID := chosen.personnelID
+
+	// the price is locked with the rules at the time of booking
+	rules, err := a.pricingRulesFor(ctx, tenantID, serviceID)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "couldn't check prices")
+		return
+	}
 
 	params := database.CreateAppointmentParams{
-		ServiceID: serviceID,
-		AccountID: authenticatedID,
-		Starting:  request.Starting,
+		ServiceID:   serviceID,
+		PersonnelID: personnelID,
+		AccountID:   authenticatedID,
+		Starting:    request.Starting,
+		LocationID: uuid.NullUUID{
+			UUID:  request.LocationID,
+			Valid: request.LocationID != uuid.Nil,
+		},
+		ServicePrice: rules.priceAt(chosen.price, request.Starting),
+		OptionsPrice: selection.price,
+		PurchaseID: uuid.NullUUID{
+			UUID:  request.PurchaseID,
+			Valid: request.PurchaseID != uuid.Nil,
+		},
 	}
-	appointmentID, err := a.db.CreateAppointment(ctx, params)
+	params.OptionsDuration.Set(selection.duration)
+
+	if request.PurchaseID != uuid.Nil {
+		lpcp := database.LockPurchaseCreditsParams{
+			PurchaseID: request.PurchaseID,
+			AccountID:  authenticatedID,
+			ServiceID:  serviceID,
+		}
+		remaining, err := queries.LockPurchaseCredits(ctx, lpcp)
+		if errors.Is(err, pgx.ErrNoRows) {
+			JsonError(w, http.StatusBadRequest, errInvalidPurchase.Error())
+			return
+		}
+		if err != nil {
+			JsonError(w, http.StatusInternalServerError, "not implemented")
+			return
+		}
+		if remaining <= 0 {
+			JsonError(w, http.StatusBadRequest, errNoCredits.Error())
+			return
+		}
+	}
+
+	appointment, err := queries.CreateAppointment(ctx, params)
 	if err != nil {
 		JsonError(w, http.StatusBadRequest, "couldn't create appointment")
 		return
 	}
 
-	JsonResp(w, http.StatusCreated, CreateAppointmentResponse{AppointmentID: appointmentID})
+	aaop := database.AddAppointmentOptionsParams{
+		AppointmentID: appointment.AppointmentID,
+		OptionIds:     selection.optionIDs,
+	}
+	err = queries.AddAppointmentOptions(ctx, aaop)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	price := Money{Amount: appointment.Price, Currency: appointment.Currency}
+	JsonResp(w, http.StatusCreated, CreateAppointmentResponse{
+		AppointmentID: appointment.AppointmentID,
+		PersonnelID:   personnelID,
+		Price:         price,
+		Total:         price.WithVAT(int(appointment.VatRate)),
+	})
+}
+
+// Appointments lists the appointments of the authenticated account, newest
+// first, including the ones for archived services.
+func (a *API) Appointments(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	authenticatedID := ctx.Value(CtxAuthenticatedID).(uuid.UUID)
+
+	rows, err := a.db.GetAppointmentsForAccount(ctx, authenticatedID)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	var response AppointmentsResponse
+	response.Appointments = make([]appointmentEntry, 0, len(rows))
+	for i := range rows {
+		row := &rows[i]
+		appointment := appointmentEntry{
+			AppointmentID: row.AppointmentID,
+			TenantID:      row.TenantID,
+			ServiceID:     row.ServiceID,
+			PersonnelID:   row.PersonnelID,
+			ServiceName:   row.ServiceName,
+			Price:         Money{Amount: row.Price, Currency: row.Currency},
+			Starting:      row.Starting,
+			VATRate:       int(row.VatRate),
+			Status:        string(row.Status),
+			LocationID:    row.LocationID.UUID,
+			Options:       row.Options,
+			PurchaseID:    row.PurchaseID.UUID,
+			Conflicting:   row.Conflicting,
+		}
+		if appointment.Options == nil {
+			appointment.Options = []string{}
+		}
+		appointment.Total = appointment.Price.WithVAT(appointment.VATRate)
+		err := row.Duration.AssignTo(&appointment.Duration)
+		if err != nil {
+			JsonError(w, http.StatusInternalServerError, "not implemented")
+			return
+		}
+		response.Appointments = append(response.Appointments, appointment)
+	}
+
+	JsonResp(w, http.StatusOK, response)
 }
 
+// Timetable lists the starting times on the date when the requested member,
+// or any member doing the service, is free. The price of each time is the one
+// of the member who would get the appointment, with the pricing rules of the
+// service.
 func (a *API) Timetable(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
 	serviceID := ctx.Value(CtxServiceID).(uuid.UUID)
 	request := ctx.Value(CtxJSON).(*TimetableRequest)
 
-
-	serviceData, err := a.db.GetServiceDurationAndPersonnel(ctx, serviceID)
+	selection, err := a.selectOptions(
+		ctx, tenantID, serviceID, request.VariantID, request.AddOnIDs,
+	)
+	if isBookingError(err) {
+		JsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	if err != nil {
-		JsonError(w, http.StatusInternalServerError, "couldn't fetch service")
+		JsonError(w, http.StatusInternalServerError, "couldn't check options")
 		return
 	}
 
-	var duration time.Duration
-	err = serviceData.Duration.AssignTo(&duration)
+	slots, err := freeSlots(
+		ctx, a.db, tenantID, serviceID, request.PersonnelID, request.LocationID,
+		request.Date, selection.duration,
+	)
+	if isBookingError(err) {
+		JsonError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	if err != nil {
-		JsonError(w, http.StatusInternalServerError, "couldn't convert durations")
+		JsonError(w, http.StatusInternalServerError, "couldn't check availability")
 		return
 	}
 
-
-	rows, err := a.db.GetTimetableForDate(ctx, database.GetTimetableForDateParams{
-		DesiredDate: request.Date,
-		Weekday: request.Date.Weekday(),
-		PersonnelID: serviceData.AccountID,
-	})
+	rules, err := a.pricingRulesFor(ctx, tenantID, serviceID)
 	if err != nil {
-		JsonError(w, http.StatusBadRequest, "bad request")
+		JsonError(w, http.StatusInternalServerError, "couldn't check prices")
+		return
+	}
+	pricing, err := a.pricingFor(ctx, tenantID)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
 		return
 	}
 
 	var response TimetableResponse
-	response.Times = make([]time.Time, 0, len(rows))
-
-	delta := int(duration / (30 * time.Minute))
-
-	count := 0
-	for _, row := range rows {
-		if row.IsBlocked {
-			count = 0
-		} else {
-			count++
-		}
-		if count >= delta {
-			response.Times = append(response.Times, row.Times)
+	response.Times = make([]time.Time, 0)
+	response.Slots = make([]timetableSlot, 0)
+	seen := make(map[time.Time]bool)
+	for _, ps := range slots {
+		for _, slot := range ps.times {
+			if seen[slot] {
+				continue
+			}
+			seen[slot] = true
+			starting := slotOn(slot, ps.date)
+			price := rules.priceAt(ps.price, starting) + selection.price
+			response.Times = append(response.Times, slot)
+			response.Slots = append(response.Slots, timetableSlot{
+				Starting: starting,
+				Price:    pricing.price(price),
+				Total:    pricing.total(price),
+			})
 		}
 	}
+	sort.Slice(response.Times, func(i, j int) bool {
+		return response.Times[i].Before(response.Times[j])
+	})
+	sort.Slice(response.Slots, func(i, j int) bool {
+		return response.Slots[i].Starting.Before(response.Slots[j].Starting)
+	})
 
 	JsonResp(w, http.StatusOK, response)
 }
@@ -25,11 +25,12 @@ func TestGetTimetable(t *testing.T) {
 	api.forceBusiness(email, true)
 	token := api.generateToken(email, password)
 	tenantID := api.createTenant(token, tenantName)
-	serviceID := api.createService(api.generateToken(email, password), tenantID, accountID , "control", 4.20, time.Hour)
+	api.publishTenant(tenantID)
+	serviceID := api.createService(api.generateToken(email, password), tenantID, accountID , "control", 420, time.Hour)
 	starting := time.Time{}.Add(10 * time.Hour)
 	ending := starting.Add(8 * time.Hour)
 
-	api.setSchedule(token, accountID, tenantID, starting, ending , time.Now().Weekday())
+	api.setSchedule(token, accountID, tenantID, starting, ending , localDate(time.Now()).Weekday())
 	endpoint := fmt.Sprintf(
 		"/tenants/%s/services/%s/timetable",
 		tenantID, serviceID,
@@ -80,7 +81,8 @@ func TestGetTimetableWithoutSchedule(t *testing.T) {
 	api.forceBusiness(email, true)
 	token := api.generateToken(email, password)
 	tenantID := api.createTenant(token, tenantName)
-	serviceID := api.createService(api.generateToken(email, password), tenantID, accountID , "control", 4.20, time.Hour)
+	api.publishTenant(tenantID)
+	serviceID := api.createService(api.generateToken(email, password), tenantID, accountID , "control", 420, time.Hour)
 	endpoint := fmt.Sprintf(
 		"/tenants/%s/services/%s/timetable",
 		tenantID, serviceID,
@@ -113,16 +115,17 @@ func TestCreateAppointment(t *testing.T) {
 	api.forceBusiness(email, true)
 	token := api.generateToken(email, password)
 	tenantID := api.createTenant(token, tenantName)
-	serviceID := api.createService(api.generateToken(email, password), tenantID, accountID , "control", 4.20, time.Hour)
+	api.publishTenant(tenantID)
+	serviceID := api.createService(api.generateToken(email, password), tenantID, accountID , "control", 420, time.Hour)
 
-	today := time.Now().Truncate(24 * time.Hour)
-	starting := today.Add(10 * time.Hour)
+	today := localDate(time.Now())
+	starting := hourOn(today, 10)
 	ending := starting.Add(8 * time.Hour)
 	desired := starting.Add(2 * time.Hour)
 	fmt.Printf("desired: %v\n", desired)
 	fmt.Printf("starting: %v\n", starting)
 	fmt.Printf("ending: %v\n", ending)
-	api.setSchedule(token, accountID, tenantID, starting, ending , time.Now().Weekday())
+	api.setSchedule(token, accountID, tenantID, starting, ending , today.Weekday())
 
 	endpoint := fmt.Sprintf(
 		"/tenants/%s/services/%s/schedule",
@@ -149,3 +152,84 @@ func TestCreateAppointment(t *testing.T) {
 
 	unexpect(t, uuid.Nil, response.AppointmentID)
 }
+
+func TestTimetableSlotGranularityAndBuffers(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantName := "Zâna Măseluță"
+
+	tenantID := api.createTenantAndAccount(email, password, tenantName)
+	accountID := api.findAccountByEmail(email)
+	token := api.generateToken(email, password)
+	api.publishTenant(tenantID)
+	serviceID := api.createService(token, tenantID, accountID, "Masaj", 5000, 45*time.Minute)
+
+	today := localDate(time.Now())
+	starting := hourOn(today, 10)
+	api.setSchedule(token, accountID, tenantID, starting, starting.Add(3*time.Hour), today.Weekday())
+
+	tenantEndpoint := fmt.Sprintf("/tenants/%s", tenantID)
+	resp := api.send(
+		token, http.MethodPut, tenantEndpoint+"/slots",
+		schedder.SlotGranularityRequest{SlotGranularity: 7 * time.Minute},
+	)
+	expect(t, http.StatusBadRequest, resp.StatusCode)
+	resp = api.send(
+		token, http.MethodPut, tenantEndpoint+"/slots",
+		schedder.SlotGranularityRequest{SlotGranularity: 15 * time.Minute},
+	)
+	expect(t, http.StatusOK, resp.StatusCode)
+
+	serviceEndpoint := fmt.Sprintf("%s/services/%s", tenantEndpoint, serviceID)
+	resp = api.send(token, http.MethodPut, serviceEndpoint, schedder.UpdateServiceRequest{
+		ServiceName:  "Masaj",
+		Price:        schedder.Money{Amount: 5000},
+		Duration:     45 * time.Minute,
+		BufferBefore: 15 * time.Minute,
+		BufferAfter:  15 * time.Minute,
+	})
+	expect(t, http.StatusOK, resp.StatusCode)
+
+	timetable := func() []int {
+		resp := api.send(
+			token, http.MethodGet, serviceEndpoint+"/timetable",
+			schedder.TimetableRequest{Date: starting},
+		)
+		var response schedder.TimetableResponse
+		err := json.NewDecoder(resp.Body).Decode(&response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect(t, "", response.Error)
+		minutes := make([]int, 0, len(response.Times))
+		for _, slot := range response.Times {
+			minutes = append(minutes, slot.Hour()*60+slot.Minute())
+		}
+		return minutes
+	}
+
+	// the buffer before needs the first slot, and the service with the buffer
+	// after must end by 13:00
+	times := timetable()
+	expect(t, 8, len(times))
+	expect(t, 10*60+15, times[0])
+	expect(t, 12*60, times[7])
+
+	resp = api.send(
+		token, http.MethodPost, serviceEndpoint+"/schedule",
+		schedder.CreateAppointmentRequest{Starting: starting.Add(15 * time.Minute)},
+	)
+	expect(t, http.StatusCreated, resp.StatusCode)
+
+	// the appointment takes 10:00 to 11:15 with its buffers, the next one
+	// needs its buffer before to start after that
+	times = timetable()
+	expect(t, 3, len(times))
+	expect(t, 11*60+30, times[0])
+	expect(t, 11*60+45, times[1])
+	expect(t, 12*60, times[2])
+}
@@ -0,0 +1,488 @@
+package schedder
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"gitlab.com/vlad.anghel/schedder-api/database"
+)
+
+var (
+	// errLocationRequired is returned when a service is offered only at some
+	// locations and none was given.
+	errLocationRequired = errors.New("location required")
+	// errInvalidLocation is returned when a service isn't offered at the
+	// given location.
+	errInvalidLocation = errors.New("invalid location")
+	// errTenantNotBookable is returned when the tenant isn't published.
+	errTenantNotBookable = errors.New("tenant not bookable")
+	// errInvalidService is returned when the service doesn't exist, is
+	// archived or has no personnel.
+	errInvalidService = errors.New("invalid service")
+	// errInvalidPersonnel is returned when the requested personnel doesn't do
+	// the service.
+	errInvalidPersonnel = errors.New("invalid personnel")
+)
+
+// isBookingError reports whether the error returned by availabilityFor is
+// caused by the request, so it can be shown to the client.
+func isBookingError(err error) bool {
+	return errors.Is(err, errLocationRequired) ||
+		errors.Is(err, errInvalidLocation) ||
+		errors.Is(err, errTenantNotBookable) ||
+		errors.Is(err, errInvalidService) ||
+		errors.Is(err, errInvalidPersonnel) ||
+		errors.Is(err, errVariantRequired) ||
+		errors.Is(err, errInvalidVariant) ||
+		errors.Is(err, errInvalidAddOn)
+}
+
+// weeklyHours represents the opening hours for a weekday, only the time of day
+// of opening and closing is used.
+type weeklyHours struct {
+	weekday time.Weekday
+	opening time.Time
+	closing time.Time
+}
+
+// openingHours represents the weekly opening hours in a time zone. A missing
+// weekday means closed, but no hours at all means that there is no
+// restriction.
+type openingHours struct {
+	timezone *time.Location
+	hours    []weeklyHours
+}
+
+// allows reports whether an appointment between starting and ending fits in
+// the opening hours. A nil *openingHours allows everything.
+func (o *openingHours) allows(starting, ending time.Time) bool {
+	if o == nil || len(o.hours) == 0 {
+		return true
+	}
+
+	local := starting.In(o.timezone)
+	year, month, day := local.Date()
+	for _, h := range o.hours {
+		if h.weekday != local.Weekday() {
+			continue
+		}
+		opening := time.Date(
+			year, month, day,
+			h.opening.Hour(), h.opening.Minute(), 0, 0, o.timezone,
+		)
+		closing := time.Date(
+			year, month, day,
+			h.closing.Hour(), h.closing.Minute(), 0, 0, o.timezone,
+		)
+		return !starting.Before(opening) && !ending.After(closing)
+	}
+	return false
+}
+
+// interval represents a closed interval of time, like a closure.
+type interval struct {
+	starting time.Time
+	ending   time.Time
+}
+
+// availability restricts the slots of a timetable to the times when the
+// tenant and the location are open. A nil *availability allows everything.
+type availability struct {
+	tenant   *openingHours
+	location *openingHours
+	// closures represents the closures and the public holidays.
+	closures []interval
+}
+
+// allows reports whether an appointment between starting and ending can take
+// place.
+func (av *availability) allows(starting, ending time.Time) bool {
+	if av == nil {
+		return true
+	}
+	if !av.tenant.allows(starting, ending) ||
+		!av.location.allows(starting, ending) {
+		return false
+	}
+	for _, c := range av.closures {
+		if starting.Before(c.ending) && ending.After(c.starting) {
+			return false
+		}
+	}
+	return true
+}
+
+// availabilityFor checks that the service can be booked at the location and
+// returns the availability around the given date. The zero locationID means
+// that no location was requested, which is allowed only for services that
+// aren't tied to any location.
+func availabilityFor(
+	ctx context.Context, queries *database.Queries,
+	tenantID, serviceID, personnelID, locationID uuid.UUID,
+	date time.Time,
+) (*availability, error) {
+	status, err := queries.GetTenantStatus(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if status.Status != database.TenantStatusPublished {
+		return nil, errTenantNotBookable
+	}
+
+	av := new(availability)
+
+	if locationID == uuid.Nil {
+		hasLocations, err := queries.ServiceHasLocations(ctx, serviceID)
+		if err != nil {
+			return nil, err
+		}
+		if hasLocations {
+			return nil, errLocationRequired
+		}
+	} else {
+		av.location, err = locationHoursFor(
+			ctx, queries, tenantID, serviceID, personnelID, locationID,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	calendar, err := queries.GetTenantCalendar(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	av.tenant = new(openingHours)
+	av.tenant.timezone, err = time.LoadLocation(calendar.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	tenantHours, err := queries.GetTenantHours(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range tenantHours {
+		av.tenant.hours = append(av.tenant.hours, weeklyHours{
+			weekday: h.Weekday,
+			opening: h.OpeningTime,
+			closing: h.ClosingTime,
+		})
+	}
+
+	// A day before and after is enough for any time zone and appointment.
+	since := date.AddDate(0, 0, -1)
+	until := date.AddDate(0, 0, 2)
+
+	gcbp := database.GetClosuresBetweenParams{
+		TenantID:   tenantID,
+		LocationID: uuid.NullUUID{UUID: locationID, Valid: locationID != uuid.Nil},
+		Until:      until,
+		Since:      since,
+	}
+	closures, err := queries.GetClosuresBetween(ctx, gcbp)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range closures {
+		av.closures = append(av.closures, interval{c.Starting, c.Ending})
+	}
+
+	if calendar.HolidayCalendar.Valid {
+		tz := av.tenant.timezone
+		for year := since.Year(); year <= until.Year(); year++ {
+			for _, h := range publicHolidays(calendar.HolidayCalendar.String, year) {
+				starting := time.Date(h.Year(), h.Month(), h.Day(), 0, 0, 0, 0, tz)
+				av.closures = append(av.closures, interval{
+					starting: starting,
+					ending:   starting.AddDate(0, 0, 1),
+				})
+			}
+		}
+	}
+
+	return av, nil
+}
+
+// locationHoursFor checks that the service is offered at the location and
+// returns its opening hours.
+func locationHoursFor(
+	ctx context.Context, queries *database.Queries,
+	tenantID, serviceID, personnelID, locationID uuid.UUID,
+) (*openingHours, error) {
+	isofp := database.IsServiceOfferedAtLocationParams{
+		TenantID:    tenantID,
+		ServiceID:   serviceID,
+		LocationID:  locationID,
+		PersonnelID: personnelID,
+	}
+	offered, err := queries.IsServiceOfferedAtLocation(ctx, isofp)
+	if err != nil {
+		return nil, err
+	}
+	if !offered {
+		return nil, errInvalidLocation
+	}
+
+	glp := database.GetLocationParams{
+		TenantID:   tenantID,
+		LocationID: locationID,
+	}
+	location, err := queries.GetLocation(ctx, glp)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, errInvalidLocation
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	hours := new(openingHours)
+	hours.timezone, err = time.LoadLocation(location.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := queries.GetOpeningHours(ctx, locationID)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range rows {
+		hours.hours = append(hours.hours, weeklyHours{
+			weekday: h.Weekday,
+			opening: h.OpeningTime,
+			closing: h.ClosingTime,
+		})
+	}
+
+	return hours, nil
+}
+
+// personnelSlots represents the free starting times of a member for a
+// service.
+type personnelSlots struct {
+	personnelID uuid.UUID
+	duration    time.Duration
+	// price represents the price of the service done by the member, before
+	// the pricing rules.
+	price int64
+	// times represents the starting times as local times of day on the first
+	// of January 2000, like in GetTimetableForDate.
+	times []time.Time
+	// date represents the day of the times, in the time zone of the tenant.
+	date time.Time
+}
+
+// freeAt reports whether the member is free for an appointment starting at the
+// time.
+func (ps *personnelSlots) freeAt(starting time.Time) bool {
+	for _, slot := range ps.times {
+		if slotOn(slot, ps.date).Equal(starting) {
+			return true
+		}
+	}
+	return false
+}
+
+// slotOn returns the time of day of the slot on the date, in the time zone of
+// the date.
+func slotOn(slot time.Time, date time.Time) time.Time {
+	year, month, day := date.Date()
+	return time.Date(
+		year, month, day,
+		slot.Hour(), slot.Minute(), slot.Second(), 0, date.Location(),
+	)
+}
+
+// slotsFor returns the number of slots of the granularity needed by the
+// duration, a started slot counts as a whole one.
+func slotsFor(duration, granularity time.Duration) int {
+	return int((duration + granularity - 1) / granularity)
+}
+
+// freeSlots returns the free starting times on the date for every member
+// doing the service, or only for personnelID if it isn't the zero UUID. The
+// day is the one of the date in the time zone of the tenant. The members who
+// don't work at the location are left out. The extra duration of the chosen
+// options is added to the one of every member. The buffers of the service
+// must be free too, within the working hours.
+func freeSlots(
+	ctx context.Context, queries *database.Queries,
+	tenantID, serviceID, personnelID, locationID uuid.UUID,
+	date time.Time, extra time.Duration,
+) ([]personnelSlots, error) {
+	gspp := database.GetServicePersonnelParams{
+		TenantID:  tenantID,
+		ServiceID: serviceID,
+	}
+	candidates, err := queries.GetServicePersonnel(ctx, gspp)
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		return nil, errInvalidService
+	}
+
+	tz, err := tenantTimezone(ctx, queries, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	date = date.In(tz)
+
+	var slots []personnelSlots
+	elsewhere := false
+	for _, candidate := range candidates {
+		if personnelID != uuid.Nil && candidate.AccountID != personnelID {
+			continue
+		}
+
+		filter, err := availabilityFor(
+			ctx, queries,
+			tenantID, serviceID, candidate.AccountID, locationID, date,
+		)
+		if errors.Is(err, errInvalidLocation) {
+			elsewhere = true
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		ps := personnelSlots{
+			personnelID: candidate.AccountID,
+			price:       candidate.Price,
+			date:        date,
+		}
+		err = candidate.Duration.AssignTo(&ps.duration)
+		if err != nil {
+			return nil, err
+		}
+		ps.duration += extra
+		var bufferBefore, bufferAfter, granularity time.Duration
+		err = candidate.BufferBefore.AssignTo(&bufferBefore)
+		if err != nil {
+			return nil, err
+		}
+		err = candidate.BufferAfter.AssignTo(&bufferAfter)
+		if err != nil {
+			return nil, err
+		}
+		err = candidate.SlotGranularity.AssignTo(&granularity)
+		if err != nil {
+			return nil, err
+		}
+
+		gtfdp := database.GetTimetableForDateParams{
+			TenantID:    tenantID,
+			DesiredDate: dateOf(date, tz),
+			Granularity: candidate.SlotGranularity,
+			Weekday:     date.Weekday(),
+			PersonnelID: candidate.AccountID,
+			LocationID: uuid.NullUUID{
+				UUID:  locationID,
+				Valid: locationID != uuid.Nil,
+			},
+		}
+		rows, err := queries.GetTimetableForDate(ctx, gtfdp)
+		if err != nil {
+			return nil, err
+		}
+
+		// an appointment needs before+after consecutive free entries,
+		// starting after the ones of the buffer before, the breaks between
+		// the working intervals aren't free
+		before := slotsFor(bufferBefore, granularity)
+		after := slotsFor(ps.duration+bufferAfter, granularity)
+		count := 0
+		for i, row := range rows {
+			if row.IsBlocked {
+				count = 0
+				continue
+			}
+			if i > 0 && !rows[i-1].Times.Add(granularity).Equal(row.Times) {
+				count = 0
+			}
+			count++
+			if count < before+after {
+				continue
+			}
+			first := rows[i-after+1].Times
+			starting := slotOn(first, date)
+			if filter.allows(starting, starting.Add(ps.duration)) {
+				ps.times = append(ps.times, first)
+			}
+		}
+		slots = append(slots, ps)
+	}
+
+	if len(slots) == 0 {
+		if elsewhere {
+			return nil, errInvalidLocation
+		}
+		return nil, errInvalidPersonnel
+	}
+	return slots, nil
+}
+
+// SlotGranularityRequest represents the slot granularity of a tenant.
+type SlotGranularityRequest struct {
+	// SlotGranularity represents the length of the slots of the timetables,
+	// it must be whole minutes, at least 5, and divide an hour.
+	SlotGranularity time.Duration `json:"slot_granularity"`
+}
+
+// SlotGranularityResponse represents the slot granularity of a tenant.
+type SlotGranularityResponse struct {
+	Response
+	SlotGranularity time.Duration `json:"slot_granularity"`
+}
+
+// SlotGranularity returns the length of the slots of the timetables of the
+// tenant.
+func (a *API) SlotGranularity(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+
+	granularity, err := a.db.GetSlotGranularity(ctx, tenantID)
+	if err != nil {
+		JsonError(w, http.StatusNotFound, "invalid tenant")
+		return
+	}
+
+	var response SlotGranularityResponse
+	err = granularity.AssignTo(&response.SlotGranularity)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	JsonResp(w, http.StatusOK, response)
+}
+
+// SetSlotGranularity changes the length of the slots of the timetables of the
+// tenant. The booked appointments are kept, the slots they overlap are
+// blocked.
+func (a *API) SetSlotGranularity(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	request := ctx.Value(CtxJSON).(*SlotGranularityRequest)
+
+	granularity := request.SlotGranularity
+	if granularity < 5*time.Minute || granularity%time.Minute != 0 ||
+		time.Hour%granularity != 0 {
+		JsonError(w, http.StatusBadRequest, "invalid slot granularity")
+		return
+	}
+
+	ssgp := database.SetSlotGranularityParams{TenantID: tenantID}
+	ssgp.SlotGranularity.Set(granularity)
+	err := a.db.SetSlotGranularity(ctx, ssgp)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
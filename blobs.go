@@ -0,0 +1,120 @@
+package schedder
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"gitlab.com/vlad.anghel/schedder-api/database"
+)
+
+// blobDeletionInterval represents how often the outbox of blob deletions is
+// processed, to retry the deletions that failed right after the commit.
+const blobDeletionInterval = time.Minute
+
+// storePhotoBlob writes the variants of the photo to the storage if the photo
+// that was just inserted by the transaction is the first reference to its
+// blob. It must be called before the transaction commits: the blob row stays
+// locked until then, so a concurrent deletion of the same blob waits for it.
+// If the transaction doesn't commit, the written files are left for the
+// consistency checker.
+func (a *API) storePhotoBlob(
+	ctx context.Context, queries *database.Queries, photo *uploadedPhoto,
+) error {
+	refcount, err := queries.GetBlobRefcount(ctx, photo.checksum[:])
+	if err != nil {
+		return err
+	}
+	if refcount != 1 {
+		return nil
+	}
+
+	return a.writePhoto(ctx, photo)
+}
+
+// deleteBlob removes the files of the blob if it isn't referenced anymore,
+// then removes it from the outbox. The blob row is locked meanwhile, so a
+// concurrent upload of the same content waits and then writes the files
+// again.
+func (a *API) deleteBlob(ctx context.Context, hash []byte) error {
+	tx, err := a.txlike.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	queries := database.New(tx)
+
+	refcount, err := queries.LockBlob(ctx, hash)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+	if err == nil && refcount == 0 {
+		err = a.removePhoto(ctx, hash)
+		if err != nil {
+			return err
+		}
+
+		err = queries.DeleteBlob(ctx, hash)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = queries.DeleteBlobDeletion(ctx, hash)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// processBlobDeletions deletes the blobs waiting in the outbox.
+func (a *API) processBlobDeletions(ctx context.Context) error {
+	hashes, err := a.db.GetBlobDeletions(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, hash := range hashes {
+		err = a.deleteBlob(ctx, hash)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deleteUnreferencedBlobs tries to delete the blobs right after the
+// transaction that dropped their last reference commits. The failed deletions
+// stay in the outbox, for processBlobDeletions.
+func (a *API) deleteUnreferencedBlobs(ctx context.Context, hashes ...[]byte) {
+	for _, hash := range hashes {
+		err := a.deleteBlob(ctx, hash)
+		if err != nil {
+			log.Printf("WARN: couldn't delete blob %x: %v", hash, err)
+		}
+	}
+}
+
+// ProcessBlobDeletions processes the outbox of blob deletions periodically,
+// until the context is canceled.
+func (a *API) ProcessBlobDeletions(ctx context.Context) {
+	ticker := time.NewTicker(blobDeletionInterval)
+	defer ticker.Stop()
+
+	for {
+		err := a.processBlobDeletions(ctx)
+		if err != nil {
+			log.Printf("WARN: couldn't process blob deletions: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,320 @@
+package schedder
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"gitlab.com/vlad.anghel/schedder-api/database"
+	"golang.org/x/text/language"
+)
+
+// validLanguage reports whether the language is an ISO 639-1 code, like "ro".
+func validLanguage(lang string) bool {
+	if len(lang) != 2 {
+		return false
+	}
+	for _, c := range lang {
+		if c < 'a' || c > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// requestLanguage returns the one of the available languages that best
+// matches the lang query parameter or else the Accept-Language header, or ""
+// if none matches.
+func requestLanguage(r *http.Request, available []string) string {
+	var tags []language.Tag
+	if lang := r.URL.Query().Get("lang"); lang != "" {
+		tag, err := language.Parse(lang)
+		if err != nil {
+			return ""
+		}
+		tags = append(tags, tag)
+	} else {
+		var err error
+		tags, _, err = language.ParseAcceptLanguage(r.Header.Get("Accept-Language"))
+		if err != nil {
+			return ""
+		}
+	}
+
+	supported := make([]language.Tag, 0, len(available))
+	for _, lang := range available {
+		tag, err := language.Parse(lang)
+		if err != nil {
+			continue
+		}
+		supported = append(supported, tag)
+	}
+	if len(tags) == 0 || len(supported) == 0 {
+		return ""
+	}
+
+	_, index, confidence := language.NewMatcher(supported).Match(tags...)
+	if confidence == language.No {
+		return ""
+	}
+	base, _ := supported[index].Base()
+	return base.String()
+}
+
+// CreateCategoryRequest represents a new category of the catalogue of a
+// tenant.
+type CreateCategoryRequest struct {
+	// CategoryName represents the name of the category, like "Hair".
+	CategoryName string `json:"category_name"`
+	// Position represents the display order, the categories are shown by
+	// position and then by name.
+	Position int `json:"position"`
+}
+
+// CreateCategoryResponse represents the response of the category creation
+// endpoint.
+type CreateCategoryResponse struct {
+	Response
+	CategoryID uuid.UUID `json:"category_id"`
+}
+
+// UpdateCategoryRequest represents the new name and position of a category,
+// all the fields are replaced.
+type UpdateCategoryRequest struct {
+	CategoryName string `json:"category_name"`
+	Position     int    `json:"position"`
+}
+
+// CategoryTranslationRequest represents the name of a category in a language.
+type CategoryTranslationRequest struct {
+	CategoryName string `json:"category_name"`
+}
+
+// ServiceTranslationRequest represents the name and the description of a
+// service in a language.
+type ServiceTranslationRequest struct {
+	ServiceName string `json:"service_name"`
+	Description string `json:"description"`
+}
+
+// CreateServiceCategory adds a category to the catalogue of the tenant.
+func (a *API) CreateServiceCategory(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	request := ctx.Value(CtxJSON).(*CreateCategoryRequest)
+
+	if request.CategoryName == "" {
+		JsonError(w, http.StatusBadRequest, "invalid category name")
+		return
+	}
+
+	cscp := database.CreateServiceCategoryParams{
+		TenantID:     tenantID,
+		CategoryName: request.CategoryName,
+		Position:     int32(request.Position),
+	}
+	categoryID, err := a.db.CreateServiceCategory(ctx, cscp)
+	if err != nil {
+		JsonError(w, http.StatusBadRequest, "couldn't create category")
+		return
+	}
+
+	response := CreateCategoryResponse{CategoryID: categoryID}
+	JsonResp(w, http.StatusCreated, response)
+}
+
+// UpdateServiceCategory changes the name and the position of a category.
+func (a *API) UpdateServiceCategory(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	categoryID := ctx.Value(CtxCategoryID).(uuid.UUID)
+	request := ctx.Value(CtxJSON).(*UpdateCategoryRequest)
+
+	if request.CategoryName == "" {
+		JsonError(w, http.StatusBadRequest, "invalid category name")
+		return
+	}
+
+	uscp := database.UpdateServiceCategoryParams{
+		CategoryName: request.CategoryName,
+		Position:     int32(request.Position),
+		TenantID:     tenantID,
+		CategoryID:   categoryID,
+	}
+	affected, err := a.db.UpdateServiceCategory(ctx, uscp)
+	if err != nil {
+		JsonError(w, http.StatusBadRequest, "couldn't update category")
+		return
+	}
+	if affected == 0 {
+		JsonError(w, http.StatusNotFound, "invalid category")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// DeleteServiceCategory deletes a category, its services are left without
+// one.
+func (a *API) DeleteServiceCategory(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	categoryID := ctx.Value(CtxCategoryID).(uuid.UUID)
+
+	tx, err := a.txlike.Begin(ctx)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	defer tx.Rollback(ctx)
+	queries := database.New(tx)
+
+	cscp := database.ClearServiceCategoryParams{
+		TenantID:   tenantID,
+		CategoryID: uuid.NullUUID{UUID: categoryID, Valid: true},
+	}
+	err = queries.ClearServiceCategory(ctx, cscp)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	dscp := database.DeleteServiceCategoryParams{
+		TenantID:   tenantID,
+		CategoryID: categoryID,
+	}
+	affected, err := queries.DeleteServiceCategory(ctx, dscp)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	if affected == 0 {
+		JsonError(w, http.StatusNotFound, "invalid category")
+		return
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// SetCategoryTranslation adds or replaces the name of a category in a
+// language.
+func (a *API) SetCategoryTranslation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	categoryID := ctx.Value(CtxCategoryID).(uuid.UUID)
+	lang := ctx.Value(CtxLanguage).(string)
+	request := ctx.Value(CtxJSON).(*CategoryTranslationRequest)
+
+	if request.CategoryName == "" {
+		JsonError(w, http.StatusBadRequest, "invalid category name")
+		return
+	}
+
+	sctp := database.SetCategoryTranslationParams{
+		Language:     lang,
+		CategoryName: request.CategoryName,
+		TenantID:     tenantID,
+		CategoryID:   categoryID,
+	}
+	affected, err := a.db.SetCategoryTranslation(ctx, sctp)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	if affected == 0 {
+		JsonError(w, http.StatusNotFound, "invalid category")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// DeleteCategoryTranslation deletes the name of a category in a language.
+func (a *API) DeleteCategoryTranslation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	categoryID := ctx.Value(CtxCategoryID).(uuid.UUID)
+	lang := ctx.Value(CtxLanguage).(string)
+
+	dctp := database.DeleteCategoryTranslationParams{
+		TenantID:   tenantID,
+		CategoryID: categoryID,
+		Language:   lang,
+	}
+	affected, err := a.db.DeleteCategoryTranslation(ctx, dctp)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	if affected == 0 {
+		JsonError(w, http.StatusNotFound, "invalid translation")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// SetServiceTranslation adds or replaces the name and the description of a
+// service in a language.
+func (a *API) SetServiceTranslation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	serviceID := ctx.Value(CtxServiceID).(uuid.UUID)
+	lang := ctx.Value(CtxLanguage).(string)
+	request := ctx.Value(CtxJSON).(*ServiceTranslationRequest)
+
+	if request.ServiceName == "" {
+		JsonError(w, http.StatusBadRequest, "invalid service name")
+		return
+	}
+
+	sstp := database.SetServiceTranslationParams{
+		Language:    lang,
+		ServiceName: request.ServiceName,
+		Description: request.Description,
+		TenantID:    tenantID,
+		ServiceID:   serviceID,
+	}
+	affected, err := a.db.SetServiceTranslation(ctx, sstp)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	if affected == 0 {
+		JsonError(w, http.StatusNotFound, "invalid service")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// DeleteServiceTranslation deletes the name and the description of a service
+// in a language.
+func (a *API) DeleteServiceTranslation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	serviceID := ctx.Value(CtxServiceID).(uuid.UUID)
+	lang := ctx.Value(CtxLanguage).(string)
+
+	dstp := database.DeleteServiceTranslationParams{
+		TenantID:  tenantID,
+		ServiceID: serviceID,
+		Language:  lang,
+	}
+	affected, err := a.db.DeleteServiceTranslation(ctx, dstp)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	if affected == 0 {
+		JsonError(w, http.StatusNotFound, "invalid translation")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
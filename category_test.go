@@ -0,0 +1,140 @@
+package schedder_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gitlab.com/vlad.anghel/schedder-api"
+)
+
+func TestServiceCategories(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantName := "Zâna Măseluță"
+
+	tenantID := api.createTenantAndAccount(email, password, tenantName)
+	accountID := api.findAccountByEmail(email)
+	token := api.generateToken(email, password)
+	api.publishTenant(tenantID)
+	haircut := api.createService(token, tenantID, accountID, "Tuns", 5000, time.Hour)
+	api.createService(token, tenantID, accountID, "Consultanță", 0, 30*time.Minute)
+
+	tenantEndpoint := fmt.Sprintf("/tenants/%s", tenantID)
+	createCategory := func(name string, position int) uuid.UUID {
+		resp := api.send(
+			token, http.MethodPost, tenantEndpoint+"/categories",
+			schedder.CreateCategoryRequest{CategoryName: name, Position: position},
+		)
+		var response schedder.CreateCategoryResponse
+		err := json.NewDecoder(resp.Body).Decode(&response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect(t, "", response.Error)
+		expect(t, http.StatusCreated, resp.StatusCode)
+		return response.CategoryID
+	}
+	hair := createCategory("Păr", 1)
+	nails := createCategory("Unghii", 0)
+
+	// the names are unique for the tenant
+	resp := api.send(
+		token, http.MethodPost, tenantEndpoint+"/categories",
+		schedder.CreateCategoryRequest{CategoryName: "Păr"},
+	)
+	expect(t, http.StatusBadRequest, resp.StatusCode)
+
+	serviceEndpoint := fmt.Sprintf("%s/services/%s", tenantEndpoint, haircut)
+	resp = api.send(token, http.MethodPut, serviceEndpoint, schedder.UpdateServiceRequest{
+		ServiceName: "Tuns",
+		Price:       schedder.Money{Amount: 5000},
+		Duration:    time.Hour,
+		Description: "Spălat, tuns și coafat",
+		CategoryID:  hair,
+	})
+	expect(t, http.StatusOK, resp.StatusCode)
+
+	// a category of another tenant can't be used
+	resp = api.send(token, http.MethodPut, serviceEndpoint, schedder.UpdateServiceRequest{
+		ServiceName: "Tuns",
+		Price:       schedder.Money{Amount: 5000},
+		Duration:    time.Hour,
+		CategoryID:  uuid.New(),
+	})
+	expect(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = api.send(
+		token, http.MethodPut, serviceEndpoint+"/translations/en",
+		schedder.ServiceTranslationRequest{
+			ServiceName: "Haircut",
+			Description: "Wash, cut and style",
+		},
+	)
+	expect(t, http.StatusOK, resp.StatusCode)
+	resp = api.send(
+		token, http.MethodPut, serviceEndpoint+"/translations/english",
+		schedder.ServiceTranslationRequest{ServiceName: "Haircut"},
+	)
+	expect(t, http.StatusNotFound, resp.StatusCode)
+	resp = api.send(
+		token, http.MethodPut, fmt.Sprintf("%s/categories/%s/translations/en", tenantEndpoint, hair),
+		schedder.CategoryTranslationRequest{CategoryName: "Hair"},
+	)
+	expect(t, http.StatusOK, resp.StatusCode)
+
+	catalogue := func(query string) schedder.ServicesForTenantResponse {
+		r := httptest.NewRequest(http.MethodGet, tenantEndpoint+"/services"+query, nil)
+		r.Header.Add("Accept-Language", "en-GB,en;q=0.9,ro;q=0.8")
+		resp := api.serve(token, r)
+		var response schedder.ServicesForTenantResponse
+		err := json.NewDecoder(resp.Body).Decode(&response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect(t, "", response.Error)
+		return response
+	}
+
+	// the categories are ordered by position, the services without one last
+	response := catalogue("")
+	expect(t, "en", response.Language)
+	expect(t, 3, len(response.Categories))
+	expect(t, nails, response.Categories[0].CategoryID)
+	expect(t, 0, len(response.Categories[0].Services))
+	expect(t, hair, response.Categories[1].CategoryID)
+	expect(t, "Hair", response.Categories[1].CategoryName)
+	expect(t, 1, len(response.Categories[1].Services))
+	expect(t, "Haircut", response.Categories[1].Services[0].ServiceName)
+	expect(t, "Wash, cut and style", response.Categories[1].Services[0].Description)
+	expect(t, uuid.Nil, response.Categories[2].CategoryID)
+	expect(t, "Consultanță", response.Categories[2].Services[0].ServiceName)
+
+	// the query parameter is preferred to the header, the catalogue isn't
+	// translated to Romanian so the names aren't either
+	response = catalogue("?lang=ro")
+	expect(t, "", response.Language)
+	expect(t, "Păr", response.Categories[1].CategoryName)
+	expect(t, "Tuns", response.Categories[1].Services[0].ServiceName)
+	expect(t, "Spălat, tuns și coafat", response.Categories[1].Services[0].Description)
+
+	// the regional variants match the translation of the language
+	response = catalogue("?lang=en-US")
+	expect(t, "en", response.Language)
+	expect(t, "Haircut", response.Categories[1].Services[0].ServiceName)
+
+	// deleting a category keeps its services
+	resp = api.send(token, http.MethodDelete, fmt.Sprintf("%s/categories/%s", tenantEndpoint, hair), nil)
+	expect(t, http.StatusOK, resp.StatusCode)
+	response = catalogue("")
+	expect(t, 2, len(response.Categories))
+	expect(t, 2, len(response.Categories[1].Services))
+}
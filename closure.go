@@ -0,0 +1,389 @@
+package schedder
+
+import (
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"gitlab.com/vlad.anghel/schedder-api/database"
+)
+
+// maxICSSize represents the maximum size of an imported iCalendar file.
+const maxICSSize = 1 << 20
+
+// SetTenantHoursRequest represents a request to replace the weekly opening
+// hours of a tenant.
+type SetTenantHoursRequest struct {
+	// Timezone represents the IANA time zone of the opening hours and of the
+	// public holidays, like "Europe/Bucharest".
+	Timezone string `json:"timezone"`
+	// Hours represents the opening hours, at most one entry per weekday. A
+	// missing weekday means that the tenant is closed on that day. No hours
+	// at all means that only the schedules of the personnel are used.
+	Hours []openingHoursEntry `json:"hours"`
+}
+
+// TenantHoursResponse represents the response of the tenant opening hours
+// endpoint.
+type TenantHoursResponse struct {
+	Response
+	// Timezone represents the IANA time zone of the opening hours.
+	Timezone string `json:"timezone"`
+	// HolidayCalendar represents the observed public holiday calendar.
+	HolidayCalendar string `json:"holiday_calendar,omitempty"`
+	// Hours represents the weekly opening hours.
+	Hours []openingHoursEntry `json:"hours"`
+	// Holidays represents the public holidays in the following year.
+	Holidays []time.Time `json:"holidays"`
+}
+
+// SetHolidayCalendarRequest represents a request to opt into a public holiday
+// calendar, the tenant is closed on the public holidays.
+type SetHolidayCalendarRequest struct {
+	// Calendar represents the public holiday calendar, either "RO" or empty
+	// to opt out.
+	Calendar string `json:"calendar"`
+}
+
+// CreateClosureRequest represents a request to close a tenant or one of its
+// locations for an interval.
+type CreateClosureRequest struct {
+	// Starting represents when the closure starts.
+	Starting time.Time `json:"starting"`
+	// Ending represents when the closure ends.
+	Ending time.Time `json:"ending"`
+	// Reason represents why the tenant is closed, like "renovation".
+	Reason string `json:"reason"`
+	// LocationID represents the closed location. If it's missing then the
+	// whole tenant is closed.
+	LocationID uuid.UUID `json:"location_id,omitempty"`
+}
+
+// CreateClosureResponse represents the response of the closure creation
+// endpoint.
+type CreateClosureResponse struct {
+	Response
+	// ClosureID represents the ID of the newly created closure.
+	ClosureID uuid.UUID `json:"closure_id"`
+}
+
+// closureResponse represents a closure.
+type closureResponse struct {
+	// ClosureID represents the ID of the closure.
+	ClosureID uuid.UUID `json:"closure_id"`
+	// LocationID represents the closed location, missing for the whole
+	// tenant.
+	LocationID uuid.UUID `json:"location_id,omitempty"`
+	// Starting represents when the closure starts.
+	Starting time.Time `json:"starting"`
+	// Ending represents when the closure ends.
+	Ending time.Time `json:"ending"`
+	// Reason represents why the tenant is closed.
+	Reason string `json:"reason"`
+	// Source represents where the closure comes from, either "manual" or
+	// "ics".
+	Source string `json:"source"`
+}
+
+// ClosuresResponse represents the response of the closure listing endpoint.
+type ClosuresResponse struct {
+	Response
+	// Closures represents the current and future closures.
+	Closures []closureResponse `json:"closures"`
+}
+
+// ImportClosuresResponse represents the response of the iCalendar import
+// endpoint.
+type ImportClosuresResponse struct {
+	Response
+	// Imported represents the number of imported closures.
+	Imported int `json:"imported"`
+}
+
+// SetTenantHours replaces the weekly opening hours of the tenant.
+func (a *API) SetTenantHours(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	request := ctx.Value(CtxJSON).(*SetTenantHoursRequest)
+
+	if _, err := time.LoadLocation(request.Timezone); request.Timezone == "" ||
+		err != nil {
+		JsonError(w, http.StatusBadRequest, "invalid timezone")
+		return
+	}
+	if msg := validHours(request.Hours); msg != "" {
+		JsonError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	tx, err := a.txlike.Begin(ctx)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	defer tx.Rollback(ctx)
+	queries := database.New(tx)
+
+	sttp := database.SetTenantTimezoneParams{
+		Timezone: request.Timezone,
+		TenantID: tenantID,
+	}
+	err = queries.SetTenantTimezone(ctx, sttp)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	err = queries.ClearTenantHours(ctx, tenantID)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	for _, entry := range request.Hours {
+		athp := database.AddTenantHoursParams{
+			TenantID:    tenantID,
+			Weekday:     entry.Weekday,
+			OpeningTime: entry.Opening,
+			ClosingTime: entry.Closing,
+		}
+		err = queries.AddTenantHours(ctx, athp)
+		if err != nil {
+			JsonError(w, http.StatusBadRequest, "invalid hours")
+			return
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "couldn't set hours")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// TenantHours returns the opening hours of the tenant, along with the public
+// holidays in the following year.
+func (a *API) TenantHours(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+
+	calendar, err := a.db.GetTenantCalendar(ctx, tenantID)
+	if err != nil {
+		JsonError(w, http.StatusNotFound, "invalid tenant")
+		return
+	}
+
+	hours, err := a.db.GetTenantHours(ctx, tenantID)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "couldn't get hours")
+		return
+	}
+
+	var response TenantHoursResponse
+	response.Timezone = calendar.Timezone
+	response.Hours = make([]openingHoursEntry, 0, len(hours))
+	for _, h := range hours {
+		response.Hours = append(response.Hours, openingHoursEntry{
+			Weekday: h.Weekday,
+			Opening: h.OpeningTime,
+			Closing: h.ClosingTime,
+		})
+	}
+
+	response.Holidays = make([]time.Time, 0)
+	if calendar.HolidayCalendar.Valid {
+		response.HolidayCalendar = calendar.HolidayCalendar.String
+		today := time.Now().UTC().Truncate(24 * time.Hour)
+		nextYear := today.AddDate(1, 0, 0)
+		for year := today.Year(); year <= nextYear.Year(); year++ {
+			for _, h := range publicHolidays(response.HolidayCalendar, year) {
+				if !h.Before(today) && h.Before(nextYear) {
+					response.Holidays = append(response.Holidays, h)
+				}
+			}
+		}
+	}
+
+	JsonResp(w, http.StatusOK, response)
+}
+
+// SetHolidayCalendar opts the tenant into, or out of, a public holiday
+// calendar.
+func (a *API) SetHolidayCalendar(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	request := ctx.Value(CtxJSON).(*SetHolidayCalendarRequest)
+
+	if request.Calendar != "" && request.Calendar != HolidayCalendarRO {
+		JsonError(w, http.StatusBadRequest, "invalid calendar")
+		return
+	}
+
+	shcp := database.SetHolidayCalendarParams{
+		TenantID: tenantID,
+	}
+	shcp.HolidayCalendar.String = request.Calendar
+	shcp.HolidayCalendar.Valid = request.Calendar != ""
+
+	err := a.db.SetHolidayCalendar(ctx, shcp)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "couldn't set calendar")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// CreateClosure closes the tenant, or one of its locations, for an interval.
+func (a *API) CreateClosure(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	request := ctx.Value(CtxJSON).(*CreateClosureRequest)
+
+	if !request.Starting.Before(request.Ending) {
+		JsonError(w, http.StatusBadRequest, "invalid interval")
+		return
+	}
+	if utf8.RuneCountInString(request.Reason) > 200 {
+		JsonError(w, http.StatusBadRequest, "invalid reason")
+		return
+	}
+
+	ccp := database.CreateClosureParams{
+		TenantID: tenantID,
+		LocationID: uuid.NullUUID{
+			UUID:  request.LocationID,
+			Valid: request.LocationID != uuid.Nil,
+		},
+		Starting: request.Starting,
+		Ending:   request.Ending,
+		Reason:   request.Reason,
+		Source:   database.ClosureSourceManual,
+	}
+	closureID, err := a.db.CreateClosure(ctx, ccp)
+	if err != nil {
+		JsonError(w, http.StatusBadRequest, "invalid location")
+		return
+	}
+
+	JsonResp(w, http.StatusCreated, CreateClosureResponse{ClosureID: closureID})
+}
+
+// Closures lists the current and future closures of the tenant.
+func (a *API) Closures(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+
+	rows, err := a.db.GetClosures(ctx, tenantID)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "couldn't get closures")
+		return
+	}
+
+	var response ClosuresResponse
+	response.Closures = make([]closureResponse, 0, len(rows))
+	for _, row := range rows {
+		response.Closures = append(response.Closures, closureResponse{
+			ClosureID:  row.ClosureID,
+			LocationID: row.LocationID.UUID,
+			Starting:   row.Starting,
+			Ending:     row.Ending,
+			Reason:     row.Reason,
+			Source:     string(row.Source),
+		})
+	}
+
+	JsonResp(w, http.StatusOK, response)
+}
+
+// DeleteClosure deletes a closure, reopening the tenant for that interval.
+func (a *API) DeleteClosure(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	closureID := ctx.Value(CtxClosureID).(uuid.UUID)
+
+	dcp := database.DeleteClosureParams{
+		TenantID:  tenantID,
+		ClosureID: closureID,
+	}
+	affected, err := a.db.DeleteClosure(ctx, dcp)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	if affected != 1 {
+		JsonError(w, http.StatusNotFound, "invalid closure")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// ImportClosures imports the events of an iCalendar file (text/calendar) as
+// closures of the whole tenant. The closures from a previous import are
+// replaced, so the same calendar can be imported again after it changes.
+func (a *API) ImportClosures(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+
+	calendar, err := a.db.GetTenantCalendar(ctx, tenantID)
+	if err != nil {
+		JsonError(w, http.StatusNotFound, "invalid tenant")
+		return
+	}
+	tz, err := time.LoadLocation(calendar.Timezone)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "invalid timezone")
+		return
+	}
+
+	events, err := parseICS(http.MaxBytesReader(w, r.Body, maxICSSize), tz)
+	if err != nil {
+		JsonError(w, http.StatusBadRequest, "invalid calendar")
+		return
+	}
+
+	tx, err := a.txlike.Begin(ctx)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	defer tx.Rollback(ctx)
+	queries := database.New(tx)
+
+	dcfsp := database.DeleteClosuresFromSourceParams{
+		TenantID: tenantID,
+		Source:   database.ClosureSourceIcs,
+	}
+	err = queries.DeleteClosuresFromSource(ctx, dcfsp)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	for _, event := range events {
+		ccp := database.CreateClosureParams{
+			TenantID: tenantID,
+			Starting: event.starting,
+			Ending:   event.ending,
+			Reason:   event.summary,
+			Source:   database.ClosureSourceIcs,
+		}
+		_, err = queries.CreateClosure(ctx, ccp)
+		if err != nil {
+			JsonError(w, http.StatusBadRequest, "invalid event")
+			return
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "couldn't import calendar")
+		return
+	}
+
+	JsonResp(w, http.StatusOK, ImportClosuresResponse{Imported: len(events)})
+}
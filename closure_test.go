@@ -0,0 +1,268 @@
+package schedder_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.com/vlad.anghel/schedder-api"
+)
+
+func TestTimetableDuringClosure(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantName := "Zâna Măseluță"
+
+	tenantID := api.createTenantAndAccount(email, password, tenantName)
+	api.publishTenant(tenantID)
+	accountID := api.findAccountByEmail(email)
+	token := api.generateToken(email, password)
+
+	serviceID := api.createService(
+		token, tenantID, accountID, "control", 420, time.Hour,
+	)
+	date := localDate(time.Now())
+	starting := time.Time{}.Add(10 * time.Hour)
+	ending := starting.Add(8 * time.Hour)
+	api.setSchedule(token, accountID, tenantID, starting, ending, date.Weekday())
+
+	request := schedder.CreateClosureRequest{
+		Starting: date,
+		Ending:   date.AddDate(0, 0, 1),
+		Reason:   "renovation",
+	}
+	endpoint := fmt.Sprintf("/tenants/%s/closures", tenantID)
+	r, err := NewJSONRequest(http.MethodPost, endpoint, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Add("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+
+	api.ServeHTTP(w, r)
+	expect(t, http.StatusCreated, w.Result().StatusCode)
+
+	endpoint = fmt.Sprintf(
+		"/tenants/%s/services/%s/timetable", tenantID, serviceID,
+	)
+	r, err = NewJSONRequest(
+		http.MethodGet, endpoint, schedder.TimetableRequest{Date: date},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w = httptest.NewRecorder()
+	api.ServeHTTP(w, r)
+
+	var response schedder.TimetableResponse
+	err = json.NewDecoder(w.Result().Body).Decode(&response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, "", response.Error)
+	expect(t, http.StatusOK, w.Result().StatusCode)
+	expect(t, 0, len(response.Times))
+}
+
+func TestTimetableInTenantTimezone(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantName := "Zâna Măseluță"
+
+	tenantID := api.createTenantAndAccount(email, password, tenantName)
+	api.publishTenant(tenantID)
+	accountID := api.findAccountByEmail(email)
+	token := api.generateToken(email, password)
+	serviceID := api.createService(token, tenantID, accountID, "Tuns", 5000, time.Hour)
+
+	// the national day, and the same weekday a week later
+	holiday := time.Date(time.Now().Year()+1, time.December, 1, 0, 0, 0, 0, bucharest)
+	workday := holiday.AddDate(0, 0, 7)
+
+	// the schedule and the opening hours are local times, in the default
+	// time zone of the tenant
+	api.setSchedule(
+		token, accountID, tenantID,
+		time.Time{}.Add(9*time.Hour), time.Time{}.Add(14*time.Hour), holiday.Weekday(),
+	)
+	tenantEndpoint := fmt.Sprintf("/tenants/%s", tenantID)
+	body := fmt.Sprintf(
+		`{"timezone":"Europe/Bucharest","hours":[{"weekday":%d,"opening":"%s","closing":"%s"}]}`,
+		holiday.Weekday(),
+		time.Time{}.Add(10*time.Hour).Format(time.RFC3339),
+		time.Time{}.Add(13*time.Hour).Format(time.RFC3339),
+	)
+	resp := api.serve(token, httptest.NewRequest(
+		http.MethodPut, tenantEndpoint+"/hours", strings.NewReader(body),
+	))
+	expect(t, http.StatusOK, resp.StatusCode)
+	resp = api.send(
+		token, http.MethodPut, tenantEndpoint+"/holidays",
+		schedder.SetHolidayCalendarRequest{Calendar: schedder.HolidayCalendarRO},
+	)
+	expect(t, http.StatusOK, resp.StatusCode)
+
+	serviceEndpoint := fmt.Sprintf("%s/services/%s", tenantEndpoint, serviceID)
+	timetable := func(date time.Time) []string {
+		resp := api.send(
+			token, http.MethodGet, serviceEndpoint+"/timetable",
+			schedder.TimetableRequest{Date: date},
+		)
+		var response schedder.TimetableResponse
+		err := json.NewDecoder(resp.Body).Decode(&response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect(t, "", response.Error)
+		startings := make([]string, 0, len(response.Slots))
+		for _, slot := range response.Slots {
+			startings = append(startings, slot.Starting.UTC().Format("15:04"))
+		}
+		return startings
+	}
+	book := func(starting time.Time) int {
+		resp := api.send(
+			token, http.MethodPost, serviceEndpoint+"/schedule",
+			schedder.CreateAppointmentRequest{Starting: starting},
+		)
+		return resp.StatusCode
+	}
+
+	// 10:00 to 13:00 in Bucharest is 08:00 to 11:00 UTC in December
+	expect(t, "[08:00 08:30 09:00 09:30 10:00]", fmt.Sprint(timetable(workday)))
+	expect(t, http.StatusBadRequest, book(hourOn(workday, 9)))
+	expect(t, http.StatusCreated, book(hourOn(workday, 10)))
+	expect(t, "[09:00 09:30 10:00]", fmt.Sprint(timetable(workday)))
+
+	// the whole local day of the holiday is closed
+	expect(t, "[]", fmt.Sprint(timetable(holiday)))
+	expect(t, http.StatusBadRequest, book(hourOn(holiday, 10)))
+}
+
+func TestImportClosures(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantName := "Zâna Măseluță"
+
+	tenantID := api.createTenantAndAccount(email, password, tenantName)
+	api.publishTenant(tenantID)
+	token := api.generateToken(email, password)
+
+	nextYear := time.Now().Year() + 1
+	calendar := fmt.Sprintf(
+		"BEGIN:VCALENDAR\r\n"+
+			"VERSION:2.0\r\n"+
+			"BEGIN:VEVENT\r\n"+
+			"DTSTART;VALUE=DATE:%d0815\r\n"+
+			"SUMMARY:Concediu\\, toată echipa\r\n"+
+			"END:VEVENT\r\n"+
+			"END:VCALENDAR\r\n",
+		nextYear,
+	)
+	endpoint := fmt.Sprintf("/tenants/%s/closures/ics", tenantID)
+
+	// importing twice replaces the previous import
+	for i := 0; i < 2; i++ {
+		r := httptest.NewRequest(
+			http.MethodPost, endpoint, strings.NewReader(calendar),
+		)
+		r.Header.Add("Authorization", "Bearer "+token)
+		r.Header.Add("Content-Type", "text/calendar")
+		w := httptest.NewRecorder()
+
+		api.ServeHTTP(w, r)
+
+		var response schedder.ImportClosuresResponse
+		err := json.NewDecoder(w.Result().Body).Decode(&response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect(t, "", response.Error)
+		expect(t, http.StatusOK, w.Result().StatusCode)
+		expect(t, 1, response.Imported)
+	}
+
+	endpoint = fmt.Sprintf("/tenants/%s/closures", tenantID)
+	r := httptest.NewRequest(http.MethodGet, endpoint, nil)
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, r)
+
+	var response schedder.ClosuresResponse
+	err := json.NewDecoder(w.Result().Body).Decode(&response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, 1, len(response.Closures))
+	expect(t, "Concediu, toată echipa", response.Closures[0].Reason)
+	expect(t, "ics", response.Closures[0].Source)
+	expect(t, 24*time.Hour, response.Closures[0].Ending.Sub(
+		response.Closures[0].Starting,
+	))
+}
+
+func TestHolidayCalendar(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantName := "Zâna Măseluță"
+
+	tenantID := api.createTenantAndAccount(email, password, tenantName)
+	api.publishTenant(tenantID)
+	token := api.generateToken(email, password)
+
+	endpoint := fmt.Sprintf("/tenants/%s/holidays", tenantID)
+	request := schedder.SetHolidayCalendarRequest{
+		Calendar: schedder.HolidayCalendarRO,
+	}
+	r, err := NewJSONRequest(http.MethodPut, endpoint, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Add("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+
+	api.ServeHTTP(w, r)
+	expect(t, http.StatusOK, w.Result().StatusCode)
+
+	endpoint = fmt.Sprintf("/tenants/%s/hours", tenantID)
+	r = httptest.NewRequest(http.MethodGet, endpoint, nil)
+	w = httptest.NewRecorder()
+	api.ServeHTTP(w, r)
+
+	var response schedder.TenantHoursResponse
+	err = json.NewDecoder(w.Result().Body).Decode(&response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, "", response.Error)
+	expect(t, "Europe/Bucharest", response.Timezone)
+	expect(t, schedder.HolidayCalendarRO, response.HolidayCalendar)
+
+	// the national day is always in the following year
+	found := false
+	for _, holiday := range response.Holidays {
+		if holiday.Month() == time.December && holiday.Day() == 1 {
+			found = true
+		}
+	}
+	expect(t, true, found)
+}
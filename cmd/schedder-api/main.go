@@ -1,7 +1,15 @@
 package main
 
-import "gitlab.com/vlad.anghel/schedder-api"
+import (
+	"os"
+
+	"gitlab.com/vlad.anghel/schedder-api"
+)
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "fsck" {
+		os.Exit(schedder.Fsck(os.Args[2:], os.Stdout, os.Stderr))
+	}
 
-func main()  {
 	schedder.Run()
 }
@@ -18,6 +18,32 @@ const (
 	CtxPhotoID = CtxKey(6)
 	// CtxServiceID is used when an endpoint needs a photoID URL parameter.
 	CtxServiceID = CtxKey(6)
+	// CtxInvitationID is used when an endpoint needs an invitationID URL
+	// parameter.
+	CtxInvitationID = CtxKey(7)
+	// CtxLocationID is used when an endpoint needs a locationID URL parameter.
+	CtxLocationID = CtxKey(8)
+	// CtxClosureID is used when an endpoint needs a closureID URL parameter.
+	CtxClosureID = CtxKey(9)
+	// CtxOptionID is used when an endpoint needs an optionID URL parameter.
+	CtxOptionID = CtxKey(10)
+	// CtxCategoryID is used when an endpoint needs a categoryID URL
+	// parameter.
+	CtxCategoryID = CtxKey(11)
+	// CtxLanguage is used when an endpoint needs a language URL parameter.
+	CtxLanguage = CtxKey(12)
+	// CtxPackageID is used when an endpoint needs a packageID URL parameter.
+	CtxPackageID = CtxKey(13)
+	// CtxRuleID is used when an endpoint needs a ruleID URL parameter.
+	CtxRuleID = CtxKey(14)
+	// CtxIntervalID is used when an endpoint needs an intervalID URL
+	// parameter.
+	CtxIntervalID = CtxKey(15)
+	// CtxExceptionID is used when an endpoint needs an exceptionID URL
+	// parameter.
+	CtxExceptionID = CtxKey(16)
+	// CtxTimeOffID is used when an endpoint needs a timeOffID URL parameter.
+	CtxTimeOffID = CtxKey(17)
 
 
 	// BcryptRounds represents the number of rounds to be used in bcrypt.
@@ -28,4 +54,7 @@ const (
 
 	// minimumLengthForDevice is the minimum length of the device name.
 	minimumLengthForDevice = 8
+	// maxUnpublishedTenants is the maximum number of tenants owned by an
+	// account that can wait in draft or for a review at the same time.
+	maxUnpublishedTenants = 3
 )
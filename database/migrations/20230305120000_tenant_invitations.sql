@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+
+CREATE TYPE invitation_status AS ENUM ('pending', 'accepted', 'declined', 'revoked');
+
+CREATE TABLE tenant_invitations (
+	invitation_id uuid DEFAULT gen_random_uuid() NOT NULL,
+	tenant_id uuid REFERENCES tenants(tenant_id) NOT NULL,
+	invited_by uuid REFERENCES accounts(account_id) NOT NULL,
+
+	-- exactly one of email, phone identifies the invitee
+	email text DEFAULT NULL,
+	phone text DEFAULT NULL,
+
+	code text NOT NULL,
+	status invitation_status DEFAULT 'pending' NOT NULL,
+	expiration_date timestamp NOT NULL DEFAULT (NOW() + interval '7d'),
+
+	PRIMARY KEY(invitation_id),
+	CONSTRAINT invitation_email_or_phone CHECK((email IS NULL) != (phone IS NULL)),
+	CONSTRAINT unique_invitation_code UNIQUE(code)
+);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS tenant_invitations;
+DROP TYPE IF EXISTS invitation_status;
+-- +goose StatementEnd
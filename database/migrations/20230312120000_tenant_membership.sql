@@ -0,0 +1,49 @@
+-- +goose Up
+-- +goose StatementBegin
+
+ALTER TABLE tenant_accounts ADD COLUMN is_owner boolean DEFAULT FALSE NOT NULL;
+-- the owner is always a manager
+ALTER TABLE tenant_accounts ADD CONSTRAINT owner_is_manager CHECK(is_manager OR NOT is_owner);
+CREATE UNIQUE INDEX one_owner_per_tenant ON tenant_accounts(tenant_id) WHERE is_owner;
+
+-- until now the only manager of a tenant was the one that created it
+UPDATE tenant_accounts SET is_owner = true WHERE (tenant_id, account_id) IN (
+	SELECT DISTINCT ON (tenant_id) tenant_id, account_id FROM tenant_accounts
+		WHERE is_manager ORDER BY tenant_id, account_id
+);
+
+CREATE FUNCTION check_tenant_has_manager() RETURNS trigger AS $$
+BEGIN
+	IF EXISTS (SELECT 1 FROM tenants WHERE tenant_id = OLD.tenant_id)
+		AND NOT EXISTS (
+			SELECT 1 FROM tenant_accounts
+				WHERE tenant_id = OLD.tenant_id AND is_manager
+		) THEN
+		RAISE EXCEPTION 'tenant % must keep at least one manager', OLD.tenant_id
+			USING ERRCODE = 'check_violation';
+	END IF;
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER tenant_keeps_a_manager
+	AFTER UPDATE OR DELETE ON tenant_accounts
+	DEFERRABLE INITIALLY IMMEDIATE
+	FOR EACH ROW EXECUTE FUNCTION check_tenant_has_manager();
+
+-- Services outlive the membership of their personnel, so that past
+-- appointments still point to them. Membership is checked when creating and
+-- listing services instead.
+ALTER TABLE services DROP CONSTRAINT services_tenant_id_account_id_fkey;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE services ADD FOREIGN KEY(tenant_id, account_id) REFERENCES tenant_accounts(tenant_id, account_id);
+DROP TRIGGER IF EXISTS tenant_keeps_a_manager ON tenant_accounts;
+DROP FUNCTION IF EXISTS check_tenant_has_manager;
+DROP INDEX IF EXISTS one_owner_per_tenant;
+ALTER TABLE tenant_accounts DROP CONSTRAINT IF EXISTS owner_is_manager;
+ALTER TABLE tenant_accounts DROP COLUMN IF EXISTS is_owner;
+-- +goose StatementEnd
@@ -0,0 +1,81 @@
+-- +goose Up
+-- +goose StatementBegin
+
+CREATE TABLE tenant_locations (
+	location_id uuid DEFAULT gen_random_uuid() NOT NULL,
+	tenant_id uuid REFERENCES tenants(tenant_id) NOT NULL,
+
+	location_name text NOT NULL,
+	address text NOT NULL,
+	geo geography(Point, 4326) NOT NULL,
+	-- IANA time zone name, like Europe/Bucharest
+	timezone text NOT NULL,
+
+	-- needed by the foreign keys that keep the tenant of the location in sync
+	UNIQUE(tenant_id, location_id),
+	CONSTRAINT unique_location_name_for_tenant UNIQUE(tenant_id, location_name),
+
+	PRIMARY KEY(location_id)
+);
+
+CREATE INDEX tenant_locations_geo ON tenant_locations USING GIST(geo);
+
+CREATE TABLE location_hours (
+	location_id uuid REFERENCES tenant_locations(location_id) ON DELETE CASCADE NOT NULL,
+	weekday weekdays NOT NULL,
+	-- local time, in the time zone of the location
+	opening_time time NOT NULL,
+	closing_time time NOT NULL,
+
+	PRIMARY KEY(location_id, weekday),
+	CHECK(opening_time < closing_time)
+);
+
+CREATE TABLE location_personnel (
+	tenant_id uuid NOT NULL,
+	location_id uuid NOT NULL,
+	account_id uuid NOT NULL,
+
+	FOREIGN KEY(tenant_id, location_id) REFERENCES tenant_locations(tenant_id, location_id) ON DELETE CASCADE,
+	-- former members are no longer assigned to any location
+	FOREIGN KEY(tenant_id, account_id) REFERENCES tenant_accounts(tenant_id, account_id) ON DELETE CASCADE,
+
+	PRIMARY KEY(location_id, account_id)
+);
+
+ALTER TABLE services ADD CONSTRAINT unique_service_for_tenant UNIQUE(tenant_id, service_id);
+
+CREATE TABLE location_services (
+	tenant_id uuid NOT NULL,
+	location_id uuid NOT NULL,
+	service_id uuid NOT NULL,
+
+	FOREIGN KEY(tenant_id, location_id) REFERENCES tenant_locations(tenant_id, location_id) ON DELETE CASCADE,
+	FOREIGN KEY(tenant_id, service_id) REFERENCES services(tenant_id, service_id) ON DELETE CASCADE,
+
+	PRIMARY KEY(location_id, service_id)
+);
+
+-- photos without a location belong to the tenant as a whole
+ALTER TABLE tenant_photos ADD COLUMN location_id uuid DEFAULT NULL;
+ALTER TABLE tenant_photos ADD FOREIGN KEY(tenant_id, location_id) REFERENCES tenant_locations(tenant_id, location_id);
+
+-- schedules without a location are valid for any location of the personnel,
+-- so a location can't be deleted while schedules still reference it
+ALTER TABLE schedules ADD COLUMN location_id uuid REFERENCES tenant_locations(location_id) DEFAULT NULL;
+
+ALTER TABLE appointments ADD COLUMN location_id uuid REFERENCES tenant_locations(location_id) DEFAULT NULL;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE appointments DROP COLUMN IF EXISTS location_id;
+ALTER TABLE schedules DROP COLUMN IF EXISTS location_id;
+ALTER TABLE tenant_photos DROP COLUMN IF EXISTS location_id;
+DROP TABLE IF EXISTS location_services;
+ALTER TABLE services DROP CONSTRAINT IF EXISTS unique_service_for_tenant;
+DROP TABLE IF EXISTS location_personnel;
+DROP TABLE IF EXISTS location_hours;
+DROP TABLE IF EXISTS tenant_locations;
+-- +goose StatementEnd
@@ -0,0 +1,63 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- IANA time zone name, the tenant opening hours and public holidays use it
+ALTER TABLE tenants ADD COLUMN timezone text DEFAULT 'Europe/Bucharest' NOT NULL;
+-- the public holiday calendar observed by the tenant, NULL for none
+ALTER TABLE tenants ADD COLUMN holiday_calendar text DEFAULT NULL CHECK(holiday_calendar IN ('RO'));
+
+-- the schedules are local times in the time zone of the tenant too, every
+-- tenant is still in the default one
+ALTER TABLE schedules
+	ALTER COLUMN starting_time TYPE time
+		USING (starting_time AT TIME ZONE 'Europe/Bucharest')::time,
+	ALTER COLUMN ending_time TYPE time
+		USING (ending_time AT TIME ZONE 'Europe/Bucharest')::time;
+
+CREATE TABLE tenant_hours (
+	tenant_id uuid REFERENCES tenants(tenant_id) NOT NULL,
+	weekday weekdays NOT NULL,
+	-- local time, in the time zone of the tenant
+	opening_time time NOT NULL,
+	closing_time time NOT NULL,
+
+	PRIMARY KEY(tenant_id, weekday),
+	CHECK(opening_time < closing_time)
+);
+
+CREATE TYPE closure_source AS ENUM ('manual', 'ics');
+
+CREATE TABLE tenant_closures (
+	closure_id uuid DEFAULT gen_random_uuid() NOT NULL,
+	tenant_id uuid REFERENCES tenants(tenant_id) NOT NULL,
+	-- closures without a location apply to the whole tenant
+	location_id uuid DEFAULT NULL,
+
+	starting timestamptz NOT NULL,
+	ending timestamptz NOT NULL,
+	reason text NOT NULL,
+	source closure_source DEFAULT 'manual' NOT NULL,
+
+	FOREIGN KEY(tenant_id, location_id) REFERENCES tenant_locations(tenant_id, location_id) ON DELETE CASCADE,
+	CHECK(starting < ending),
+
+	PRIMARY KEY(closure_id)
+);
+
+CREATE INDEX tenant_closures_range ON tenant_closures(tenant_id, starting, ending);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS tenant_closures;
+DROP TYPE IF EXISTS closure_source;
+DROP TABLE IF EXISTS tenant_hours;
+ALTER TABLE schedules
+	ALTER COLUMN starting_time TYPE timetz
+		USING ((DATE '2000-01-01' + starting_time) AT TIME ZONE 'Europe/Bucharest')::timetz,
+	ALTER COLUMN ending_time TYPE timetz
+		USING ((DATE '2000-01-01' + ending_time) AT TIME ZONE 'Europe/Bucharest')::timetz;
+ALTER TABLE tenants DROP COLUMN IF EXISTS holiday_calendar;
+ALTER TABLE tenants DROP COLUMN IF EXISTS timezone;
+-- +goose StatementEnd
@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+
+CREATE TYPE tenant_status AS ENUM ('draft', 'pending_review', 'published', 'suspended');
+
+ALTER TABLE tenants ADD COLUMN status tenant_status DEFAULT 'draft' NOT NULL;
+-- the reason of the last rejection or suspension
+ALTER TABLE tenants ADD COLUMN status_reason text DEFAULT NULL;
+ALTER TABLE tenants ADD COLUMN verified boolean DEFAULT FALSE NOT NULL;
+
+-- the existing tenants were already public
+UPDATE tenants SET status = 'published';
+
+CREATE TABLE tenant_moderation_log (
+	event_id uuid DEFAULT gen_random_uuid() NOT NULL,
+	tenant_id uuid REFERENCES tenants(tenant_id) NOT NULL,
+	-- the account that did the action, either an admin or a manager
+	account_id uuid REFERENCES accounts(account_id) NOT NULL,
+
+	action text NOT NULL,
+	reason text DEFAULT NULL,
+	created_at timestamptz DEFAULT NOW() NOT NULL,
+
+	PRIMARY KEY(event_id)
+);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS tenant_moderation_log;
+ALTER TABLE tenants DROP COLUMN IF EXISTS verified;
+ALTER TABLE tenants DROP COLUMN IF EXISTS status_reason;
+ALTER TABLE tenants DROP COLUMN IF EXISTS status;
+DROP TYPE IF EXISTS tenant_status;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- the type of the stored photo, like image/jpeg; unknown for the photos
+-- uploaded before it was stored, which are detected when downloaded
+ALTER TABLE photos ADD COLUMN mime_type text DEFAULT NULL;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+ALTER TABLE photos DROP COLUMN IF EXISTS mime_type;
+
+-- +goose StatementEnd
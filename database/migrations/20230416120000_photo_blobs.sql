@@ -0,0 +1,67 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- The stored files of the photos, shared by all the photos with the same
+-- content. The refcount is kept by the triggers on photos.
+CREATE TABLE blobs (
+	sha256sum bytea NOT NULL,
+	refcount integer DEFAULT 0 NOT NULL CHECK(refcount >= 0),
+	created_at timestamptz DEFAULT NOW() NOT NULL,
+
+	PRIMARY KEY(sha256sum)
+);
+
+-- The outbox of the blobs that aren't referenced anymore, their files are
+-- removed after the transaction that dropped the last reference commits.
+CREATE TABLE blob_deletions (
+	sha256sum bytea NOT NULL,
+	created_at timestamptz DEFAULT NOW() NOT NULL,
+
+	PRIMARY KEY(sha256sum)
+);
+
+INSERT INTO blobs(sha256sum, refcount)
+	SELECT sha256sum, COUNT(*) FROM photos GROUP BY sha256sum;
+
+ALTER TABLE photos ADD FOREIGN KEY(sha256sum) REFERENCES blobs(sha256sum);
+
+CREATE FUNCTION reference_blob() RETURNS trigger AS $$
+BEGIN
+	INSERT INTO blobs(sha256sum, refcount) VALUES (NEW.sha256sum, 1)
+		ON CONFLICT (sha256sum) DO UPDATE SET refcount = blobs.refcount + 1;
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE FUNCTION unreference_blob() RETURNS trigger AS $$
+BEGIN
+	UPDATE blobs SET refcount = refcount - 1 WHERE sha256sum = OLD.sha256sum;
+	IF (SELECT refcount FROM blobs WHERE sha256sum = OLD.sha256sum) = 0 THEN
+		INSERT INTO blob_deletions(sha256sum) VALUES (OLD.sha256sum)
+			ON CONFLICT DO NOTHING;
+	END IF;
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- the blob row has to exist before the photo references it
+CREATE TRIGGER photo_references_blob
+	BEFORE INSERT ON photos
+	FOR EACH ROW EXECUTE FUNCTION reference_blob();
+
+CREATE TRIGGER photo_unreferences_blob
+	AFTER DELETE ON photos
+	FOR EACH ROW EXECUTE FUNCTION unreference_blob();
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS photo_unreferences_blob ON photos;
+DROP TRIGGER IF EXISTS photo_references_blob ON photos;
+DROP FUNCTION IF EXISTS unreference_blob;
+DROP FUNCTION IF EXISTS reference_blob;
+ALTER TABLE photos DROP CONSTRAINT IF EXISTS photos_sha256sum_fkey;
+DROP TABLE IF EXISTS blob_deletions;
+DROP TABLE IF EXISTS blobs;
+-- +goose StatementEnd
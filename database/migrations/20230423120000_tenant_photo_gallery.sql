@@ -0,0 +1,57 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- the order of the photo in the gallery, lowest first
+ALTER TABLE tenant_photos ADD COLUMN position int DEFAULT 0 NOT NULL;
+ALTER TABLE tenant_photos ADD COLUMN caption text DEFAULT '' NOT NULL;
+-- the text alternative of the photo, for screen readers
+ALTER TABLE tenant_photos ADD COLUMN alt_text text DEFAULT '' NOT NULL;
+ALTER TABLE tenant_photos ADD COLUMN is_cover boolean DEFAULT FALSE NOT NULL;
+
+-- keep the existing photos in a stable order
+UPDATE tenant_photos SET position = ordered.position FROM (
+	SELECT tenant_id, photo_id,
+		(row_number() OVER (PARTITION BY tenant_id ORDER BY photo_id) - 1)::int AS position
+		FROM tenant_photos
+) ordered
+WHERE tenant_photos.tenant_id = ordered.tenant_id
+	AND tenant_photos.photo_id = ordered.photo_id;
+
+-- a tenant has at most one cover photo
+CREATE UNIQUE INDEX tenant_photos_cover ON tenant_photos(tenant_id) WHERE is_cover;
+
+CREATE TABLE tenant_photo_services (
+	tenant_id uuid NOT NULL,
+	photo_id uuid NOT NULL,
+	service_id uuid NOT NULL,
+
+	FOREIGN KEY(tenant_id, photo_id) REFERENCES tenant_photos(tenant_id, photo_id) ON DELETE CASCADE,
+	FOREIGN KEY(tenant_id, service_id) REFERENCES services(tenant_id, service_id) ON DELETE CASCADE,
+
+	PRIMARY KEY(photo_id, service_id)
+);
+
+CREATE TABLE tenant_photo_personnel (
+	tenant_id uuid NOT NULL,
+	photo_id uuid NOT NULL,
+	account_id uuid NOT NULL,
+
+	FOREIGN KEY(tenant_id, photo_id) REFERENCES tenant_photos(tenant_id, photo_id) ON DELETE CASCADE,
+	-- former members are no longer tagged
+	FOREIGN KEY(tenant_id, account_id) REFERENCES tenant_accounts(tenant_id, account_id) ON DELETE CASCADE,
+
+	PRIMARY KEY(photo_id, account_id)
+);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS tenant_photo_personnel;
+DROP TABLE IF EXISTS tenant_photo_services;
+DROP INDEX IF EXISTS tenant_photos_cover;
+ALTER TABLE tenant_photos DROP COLUMN IF EXISTS is_cover;
+ALTER TABLE tenant_photos DROP COLUMN IF EXISTS alt_text;
+ALTER TABLE tenant_photos DROP COLUMN IF EXISTS caption;
+ALTER TABLE tenant_photos DROP COLUMN IF EXISTS position;
+-- +goose StatementEnd
@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- the public profile of a member, the account name is shown when the
+-- display name is missing
+ALTER TABLE tenant_accounts ADD COLUMN display_name text DEFAULT NULL;
+ALTER TABLE tenant_accounts ADD COLUMN bio text DEFAULT '' NOT NULL;
+ALTER TABLE tenant_accounts ADD COLUMN specialties text[] DEFAULT '{}' NOT NULL;
+
+-- the appointment a review is about, the last one of the customer at the
+-- tenant before the review, so that it rates the member who did it
+ALTER TABLE reviews ADD COLUMN appointment_id uuid REFERENCES appointments(appointment_id) DEFAULT NULL;
+UPDATE reviews SET appointment_id = (
+	SELECT appointments.appointment_id FROM appointments
+		JOIN services ON services.service_id = appointments.service_id
+		WHERE appointments.account_id = reviews.account_id
+		AND services.tenant_id = reviews.tenant_id
+		AND appointments.status != 'cancelled' AND appointments.starting < NOW()
+		ORDER BY appointments.starting DESC LIMIT 1
+);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE reviews DROP COLUMN IF EXISTS appointment_id;
+ALTER TABLE tenant_accounts DROP COLUMN IF EXISTS specialties;
+ALTER TABLE tenant_accounts DROP COLUMN IF EXISTS bio;
+ALTER TABLE tenant_accounts DROP COLUMN IF EXISTS display_name;
+-- +goose StatementEnd
@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+
+CREATE TYPE photo_status AS ENUM ('pending', 'approved', 'rejected');
+
+-- the existing photos were already public
+ALTER TABLE photos ADD COLUMN moderation_status photo_status DEFAULT 'approved' NOT NULL;
+-- why the photo was flagged or rejected
+ALTER TABLE photos ADD COLUMN moderation_reason text DEFAULT NULL;
+-- the difference hash of the photo, see PerceptualHash
+ALTER TABLE photos ADD COLUMN perceptual_hash bigint DEFAULT NULL;
+ALTER TABLE photos ADD COLUMN uploaded_at timestamptz DEFAULT NOW() NOT NULL;
+
+CREATE INDEX photos_moderation_queue ON photos(uploaded_at) WHERE moderation_status != 'approved';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS photos_moderation_queue;
+ALTER TABLE photos DROP COLUMN IF EXISTS uploaded_at;
+ALTER TABLE photos DROP COLUMN IF EXISTS perceptual_hash;
+ALTER TABLE photos DROP COLUMN IF EXISTS moderation_reason;
+ALTER TABLE photos DROP COLUMN IF EXISTS moderation_status;
+DROP TYPE IF EXISTS photo_status;
+-- +goose StatementEnd
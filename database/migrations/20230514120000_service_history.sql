@@ -0,0 +1,79 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- archived services can't be booked anymore, but past appointments still
+-- point to them
+ALTER TABLE services ADD COLUMN archived_at timestamptz DEFAULT NULL;
+
+-- the name of an archived service can be reused
+ALTER TABLE services DROP CONSTRAINT unique_service_name_for_tenant_user;
+CREATE UNIQUE INDEX unique_service_name_for_tenant_user
+	ON services(tenant_id, account_id, service_name) WHERE archived_at IS NULL;
+
+CREATE TABLE service_versions (
+	service_id uuid REFERENCES services(service_id) ON DELETE CASCADE NOT NULL,
+	version int NOT NULL,
+
+	service_name text NOT NULL,
+	price numeric NOT NULL,
+	duration interval NOT NULL,
+
+	valid_from timestamptz DEFAULT NOW() NOT NULL,
+
+	PRIMARY KEY(service_id, version)
+);
+
+INSERT INTO service_versions (service_id, version, service_name, price, duration)
+	SELECT service_id, 1, service_name, price, duration FROM services;
+
+-- every change of the name, price or duration of a service is a new version
+CREATE FUNCTION record_service_version() RETURNS trigger AS $$
+BEGIN
+	INSERT INTO service_versions (service_id, version, service_name, price, duration)
+		SELECT NEW.service_id, COALESCE(MAX(version), 0) + 1,
+			NEW.service_name, NEW.price, NEW.duration
+		FROM service_versions WHERE service_id = NEW.service_id;
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER service_created
+	AFTER INSERT ON services
+	FOR EACH ROW EXECUTE FUNCTION record_service_version();
+
+CREATE TRIGGER service_changed
+	AFTER UPDATE OF service_name, price, duration ON services
+	FOR EACH ROW
+	WHEN ((OLD.service_name, OLD.price, OLD.duration)
+		IS DISTINCT FROM (NEW.service_name, NEW.price, NEW.duration))
+	EXECUTE FUNCTION record_service_version();
+
+-- the service as it was booked, so that later changes don't affect the
+-- appointment
+ALTER TABLE appointments ADD COLUMN service_name text;
+ALTER TABLE appointments ADD COLUMN price numeric;
+ALTER TABLE appointments ADD COLUMN duration interval;
+
+UPDATE appointments SET service_name = services.service_name,
+	price = services.price, duration = services.duration
+	FROM services WHERE services.service_id = appointments.service_id;
+
+ALTER TABLE appointments ALTER COLUMN service_name SET NOT NULL;
+ALTER TABLE appointments ALTER COLUMN price SET NOT NULL;
+ALTER TABLE appointments ALTER COLUMN duration SET NOT NULL;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE appointments DROP COLUMN IF EXISTS duration;
+ALTER TABLE appointments DROP COLUMN IF EXISTS price;
+ALTER TABLE appointments DROP COLUMN IF EXISTS service_name;
+DROP TRIGGER IF EXISTS service_changed ON services;
+DROP TRIGGER IF EXISTS service_created ON services;
+DROP FUNCTION IF EXISTS record_service_version;
+DROP TABLE IF EXISTS service_versions;
+DROP INDEX IF EXISTS unique_service_name_for_tenant_user;
+ALTER TABLE services ADD CONSTRAINT unique_service_name_for_tenant_user UNIQUE(tenant_id, account_id, service_name);
+ALTER TABLE services DROP COLUMN IF EXISTS archived_at;
+-- +goose StatementEnd
@@ -0,0 +1,116 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- the services are a catalogue of the tenant, assigned to any number of
+-- members, each with an optional price or duration of their own
+CREATE TABLE service_personnel (
+	service_id uuid NOT NULL,
+	tenant_id uuid NOT NULL,
+	account_id uuid NOT NULL,
+
+	-- NULL for the price and duration of the catalogue
+	price numeric DEFAULT NULL,
+	duration interval DEFAULT NULL,
+
+	PRIMARY KEY(service_id, account_id),
+	FOREIGN KEY(tenant_id, service_id) REFERENCES services(tenant_id, service_id) ON DELETE CASCADE,
+	FOREIGN KEY(tenant_id, account_id) REFERENCES tenant_accounts(tenant_id, account_id) ON DELETE CASCADE,
+
+	CONSTRAINT duration_30mins_multiple CHECK(((EXTRACT(epoch from duration::interval)/60) % 30) = 0),
+	CONSTRAINT duration_gt_zero CHECK(EXTRACT(epoch from duration::interval) > 0),
+	CONSTRAINT price_not_negative CHECK(price >= 0)
+);
+
+CREATE INDEX service_personnel_account ON service_personnel(tenant_id, account_id);
+
+-- services of former members stay unassigned
+INSERT INTO service_personnel (service_id, tenant_id, account_id)
+	SELECT services.service_id, services.tenant_id, services.account_id
+	FROM services
+	JOIN tenant_accounts ON tenant_accounts.tenant_id = services.tenant_id
+		AND tenant_accounts.account_id = services.account_id;
+
+-- the member doing the appointment, picked when booking
+ALTER TABLE appointments ADD COLUMN personnel_id uuid REFERENCES accounts(account_id);
+UPDATE appointments SET personnel_id = services.account_id
+	FROM services WHERE services.service_id = appointments.service_id;
+ALTER TABLE appointments ALTER COLUMN personnel_id SET NOT NULL;
+
+CREATE INDEX appointments_personnel ON appointments(personnel_id, starting);
+
+-- The names were unique for each member, and members often had a service of
+-- the same name with their own price and duration. Those services are merged
+-- into the first one created, the others become the overrides of their
+-- members, and their appointments, locations and photos move to it. The
+-- merged services are archived rather than deleted, so that their price
+-- history is kept.
+CREATE TEMPORARY TABLE merged_services AS
+	SELECT services.service_id, first_value(services.service_id) OVER (
+		PARTITION BY services.tenant_id, services.service_name
+		ORDER BY service_versions.valid_from, services.service_id
+	) AS kept_id
+	FROM services
+	JOIN service_versions ON service_versions.service_id = services.service_id
+		AND service_versions.version = 1
+	WHERE services.archived_at IS NULL;
+DELETE FROM merged_services WHERE service_id = kept_id;
+
+UPDATE service_personnel SET service_id = merged_services.kept_id,
+	price = NULLIF(merged.price, kept.price),
+	duration = NULLIF(merged.duration, kept.duration)
+	FROM merged_services, services AS merged, services AS kept
+	WHERE service_personnel.service_id = merged_services.service_id
+	AND merged.service_id = merged_services.service_id
+	AND kept.service_id = merged_services.kept_id;
+
+UPDATE appointments SET service_id = merged_services.kept_id
+	FROM merged_services WHERE appointments.service_id = merged_services.service_id;
+
+INSERT INTO location_services (tenant_id, location_id, service_id)
+	SELECT location_services.tenant_id, location_services.location_id, merged_services.kept_id
+	FROM location_services
+	JOIN merged_services ON merged_services.service_id = location_services.service_id
+	ON CONFLICT DO NOTHING;
+
+INSERT INTO tenant_photo_services (tenant_id, photo_id, service_id)
+	SELECT tenant_photo_services.tenant_id, tenant_photo_services.photo_id, merged_services.kept_id
+	FROM tenant_photo_services
+	JOIN merged_services ON merged_services.service_id = tenant_photo_services.service_id
+	ON CONFLICT DO NOTHING;
+
+DELETE FROM location_services USING merged_services
+	WHERE location_services.service_id = merged_services.service_id;
+DELETE FROM tenant_photo_services USING merged_services
+	WHERE tenant_photo_services.service_id = merged_services.service_id;
+UPDATE services SET archived_at = NOW() FROM merged_services
+	WHERE services.service_id = merged_services.service_id;
+DROP TABLE merged_services;
+
+DROP INDEX unique_service_name_for_tenant_user;
+ALTER TABLE services DROP COLUMN account_id;
+
+-- the name of an archived service can be reused
+CREATE UNIQUE INDEX unique_service_name_for_tenant
+	ON services(tenant_id, service_name) WHERE archived_at IS NULL;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- the merged services aren't split again
+DROP INDEX IF EXISTS unique_service_name_for_tenant;
+ALTER TABLE services ADD COLUMN account_id uuid REFERENCES accounts(account_id);
+UPDATE services SET account_id = (
+	SELECT appointments.personnel_id FROM appointments
+		WHERE appointments.service_id = services.service_id
+	UNION ALL
+	SELECT service_personnel.account_id FROM service_personnel
+		WHERE service_personnel.service_id = services.service_id
+	LIMIT 1
+);
+CREATE UNIQUE INDEX unique_service_name_for_tenant_user
+	ON services(tenant_id, account_id, service_name) WHERE archived_at IS NULL;
+DROP INDEX IF EXISTS appointments_personnel;
+ALTER TABLE appointments DROP COLUMN IF EXISTS personnel_id;
+DROP TABLE IF EXISTS service_personnel;
+-- +goose StatementEnd
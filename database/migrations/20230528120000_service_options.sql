@@ -0,0 +1,51 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- A variant replaces the plain service, like long hair, and exactly one must
+-- be chosen if the service has any. Any number of add-ons can be chosen.
+CREATE TYPE service_option_kind AS ENUM ('variant', 'addon');
+
+CREATE TABLE service_options (
+	option_id uuid DEFAULT gen_random_uuid() NOT NULL,
+	tenant_id uuid NOT NULL,
+	service_id uuid NOT NULL,
+
+	kind service_option_kind NOT NULL,
+	option_name text NOT NULL,
+
+	-- added to the price and duration of the service
+	price numeric DEFAULT 0 NOT NULL,
+	duration interval DEFAULT '0' NOT NULL,
+
+	PRIMARY KEY(option_id),
+	FOREIGN KEY(tenant_id, service_id) REFERENCES services(tenant_id, service_id) ON DELETE CASCADE,
+
+	CONSTRAINT unique_option_name_for_service UNIQUE(service_id, kind, option_name),
+	CONSTRAINT duration_30mins_multiple CHECK(((EXTRACT(epoch from duration::interval)/60) % 30) = 0),
+	CONSTRAINT duration_not_negative CHECK(EXTRACT(epoch from duration::interval) >= 0),
+	CONSTRAINT price_not_negative CHECK(price >= 0)
+);
+
+-- the options as they were booked, the price and duration of the appointment
+-- include them
+CREATE TABLE appointment_options (
+	appointment_id uuid REFERENCES appointments(appointment_id) ON DELETE CASCADE NOT NULL,
+	-- NULL once the option is deleted
+	option_id uuid REFERENCES service_options(option_id) ON DELETE SET NULL,
+
+	kind service_option_kind NOT NULL,
+	option_name text NOT NULL,
+	price numeric NOT NULL,
+	duration interval NOT NULL
+);
+
+CREATE INDEX appointment_options_appointment ON appointment_options(appointment_id);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS appointment_options;
+DROP TABLE IF EXISTS service_options;
+DROP TYPE IF EXISTS service_option_kind;
+-- +goose StatementEnd
@@ -0,0 +1,82 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- the prices of the tenant are in its currency, before VAT
+ALTER TABLE tenants ADD COLUMN currency text DEFAULT 'RON' NOT NULL;
+-- the VAT rate in basis points, 1900 for 19%
+ALTER TABLE tenants ADD COLUMN vat_rate int DEFAULT 0 NOT NULL;
+ALTER TABLE tenants ADD CONSTRAINT currency_iso_4217 CHECK(currency ~ '^[A-Z]{3}$');
+ALTER TABLE tenants ADD CONSTRAINT vat_rate_percentage CHECK(vat_rate >= 0 AND vat_rate <= 10000);
+
+-- All the prices become integers in minor units. The existing prices were
+-- in RON, which has 2 decimals.
+ALTER TABLE services ALTER COLUMN price TYPE bigint USING round(price * 100);
+ALTER TABLE service_personnel ALTER COLUMN price TYPE bigint USING round(price * 100);
+ALTER TABLE service_options ALTER COLUMN price DROP DEFAULT;
+ALTER TABLE service_options ALTER COLUMN price TYPE bigint USING round(price * 100);
+ALTER TABLE service_options ALTER COLUMN price SET DEFAULT 0;
+ALTER TABLE service_versions ALTER COLUMN price TYPE bigint USING round(price * 100);
+ALTER TABLE appointments ALTER COLUMN price TYPE bigint USING round(price * 100);
+ALTER TABLE appointment_options ALTER COLUMN price TYPE bigint USING round(price * 100);
+
+ALTER TABLE services ADD CONSTRAINT price_not_negative CHECK(price >= 0);
+
+-- the versions and the appointments keep the currency they were priced in,
+-- and the appointments the VAT rate they were booked with
+ALTER TABLE service_versions ADD COLUMN currency text;
+UPDATE service_versions SET currency = tenants.currency
+	FROM services JOIN tenants ON tenants.tenant_id = services.tenant_id
+	WHERE services.service_id = service_versions.service_id;
+ALTER TABLE service_versions ALTER COLUMN currency SET NOT NULL;
+
+ALTER TABLE appointments ADD COLUMN currency text;
+ALTER TABLE appointments ADD COLUMN vat_rate int;
+UPDATE appointments SET currency = tenants.currency, vat_rate = tenants.vat_rate
+	FROM services JOIN tenants ON tenants.tenant_id = services.tenant_id
+	WHERE services.service_id = appointments.service_id;
+ALTER TABLE appointments ALTER COLUMN currency SET NOT NULL;
+ALTER TABLE appointments ALTER COLUMN vat_rate SET NOT NULL;
+
+CREATE OR REPLACE FUNCTION record_service_version() RETURNS trigger AS $$
+BEGIN
+	INSERT INTO service_versions (service_id, version, service_name, price, currency, duration)
+		SELECT NEW.service_id, COALESCE(MAX(version), 0) + 1,
+			NEW.service_name, NEW.price,
+			(SELECT currency FROM tenants WHERE tenant_id = NEW.tenant_id),
+			NEW.duration
+		FROM service_versions WHERE service_id = NEW.service_id;
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION record_service_version() RETURNS trigger AS $$
+BEGIN
+	INSERT INTO service_versions (service_id, version, service_name, price, duration)
+		SELECT NEW.service_id, COALESCE(MAX(version), 0) + 1,
+			NEW.service_name, NEW.price, NEW.duration
+		FROM service_versions WHERE service_id = NEW.service_id;
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE appointments DROP COLUMN IF EXISTS vat_rate;
+ALTER TABLE appointments DROP COLUMN IF EXISTS currency;
+ALTER TABLE service_versions DROP COLUMN IF EXISTS currency;
+ALTER TABLE services DROP CONSTRAINT IF EXISTS price_not_negative;
+
+ALTER TABLE appointment_options ALTER COLUMN price TYPE numeric USING price / 100.0;
+ALTER TABLE appointments ALTER COLUMN price TYPE numeric USING price / 100.0;
+ALTER TABLE service_versions ALTER COLUMN price TYPE numeric USING price / 100.0;
+ALTER TABLE service_options ALTER COLUMN price TYPE numeric USING price / 100.0;
+ALTER TABLE service_personnel ALTER COLUMN price TYPE numeric USING price / 100.0;
+ALTER TABLE services ALTER COLUMN price TYPE numeric USING price / 100.0;
+
+ALTER TABLE tenants DROP CONSTRAINT IF EXISTS vat_rate_percentage;
+ALTER TABLE tenants DROP CONSTRAINT IF EXISTS currency_iso_4217;
+ALTER TABLE tenants DROP COLUMN IF EXISTS vat_rate;
+ALTER TABLE tenants DROP COLUMN IF EXISTS currency;
+-- +goose StatementEnd
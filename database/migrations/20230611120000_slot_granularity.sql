@@ -0,0 +1,55 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- the length of the slots of the timetables, which divides an hour
+ALTER TABLE tenants ADD COLUMN slot_granularity interval DEFAULT '30 minutes' NOT NULL;
+ALTER TABLE tenants ADD CONSTRAINT slot_granularity_divides_hour CHECK(
+	EXTRACT(epoch FROM slot_granularity) >= 300
+	AND (3600 % EXTRACT(epoch FROM slot_granularity)) = 0
+	AND (EXTRACT(epoch FROM slot_granularity) % 60) = 0
+);
+
+-- the time blocked before and after every appointment, like for cleaning up
+ALTER TABLE services ADD COLUMN buffer_before interval DEFAULT '0' NOT NULL;
+ALTER TABLE services ADD COLUMN buffer_after interval DEFAULT '0' NOT NULL;
+ALTER TABLE services ADD CONSTRAINT buffers_minutes_multiple CHECK(
+	EXTRACT(epoch FROM buffer_before) >= 0 AND (EXTRACT(epoch FROM buffer_before) % 60) = 0
+	AND EXTRACT(epoch FROM buffer_after) >= 0 AND (EXTRACT(epoch FROM buffer_after) % 60) = 0
+);
+
+-- the appointments keep the buffers they were booked with
+ALTER TABLE appointments ADD COLUMN buffer_before interval DEFAULT '0' NOT NULL;
+ALTER TABLE appointments ADD COLUMN buffer_after interval DEFAULT '0' NOT NULL;
+
+-- The durations and the starting times only need whole minutes, the slots of
+-- the tenant are checked when booking.
+ALTER TABLE services DROP CONSTRAINT duration_30mins_multiple;
+ALTER TABLE services ADD CONSTRAINT duration_minutes_multiple CHECK((EXTRACT(epoch FROM duration) % 60) = 0);
+ALTER TABLE service_personnel DROP CONSTRAINT duration_30mins_multiple;
+ALTER TABLE service_personnel ADD CONSTRAINT duration_minutes_multiple CHECK((EXTRACT(epoch FROM duration) % 60) = 0);
+ALTER TABLE service_options DROP CONSTRAINT duration_30mins_multiple;
+ALTER TABLE service_options ADD CONSTRAINT duration_minutes_multiple CHECK((EXTRACT(epoch FROM duration) % 60) = 0);
+ALTER TABLE appointments DROP CONSTRAINT starting_30mins_multiple;
+ALTER TABLE appointments ADD CONSTRAINT starting_minutes_multiple CHECK((EXTRACT(epoch FROM starting) % 60) = 0);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE appointments DROP CONSTRAINT IF EXISTS starting_minutes_multiple;
+ALTER TABLE appointments ADD CONSTRAINT starting_30mins_multiple CHECK((FLOOR(EXTRACT(epoch FROM starting)/60) % 30) = 0);
+ALTER TABLE service_options DROP CONSTRAINT IF EXISTS duration_minutes_multiple;
+ALTER TABLE service_options ADD CONSTRAINT duration_30mins_multiple CHECK(((EXTRACT(epoch from duration::interval)/60) % 30) = 0);
+ALTER TABLE service_personnel DROP CONSTRAINT IF EXISTS duration_minutes_multiple;
+ALTER TABLE service_personnel ADD CONSTRAINT duration_30mins_multiple CHECK(((EXTRACT(epoch from duration::interval)/60) % 30) = 0);
+ALTER TABLE services DROP CONSTRAINT IF EXISTS duration_minutes_multiple;
+ALTER TABLE services ADD CONSTRAINT duration_30mins_multiple CHECK(((EXTRACT(epoch from duration::interval)/60) % 30) = 0);
+
+ALTER TABLE appointments DROP COLUMN IF EXISTS buffer_after;
+ALTER TABLE appointments DROP COLUMN IF EXISTS buffer_before;
+ALTER TABLE services DROP CONSTRAINT IF EXISTS buffers_minutes_multiple;
+ALTER TABLE services DROP COLUMN IF EXISTS buffer_after;
+ALTER TABLE services DROP COLUMN IF EXISTS buffer_before;
+ALTER TABLE tenants DROP CONSTRAINT IF EXISTS slot_granularity_divides_hour;
+ALTER TABLE tenants DROP COLUMN IF EXISTS slot_granularity;
+-- +goose StatementEnd
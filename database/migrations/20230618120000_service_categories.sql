@@ -0,0 +1,57 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- the groups of the catalogue of a tenant, like "Hair" or "Nails"
+CREATE TABLE service_categories (
+	category_id uuid DEFAULT gen_random_uuid() NOT NULL,
+	tenant_id uuid REFERENCES tenants(tenant_id) ON DELETE CASCADE NOT NULL,
+
+	category_name text NOT NULL,
+	-- the categories are shown by position, then by name
+	position int DEFAULT 0 NOT NULL,
+
+	PRIMARY KEY(category_id),
+	CONSTRAINT unique_category_for_tenant UNIQUE(tenant_id, category_id),
+	CONSTRAINT unique_category_name_for_tenant UNIQUE(tenant_id, category_name)
+);
+
+ALTER TABLE services ADD COLUMN description text DEFAULT '' NOT NULL;
+-- NULL for the services without a category, the category must be one of the
+-- tenant of the service
+ALTER TABLE services ADD COLUMN category_id uuid DEFAULT NULL;
+ALTER TABLE services ADD CONSTRAINT service_category_of_tenant
+	FOREIGN KEY(tenant_id, category_id) REFERENCES service_categories(tenant_id, category_id);
+
+-- the names and descriptions in other languages, by ISO 639-1 code
+CREATE TABLE service_translations (
+	service_id uuid REFERENCES services(service_id) ON DELETE CASCADE NOT NULL,
+	language text NOT NULL,
+
+	service_name text NOT NULL,
+	description text DEFAULT '' NOT NULL,
+
+	PRIMARY KEY(service_id, language),
+	CONSTRAINT language_iso_639_1 CHECK(language ~ '^[a-z]{2}$')
+);
+
+CREATE TABLE category_translations (
+	category_id uuid REFERENCES service_categories(category_id) ON DELETE CASCADE NOT NULL,
+	language text NOT NULL,
+
+	category_name text NOT NULL,
+
+	PRIMARY KEY(category_id, language),
+	CONSTRAINT language_iso_639_1 CHECK(language ~ '^[a-z]{2}$')
+);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS category_translations;
+DROP TABLE IF EXISTS service_translations;
+ALTER TABLE services DROP CONSTRAINT IF EXISTS service_category_of_tenant;
+ALTER TABLE services DROP COLUMN IF EXISTS category_id;
+ALTER TABLE services DROP COLUMN IF EXISTS description;
+DROP TABLE IF EXISTS service_categories;
+-- +goose StatementEnd
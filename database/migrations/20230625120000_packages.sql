@@ -0,0 +1,81 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- bundles of services sold together, like "5 massages for the price of 4"
+CREATE TABLE packages (
+	package_id uuid DEFAULT gen_random_uuid() NOT NULL,
+	tenant_id uuid REFERENCES tenants(tenant_id) ON DELETE CASCADE NOT NULL,
+
+	package_name text NOT NULL,
+	description text DEFAULT '' NOT NULL,
+	-- in the currency of the tenant, before VAT
+	price bigint NOT NULL,
+
+	created_at timestamptz DEFAULT NOW() NOT NULL,
+	-- archived packages can't be bought, the purchases keep their credits
+	archived_at timestamptz DEFAULT NULL,
+
+	PRIMARY KEY(package_id),
+	CONSTRAINT price_not_negative CHECK(price >= 0)
+);
+
+CREATE INDEX packages_tenant ON packages(tenant_id);
+
+CREATE TABLE package_services (
+	package_id uuid REFERENCES packages(package_id) ON DELETE CASCADE NOT NULL,
+	tenant_id uuid NOT NULL,
+	service_id uuid NOT NULL,
+
+	-- the number of appointments of the service included
+	quantity int NOT NULL,
+
+	PRIMARY KEY(package_id, service_id),
+	FOREIGN KEY(tenant_id, service_id) REFERENCES services(tenant_id, service_id),
+	CONSTRAINT quantity_gt_zero CHECK(quantity > 0)
+);
+
+-- the packages bought by customers, with the name, price, currency and VAT
+-- rate they were bought with
+CREATE TABLE package_purchases (
+	purchase_id uuid DEFAULT gen_random_uuid() NOT NULL,
+	package_id uuid REFERENCES packages(package_id) NOT NULL,
+	tenant_id uuid REFERENCES tenants(tenant_id) NOT NULL,
+	account_id uuid REFERENCES accounts(account_id) NOT NULL,
+
+	package_name text NOT NULL,
+	price bigint NOT NULL,
+	currency text NOT NULL,
+	vat_rate int NOT NULL,
+
+	purchased_at timestamptz DEFAULT NOW() NOT NULL,
+
+	PRIMARY KEY(purchase_id)
+);
+
+CREATE INDEX package_purchases_account ON package_purchases(account_id, purchased_at);
+
+-- the credits bought, copied from the package
+CREATE TABLE purchase_credits (
+	purchase_id uuid REFERENCES package_purchases(purchase_id) ON DELETE CASCADE NOT NULL,
+	service_id uuid REFERENCES services(service_id) NOT NULL,
+	quantity int NOT NULL,
+
+	PRIMARY KEY(purchase_id, service_id)
+);
+
+-- the purchase whose credit the appointment consumes, a cancelled appointment
+-- gives the credit back
+ALTER TABLE appointments ADD COLUMN purchase_id uuid REFERENCES package_purchases(purchase_id) DEFAULT NULL;
+CREATE INDEX appointments_purchase ON appointments(purchase_id) WHERE purchase_id IS NOT NULL;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS appointments_purchase;
+ALTER TABLE appointments DROP COLUMN IF EXISTS purchase_id;
+DROP TABLE IF EXISTS purchase_credits;
+DROP TABLE IF EXISTS package_purchases;
+DROP TABLE IF EXISTS package_services;
+DROP TABLE IF EXISTS packages;
+-- +goose StatementEnd
@@ -0,0 +1,51 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- A percentage adjustment is in basis points of the price of the service,
+-- -1500 for 15% off. A fixed one is in minor units of the tenant currency.
+CREATE TYPE pricing_adjustment_kind AS ENUM ('percentage', 'fixed');
+
+-- Time-based adjustments of the price of a service, like cheaper weekday
+-- mornings. A rule applies to the appointments starting inside all its
+-- conditions, evaluated in the time zone of the tenant.
+CREATE TABLE pricing_rules (
+	rule_id uuid DEFAULT gen_random_uuid() NOT NULL,
+	tenant_id uuid NOT NULL,
+	service_id uuid NOT NULL,
+
+	rule_name text NOT NULL,
+	-- bit 0 for Sunday up to bit 6 for Saturday, like time.Weekday
+	weekdays int DEFAULT 127 NOT NULL,
+	-- the local time of day, as the time since midnight
+	window_start interval DEFAULT '0' NOT NULL,
+	window_end interval DEFAULT '24 hours' NOT NULL,
+	-- the local dates, inclusive, NULL for no limit
+	valid_from date DEFAULT NULL,
+	valid_until date DEFAULT NULL,
+
+	kind pricing_adjustment_kind NOT NULL,
+	adjustment bigint NOT NULL,
+
+	created_at timestamptz DEFAULT NOW() NOT NULL,
+
+	PRIMARY KEY(rule_id),
+	FOREIGN KEY(tenant_id, service_id) REFERENCES services(tenant_id, service_id) ON DELETE CASCADE,
+
+	CONSTRAINT weekdays_bitmask CHECK(weekdays > 0 AND weekdays < 128),
+	CONSTRAINT window_in_day CHECK(
+		window_start >= interval '0' AND window_start < window_end
+		AND window_end <= interval '24 hours'
+	),
+	CONSTRAINT valid_dates CHECK(valid_from <= valid_until),
+	CONSTRAINT percentage_not_below_free CHECK(kind != 'percentage' OR adjustment >= -10000)
+);
+
+CREATE INDEX pricing_rules_service ON pricing_rules(service_id);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS pricing_rules;
+DROP TYPE IF EXISTS pricing_adjustment_kind;
+-- +goose StatementEnd
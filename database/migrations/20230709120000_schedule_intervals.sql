@@ -0,0 +1,43 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- A member can work several intervals on a weekday, like 9-13 and 15-19. The
+-- intervals of a weekday don't overlap, the API validates them while holding
+-- a lock on the row of the account, so concurrent changes can't overlap.
+ALTER TABLE schedules DROP CONSTRAINT schedules_pkey;
+ALTER TABLE schedules ADD COLUMN interval_id uuid DEFAULT gen_random_uuid() NOT NULL;
+ALTER TABLE schedules ADD PRIMARY KEY(interval_id);
+
+-- The schedules belong to a membership, like the exceptions. They were shared
+-- by all the tenants of the member, so every tenant gets a copy, except of the
+-- intervals at the locations of the other tenants.
+ALTER TABLE schedules ADD COLUMN tenant_id uuid;
+INSERT INTO schedules (tenant_id, account_id, weekday, starting_time, ending_time, location_id)
+	SELECT tenant_accounts.tenant_id, schedules.account_id, schedules.weekday,
+		schedules.starting_time, schedules.ending_time, schedules.location_id
+	FROM schedules
+	JOIN tenant_accounts ON tenant_accounts.account_id = schedules.account_id
+	LEFT JOIN tenant_locations ON tenant_locations.location_id = schedules.location_id
+	WHERE schedules.tenant_id IS NULL
+	AND (schedules.location_id IS NULL OR tenant_locations.tenant_id = tenant_accounts.tenant_id);
+DELETE FROM schedules WHERE tenant_id IS NULL;
+ALTER TABLE schedules ALTER COLUMN tenant_id SET NOT NULL;
+ALTER TABLE schedules ADD FOREIGN KEY(tenant_id, account_id) REFERENCES tenant_accounts(tenant_id, account_id);
+
+CREATE INDEX schedules_weekday ON schedules(tenant_id, account_id, weekday);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- only the first interval of every weekday is kept, of any tenant
+DELETE FROM schedules USING schedules AS earlier
+	WHERE earlier.account_id = schedules.account_id
+	AND earlier.weekday = schedules.weekday
+	AND (earlier.starting_time, earlier.interval_id) < (schedules.starting_time, schedules.interval_id);
+DROP INDEX IF EXISTS schedules_weekday;
+ALTER TABLE schedules DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE schedules DROP CONSTRAINT schedules_pkey;
+ALTER TABLE schedules DROP COLUMN IF EXISTS interval_id;
+ALTER TABLE schedules ADD PRIMARY KEY(account_id, weekday);
+-- +goose StatementEnd
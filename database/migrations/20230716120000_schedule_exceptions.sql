@@ -0,0 +1,74 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- The working intervals of a member for a date, replacing the ones of the
+-- weekly schedule. An exception without intervals is a day off, and one on a
+-- weekday without a schedule is an extra working day. The dates are the days
+-- of the timetables and the times are local, in the time zone of the tenant.
+CREATE TABLE schedule_exceptions (
+	exception_id uuid DEFAULT gen_random_uuid() NOT NULL,
+	tenant_id uuid NOT NULL,
+	account_id uuid NOT NULL,
+
+	exception_date date NOT NULL,
+	reason text DEFAULT '' NOT NULL,
+
+	created_at timestamptz DEFAULT NOW() NOT NULL,
+
+	PRIMARY KEY(exception_id),
+	FOREIGN KEY(tenant_id, account_id) REFERENCES tenant_accounts(tenant_id, account_id) ON DELETE CASCADE,
+	CONSTRAINT unique_exception_date UNIQUE(tenant_id, account_id, exception_date)
+);
+
+CREATE TABLE schedule_exception_intervals (
+	exception_id uuid REFERENCES schedule_exceptions(exception_id) ON DELETE CASCADE NOT NULL,
+	starting_time time NOT NULL,
+	ending_time time NOT NULL,
+	-- like for the schedules, the location can't be deleted while it's used
+	location_id uuid REFERENCES tenant_locations(location_id) DEFAULT NULL,
+
+	CHECK(starting_time < ending_time)
+);
+
+CREATE INDEX schedule_exception_intervals_exception ON schedule_exception_intervals(exception_id);
+
+CREATE TYPE time_off_status AS ENUM ('pending', 'approved', 'rejected');
+
+-- Time off requested by a member, the approved requests are days off.
+CREATE TABLE time_off_requests (
+	time_off_id uuid DEFAULT gen_random_uuid() NOT NULL,
+	tenant_id uuid NOT NULL,
+	account_id uuid NOT NULL,
+
+	-- inclusive, like the dates of the exceptions
+	starting_date date NOT NULL,
+	ending_date date NOT NULL,
+	reason text DEFAULT '' NOT NULL,
+
+	status time_off_status DEFAULT 'pending' NOT NULL,
+	created_at timestamptz DEFAULT NOW() NOT NULL,
+	decided_at timestamptz DEFAULT NULL,
+	decided_by uuid REFERENCES accounts(account_id) DEFAULT NULL,
+
+	PRIMARY KEY(time_off_id),
+	FOREIGN KEY(tenant_id, account_id) REFERENCES tenant_accounts(tenant_id, account_id) ON DELETE CASCADE,
+	CHECK(starting_date <= ending_date)
+);
+
+CREATE INDEX time_off_requests_account ON time_off_requests(tenant_id, account_id, starting_date);
+
+-- set when an exception or an approved time off leaves the appointment
+-- outside the working hours of the member, the tenant should move or cancel
+-- it
+ALTER TABLE appointments ADD COLUMN conflicting bool DEFAULT false NOT NULL;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE appointments DROP COLUMN IF EXISTS conflicting;
+DROP TABLE IF EXISTS time_off_requests;
+DROP TYPE IF EXISTS time_off_status;
+DROP TABLE IF EXISTS schedule_exception_intervals;
+DROP TABLE IF EXISTS schedule_exceptions;
+-- +goose StatementEnd
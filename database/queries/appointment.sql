@@ -12,30 +12,133 @@
 
 
 -- name: CreateAppointment :one
-INSERT INTO appointments(service_id, account_id, starting)
-	VALUES(@service_id, @account_id, @starting)
-	RETURNING appointment_id;
+-- The service is copied, so that the appointment keeps the booked name, price,
+-- duration and buffers, and the currency and VAT rate of the tenant. The
+-- price of the service is the one after the pricing rules, and it's prepaid if
+-- it's booked with the credit of a purchase, only the options are paid.
+INSERT INTO appointments(
+	service_id, personnel_id, account_id, starting, location_id,
+	service_name, price, duration, currency, vat_rate,
+	buffer_before, buffer_after, purchase_id
+) SELECT services.service_id, service_personnel.account_id, @account_id,
+	@starting, @location_id, services.service_name,
+	CASE WHEN sqlc.narg(purchase_id)::uuid IS NULL
+		THEN @service_price::bigint ELSE 0
+	END + @options_price::bigint,
+	COALESCE(service_personnel.duration, services.duration) + @options_duration::interval,
+	tenants.currency, tenants.vat_rate,
+	services.buffer_before, services.buffer_after, sqlc.narg(purchase_id)::uuid
+	FROM services
+	JOIN service_personnel ON service_personnel.service_id = services.service_id
+	JOIN tenants ON tenants.tenant_id = services.tenant_id
+	WHERE services.service_id = @service_id
+	AND service_personnel.account_id = @personnel_id
+	AND services.archived_at IS NULL
+	RETURNING appointment_id, price, currency, vat_rate;
+
+-- name: AddAppointmentOptions :exec
+INSERT INTO appointment_options (
+	appointment_id, option_id, kind, option_name, price, duration
+) SELECT @appointment_id, option_id, kind, option_name, price, duration
+	FROM service_options WHERE option_id = ANY(@option_ids::uuid[]);
+
+-- name: GetAppointmentsForAccount :many
+SELECT appointment_id, appointments.service_id, services.tenant_id,
+	appointments.personnel_id, appointments.service_name,
+	appointments.price, appointments.currency, appointments.vat_rate,
+	appointments.duration, starting, status,
+	appointments.location_id, appointments.purchase_id, appointments.conflicting,
+	ARRAY(
+		SELECT option_name FROM appointment_options
+			WHERE appointment_options.appointment_id = appointments.appointment_id
+			ORDER BY kind, option_name
+	)::text[] AS options
+	FROM appointments
+	JOIN services ON services.service_id = appointments.service_id
+	WHERE appointments.account_id = @account_id
+	ORDER BY starting DESC;
 
 -- name: GetTimetableForDate :many
-WITH schedule AS (
-	-- get the working hours for the member
-	SELECT starting_time, ending_time FROM schedules WHERE schedules.weekday = @weekday AND schedules.account_id = @personnel_id
-), idx AS (
-	-- get the appointments for that member
-	SELECT 
-		-- get the index in their working hours
-		(extract(epoch FROM (starting::timestamp - (date_trunc('day', starting)::timestamp +(schedule.starting_time AT TIME ZONE 'UTC')::time)::timestamp))/(60 * 30)) AS idx,
-		-- convert the duration to a number of indices that should be blocked
-		(extract(epoch FROM (duration))/(60 * 30)) AS delta
-	FROM appointments, schedule JOIN services ON service_id = service_id
-	-- where date of starting = date of desired date, ignoring the time component
-	WHERE date_trunc('day', appointments.starting) = date_trunc('day', @desired_date::timestamptz) 
+-- The working intervals of the member split into slots of the granularity,
+-- each blocked if it overlaps an appointment, including its buffers. Only the
+-- intervals at the location, or without one, are used if a location is given.
+-- An exception for the date replaces the weekly schedule at the tenant, and
+-- there are no intervals during an approved time off at the tenant. The date
+-- and the times are local, in the time zone of the tenant.
+WITH params AS (
+	SELECT @desired_date::date AS day, @granularity::interval AS granularity,
+		tenants.timezone
+	FROM tenants WHERE tenants.tenant_id = @tenant_id
+), exception AS (
+	SELECT exception_id FROM schedule_exceptions, params
+	WHERE schedule_exceptions.tenant_id = @tenant_id
+	AND schedule_exceptions.account_id = @personnel_id
+	AND schedule_exceptions.exception_date = params.day::date
+), time_off AS (
+	SELECT 1 FROM time_off_requests, params
+	WHERE time_off_requests.tenant_id = @tenant_id
+	AND time_off_requests.account_id = @personnel_id
+	AND time_off_requests.status = 'approved'
+	AND params.day::date BETWEEN time_off_requests.starting_date AND time_off_requests.ending_date
+), intervals AS (
+	-- get the working intervals for the member
+	SELECT schedules.starting_time, schedules.ending_time, schedules.location_id
+	FROM schedules
+	WHERE schedules.tenant_id = @tenant_id AND schedules.account_id = @personnel_id
+	AND schedules.weekday = @weekday
+	AND NOT EXISTS (SELECT 1 FROM exception)
+	UNION ALL
+	SELECT schedule_exception_intervals.starting_time,
+		schedule_exception_intervals.ending_time,
+		schedule_exception_intervals.location_id
+	FROM schedule_exception_intervals
+	JOIN exception ON exception.exception_id = schedule_exception_intervals.exception_id
+), schedule AS (
+	SELECT starting_time, ending_time FROM intervals
+	WHERE NOT EXISTS (SELECT 1 FROM time_off)
+	AND (sqlc.narg(location_id)::uuid IS NULL OR intervals.location_id IS NULL
+		OR intervals.location_id = sqlc.narg(location_id)::uuid)
+), busy AS (
+	-- get the time taken by the appointments of that member around the date
+	SELECT appointments.starting - appointments.buffer_before AS busy_from,
+		appointments.starting + appointments.duration + appointments.buffer_after AS busy_until
+	FROM appointments, params
+	WHERE appointments.personnel_id = @personnel_id AND appointments.status != 'cancelled'
+	AND appointments.starting > (params.day - interval '1 day') AT TIME ZONE params.timezone
+	AND appointments.starting < (params.day + interval '2 days') AT TIME ZONE params.timezone
 ), series AS (
-	-- generate a list of indices for the member's working hours, for the timetable
-	SELECT generate_series(0, (extract(epoch FROM (schedule.ending_time::time - schedule.starting_time::time))/(60 * 30)) - 1, 1) AS indices FROM schedule
-), indices AS (
-	-- generate a list of blocked entries in the timetable
-	SELECT generate_series(idx, idx + delta -1, 1 ) AS block_index FROM idx ORDER BY block_index
+	-- generate the slots of every interval, for the timetable
+	SELECT (schedule.starting_time+(indices*params.granularity))::time AS times,
+		(schedule.starting_time+((indices+1)*params.granularity))::time AS ends
+	FROM schedule, params, generate_series(0, floor(extract(epoch FROM (schedule.ending_time::time - schedule.starting_time::time))/extract(epoch FROM params.granularity))::int - 1, 1) AS indices
 )
-SELECT series.indices, (schedule.starting_time+(series.indices*'30m'::interval))::time AS times , (block_index IS NOT NULL)::bool as is_blocked FROM schedule, series LEFT JOIN indices ON series.indices = indices.block_index ORDER BY series.indices;
+SELECT series.times, EXISTS(
+	SELECT 1 FROM busy
+	WHERE busy.busy_from < (params.day + series.ends) AT TIME ZONE params.timezone
+	AND busy.busy_until > (params.day + series.times) AT TIME ZONE params.timezone
+)::bool AS is_blocked FROM series, params ORDER BY series.times;
+
+
+-- name: CancelFutureAppointmentsForPersonnel :execrows
+UPDATE appointments SET status = 'cancelled' FROM services
+	WHERE appointments.service_id = services.service_id
+	AND services.tenant_id = @tenant_id AND appointments.personnel_id = @account_id
+	AND appointments.starting > NOW() AND appointments.status = 'pending';
+
+-- name: GetFutureAppointmentsForPersonnel :many
+-- The pending appointments of the member at the tenant, with the duration of
+-- their options.
+SELECT appointments.appointment_id, appointments.service_id,
+	appointments.starting, appointments.location_id, COALESCE((
+		SELECT sum(appointment_options.duration) FROM appointment_options
+			WHERE appointment_options.appointment_id = appointments.appointment_id
+	), interval '0')::interval AS options_duration
+	FROM appointments
+	JOIN services ON services.service_id = appointments.service_id
+	WHERE services.tenant_id = @tenant_id AND appointments.personnel_id = @account_id
+	AND appointments.starting > NOW() AND appointments.status = 'pending'
+	ORDER BY appointments.starting;
 
+-- name: ReassignAppointment :exec
+UPDATE appointments SET personnel_id = @personnel_id
+	WHERE appointment_id = @appointment_id;
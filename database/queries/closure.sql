@@ -0,0 +1,44 @@
+
+-- name: GetTenantCalendar :one
+SELECT timezone, holiday_calendar FROM tenants WHERE tenant_id = @tenant_id;
+
+-- name: SetTenantTimezone :exec
+UPDATE tenants SET timezone = @timezone WHERE tenant_id = @tenant_id;
+
+-- name: SetHolidayCalendar :exec
+UPDATE tenants SET holiday_calendar = @holiday_calendar WHERE tenant_id = @tenant_id;
+
+-- name: ClearTenantHours :exec
+DELETE FROM tenant_hours WHERE tenant_id = @tenant_id;
+
+-- name: AddTenantHours :exec
+INSERT INTO tenant_hours (tenant_id, weekday, opening_time, closing_time)
+	VALUES (@tenant_id, @weekday, @opening_time, @closing_time);
+
+-- name: GetTenantHours :many
+SELECT weekday, opening_time, closing_time FROM tenant_hours
+	WHERE tenant_id = @tenant_id ORDER BY weekday;
+
+-- name: CreateClosure :one
+INSERT INTO tenant_closures (tenant_id, location_id, starting, ending, reason, source)
+	VALUES (@tenant_id, @location_id, @starting, @ending, @reason, @source)
+	RETURNING closure_id;
+
+-- name: DeleteClosure :execrows
+DELETE FROM tenant_closures
+	WHERE tenant_id = @tenant_id AND closure_id = @closure_id;
+
+-- name: DeleteClosuresFromSource :exec
+DELETE FROM tenant_closures WHERE tenant_id = @tenant_id AND source = @source;
+
+-- name: GetClosures :many
+SELECT closure_id, location_id, starting, ending, reason, source
+	FROM tenant_closures WHERE tenant_id = @tenant_id AND ending > NOW()
+	ORDER BY starting;
+
+-- name: GetClosuresBetween :many
+-- Closures of the whole tenant and of the location, if one is given.
+SELECT starting, ending FROM tenant_closures
+	WHERE tenant_id = @tenant_id
+	AND (location_id IS NULL OR location_id = @location_id)
+	AND starting < @until AND ending > @since;
@@ -0,0 +1,89 @@
+-- name: DeleteExceptionForDate :exec
+DELETE FROM schedule_exceptions
+	WHERE tenant_id = @tenant_id AND account_id = @account_id
+	AND exception_date = @exception_date::date;
+
+-- name: CreateException :one
+INSERT INTO schedule_exceptions (tenant_id, account_id, exception_date, reason)
+	VALUES (@tenant_id, @account_id, @exception_date::date, @reason)
+	RETURNING exception_id;
+
+-- name: AddExceptionInterval :exec
+INSERT INTO schedule_exception_intervals (exception_id, starting_time, ending_time, location_id)
+	VALUES (@exception_id, @starting_time::time, @ending_time::time, @location_id);
+
+-- name: DeleteException :one
+DELETE FROM schedule_exceptions
+	WHERE tenant_id = @tenant_id AND account_id = @account_id
+	AND exception_id = @exception_id
+	RETURNING exception_date;
+
+-- name: GetExceptions :many
+SELECT exception_id, exception_date, reason FROM schedule_exceptions
+	WHERE tenant_id = @tenant_id AND account_id = @account_id
+	AND exception_date >= @since::date
+	ORDER BY exception_date;
+
+-- name: GetExceptionIntervals :many
+SELECT schedule_exception_intervals.exception_id,
+	schedule_exception_intervals.starting_time,
+	schedule_exception_intervals.ending_time,
+	schedule_exception_intervals.location_id
+	FROM schedule_exception_intervals
+	JOIN schedule_exceptions ON schedule_exceptions.exception_id = schedule_exception_intervals.exception_id
+	WHERE schedule_exceptions.tenant_id = @tenant_id
+	AND schedule_exceptions.account_id = @account_id
+	AND schedule_exceptions.exception_date >= @since::date
+	ORDER BY schedule_exception_intervals.starting_time;
+
+-- name: UpdateConflictingAppointments :many
+-- Recomputes the flag of the pending appointments of the member at the tenant
+-- between the dates, or since the first one if there's no until: they
+-- conflict if they're during an approved time off or outside the working
+-- intervals of the day, the ones of the exception for the date or else the
+-- ones of the weekly schedule. The days are the ones in the time zone of the
+-- tenant.
+UPDATE appointments SET conflicting = (
+	EXISTS (
+		SELECT 1 FROM time_off_requests
+		WHERE time_off_requests.tenant_id = @tenant_id
+		AND time_off_requests.account_id = @account_id
+		AND time_off_requests.status = 'approved'
+		AND timezone(tenants.timezone, appointments.starting)::date
+			BETWEEN time_off_requests.starting_date AND time_off_requests.ending_date
+	) OR CASE WHEN EXISTS (
+		SELECT 1 FROM schedule_exceptions
+		WHERE schedule_exceptions.tenant_id = @tenant_id
+		AND schedule_exceptions.account_id = @account_id
+		AND schedule_exceptions.exception_date = timezone(tenants.timezone, appointments.starting)::date
+	) THEN NOT EXISTS (
+		SELECT 1 FROM schedule_exceptions
+		JOIN schedule_exception_intervals
+			ON schedule_exception_intervals.exception_id = schedule_exceptions.exception_id
+		WHERE schedule_exceptions.tenant_id = @tenant_id
+		AND schedule_exceptions.account_id = @account_id
+		AND schedule_exceptions.exception_date = timezone(tenants.timezone, appointments.starting)::date
+		AND timezone(tenants.timezone, appointments.starting)
+			>= schedule_exceptions.exception_date + schedule_exception_intervals.starting_time
+		AND timezone(tenants.timezone, appointments.starting + appointments.duration)
+			<= schedule_exceptions.exception_date + schedule_exception_intervals.ending_time
+	) ELSE NOT EXISTS (
+		SELECT 1 FROM schedules
+		WHERE schedules.tenant_id = @tenant_id
+		AND schedules.account_id = @account_id
+		AND schedules.weekday = extract(dow FROM timezone(tenants.timezone, appointments.starting))
+		AND timezone(tenants.timezone, appointments.starting)
+			>= timezone(tenants.timezone, appointments.starting)::date + schedules.starting_time
+		AND timezone(tenants.timezone, appointments.starting + appointments.duration)
+			<= timezone(tenants.timezone, appointments.starting)::date + schedules.ending_time
+	) END
+) FROM services, tenants
+	WHERE appointments.service_id = services.service_id
+	AND services.tenant_id = @tenant_id
+	AND tenants.tenant_id = @tenant_id
+	AND appointments.personnel_id = @account_id
+	AND appointments.status = 'pending'
+	AND timezone(tenants.timezone, appointments.starting)::date >= @since::date
+	AND (sqlc.narg(until)::date IS NULL
+		OR timezone(tenants.timezone, appointments.starting)::date <= sqlc.narg(until)::date)
+	RETURNING appointments.appointment_id, appointments.conflicting;
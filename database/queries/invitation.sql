@@ -0,0 +1,70 @@
+
+-- name: CreateInvitation :one
+INSERT INTO tenant_invitations (tenant_id, invited_by, email, phone, code)
+	VALUES (@tenant_id, @invited_by, @email, @phone, @code)
+	RETURNING invitation_id, expiration_date;
+
+-- name: RevokePendingInvitations :exec
+UPDATE tenant_invitations SET status = 'revoked'
+	WHERE tenant_id = @tenant_id AND status = 'pending'
+	AND (email = @email OR phone = @phone);
+
+-- name: GetTenantInvitations :many
+SELECT invitation_id, invited_by, email, phone, status, expiration_date
+	FROM tenant_invitations WHERE tenant_id = @tenant_id
+	ORDER BY expiration_date DESC;
+
+-- name: RevokeInvitation :execrows
+UPDATE tenant_invitations SET status = 'revoked'
+	WHERE invitation_id = @invitation_id AND tenant_id = @tenant_id
+	AND status = 'pending';
+
+-- name: GetInvitationsForAccount :many
+SELECT invitation_id, tenant_invitations.tenant_id, tenant_name, tenant_invitations.expiration_date
+	FROM tenant_invitations
+	JOIN tenants ON tenants.tenant_id = tenant_invitations.tenant_id
+	JOIN accounts ON accounts.email = tenant_invitations.email
+		OR accounts.phone = tenant_invitations.phone
+	WHERE accounts.account_id = @account_id AND accounts.activated = true
+	AND tenant_invitations.status = 'pending'
+	AND tenant_invitations.expiration_date > NOW();
+
+-- name: AcceptInvitation :one
+WITH invitation AS (
+	UPDATE tenant_invitations SET status = 'accepted'
+	FROM accounts
+	WHERE invitation_id = @invitation_id AND code = @code
+		AND accounts.account_id = @account_id AND accounts.activated = true
+		AND (accounts.email = tenant_invitations.email
+			OR accounts.phone = tenant_invitations.phone)
+		AND tenant_invitations.status = 'pending'
+		AND tenant_invitations.expiration_date > NOW()
+	RETURNING tenant_invitations.tenant_id
+)
+INSERT INTO tenant_accounts (tenant_id, account_id)
+	SELECT tenant_id, @account_id::uuid FROM invitation
+	RETURNING tenant_id;
+
+-- name: AcceptInvitationByCode :one
+WITH invitation AS (
+	UPDATE tenant_invitations SET status = 'accepted'
+	FROM accounts
+	WHERE code = @code AND accounts.account_id = @account_id
+		AND (accounts.email = tenant_invitations.email
+			OR accounts.phone = tenant_invitations.phone)
+		AND tenant_invitations.status = 'pending'
+		AND tenant_invitations.expiration_date > NOW()
+	RETURNING tenant_invitations.tenant_id
+)
+INSERT INTO tenant_accounts (tenant_id, account_id)
+	SELECT tenant_id, @account_id::uuid FROM invitation
+	RETURNING tenant_id;
+
+-- name: DeclineInvitation :execrows
+UPDATE tenant_invitations SET status = 'declined'
+	FROM accounts
+	WHERE invitation_id = @invitation_id AND accounts.account_id = @account_id
+		AND (accounts.email = tenant_invitations.email
+			OR accounts.phone = tenant_invitations.phone)
+		AND tenant_invitations.status = 'pending'
+		AND tenant_invitations.expiration_date > NOW();
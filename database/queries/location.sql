@@ -0,0 +1,114 @@
+
+-- name: CreateLocation :one
+INSERT INTO tenant_locations (tenant_id, location_name, address, geo, timezone)
+	VALUES (
+		@tenant_id, @location_name, @address,
+		ST_SetSRID(ST_MakePoint(@longitude::float8, @latitude::float8), 4326)::geography,
+		@timezone
+	)
+	RETURNING location_id;
+
+-- name: GetLocations :many
+SELECT location_id, location_name, address,
+	ST_Y(geo::geometry)::float8 AS latitude,
+	ST_X(geo::geometry)::float8 AS longitude,
+	timezone
+	FROM tenant_locations WHERE tenant_id = @tenant_id
+	ORDER BY location_name;
+
+-- name: GetLocation :one
+SELECT location_id, location_name, address,
+	ST_Y(geo::geometry)::float8 AS latitude,
+	ST_X(geo::geometry)::float8 AS longitude,
+	timezone
+	FROM tenant_locations
+	WHERE tenant_id = @tenant_id AND location_id = @location_id;
+
+-- name: UpdateLocation :execrows
+UPDATE tenant_locations SET
+	location_name = @location_name,
+	address = @address,
+	geo = ST_SetSRID(ST_MakePoint(@longitude::float8, @latitude::float8), 4326)::geography,
+	timezone = @timezone
+	WHERE tenant_id = @tenant_id AND location_id = @location_id;
+
+-- name: DetachLocationPhotos :exec
+UPDATE tenant_photos SET location_id = NULL
+	WHERE tenant_id = @tenant_id AND location_id = @location_id;
+
+-- name: DeleteLocation :execrows
+DELETE FROM tenant_locations
+	WHERE tenant_id = @tenant_id AND location_id = @location_id;
+
+-- name: ClearOpeningHours :exec
+DELETE FROM location_hours WHERE location_id = @location_id;
+
+-- name: AddOpeningHours :exec
+INSERT INTO location_hours (location_id, weekday, opening_time, closing_time)
+	VALUES (@location_id, @weekday, @opening_time, @closing_time);
+
+-- name: GetOpeningHours :many
+SELECT weekday, opening_time, closing_time FROM location_hours
+	WHERE location_id = @location_id ORDER BY weekday;
+
+-- name: AssignLocationPersonnel :exec
+INSERT INTO location_personnel (tenant_id, location_id, account_id)
+	VALUES (@tenant_id, @location_id, @account_id)
+	ON CONFLICT DO NOTHING;
+
+-- name: UnassignLocationPersonnel :execrows
+DELETE FROM location_personnel
+	WHERE tenant_id = @tenant_id AND location_id = @location_id
+	AND account_id = @account_id;
+
+-- name: GetLocationPersonnel :many
+SELECT accounts.account_id, account_name FROM location_personnel
+	JOIN accounts ON accounts.account_id = location_personnel.account_id
+	WHERE tenant_id = @tenant_id AND location_id = @location_id;
+
+-- name: IsLocationPersonnel :one
+SELECT EXISTS(
+	SELECT 1 FROM location_personnel
+		WHERE tenant_id = @tenant_id AND location_id = @location_id
+		AND account_id = @account_id
+);
+
+-- name: AddLocationService :exec
+INSERT INTO location_services (tenant_id, location_id, service_id)
+	VALUES (@tenant_id, @location_id, @service_id)
+	ON CONFLICT DO NOTHING;
+
+-- name: RemoveLocationService :execrows
+DELETE FROM location_services
+	WHERE tenant_id = @tenant_id AND location_id = @location_id
+	AND service_id = @service_id;
+
+-- name: GetLocationServices :many
+SELECT services.service_id, service_personnel.account_id, services.service_name,
+	COALESCE(service_personnel.price, services.price)::bigint AS price,
+	COALESCE(service_personnel.duration, services.duration)::interval AS duration
+	FROM location_services
+	JOIN services ON services.service_id = location_services.service_id
+	JOIN service_personnel ON service_personnel.service_id = services.service_id
+	WHERE location_services.tenant_id = @tenant_id
+	AND location_services.location_id = @location_id
+	AND services.archived_at IS NULL;
+
+-- name: ServiceHasLocations :one
+SELECT EXISTS(
+	SELECT 1 FROM location_services WHERE service_id = @service_id
+);
+
+-- name: IsServiceOfferedAtLocation :one
+-- The service must be offered at the location of the tenant and the
+-- personnel must work there.
+SELECT EXISTS(
+	SELECT 1 FROM location_services
+	JOIN location_personnel
+		ON location_personnel.location_id = location_services.location_id
+	WHERE location_services.tenant_id = @tenant_id
+	AND location_services.service_id = @service_id
+	AND location_services.location_id = @location_id
+	AND location_personnel.tenant_id = @tenant_id
+	AND location_personnel.account_id = @personnel_id
+);
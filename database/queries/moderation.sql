@@ -0,0 +1,30 @@
+
+-- name: CountUnpublishedTenants :one
+SELECT COUNT(*) FROM tenants
+	JOIN tenant_accounts ON tenant_accounts.tenant_id = tenants.tenant_id
+	WHERE tenant_accounts.account_id = @account_id AND tenant_accounts.is_owner
+	AND tenants.status IN ('draft', 'pending_review');
+
+-- name: GetTenantStatus :one
+SELECT status, status_reason, verified FROM tenants WHERE tenant_id = @tenant_id;
+
+-- name: SetTenantStatus :execrows
+-- Changes the status only if the current one is one of from_statuses.
+UPDATE tenants SET status = @status, status_reason = @status_reason
+	WHERE tenant_id = @tenant_id AND status::text = ANY(@from_statuses::text[]);
+
+-- name: SetTenantVerified :execrows
+UPDATE tenants SET verified = @verified
+	WHERE tenant_id = @tenant_id AND status = 'published';
+
+-- name: LogModeration :exec
+INSERT INTO tenant_moderation_log (tenant_id, account_id, action, reason)
+	VALUES (@tenant_id, @account_id, @action, @reason);
+
+-- name: GetTenantsPendingReview :many
+SELECT tenant_id, tenant_name FROM tenants WHERE status = 'pending_review'
+	ORDER BY tenant_name;
+
+-- name: GetModerationLog :many
+SELECT account_id, action, reason, created_at FROM tenant_moderation_log
+	WHERE tenant_id = @tenant_id ORDER BY created_at DESC;
@@ -0,0 +1,110 @@
+-- name: CreatePackage :one
+INSERT INTO packages (tenant_id, package_name, description, price)
+	VALUES (@tenant_id, @package_name, @description, @price::bigint)
+	RETURNING package_id;
+
+-- name: AddPackageService :execrows
+-- The service must be in the catalogue of the tenant of the package.
+INSERT INTO package_services (package_id, tenant_id, service_id, quantity)
+	SELECT @package_id, services.tenant_id, services.service_id, @quantity
+	FROM services
+	WHERE services.tenant_id = @tenant_id AND services.service_id = @service_id
+	AND services.archived_at IS NULL;
+
+-- name: ArchivePackage :execrows
+UPDATE packages SET archived_at = NOW()
+	WHERE tenant_id = @tenant_id AND package_id = @package_id
+	AND archived_at IS NULL;
+
+-- name: GetPackages :many
+SELECT package_id, package_name, description, price FROM packages
+	WHERE tenant_id = @tenant_id AND archived_at IS NULL
+	ORDER BY package_name, package_id;
+
+-- name: GetPackageServicesForTenant :many
+SELECT package_services.package_id, package_services.service_id,
+	services.service_name, package_services.quantity
+	FROM package_services
+	JOIN packages ON packages.package_id = package_services.package_id
+	JOIN services ON services.service_id = package_services.service_id
+	WHERE packages.tenant_id = @tenant_id AND packages.archived_at IS NULL
+	ORDER BY services.service_name;
+
+-- name: CreatePurchase :one
+-- The package is copied, so that the purchase keeps the name, price, currency
+-- and VAT rate it was bought with.
+INSERT INTO package_purchases (
+	package_id, tenant_id, account_id, package_name, price, currency, vat_rate
+) SELECT packages.package_id, packages.tenant_id, @account_id,
+	packages.package_name, packages.price, tenants.currency, tenants.vat_rate
+	FROM packages
+	JOIN tenants ON tenants.tenant_id = packages.tenant_id
+	WHERE packages.tenant_id = @tenant_id AND packages.package_id = @package_id
+	AND packages.archived_at IS NULL
+	RETURNING purchase_id, price, currency, vat_rate;
+
+-- name: AddPurchaseCredits :exec
+INSERT INTO purchase_credits (purchase_id, service_id, quantity)
+	SELECT @purchase_id, service_id, quantity FROM package_services
+	WHERE package_id = @package_id;
+
+-- name: LockPurchaseCredits :one
+-- The credits left for the service, the purchase is locked until the end of
+-- the transaction so that they can't be used twice.
+SELECT purchase_credits.quantity - (
+	SELECT count(*) FROM appointments
+		WHERE appointments.purchase_id = package_purchases.purchase_id
+		AND appointments.service_id = purchase_credits.service_id
+		AND appointments.status != 'cancelled'
+)::int AS remaining
+	FROM package_purchases
+	JOIN purchase_credits ON purchase_credits.purchase_id = package_purchases.purchase_id
+	WHERE package_purchases.purchase_id = @purchase_id
+	AND package_purchases.account_id = @account_id
+	AND purchase_credits.service_id = @service_id
+	FOR UPDATE OF package_purchases;
+
+-- name: GetPurchasesForAccount :many
+SELECT purchase_id, package_id, tenant_id, package_name, price, currency,
+	vat_rate, purchased_at
+	FROM package_purchases
+	WHERE account_id = @account_id
+	ORDER BY purchased_at DESC;
+
+-- name: GetPurchaseCreditsForAccount :many
+-- The credits of the purchases, with the ones used by the appointments which
+-- weren't cancelled.
+SELECT purchase_credits.purchase_id, purchase_credits.service_id,
+	services.service_name, purchase_credits.quantity, (
+		SELECT count(*) FROM appointments
+			WHERE appointments.purchase_id = purchase_credits.purchase_id
+			AND appointments.service_id = purchase_credits.service_id
+			AND appointments.status != 'cancelled'
+	)::int AS used
+	FROM purchase_credits
+	JOIN package_purchases ON package_purchases.purchase_id = purchase_credits.purchase_id
+	JOIN services ON services.service_id = purchase_credits.service_id
+	WHERE package_purchases.account_id = @account_id
+	ORDER BY services.service_name;
+
+-- name: HasServiceCredits :one
+-- The service is still owed while a purchase has credits left for it or a
+-- package which includes it can be bought.
+SELECT (EXISTS(
+	SELECT 1 FROM purchase_credits
+		JOIN package_purchases ON package_purchases.purchase_id = purchase_credits.purchase_id
+		WHERE package_purchases.tenant_id = @tenant_id
+		AND purchase_credits.service_id = @service_id
+		AND purchase_credits.quantity > (
+			SELECT count(*) FROM appointments
+				WHERE appointments.purchase_id = purchase_credits.purchase_id
+				AND appointments.service_id = purchase_credits.service_id
+				AND appointments.status != 'cancelled'
+		)
+) OR EXISTS(
+	SELECT 1 FROM package_services
+		JOIN packages ON packages.package_id = package_services.package_id
+		WHERE package_services.tenant_id = @tenant_id
+		AND package_services.service_id = @service_id
+		AND packages.archived_at IS NULL
+))::boolean;
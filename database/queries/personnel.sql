@@ -0,0 +1,28 @@
+-- name: GetPublicPersonnel :many
+-- A member is rated by the reviews of the appointments they did.
+WITH ratings AS (
+	SELECT appointments.personnel_id AS account_id,
+		AVG(reviews.rating)::float8 AS rating, COUNT(*) AS review_count
+		FROM reviews
+		JOIN appointments ON appointments.appointment_id = reviews.appointment_id
+		WHERE reviews.tenant_id = @tenant_id
+		GROUP BY appointments.personnel_id
+)
+SELECT tenant_accounts.account_id,
+	COALESCE(tenant_accounts.display_name, accounts.account_name)::text AS display_name,
+	tenant_accounts.bio, tenant_accounts.specialties,
+	photos.sha256sum, ratings.rating, ratings.review_count
+	FROM tenant_accounts
+	JOIN accounts ON accounts.account_id = tenant_accounts.account_id
+	LEFT JOIN photos ON photos.photo_id = accounts.photo_id
+		AND photos.moderation_status = 'approved'
+	LEFT JOIN ratings ON ratings.account_id = tenant_accounts.account_id
+	WHERE tenant_accounts.tenant_id = @tenant_id
+	ORDER BY COALESCE(tenant_accounts.display_name, accounts.account_name), tenant_accounts.account_id;
+
+-- name: UpdatePersonnelProfile :execrows
+UPDATE tenant_accounts SET
+	display_name = @display_name,
+	bio = @bio,
+	specialties = @specialties::text[]
+	WHERE tenant_id = @tenant_id AND account_id = @account_id;
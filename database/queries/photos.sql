@@ -1,17 +1,92 @@
 -- name: CreatePhoto :one
-INSERT INTO photos(sha256sum) VALUES (@sha256sum) RETURNING photo_id;
+INSERT INTO photos(sha256sum, mime_type) VALUES (@sha256sum, @mime_type::text) RETURNING photo_id;
 
 -- name: AddTenantPhoto :one
 WITH tmp AS (
-	INSERT INTO photos(sha256sum) VALUES (@sha256sum) RETURNING photo_id
+	INSERT INTO photos(sha256sum, mime_type, moderation_status, moderation_reason, perceptual_hash)
+		VALUES (@sha256sum, @mime_type::text, @moderation_status, @moderation_reason, @perceptual_hash::bigint)
+		RETURNING photo_id
 )
-INSERT INTO tenant_photos(tenant_id, photo_id) SELECT @tenant_id, photo_id FROM tmp RETURNING photo_id;
+INSERT INTO tenant_photos(tenant_id, photo_id, position)
+	SELECT @tenant_id, photo_id, (
+		SELECT COALESCE(MAX(position) + 1, 0) FROM tenant_photos WHERE tenant_id = @tenant_id
+	) FROM tmp RETURNING photo_id;
+
+-- name: AddLocationPhoto :one
+WITH tmp AS (
+	INSERT INTO photos(sha256sum, mime_type, moderation_status, moderation_reason, perceptual_hash)
+		VALUES (@sha256sum, @mime_type::text, @moderation_status, @moderation_reason, @perceptual_hash::bigint)
+		RETURNING photo_id
+)
+INSERT INTO tenant_photos(tenant_id, photo_id, location_id, position)
+	SELECT @tenant_id, photo_id, @location_id::uuid, (
+		SELECT COALESCE(MAX(position) + 1, 0) FROM tenant_photos WHERE tenant_id = @tenant_id
+	) FROM tmp RETURNING photo_id;
 
 -- name: ListTenantPhotos :many
-SELECT photo_id FROM tenant_photos WHERE tenant_id = @tenant_id;
+SELECT tenant_photos.photo_id, position, caption, alt_text, is_cover, sha256sum, moderation_status
+	FROM tenant_photos JOIN photos ON photos.photo_id = tenant_photos.photo_id
+	WHERE tenant_id = @tenant_id AND (moderation_status = 'approved'
+		OR (@include_pending::boolean AND moderation_status = 'pending'))
+	ORDER BY position, tenant_photos.photo_id;
+
+-- name: ListLocationPhotos :many
+SELECT tenant_photos.photo_id, position, caption, alt_text, is_cover, sha256sum, moderation_status
+	FROM tenant_photos JOIN photos ON photos.photo_id = tenant_photos.photo_id
+	WHERE tenant_id = @tenant_id AND location_id = @location_id::uuid
+	AND (moderation_status = 'approved'
+		OR (@include_pending::boolean AND moderation_status = 'pending'))
+	ORDER BY position, tenant_photos.photo_id;
+
+-- name: ListTenantPhotoServices :many
+SELECT photo_id, service_id FROM tenant_photo_services
+	WHERE tenant_id = @tenant_id ORDER BY service_id;
+
+-- name: ListTenantPhotoPersonnel :many
+SELECT photo_id, account_id FROM tenant_photo_personnel
+	WHERE tenant_id = @tenant_id ORDER BY account_id;
+
+-- name: UpdateTenantPhoto :execrows
+UPDATE tenant_photos SET caption = @caption, alt_text = @alt_text
+	WHERE tenant_id = @tenant_id AND photo_id = @photo_id;
+
+-- name: ClearTenantPhotoServices :exec
+DELETE FROM tenant_photo_services WHERE tenant_id = @tenant_id AND photo_id = @photo_id;
+
+-- name: ClearTenantPhotoPersonnel :exec
+DELETE FROM tenant_photo_personnel WHERE tenant_id = @tenant_id AND photo_id = @photo_id;
+
+-- name: TagTenantPhotoService :exec
+INSERT INTO tenant_photo_services (tenant_id, photo_id, service_id)
+	VALUES (@tenant_id, @photo_id, @service_id)
+	ON CONFLICT DO NOTHING;
+
+-- name: TagTenantPhotoPersonnel :exec
+INSERT INTO tenant_photo_personnel (tenant_id, photo_id, account_id)
+	VALUES (@tenant_id, @photo_id, @account_id)
+	ON CONFLICT DO NOTHING;
+
+-- name: ReorderTenantPhotos :exec
+UPDATE tenant_photos SET position = ordered.position FROM (
+	SELECT photo_id, (row_number() OVER (
+		ORDER BY array_position(@photo_ids::uuid[], photo_id) NULLS LAST, position, photo_id
+	) - 1)::int AS position
+	FROM tenant_photos WHERE tenant_id = @tenant_id
+) ordered
+WHERE tenant_photos.tenant_id = @tenant_id AND tenant_photos.photo_id = ordered.photo_id;
+
+-- name: ClearTenantCoverPhoto :exec
+UPDATE tenant_photos SET is_cover = FALSE WHERE tenant_id = @tenant_id AND is_cover;
+
+-- name: SetTenantCoverPhoto :execrows
+UPDATE tenant_photos SET is_cover = TRUE
+	WHERE tenant_id = @tenant_id AND photo_id = @photo_id;
 
 -- name: GetTenantPhotoHash :one
-SELECT sha256sum FROM photos JOIN tenant_photos ON photos.photo_id = tenant_photos.photo_id WHERE photos.photo_id = @photo_id AND tenant_id = @tenant_id;
+SELECT sha256sum, mime_type, moderation_status FROM photos JOIN tenant_photos ON photos.photo_id = tenant_photos.photo_id
+	WHERE photos.photo_id = @photo_id AND tenant_id = @tenant_id
+	AND (moderation_status = 'approved'
+		OR (@include_pending::boolean AND moderation_status = 'pending'));
 
 -- name: DeleteTenantPhoto :one
 WITH tmp AS (
@@ -21,12 +96,24 @@ DELETE FROM photos WHERE photos.photo_id = @photo_id RETURNING photos.sha256sum;
 
 -- name: SetProfilePhoto :exec
 WITH tmp AS (
-	INSERT INTO photos(sha256sum) VALUES (@sha256sum) RETURNING photo_id
+	INSERT INTO photos(sha256sum, mime_type, moderation_status, moderation_reason, perceptual_hash)
+		VALUES (@sha256sum, @mime_type::text, @moderation_status, @moderation_reason, @perceptual_hash::bigint)
+		RETURNING photo_id
 )
 UPDATE accounts SET photo_id = tmp.photo_id FROM tmp WHERE account_id = @account_id;
 
 -- name: GetProfilePhotoHash :one
-SELECT sha256sum FROM photos JOIN accounts ON photos.photo_id = accounts.photo_id WHERE account_id = @account_id;
+SELECT sha256sum, mime_type FROM photos JOIN accounts ON photos.photo_id = accounts.photo_id WHERE account_id = @account_id;
+
+-- name: GetPublicProfilePhotoHash :one
+-- Only the approved photos of the members of published tenants are public.
+SELECT sha256sum, mime_type FROM photos JOIN accounts ON photos.photo_id = accounts.photo_id
+	WHERE accounts.account_id = @account_id AND (moderation_status = 'approved'
+		OR (@include_pending::boolean AND moderation_status = 'pending')) AND EXISTS(
+		SELECT 1 FROM tenant_accounts
+			JOIN tenants ON tenants.tenant_id = tenant_accounts.tenant_id
+			WHERE tenant_accounts.account_id = @account_id AND tenants.status = 'published'
+	);
 
 -- name: DeleteProfilePhoto :one
 WITH old_value AS (
@@ -36,6 +123,39 @@ WITH old_value AS (
 )
 DELETE FROM photos WHERE photo_id IN (SELECT photo_id FROM old_value) RETURNING photos.sha256sum;
 
--- name: CountPhotosWithHash :one
-SELECT COUNT(*) FROM photos WHERE sha256sum = @sha256sum;
+-- name: GetBlobRefcount :one
+SELECT refcount FROM blobs WHERE sha256sum = @sha256sum;
+
+-- name: GetBlobDeletions :many
+SELECT sha256sum FROM blob_deletions ORDER BY created_at LIMIT 100;
+
+-- name: LockBlob :one
+SELECT refcount FROM blobs WHERE sha256sum = @sha256sum FOR UPDATE;
+
+-- name: DeleteBlob :exec
+DELETE FROM blobs WHERE sha256sum = @sha256sum;
+
+-- name: DeleteBlobDeletion :exec
+DELETE FROM blob_deletions WHERE sha256sum = @sha256sum;
+
+
+-- name: ListBlobs :many
+SELECT sha256sum, refcount FROM blobs;
+
+-- name: GetPhotoModerationQueue :many
+SELECT photos.photo_id, moderation_status, moderation_reason, perceptual_hash, uploaded_at,
+	COALESCE(tenant_photos.tenant_id, accounts.account_id)::uuid AS owner_id,
+	(tenant_photos.tenant_id IS NOT NULL)::boolean AS is_tenant_photo
+	FROM photos
+	LEFT JOIN tenant_photos ON tenant_photos.photo_id = photos.photo_id
+	LEFT JOIN accounts ON accounts.photo_id = photos.photo_id
+	WHERE moderation_status = @moderation_status
+	AND (tenant_photos.tenant_id IS NOT NULL OR accounts.account_id IS NOT NULL)
+	ORDER BY uploaded_at LIMIT 100;
+
+-- name: GetPhotoForModeration :one
+SELECT sha256sum, mime_type FROM photos WHERE photo_id = @photo_id;
 
+-- name: ModeratePhoto :execrows
+UPDATE photos SET moderation_status = @moderation_status, moderation_reason = @moderation_reason
+	WHERE photo_id = @photo_id;
@@ -0,0 +1,24 @@
+-- name: CreatePricingRule :one
+INSERT INTO pricing_rules (
+	tenant_id, service_id, rule_name, weekdays, window_start, window_end,
+	valid_from, valid_until, kind, adjustment
+) SELECT services.tenant_id, services.service_id, @rule_name, @weekdays,
+	@window_start::interval, @window_end::interval,
+	sqlc.narg(valid_from)::date, sqlc.narg(valid_until)::date,
+	@kind, @adjustment::bigint
+	FROM services
+	WHERE services.tenant_id = @tenant_id AND services.service_id = @service_id
+	AND services.archived_at IS NULL
+	RETURNING rule_id;
+
+-- name: DeletePricingRule :execrows
+DELETE FROM pricing_rules
+	WHERE tenant_id = @tenant_id AND service_id = @service_id
+	AND rule_id = @rule_id;
+
+-- name: GetPricingRules :many
+SELECT rule_id, rule_name, weekdays, window_start, window_end,
+	valid_from, valid_until, kind, adjustment
+	FROM pricing_rules
+	WHERE tenant_id = @tenant_id AND service_id = @service_id
+	ORDER BY created_at, rule_id;
@@ -1,6 +1,14 @@
 
 -- name: CreateReview :exec
-INSERT INTO reviews(account_id, tenant_id, message, rating) VALUES (@account_id, @tenant_id, @message, @rating);
+-- The review is about the last appointment of the customer at the tenant.
+INSERT INTO reviews(account_id, tenant_id, message, rating, appointment_id) VALUES (@account_id, @tenant_id, @message, @rating, (
+	SELECT appointments.appointment_id FROM appointments
+		JOIN services ON services.service_id = appointments.service_id
+		WHERE appointments.account_id = @account_id
+		AND services.tenant_id = @tenant_id
+		AND appointments.status != 'cancelled' AND appointments.starting < NOW()
+		ORDER BY appointments.starting DESC LIMIT 1
+));
 
 -- name: Reviews :many
 SELECT review_id, account_id, message, rating FROM reviews WHERE tenant_id = @tenant_id;
@@ -1,11 +1,32 @@
+-- name: LockSchedule :exec
+-- Serializes the changes of the schedule of the member, the intervals are
+-- validated against each other before they're written, and locking the
+-- interval rows wouldn't cover an empty schedule.
+SELECT account_id FROM accounts WHERE account_id = @account_id FOR UPDATE;
 
--- name: SetSchedule :exec
-INSERT INTO schedules (account_id, weekday, starting_time, ending_time) VALUES (@account_id, @weekday, @starting_time, @ending_time)
-ON CONFLICT(account_id, weekday) DO
-UPDATE SET starting_time = @starting_time, ending_time = @ending_time WHERE schedules.account_id = @account_id AND schedules.weekday = @weekday;
+-- name: ClearSchedule :exec
+DELETE FROM schedules WHERE tenant_id = @tenant_id AND account_id = @account_id;
 
--- name: GetScheduleForWeekday :one
-SELECT starting_time, ending_time FROM schedules WHERE account_id = @account_id AND weekday = @weekday;
+-- name: AddScheduleInterval :one
+INSERT INTO schedules (tenant_id, account_id, weekday, starting_time, ending_time, location_id)
+	VALUES (@tenant_id, @account_id, @weekday, @starting_time, @ending_time, @location_id)
+	RETURNING interval_id;
+
+-- name: UpdateScheduleInterval :execrows
+UPDATE schedules SET weekday = @weekday, starting_time = @starting_time,
+	ending_time = @ending_time, location_id = @location_id
+	WHERE tenant_id = @tenant_id AND account_id = @account_id
+	AND interval_id = @interval_id;
+
+-- name: DeleteScheduleInterval :execrows
+DELETE FROM schedules WHERE tenant_id = @tenant_id AND account_id = @account_id
+	AND interval_id = @interval_id;
 
 -- name: GetSchedule :many
-SELECT weekday, starting_time, ending_time FROM schedules WHERE account_id = @account_id;
+SELECT interval_id, weekday, starting_time, ending_time, location_id
+	FROM schedules WHERE tenant_id = @tenant_id AND account_id = @account_id
+	ORDER BY weekday, starting_time;
+
+
+-- name: DeleteSchedulesOfFormerMember :exec
+DELETE FROM schedules WHERE tenant_id = @tenant_id AND account_id = @account_id;
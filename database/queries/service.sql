@@ -1,15 +1,203 @@
 
 -- name: CreateService :one
 INSERT INTO services (
-	tenant_id, account_id, service_name, price, duration
-) VALUES ( @tenant_id, @account_id, @service_name, @price, @duration ) RETURNING service_id;
+	tenant_id, service_name, price, duration, buffer_before, buffer_after,
+	description, category_id
+) VALUES (
+	@tenant_id, @service_name, @price::bigint, @duration::interval,
+	@buffer_before::interval, @buffer_after::interval,
+	@description, sqlc.narg(category_id)::uuid
+) RETURNING service_id;
+
+-- name: AssignServicePersonnel :execrows
+-- The personnel must be a member of the tenant, assigning them again replaces
+-- their price and duration.
+INSERT INTO service_personnel (service_id, tenant_id, account_id, price, duration)
+	SELECT services.service_id, services.tenant_id, tenant_accounts.account_id,
+		sqlc.narg(price)::bigint, @duration::interval
+	FROM services
+	JOIN tenant_accounts ON tenant_accounts.tenant_id = services.tenant_id
+	WHERE services.tenant_id = @tenant_id AND services.service_id = @service_id
+	AND services.archived_at IS NULL AND tenant_accounts.account_id = @account_id
+	ON CONFLICT (service_id, account_id) DO UPDATE
+		SET price = EXCLUDED.price, duration = EXCLUDED.duration;
+
+-- name: UnassignServicePersonnel :execrows
+DELETE FROM service_personnel
+	WHERE tenant_id = @tenant_id AND service_id = @service_id
+	AND account_id = @account_id;
 
 -- name: GetServicesForTenant :many
-SELECT service_id, account_id, service_name, price, duration FROM services WHERE tenant_id = @tenant_id;
+-- The services with their name and description in the language, if they're
+-- translated to it.
+SELECT services.service_id,
+	COALESCE(service_translations.service_name, services.service_name)::text AS service_name,
+	COALESCE(service_translations.description, services.description)::text AS description,
+	services.category_id, price, duration, buffer_before, buffer_after
+	FROM services
+	LEFT JOIN service_translations ON service_translations.service_id = services.service_id
+		AND service_translations.language = @language
+	WHERE tenant_id = @tenant_id AND archived_at IS NULL
+	ORDER BY service_name, services.service_id;
+
+-- name: GetTenantTranslationLanguages :many
+-- The languages the catalogue of the tenant is translated to.
+SELECT service_translations.language FROM service_translations
+	JOIN services ON services.service_id = service_translations.service_id
+	WHERE services.tenant_id = @tenant_id AND archived_at IS NULL
+UNION
+SELECT category_translations.language FROM category_translations
+	JOIN service_categories ON service_categories.category_id = category_translations.category_id
+	WHERE service_categories.tenant_id = @tenant_id
+ORDER BY language;
+
+-- name: GetServicePersonnelForTenant :many
+SELECT service_personnel.service_id, service_personnel.account_id,
+	COALESCE(service_personnel.price, services.price)::bigint AS price,
+	COALESCE(service_personnel.duration, services.duration)::interval AS duration
+	FROM service_personnel
+	JOIN services ON services.service_id = service_personnel.service_id
+	WHERE services.tenant_id = @tenant_id AND services.archived_at IS NULL
+	ORDER BY service_personnel.account_id;
 
 -- name: GetServices :many
-SELECT service_id, service_name, price, duration FROM services WHERE tenant_id = @tenant_id AND account_id = @account_id;
+SELECT services.service_id, services.service_name,
+	COALESCE(service_personnel.price, services.price)::bigint AS price,
+	COALESCE(service_personnel.duration, services.duration)::interval AS duration
+	FROM service_personnel
+	JOIN services ON services.service_id = service_personnel.service_id
+	WHERE service_personnel.tenant_id = @tenant_id
+	AND service_personnel.account_id = @account_id
+	AND services.archived_at IS NULL;
+
+-- name: ReassignServices :exec
+INSERT INTO service_personnel (service_id, tenant_id, account_id, price, duration)
+	SELECT assigned.service_id, assigned.tenant_id, @new_account_id,
+		assigned.price, assigned.duration
+	FROM service_personnel AS assigned
+	WHERE assigned.tenant_id = @tenant_id AND assigned.account_id = @account_id
+	ON CONFLICT (service_id, account_id) DO NOTHING;
+
+-- name: GetServicePersonnel :many
+-- The members who can do the service, with their price and duration, the
+-- buffers of the service and the slot granularity of the tenant.
+SELECT service_personnel.account_id,
+	COALESCE(service_personnel.price, services.price)::bigint AS price,
+	COALESCE(service_personnel.duration, services.duration)::interval AS duration,
+	services.buffer_before, services.buffer_after, tenants.slot_granularity
+	FROM service_personnel
+	JOIN services ON services.service_id = service_personnel.service_id
+	JOIN tenants ON tenants.tenant_id = services.tenant_id
+	WHERE services.tenant_id = @tenant_id AND services.service_id = @service_id
+	AND services.archived_at IS NULL
+	ORDER BY service_personnel.account_id;
+
+-- name: UpdateService :execrows
+UPDATE services SET service_name = @service_name, price = @price::bigint,
+	duration = @duration::interval, buffer_before = @buffer_before::interval,
+	buffer_after = @buffer_after::interval, description = @description,
+	category_id = sqlc.narg(category_id)::uuid
+	WHERE tenant_id = @tenant_id AND service_id = @service_id
+	AND archived_at IS NULL;
+
+-- name: ArchiveService :execrows
+UPDATE services SET archived_at = NOW()
+	WHERE tenant_id = @tenant_id AND service_id = @service_id
+	AND archived_at IS NULL;
+
+-- name: GetServiceVersions :many
+SELECT version, service_versions.service_name, service_versions.price,
+	service_versions.currency, service_versions.duration, valid_from
+	FROM service_versions
+	JOIN services ON services.service_id = service_versions.service_id
+	WHERE services.tenant_id = @tenant_id
+	AND service_versions.service_id = @service_id
+	ORDER BY version;
+
+-- name: CreateServiceOption :one
+INSERT INTO service_options (
+	tenant_id, service_id, kind, option_name, price, duration
+) SELECT services.tenant_id, services.service_id, @kind, @option_name,
+	@price::bigint, @duration::interval
+	FROM services
+	WHERE services.tenant_id = @tenant_id AND services.service_id = @service_id
+	AND services.archived_at IS NULL
+	RETURNING option_id;
+
+-- name: DeleteServiceOption :execrows
+DELETE FROM service_options
+	WHERE tenant_id = @tenant_id AND service_id = @service_id
+	AND option_id = @option_id;
+
+-- name: GetServiceOptions :many
+SELECT option_id, kind, option_name, price, duration FROM service_options
+	WHERE tenant_id = @tenant_id AND service_id = @service_id
+	ORDER BY kind, option_name;
+
+-- name: GetServiceOptionsForTenant :many
+SELECT service_options.service_id, option_id, kind, option_name,
+	service_options.price, service_options.duration
+	FROM service_options
+	JOIN services ON services.service_id = service_options.service_id
+	WHERE services.tenant_id = @tenant_id AND services.archived_at IS NULL
+	ORDER BY kind, option_name;
+
+-- name: SetServiceTranslation :execrows
+INSERT INTO service_translations (service_id, language, service_name, description)
+	SELECT services.service_id, @language, @service_name, @description
+	FROM services
+	WHERE services.tenant_id = @tenant_id AND services.service_id = @service_id
+	AND services.archived_at IS NULL
+	ON CONFLICT (service_id, language) DO UPDATE
+		SET service_name = EXCLUDED.service_name, description = EXCLUDED.description;
+
+-- name: DeleteServiceTranslation :execrows
+DELETE FROM service_translations USING services
+	WHERE services.service_id = service_translations.service_id
+	AND services.tenant_id = @tenant_id AND service_translations.service_id = @service_id
+	AND service_translations.language = @language;
+
+-- name: CreateServiceCategory :one
+INSERT INTO service_categories (tenant_id, category_name, position)
+	VALUES (@tenant_id, @category_name, @position)
+	RETURNING category_id;
+
+-- name: UpdateServiceCategory :execrows
+UPDATE service_categories SET category_name = @category_name, position = @position
+	WHERE tenant_id = @tenant_id AND category_id = @category_id;
+
+-- name: ClearServiceCategory :exec
+-- The services of the category are left without one.
+UPDATE services SET category_id = NULL
+	WHERE tenant_id = @tenant_id AND category_id = @category_id;
+
+-- name: DeleteServiceCategory :execrows
+DELETE FROM service_categories
+	WHERE tenant_id = @tenant_id AND category_id = @category_id;
+
+-- name: GetServiceCategories :many
+-- The categories with their name in the language, if they're translated to it.
+SELECT service_categories.category_id,
+	COALESCE(category_translations.category_name, service_categories.category_name)::text AS category_name,
+	position
+	FROM service_categories
+	LEFT JOIN category_translations ON category_translations.category_id = service_categories.category_id
+		AND category_translations.language = @language
+	WHERE tenant_id = @tenant_id
+	ORDER BY position, category_name, service_categories.category_id;
 
--- name: GetServiceDurationAndPersonnel :one
-SELECT duration, account_id FROM services WHERE service_id = @service_id;
+-- name: SetCategoryTranslation :execrows
+INSERT INTO category_translations (category_id, language, category_name)
+	SELECT service_categories.category_id, @language, @category_name
+	FROM service_categories
+	WHERE service_categories.tenant_id = @tenant_id
+	AND service_categories.category_id = @category_id
+	ON CONFLICT (category_id, language) DO UPDATE
+		SET category_name = EXCLUDED.category_name;
 
+-- name: DeleteCategoryTranslation :execrows
+DELETE FROM category_translations USING service_categories
+	WHERE service_categories.category_id = category_translations.category_id
+	AND service_categories.tenant_id = @tenant_id
+	AND category_translations.category_id = @category_id
+	AND category_translations.language = @language;
@@ -5,10 +5,7 @@ WITH tmp AS (
 ), is_business AS (
 	SELECT is_business FROM accounts WHERE account_id = $1
 )
-INSERT INTO tenant_accounts (tenant_id, account_id, is_manager) SELECT tenant_id, $1, true FROM tmp, is_business WHERE is_business.is_business = true RETURNING tenant_id;
-
--- name: GetTenants :many
-SELECT tenant_id, tenant_name FROM tenants;
+INSERT INTO tenant_accounts (tenant_id, account_id, is_manager, is_owner) SELECT tenant_id, $1, true, true FROM tmp, is_business WHERE is_business.is_business = true RETURNING tenant_id;
 
 -- name: IsTenantManager :one
 SELECT is_manager FROM tenant_accounts WHERE tenant_id = $1 AND account_id = $2;
@@ -20,7 +17,30 @@ WITH tmp AS (
 INSERT INTO tenant_accounts (tenant_id, account_id, is_manager) SELECT @tenant_id, @new_member_id, @is_manager FROM tmp WHERE tmp.is_manager = true;
 
 -- name: GetTenantMembers :many
-SELECT accounts.account_id, account_name, email, phone, is_manager FROM accounts JOIN tenant_accounts ON accounts.account_id = tenant_accounts.account_id WHERE tenant_id = $1;
+SELECT accounts.account_id, account_name, email, phone, is_manager, is_owner FROM accounts JOIN tenant_accounts ON accounts.account_id = tenant_accounts.account_id WHERE tenant_id = $1;
+
+-- name: IsTenantMember :one
+SELECT EXISTS(
+	SELECT 1 FROM tenant_accounts WHERE tenant_id = @tenant_id AND account_id = @account_id
+);
+
+-- name: IsTenantOwner :one
+SELECT is_owner FROM tenant_accounts WHERE tenant_id = @tenant_id AND account_id = @account_id;
+
+-- name: RemoveTenantMember :execrows
+DELETE FROM tenant_accounts WHERE tenant_id = @tenant_id AND account_id = @account_id AND NOT is_owner;
+
+-- name: SetTenantManager :execrows
+UPDATE tenant_accounts SET is_manager = @is_manager
+	WHERE tenant_id = @tenant_id AND account_id = @account_id AND NOT is_owner;
+
+-- name: ClearTenantOwner :execrows
+UPDATE tenant_accounts SET is_owner = false
+	WHERE tenant_id = @tenant_id AND account_id = @account_id AND is_owner;
+
+-- name: SetTenantOwner :execrows
+UPDATE tenant_accounts SET is_owner = true, is_manager = true
+	WHERE tenant_id = @tenant_id AND account_id = @account_id;
 
 
 
@@ -28,4 +48,26 @@ SELECT accounts.account_id, account_name, email, phone, is_manager FROM accounts
 WITH ratings AS (
 	SELECT tenant_id, AVG(rating) as rating, COUNT(rating) as review_count FROM reviews GROUP BY tenant_id
 )
-SELECT tenants.tenant_id, tenant_name, rating, review_count FROM tenants LEFT JOIN ratings ON tenants.tenant_id = ratings.tenant_id;
+SELECT tenants.tenant_id, tenant_name, verified, rating, review_count FROM tenants LEFT JOIN ratings ON tenants.tenant_id = ratings.tenant_id
+	WHERE status = 'published';
+
+-- name: GetTenantPricing :one
+SELECT currency, vat_rate FROM tenants WHERE tenant_id = @tenant_id;
+
+-- name: SetTenantPricing :execrows
+-- The prices aren't converted, so the currency can't change while the tenant
+-- sells anything.
+UPDATE tenants SET currency = @currency, vat_rate = @vat_rate
+	WHERE tenants.tenant_id = @tenant_id AND (currency = @currency OR (
+		NOT EXISTS(SELECT 1 FROM services
+			WHERE services.tenant_id = @tenant_id AND archived_at IS NULL)
+		AND NOT EXISTS(SELECT 1 FROM packages
+			WHERE packages.tenant_id = @tenant_id AND archived_at IS NULL)
+	));
+
+-- name: GetSlotGranularity :one
+SELECT slot_granularity FROM tenants WHERE tenant_id = @tenant_id;
+
+-- name: SetSlotGranularity :exec
+UPDATE tenants SET slot_granularity = @slot_granularity::interval
+	WHERE tenant_id = @tenant_id;
@@ -0,0 +1,32 @@
+-- name: CreateTimeOff :one
+INSERT INTO time_off_requests (tenant_id, account_id, starting_date, ending_date, reason)
+	VALUES (@tenant_id, @account_id, @starting_date::date, @ending_date::date, @reason)
+	RETURNING time_off_id;
+
+-- name: DecideTimeOff :one
+-- The pending requests are approved or rejected, the approved ones can still
+-- be rejected to cancel them.
+UPDATE time_off_requests SET status = @status, decided_at = NOW(),
+	decided_by = @decided_by
+	WHERE tenant_id = @tenant_id AND time_off_id = @time_off_id
+	AND (status = 'pending' OR (status = 'approved' AND @status = 'rejected'))
+	RETURNING account_id, starting_date, ending_date;
+
+-- name: WithdrawTimeOff :execrows
+DELETE FROM time_off_requests
+	WHERE tenant_id = @tenant_id AND account_id = @account_id
+	AND time_off_id = @time_off_id AND status = 'pending';
+
+-- name: GetTimeOffForAccount :many
+SELECT time_off_id, account_id, starting_date, ending_date, reason, status,
+	created_at, decided_at
+	FROM time_off_requests
+	WHERE tenant_id = @tenant_id AND account_id = @account_id
+	ORDER BY starting_date DESC;
+
+-- name: GetPendingTimeOff :many
+SELECT time_off_id, account_id, starting_date, ending_date, reason, status,
+	created_at, decided_at
+	FROM time_off_requests
+	WHERE tenant_id = @tenant_id AND status = 'pending'
+	ORDER BY starting_date;
@@ -0,0 +1,337 @@
+package schedder
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"gitlab.com/vlad.anghel/schedder-api/database"
+)
+
+// ExceptionInterval represents an interval when a member works on the
+// date of an exception.
+type ExceptionInterval struct {
+	// Starting and Ending represent the time of day of the interval, only the
+	// time of day is used and it's in the time zone of the tenant.
+	Starting time.Time `json:"starting"`
+	Ending   time.Time `json:"ending"`
+	// LocationID represents the location where the personnel works during
+	// the interval, it's optional like for the weekly schedule.
+	LocationID uuid.UUID `json:"location_id,omitempty"`
+}
+
+// CreateExceptionRequest represents the working intervals of a member for a
+// date, replacing the ones of the weekly schedule and any previous exception
+// for the date.
+type CreateExceptionRequest struct {
+	// Date represents the day of the timetable, only the date in the time
+	// zone of the tenant is used.
+	Date time.Time `json:"date"`
+	// Intervals represents the working intervals, none for a day off. They
+	// can also be on a weekday without a schedule, for an extra working day.
+	Intervals []ExceptionInterval `json:"intervals"`
+	// Reason represents the reason shown to the managers, like "sick".
+	Reason string `json:"reason"`
+}
+
+// CreateExceptionResponse represents the response of the exception creation
+// endpoint.
+type CreateExceptionResponse struct {
+	Response
+	ExceptionID uuid.UUID `json:"exception_id"`
+	// ConflictingAppointments represents the pending appointments of the
+	// date that are outside the new working intervals, they're flagged as
+	// conflicting until the working hours cover them again.
+	ConflictingAppointments []uuid.UUID `json:"conflicting_appointments"`
+}
+
+// exceptionEntry represents an exception of the schedule of a member.
+type exceptionEntry struct {
+	ExceptionID uuid.UUID           `json:"exception_id"`
+	Date        time.Time           `json:"date"`
+	Reason      string              `json:"reason"`
+	Intervals   []ExceptionInterval `json:"intervals"`
+}
+
+// ExceptionsResponse represents the upcoming exceptions of the schedule of a
+// member.
+type ExceptionsResponse struct {
+	Response
+	Exceptions []exceptionEntry `json:"exceptions"`
+}
+
+// tenantTimezone returns the time zone of the tenant, the one of its opening
+// hours, schedules and timetables.
+func tenantTimezone(
+	ctx context.Context, queries *database.Queries, tenantID uuid.UUID,
+) (*time.Location, error) {
+	calendar, err := queries.GetTenantCalendar(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return time.LoadLocation(calendar.Timezone)
+}
+
+// dateOf returns the date of the time in the time zone, which is how the days
+// of the timetables are chosen, at midnight UTC like the dates read from the
+// database.
+func dateOf(t time.Time, tz *time.Location) time.Time {
+	year, month, day := t.In(tz).Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// updateConflicts recomputes which pending appointments of the member between
+// the dates are outside their working hours, returning the conflicting ones.
+// The zero until means all the appointments since the first date.
+func updateConflicts(
+	ctx context.Context, queries *database.Queries,
+	tenantID, accountID uuid.UUID, since, until time.Time,
+) ([]uuid.UUID, error) {
+	ucap := database.UpdateConflictingAppointmentsParams{
+		TenantID:  tenantID,
+		AccountID: accountID,
+		Since:     since,
+		Until:     sql.NullTime{Time: until, Valid: !until.IsZero()},
+	}
+	rows, err := queries.UpdateConflictingAppointments(ctx, ucap)
+	if err != nil {
+		return nil, err
+	}
+
+	conflicting := []uuid.UUID{}
+	for _, row := range rows {
+		if row.Conflicting {
+			conflicting = append(conflicting, row.AppointmentID)
+		}
+	}
+	return conflicting, nil
+}
+
+// CreateException sets the working intervals of a member for a date. The
+// pending appointments outside the new intervals are flagged as conflicting,
+// they aren't cancelled, and the ones inside them aren't anymore.
+func (a *API) CreateException(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	accountID := ctx.Value(CtxAccountID).(uuid.UUID)
+	request := ctx.Value(CtxJSON).(*CreateExceptionRequest)
+
+	if request.Date.IsZero() {
+		JsonError(w, http.StatusBadRequest, "invalid date")
+		return
+	}
+	tz, err := tenantTimezone(ctx, a.db, tenantID)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	date := dateOf(request.Date, tz)
+
+	// the intervals are checked like the ones of a weekday
+	intervals := make([]ScheduleInterval, 0, len(request.Intervals))
+	for _, entry := range request.Intervals {
+		intervals = append(intervals, ScheduleInterval{
+			Weekday:    date.Weekday(),
+			Starting:   entry.Starting,
+			Ending:     entry.Ending,
+			LocationID: entry.LocationID,
+		})
+	}
+	if msg := validSchedule(intervals); msg != "" {
+		JsonError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	tx, err := a.txlike.Begin(ctx)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	defer tx.Rollback(ctx)
+	queries := database.New(tx)
+
+	msg, err := validScheduleLocations(
+		ctx, queries, tenantID, accountID, intervals,
+	)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	if msg != "" {
+		JsonError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	defp := database.DeleteExceptionForDateParams{
+		TenantID:      tenantID,
+		AccountID:     accountID,
+		ExceptionDate: date,
+	}
+	err = queries.DeleteExceptionForDate(ctx, defp)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	cep := database.CreateExceptionParams{
+		TenantID:      tenantID,
+		AccountID:     accountID,
+		ExceptionDate: date,
+		Reason:        request.Reason,
+	}
+	exceptionID, err := queries.CreateException(ctx, cep)
+	if err != nil {
+		JsonError(w, http.StatusBadRequest, "couldn't create exception")
+		return
+	}
+
+	for _, entry := range intervals {
+		aeip := database.AddExceptionIntervalParams{
+			ExceptionID:  exceptionID,
+			StartingTime: time.Time{}.Add(timeOfDay(entry.Starting)),
+			EndingTime:   time.Time{}.Add(timeOfDay(entry.Ending)),
+			LocationID: uuid.NullUUID{
+				UUID:  entry.LocationID,
+				Valid: entry.LocationID != uuid.Nil,
+			},
+		}
+		err = queries.AddExceptionInterval(ctx, aeip)
+		if err != nil {
+			JsonError(w, http.StatusBadRequest, "invalid interval")
+			return
+		}
+	}
+
+	conflicting, err := updateConflicts(
+		ctx, queries, tenantID, accountID, date, date,
+	)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "couldn't create exception")
+		return
+	}
+
+	response := CreateExceptionResponse{
+		ExceptionID:             exceptionID,
+		ConflictingAppointments: conflicting,
+	}
+	JsonResp(w, http.StatusCreated, response)
+}
+
+// Exceptions lists the exceptions of the schedule of a member from today on.
+func (a *API) Exceptions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	accountID := ctx.Value(CtxAccountID).(uuid.UUID)
+
+	tz, err := tenantTimezone(ctx, a.db, tenantID)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	since := dateOf(time.Now(), tz)
+
+	gep := database.GetExceptionsParams{
+		TenantID:  tenantID,
+		AccountID: accountID,
+		Since:     since,
+	}
+	rows, err := a.db.GetExceptions(ctx, gep)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	geip := database.GetExceptionIntervalsParams{
+		TenantID:  tenantID,
+		AccountID: accountID,
+		Since:     since,
+	}
+	intervals, err := a.db.GetExceptionIntervals(ctx, geip)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	var response ExceptionsResponse
+	response.Exceptions = make([]exceptionEntry, 0, len(rows))
+	indices := make(map[uuid.UUID]int, len(rows))
+	for _, row := range rows {
+		indices[row.ExceptionID] = len(response.Exceptions)
+		response.Exceptions = append(response.Exceptions, exceptionEntry{
+			ExceptionID: row.ExceptionID,
+			Date:        row.ExceptionDate,
+			Reason:      row.Reason,
+			Intervals:   []ExceptionInterval{},
+		})
+	}
+	for _, interval := range intervals {
+		i, ok := indices[interval.ExceptionID]
+		if !ok {
+			continue
+		}
+		exception := &response.Exceptions[i]
+		exception.Intervals = append(exception.Intervals, ExceptionInterval{
+			Starting:   interval.StartingTime,
+			Ending:     interval.EndingTime,
+			LocationID: interval.LocationID.UUID,
+		})
+	}
+
+	JsonResp(w, http.StatusOK, response)
+}
+
+// DeleteException deletes an exception, the member works on the date like in
+// the weekly schedule again. The conflicts of the pending appointments of the
+// date are recomputed.
+func (a *API) DeleteException(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	accountID := ctx.Value(CtxAccountID).(uuid.UUID)
+	exceptionID := ctx.Value(CtxExceptionID).(uuid.UUID)
+
+	tx, err := a.txlike.Begin(ctx)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	defer tx.Rollback(ctx)
+	queries := database.New(tx)
+
+	dep := database.DeleteExceptionParams{
+		TenantID:    tenantID,
+		AccountID:   accountID,
+		ExceptionID: exceptionID,
+	}
+	date, err := queries.DeleteException(ctx, dep)
+	if errors.Is(err, pgx.ErrNoRows) {
+		JsonError(w, http.StatusNotFound, "invalid exception")
+		return
+	}
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	_, err = updateConflicts(ctx, queries, tenantID, accountID, date, date)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "couldn't delete exception")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
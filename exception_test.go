@@ -0,0 +1,149 @@
+package schedder_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gitlab.com/vlad.anghel/schedder-api"
+)
+
+func TestScheduleExceptions(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantName := "Zâna Măseluță"
+
+	tenantID := api.createTenantAndAccount(email, password, tenantName)
+	accountID := api.findAccountByEmail(email)
+	token := api.generateToken(email, password)
+	api.publishTenant(tenantID)
+	serviceID := api.createService(token, tenantID, accountID, "Tuns", 5000, time.Hour)
+
+	today := localDate(time.Now())
+	tomorrow := today.AddDate(0, 0, 1)
+	api.setSchedule(token, accountID, tenantID, hourOn(today, 10), hourOn(today, 13), today.Weekday())
+
+	tenantEndpoint := fmt.Sprintf("/tenants/%s", tenantID)
+	resp := api.send(
+		token, http.MethodPost, fmt.Sprintf("%s/services/%s/schedule", tenantEndpoint, serviceID),
+		schedder.CreateAppointmentRequest{Starting: hourOn(today, 10)},
+	)
+	var booked schedder.CreateAppointmentResponse
+	err := json.NewDecoder(resp.Body).Decode(&booked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, http.StatusCreated, resp.StatusCode)
+
+	timetable := func(date time.Time) string {
+		resp := api.send(
+			token, http.MethodGet,
+			fmt.Sprintf("%s/services/%s/timetable", tenantEndpoint, serviceID),
+			schedder.TimetableRequest{Date: date},
+		)
+		var response schedder.TimetableResponse
+		err := json.NewDecoder(resp.Body).Decode(&response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect(t, "", response.Error)
+		minutes := make([]int, 0, len(response.Times))
+		for _, slot := range response.Times {
+			minutes = append(minutes, slot.Hour()*60+slot.Minute())
+		}
+		return fmt.Sprint(minutes)
+	}
+
+	endpoint := fmt.Sprintf("%s/personnel/%s/exceptions", tenantEndpoint, accountID)
+	createException := func(date time.Time, intervals ...[2]int) schedder.CreateExceptionResponse {
+		request := schedder.CreateExceptionRequest{
+			Date:      date,
+			Reason:    "test",
+			Intervals: []schedder.ExceptionInterval{},
+		}
+		for _, interval := range intervals {
+			request.Intervals = append(request.Intervals, schedder.ExceptionInterval{
+				Starting: hourOn(date, interval[0]),
+				Ending:   hourOn(date, interval[1]),
+			})
+		}
+		resp := api.send(token, http.MethodPost, endpoint, request)
+		var response schedder.CreateExceptionResponse
+		err := json.NewDecoder(resp.Body).Decode(&response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect(t, "", response.Error)
+		expect(t, http.StatusCreated, resp.StatusCode)
+		return response
+	}
+
+	resp = api.send(token, http.MethodPost, endpoint, schedder.CreateExceptionRequest{
+		Date: tomorrow,
+		Intervals: []schedder.ExceptionInterval{
+			{Starting: hourOn(tomorrow, 9), Ending: hourOn(tomorrow, 12)},
+			{Starting: hourOn(tomorrow, 11), Ending: hourOn(tomorrow, 14)},
+		},
+	})
+	expect(t, http.StatusBadRequest, resp.StatusCode)
+
+	// an extra working day
+	expect(t, "[]", timetable(tomorrow))
+	createException(tomorrow, [2]int{14, 16})
+	expect(t, "[840 870 900]", timetable(tomorrow))
+
+	// custom hours leave the booked appointment outside, it's flagged
+	expect(t, "[660 690 720]", timetable(today))
+	response := createException(today, [2]int{12, 13})
+	expect(t, 1, len(response.ConflictingAppointments))
+	expect(t, booked.AppointmentID, response.ConflictingAppointments[0])
+	expect(t, "[720]", timetable(today))
+
+	// a new exception for the date replaces the previous one, a day off
+	response = createException(today)
+	expect(t, "[]", timetable(today))
+
+	resp = api.send(token, http.MethodGet, endpoint, nil)
+	var exceptions schedder.ExceptionsResponse
+	err = json.NewDecoder(resp.Body).Decode(&exceptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, 2, len(exceptions.Exceptions))
+	expect(t, response.ExceptionID, exceptions.Exceptions[0].ExceptionID)
+	expect(t, 0, len(exceptions.Exceptions[0].Intervals))
+	expect(t, 1, len(exceptions.Exceptions[1].Intervals))
+	expect(t, 14, exceptions.Exceptions[1].Intervals[0].Starting.Hour())
+
+	resp = api.send(token, http.MethodGet, "/accounts/self/appointments", nil)
+	var appointments schedder.AppointmentsResponse
+	err = json.NewDecoder(resp.Body).Decode(&appointments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, 1, len(appointments.Appointments))
+	expect(t, true, appointments.Appointments[0].Conflicting)
+
+	resp = api.send(token, http.MethodDelete, fmt.Sprintf("%s/%s", endpoint, response.ExceptionID), nil)
+	expect(t, http.StatusOK, resp.StatusCode)
+	resp = api.send(token, http.MethodDelete, fmt.Sprintf("%s/%s", endpoint, uuid.New()), nil)
+	expect(t, http.StatusNotFound, resp.StatusCode)
+	expect(t, "[660 690 720]", timetable(today))
+
+	// the weekly schedule covers the appointment again
+	resp = api.send(token, http.MethodGet, "/accounts/self/appointments", nil)
+	appointments = schedder.AppointmentsResponse{}
+	err = json.NewDecoder(resp.Body).Decode(&appointments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, 1, len(appointments.Appointments))
+	expect(t, false, appointments.Appointments[0].Conflicting)
+}
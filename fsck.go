@@ -0,0 +1,313 @@
+package schedder
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"gitlab.com/vlad.anghel/schedder-api/database"
+)
+
+// FsckOptions represents the options of CheckBlobs.
+type FsckOptions struct {
+	// DeleteOrphans enables deleting the orphans older than Grace. Younger
+	// orphans may belong to uploads that didn't commit yet.
+	DeleteOrphans bool
+	Grace         time.Duration
+	// Secondary represents the storage used to restore the missing or
+	// corrupt blobs, nothing is restored if it's nil.
+	Secondary BlobStorage
+}
+
+// FsckReport represents the result of CheckBlobs, written as JSON by the fsck
+// command.
+type FsckReport struct {
+	// Checked represents the number of referenced blobs that were verified,
+	// every variant counts.
+	Checked int `json:"checked"`
+	// Orphans represents the stored blobs that no photo references.
+	Orphans []FsckBlob `json:"orphans"`
+	// Missing represents the referenced blobs that aren't stored.
+	Missing []FsckBlob `json:"missing"`
+	// Corrupt represents the referenced blobs whose sha256 doesn't match,
+	// or the variants that aren't valid images.
+	Corrupt []FsckBlob `json:"corrupt"`
+	// Failed represents the referenced blobs that couldn't be checked, with
+	// the error.
+	Failed []FsckBlob `json:"failed"`
+}
+
+// FsckBlob represents a blob with a problem.
+type FsckBlob struct {
+	// Key represents the key of the blob in the storage.
+	Key string `json:"key"`
+	// Size represents the stored size, for orphans.
+	Size int64 `json:"size,omitempty"`
+	// Modified represents when an orphan was last written, it's zero for
+	// the missing and corrupt blobs.
+	Modified time.Time `json:"modified"`
+	// Deleted represents whether the orphan was deleted.
+	Deleted bool `json:"deleted,omitempty"`
+	// Restored represents whether the blob was restored from the secondary
+	// storage.
+	Restored bool `json:"restored,omitempty"`
+	// Error represents why the blob couldn't be deleted or restored.
+	Error string `json:"error,omitempty"`
+}
+
+// Clean reports whether all the problems found were fixed.
+func (r *FsckReport) Clean() bool {
+	if len(r.Failed) > 0 {
+		return false
+	}
+	for _, b := range r.Orphans {
+		if !b.Deleted {
+			return false
+		}
+	}
+	for _, b := range r.Missing {
+		if !b.Restored {
+			return false
+		}
+	}
+	for _, b := range r.Corrupt {
+		if !b.Restored {
+			return false
+		}
+	}
+	return true
+}
+
+// hashOfKey returns the hex encoded hash that the key of the blob starts
+// with.
+func hashOfKey(key string) string {
+	hash, _, _ := strings.Cut(key, "-")
+	return hash
+}
+
+// validBlob reports whether the content matches the key. The originals must
+// match the hash, the variants can't as the hash is of the original, so they
+// only have to be valid images.
+func validBlob(key string, r io.Reader) (bool, error) {
+	if hashOfKey(key) != key {
+		_, _, err := image.DecodeConfig(r)
+		return err == nil, nil
+	}
+
+	h := sha256.New()
+	_, err := io.Copy(h, r)
+	if err != nil {
+		return false, err
+	}
+	return hex.EncodeToString(h.Sum(nil)) == key, nil
+}
+
+// verifyBlob reports whether the content of the stored blob matches the key.
+func verifyBlob(ctx context.Context, storage BlobStorage, key string) (bool, error) {
+	blob, err := storage.Get(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	defer blob.Close()
+
+	return validBlob(key, blob)
+}
+
+// restoreBlob copies the blob from the secondary storage, if its content is
+// valid there.
+func restoreBlob(
+	ctx context.Context, storage, secondary BlobStorage, key string,
+) error {
+	blob, err := secondary.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(blob)
+	blob.Close()
+	if err != nil {
+		return err
+	}
+
+	valid, err := validBlob(key, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return fmt.Errorf("corrupt in the secondary storage")
+	}
+
+	return storage.Put(ctx, key, bytes.NewReader(data), int64(len(data)))
+}
+
+// CheckBlobs compares the blobs referenced by the database with the stored
+// ones. It checks every variant of every referenced photo, verifying the
+// sha256 of the originals and that the other variants are images, as their
+// hash is of the original. A blob that can't be checked is reported as failed
+// and the check goes on with the others.
+func CheckBlobs(
+	ctx context.Context,
+	queries *database.Queries,
+	storage BlobStorage,
+	options FsckOptions,
+) (FsckReport, error) {
+	report := FsckReport{
+		Orphans: []FsckBlob{},
+		Missing: []FsckBlob{},
+		Corrupt: []FsckBlob{},
+		Failed:  []FsckBlob{},
+	}
+
+	// The database is read first, so blobs committed meanwhile are seen as
+	// orphans, which are young enough to be kept, instead of as missing.
+	rows, err := queries.ListBlobs(ctx)
+	if err != nil {
+		return report, err
+	}
+	referenced := make(map[string][]byte, len(rows))
+	for _, row := range rows {
+		if row.Refcount > 0 {
+			referenced[hex.EncodeToString(row.Sha256sum)] = row.Sha256sum
+		}
+	}
+
+	stored := make(map[string]BlobInfo)
+	err = storage.List(ctx, func(key string, info BlobInfo) error {
+		stored[key] = info
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	restore := func(b *FsckBlob) {
+		if options.Secondary == nil {
+			return
+		}
+		err := restoreBlob(ctx, storage, options.Secondary, b.Key)
+		if err != nil {
+			b.Error = err.Error()
+			return
+		}
+		b.Restored = true
+	}
+
+	for _, hash := range referenced {
+		for size := range photoSizes {
+			key := photoKey(hash, size)
+			report.Checked++
+			b := FsckBlob{Key: key}
+			if _, ok := stored[key]; !ok {
+				restore(&b)
+				report.Missing = append(report.Missing, b)
+				continue
+			}
+
+			valid, err := verifyBlob(ctx, storage, key)
+			if err != nil {
+				b.Error = err.Error()
+				report.Failed = append(report.Failed, b)
+				continue
+			}
+			if !valid {
+				restore(&b)
+				report.Corrupt = append(report.Corrupt, b)
+			}
+		}
+	}
+
+	deleteBefore := time.Now().Add(-options.Grace)
+	for key, info := range stored {
+		if _, ok := referenced[hashOfKey(key)]; ok {
+			continue
+		}
+
+		b := FsckBlob{Key: key, Size: info.Size, Modified: info.Modified}
+		if options.DeleteOrphans && !info.Modified.After(deleteBefore) {
+			err := storage.Delete(ctx, key)
+			if err != nil {
+				b.Error = err.Error()
+			} else {
+				b.Deleted = true
+			}
+		}
+		report.Orphans = append(report.Orphans, b)
+	}
+
+	for _, blobs := range [][]FsckBlob{
+		report.Orphans, report.Missing, report.Corrupt, report.Failed,
+	} {
+		sort.Slice(blobs, func(i, j int) bool {
+			return blobs[i].Key < blobs[j].Key
+		})
+	}
+
+	return report, nil
+}
+
+// Fsck runs the fsck command, which checks the consistency of the photo
+// storage and the database, writing the report as JSON to stdout. It returns
+// the exit code: 0 if there are no problems left, 1 if there are and 2 if the
+// check failed.
+func Fsck(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("fsck", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	deleteOrphans := flags.Bool(
+		"delete-orphans", false, "delete the orphans older than the grace period",
+	)
+	grace := flags.Duration(
+		"grace", 24*time.Hour, "the grace period of the orphans",
+	)
+	restoreFrom := flags.String(
+		"restore-from", "",
+		"a directory to restore the missing or corrupt blobs from",
+	)
+	err := flags.Parse(args)
+	if err != nil {
+		return 2
+	}
+
+	options := FsckOptions{DeleteOrphans: *deleteOrphans, Grace: *grace}
+	if *restoreFrom != "" {
+		options.Secondary = &FSStorage{dir: *restoreFrom}
+	}
+
+	postgresURI := RequiredEnv(
+		"SCHEDDER_POSTGRES", "postgres://user@localhost/schedder_db",
+	)
+	ctx := context.Background()
+	conn, err := pgxpool.Connect(ctx, postgresURI)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	defer conn.Close()
+
+	report, err := CheckBlobs(ctx, database.New(conn), storageFromEnv(), options)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	encoder := json.NewEncoder(stdout)
+	encoder.SetIndent("", "\t")
+	err = encoder.Encode(report)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	if !report.Clean() {
+		return 1
+	}
+	return 0
+}
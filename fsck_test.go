@@ -0,0 +1,164 @@
+package schedder_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gitlab.com/vlad.anghel/schedder-api"
+)
+
+// findFsckBlob returns the blob with the key from the list.
+func findFsckBlob(blobs []schedder.FsckBlob, key string) (schedder.FsckBlob, bool) {
+	for _, b := range blobs {
+		if b.Key == key {
+			return b, true
+		}
+	}
+	return schedder.FsckBlob{}, false
+}
+
+func TestCheckBlobs(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantName := "Zâna Măseluță"
+
+	tenantID := api.createTenantAndAccount(email, password, tenantName)
+	api.publishTenant(tenantID)
+	token := api.generateToken(email, password)
+
+	photoID := api.addTenantPhoto(
+		token, tenantID, bytes.NewReader(testImage(t, 40, 30)),
+	)
+
+	endpoint := fmt.Sprintf("/tenants/%s/photos/by-id/%s", tenantID, photoID)
+	r := httptest.NewRequest(http.MethodGet, endpoint, nil)
+	w := httptest.NewRecorder()
+
+	api.ServeHTTP(w, r)
+
+	resp := w.Result()
+	expect(t, http.StatusOK, resp.StatusCode)
+	key := strings.Trim(resp.Header.Get("ETag"), `"`)
+	original, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	storage := api.Storage()
+
+	orphan := strings.Repeat("ab", 32)
+	err = storage.Put(ctx, orphan, strings.NewReader("orphan"), 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupt := []byte("corrupt")
+	err = storage.Put(ctx, key, bytes.NewReader(corrupt), int64(len(corrupt)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the variants are checked too
+	thumbnailKey := key + "-" + schedder.PhotoSizeThumbnail
+	mediumKey := key + "-" + schedder.PhotoSizeMedium
+	blob, err := storage.Get(ctx, thumbnailKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	thumbnail, err := io.ReadAll(blob)
+	blob.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = storage.Delete(ctx, thumbnailKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = storage.Put(ctx, mediumKey, bytes.NewReader(corrupt), int64(len(corrupt)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := schedder.CheckBlobs(
+		ctx, api.DB(), storage, schedder.FsckOptions{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, found := findFsckBlob(report.Orphans, orphan)
+	expect(t, true, found)
+	expect(t, false, b.Deleted)
+	_, found = findFsckBlob(report.Orphans, mediumKey)
+	expect(t, false, found)
+	_, found = findFsckBlob(report.Corrupt, key)
+	expect(t, true, found)
+	_, found = findFsckBlob(report.Missing, thumbnailKey)
+	expect(t, true, found)
+	_, found = findFsckBlob(report.Corrupt, mediumKey)
+	expect(t, true, found)
+	expect(t, 0, len(report.Failed))
+	expect(t, false, report.Clean())
+
+	secondary, err := schedder.NewFSStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = secondary.Put(
+		ctx, key, bytes.NewReader(original), int64(len(original)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = secondary.Put(
+		ctx, thumbnailKey, bytes.NewReader(thumbnail), int64(len(thumbnail)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options := schedder.FsckOptions{DeleteOrphans: true, Secondary: secondary}
+	report, err = schedder.CheckBlobs(ctx, api.DB(), storage, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, _ = findFsckBlob(report.Orphans, orphan)
+	expect(t, true, b.Deleted)
+	b, _ = findFsckBlob(report.Corrupt, key)
+	expect(t, true, b.Restored)
+	b, _ = findFsckBlob(report.Missing, thumbnailKey)
+	expect(t, true, b.Restored)
+	// the medium variant isn't in the secondary storage
+	b, _ = findFsckBlob(report.Corrupt, mediumKey)
+	expect(t, false, b.Restored)
+	unexpect(t, "", b.Error)
+
+	_, err = storage.Stat(ctx, orphan)
+	expect(t, schedder.ErrBlobNotFound, err)
+
+	err = storage.Delete(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err = schedder.CheckBlobs(
+		ctx, api.DB(), storage, schedder.FsckOptions{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, found = findFsckBlob(report.Missing, key)
+	expect(t, true, found)
+}
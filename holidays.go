@@ -0,0 +1,76 @@
+package schedder
+
+import "time"
+
+// HolidayCalendarRO represents the public holidays of Romania, as defined by
+// the Labour Code (Codul Muncii, art. 139).
+const HolidayCalendarRO = "RO"
+
+// orthodoxEaster returns the date of the Orthodox Easter Sunday, in the
+// Gregorian calendar. It uses Meeus' Julian algorithm, so it's valid only for
+// the years between 1900 and 2099.
+func orthodoxEaster(year int) time.Time {
+	a := year % 4
+	b := year % 7
+	c := year % 19
+	d := (19*c + 15) % 30
+	e := (2*a + 4*b - d + 34) % 7
+	month := (d + e + 114) / 31
+	day := (d+e+114)%31 + 1
+
+	// The Julian calendar is 13 days behind between 1900 and 2099, time.Date
+	// normalizes the overflowing day.
+	return time.Date(year, time.Month(month), day+13, 0, 0, 0, 0, time.UTC)
+}
+
+// romanianHolidays returns the public holidays of Romania in the year.
+func romanianHolidays(year int) []time.Time {
+	date := func(month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+	easter := orthodoxEaster(year)
+
+	holidays := []time.Time{
+		date(time.January, 1),
+		date(time.January, 2),
+		// Ziua Unirii Principatelor Române
+		date(time.January, 24),
+		easter,
+		easter.AddDate(0, 0, 1),
+		date(time.May, 1),
+		// Ziua Copilului
+		date(time.June, 1),
+		// Rusaliile
+		easter.AddDate(0, 0, 49),
+		easter.AddDate(0, 0, 50),
+		// Adormirea Maicii Domnului
+		date(time.August, 15),
+		// Sfântul Andrei
+		date(time.November, 30),
+		// Ziua Națională
+		date(time.December, 1),
+		date(time.December, 25),
+		date(time.December, 26),
+	}
+	if year >= 2018 {
+		// Vinerea Mare
+		holidays = append(holidays, easter.AddDate(0, 0, -2))
+	}
+	if year >= 2024 {
+		// Boboteaza and Sfântul Ioan Botezătorul
+		holidays = append(holidays, date(time.January, 6), date(time.January, 7))
+	}
+
+	return holidays
+}
+
+// publicHolidays returns the public holidays of the calendar in the year, as
+// dates at midnight UTC. Unknown calendars don't have any holidays.
+func publicHolidays(calendar string, year int) []time.Time {
+	switch calendar {
+	case HolidayCalendarRO:
+		return romanianHolidays(year)
+	default:
+		return nil
+	}
+}
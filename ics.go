@@ -0,0 +1,156 @@
+package schedder
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// icsEvent represents an event from an iCalendar file.
+type icsEvent struct {
+	starting time.Time
+	ending   time.Time
+	summary  string
+}
+
+// icsProperty represents a content line of an iCalendar file, like
+// DTSTART;TZID=Europe/Bucharest:20230101T090000.
+type icsProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// parseICSLine parses an unfolded content line.
+func parseICSLine(line string) (icsProperty, error) {
+	var p icsProperty
+
+	// The value starts after the first colon that isn't quoted.
+	quoted := false
+	colon := -1
+	for i, c := range line {
+		if c == '"' {
+			quoted = !quoted
+		} else if c == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon == -1 {
+		return p, errors.New("invalid content line")
+	}
+
+	p.value = line[colon+1:]
+	parts := strings.Split(line[:colon], ";")
+	p.name = strings.ToUpper(parts[0])
+	p.params = make(map[string]string, len(parts)-1)
+	for _, param := range parts[1:] {
+		key, value, found := strings.Cut(param, "=")
+		if !found {
+			return p, errors.New("invalid parameter")
+		}
+		p.params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+	}
+
+	return p, nil
+}
+
+// parseICSTime parses the value of DTSTART or DTEND. Dates and times without
+// a time zone are in loc. It also reports whether the value was a date.
+func parseICSTime(p icsProperty, loc *time.Location) (time.Time, bool, error) {
+	if p.params["VALUE"] == "DATE" || len(p.value) == len("20060102") {
+		t, err := time.ParseInLocation("20060102", p.value, loc)
+		return t, true, err
+	}
+
+	if strings.HasSuffix(p.value, "Z") {
+		t, err := time.Parse("20060102T150405Z", p.value)
+		return t, false, err
+	}
+
+	if tzid, ok := p.params["TZID"]; ok {
+		var err error
+		loc, err = time.LoadLocation(tzid)
+		if err != nil {
+			return time.Time{}, false, err
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", p.value, loc)
+	return t, false, err
+}
+
+// unescapeICSText reverses the escaping of TEXT values.
+var unescapeICSText = strings.NewReplacer(
+	`\\`, `\`, `\;`, `;`, `\,`, `,`, `\n`, "\n", `\N`, "\n",
+)
+
+// parseICS parses the events of an iCalendar file, as described by
+// https://www.rfc-editor.org/rfc/rfc5545. Only DTSTART, DTEND and SUMMARY are
+// used, recurrences aren't expanded. Events with only a start date last the
+// whole day.
+func parseICS(r io.Reader, loc *time.Location) ([]icsEvent, error) {
+	scanner := bufio.NewScanner(r)
+
+	// Long lines are folded by starting the next line with a whitespace.
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") ||
+			strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var events []icsEvent
+	var event *icsEvent
+	allDay := false
+	for _, line := range lines {
+		p, err := parseICSLine(line)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case p.name == "BEGIN" && strings.EqualFold(p.value, "VEVENT"):
+			event = new(icsEvent)
+			allDay = false
+		case p.name == "END" && strings.EqualFold(p.value, "VEVENT"):
+			if event == nil || event.starting.IsZero() {
+				return nil, errors.New("event without start")
+			}
+			if event.ending.IsZero() && allDay {
+				event.ending = event.starting.AddDate(0, 0, 1)
+			}
+			if !event.starting.Before(event.ending) {
+				return nil, errors.New("event without duration")
+			}
+			events = append(events, *event)
+			event = nil
+		case event == nil:
+			// properties of the calendar itself, or of other components
+		case p.name == "DTSTART":
+			event.starting, allDay, err = parseICSTime(p, loc)
+			if err != nil {
+				return nil, err
+			}
+		case p.name == "DTEND":
+			event.ending, _, err = parseICSTime(p, loc)
+			if err != nil {
+				return nil, err
+			}
+		case p.name == "SUMMARY":
+			event.summary = unescapeICSText.Replace(p.value)
+		}
+	}
+
+	return events, nil
+}
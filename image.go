@@ -0,0 +1,253 @@
+package schedder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Sizes of the stored variants of a photo, selected by the size query
+// parameter of the download endpoints.
+const (
+	// PhotoSizeThumbnail fits in a 256x256 square, for lists.
+	PhotoSizeThumbnail = "thumbnail"
+	// PhotoSizeMedium fits in a 1024x1024 square, for pages.
+	PhotoSizeMedium = "medium"
+	// PhotoSizeOriginal keeps the dimensions of the upload.
+	PhotoSizeOriginal = "original"
+)
+
+// photoSizes maps the sizes of the variants to the maximum length of their
+// sides, 0 meaning unchanged.
+var photoSizes = map[string]int{
+	PhotoSizeThumbnail: 256,
+	PhotoSizeMedium:    1024,
+	PhotoSizeOriginal:  0,
+}
+
+// maxImagePixels limits the dimensions of uploaded images, so that small
+// files can't decode into huge images.
+const maxImagePixels = 50_000_000
+
+var errInvalidImage = errors.New("invalid image")
+
+// exifHeaderSize limits how much of a JPEG file is searched for the EXIF
+// metadata, which has to be in the first segments.
+const exifHeaderSize = 128 << 10
+
+// processImage decodes an uploaded JPEG, PNG or WebP image and re-encodes it
+// as all the variants in photoSizes, writing each to the writer returned by
+// dst. It returns the MIME type of the variants. Re-encoding drops all the
+// metadata, like EXIF, but the orientation from EXIF is applied to the pixels
+// first.
+func processImage(
+	src io.ReadSeeker, dst func(size string) io.Writer,
+) (string, error) {
+	header, err := io.ReadAll(io.LimitReader(src, exifHeaderSize))
+	if err != nil {
+		return "", err
+	}
+	switch http.DetectContentType(header) {
+	case "image/jpeg", "image/png", "image/webp":
+	default:
+		return "", errInvalidImage
+	}
+
+	if _, err = src.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	config, _, err := image.DecodeConfig(src)
+	if err != nil {
+		return "", errInvalidImage
+	}
+	if config.Width <= 0 || config.Height <= 0 ||
+		config.Width*config.Height > maxImagePixels {
+		return "", errInvalidImage
+	}
+
+	if _, err = src.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	img, format, err := image.Decode(src)
+	if err != nil {
+		return "", errInvalidImage
+	}
+	if format == "jpeg" {
+		img = orient(img, exifOrientation(header))
+	}
+
+	// All the variants have the same type, opaque images are stored as JPEG
+	// and the rest as PNG, to keep the transparency.
+	mimeType := "image/png"
+	if o, ok := img.(interface{ Opaque() bool }); ok && o.Opaque() {
+		mimeType = "image/jpeg"
+	}
+
+	for size, max := range photoSizes {
+		err = encodeImage(dst(size), resizeImage(img, max), mimeType)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return mimeType, nil
+}
+
+// resizeImage scales the image down to fit in a max x max square, keeping the
+// aspect ratio. Smaller images are returned as they are.
+func resizeImage(img image.Image, max int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if max == 0 || (width <= max && height <= max) {
+		return img
+	}
+
+	if width > height {
+		height = height * max / width
+		width = max
+	} else {
+		width = width * max / height
+		height = max
+	}
+	if width == 0 {
+		width = 1
+	}
+	if height == 0 {
+		height = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
+
+// encodeImage encodes the image as JPEG or PNG, depending on the MIME type.
+func encodeImage(w io.Writer, img image.Image, mimeType string) error {
+	if mimeType == "image/jpeg" {
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: 85})
+	}
+	return png.Encode(w, img)
+}
+
+// exifOrientation returns the orientation tag from the EXIF metadata of a
+// JPEG file, as described by the TIFF 6.0 specification. It returns 1, the
+// normal orientation, if the tag is missing or the metadata is invalid. The
+// data can be just the start of the file.
+func exifOrientation(data []byte) int {
+	const (
+		markerSOS  = 0xDA
+		markerAPP1 = 0xE1
+		tagOrient  = 0x0112
+	)
+
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if marker == markerSOS || length < 2 || i+2+length > len(data) {
+			return 1
+		}
+		segment := data[i+4 : i+2+length]
+		i += 2 + length
+
+		if marker != markerAPP1 || !bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			continue
+		}
+
+		tiff := segment[6:]
+		if len(tiff) < 8 {
+			return 1
+		}
+		var order binary.ByteOrder
+		switch string(tiff[:2]) {
+		case "II":
+			order = binary.LittleEndian
+		case "MM":
+			order = binary.BigEndian
+		default:
+			return 1
+		}
+
+		ifd := int(order.Uint32(tiff[4:]))
+		if ifd+2 > len(tiff) {
+			return 1
+		}
+		entries := int(order.Uint16(tiff[ifd:]))
+		for e := 0; e < entries; e++ {
+			entry := ifd + 2 + e*12
+			if entry+12 > len(tiff) {
+				return 1
+			}
+			if order.Uint16(tiff[entry:]) != tagOrient {
+				continue
+			}
+			orientation := int(order.Uint16(tiff[entry+8:]))
+			if orientation < 1 || orientation > 8 {
+				return 1
+			}
+			return orientation
+		}
+		return 1
+	}
+
+	return 1
+}
+
+// orient transforms the image so that it's displayed correctly without the
+// EXIF orientation tag.
+func orient(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+
+	bounds := img.Bounds()
+	src := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+
+	w, h := bounds.Dx(), bounds.Dy()
+	dstWidth, dstHeight := w, h
+	if orientation >= 5 {
+		// the orientations from 5 to 8 swap the sides
+		dstWidth, dstHeight = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+			copy(dst.Pix[dst.PixOffset(dx, dy):][:4], src.Pix[src.PixOffset(x, y):][:4])
+		}
+	}
+
+	return dst
+}
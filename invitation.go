@@ -0,0 +1,346 @@
+package schedder
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"net/http"
+	"net/mail"
+	"time"
+
+	"github.com/google/uuid"
+	"gitlab.com/vlad.anghel/schedder-api/database"
+)
+
+// CreateInvitationRequest represents a request to invite somebody to join a
+// tenant. Exactly one of Email or Phone should be set.
+type CreateInvitationRequest struct {
+	// Email represents the email of the invited person.
+	Email string `json:"email,omitempty"`
+	// Phone represents the phone number of the invited person.
+	Phone string `json:"phone,omitempty"`
+}
+
+// CreateInvitationResponse represents the response of the invitation creation
+// endpoint.
+type CreateInvitationResponse struct {
+	Response
+	// InvitationID represents the ID of the newly created invitation.
+	InvitationID uuid.UUID `json:"invitation_id"`
+	// ExpirationDate represents when the invitation stops being valid.
+	ExpirationDate time.Time `json:"expiration_date"`
+}
+
+// tenantInvitationResponse represents an invitation from the viewpoint of a
+// tenant manager.
+type tenantInvitationResponse struct {
+	// InvitationID represents the ID of the invitation.
+	InvitationID uuid.UUID `json:"invitation_id"`
+	// InvitedBy represents the ID of the manager that sent the invitation.
+	InvitedBy uuid.UUID `json:"invited_by"`
+	// Email represents the email of the invited person.
+	Email string `json:"email,omitempty"`
+	// Phone represents the phone number of the invited person.
+	Phone string `json:"phone,omitempty"`
+	// Status represents the status of the invitation, one of: pending,
+	// accepted, declined, revoked.
+	Status string `json:"status"`
+	// ExpirationDate represents when the invitation stops being valid.
+	ExpirationDate time.Time `json:"expiration_date"`
+}
+
+// TenantInvitationsResponse represents the response of the tenant invitation
+// listing endpoint.
+type TenantInvitationsResponse struct {
+	Response
+	// Invitations represents the list of invitations sent by the tenant.
+	Invitations []tenantInvitationResponse `json:"invitations"`
+}
+
+// invitationResponse represents an invitation from the viewpoint of the
+// invited person.
+type invitationResponse struct {
+	// InvitationID represents the ID of the invitation.
+	InvitationID uuid.UUID `json:"invitation_id"`
+	// TenantID represents the ID of the tenant that sent the invitation.
+	TenantID uuid.UUID `json:"tenant_id"`
+	// TenantName represents the name of the tenant that sent the invitation.
+	TenantName string `json:"tenant_name"`
+	// ExpirationDate represents when the invitation stops being valid.
+	ExpirationDate time.Time `json:"expiration_date"`
+}
+
+// InvitationsResponse represents the response of the endpoint listing the
+// pending invitations of the authenticated account.
+type InvitationsResponse struct {
+	Response
+	// Invitations represents the list of pending invitations.
+	Invitations []invitationResponse `json:"invitations"`
+}
+
+// AcceptInvitationRequest represents a request to accept an invitation.
+type AcceptInvitationRequest struct {
+	// Code represents the code that was sent along with the invitation.
+	Code string `json:"code"`
+}
+
+// AcceptInvitationResponse represents the response of the invitation accepting
+// endpoint.
+type AcceptInvitationResponse struct {
+	Response
+	// TenantID represents the ID of the tenant that was joined.
+	TenantID uuid.UUID `json:"tenant_id"`
+}
+
+// generateInvitationCode generates a random code for an invitation. Unlike
+// verification codes, invitation codes are long lived and can be used for
+// signing up, so they are much longer.
+func generateInvitationCode() (string, error) {
+	var b [24]byte
+	_, err := rand.Read(b[:])
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b[:]), nil
+}
+
+// joinByInvitation activates a freshly created account and makes it a member
+// of the tenant that invited it. Receiving the invitation code already proves
+// that the email or phone belongs to the account, so there is no need for an
+// additional verification code.
+func joinByInvitation(
+	ctx context.Context, queries *database.Queries, accountID uuid.UUID,
+	code string,
+) error {
+	err := queries.ActivateAccount(ctx, accountID)
+	if err != nil {
+		return err
+	}
+
+	params := database.AcceptInvitationByCodeParams{
+		AccountID: accountID,
+		Code:      code,
+	}
+	_, err = queries.AcceptInvitationByCode(ctx, params)
+	return err
+}
+
+// CreateInvitation invites somebody, by email or phone, to join the tenant.
+// The invitation code is sent using the corresponding Verifier, inviting the
+// same person again replaces the previous pending invitation.
+func (a *API) CreateInvitation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	authenticatedID := ctx.Value(CtxAuthenticatedID).(uuid.UUID)
+	request := ctx.Value(CtxJSON).(*CreateInvitationRequest)
+
+	params := database.CreateInvitationParams{
+		TenantID:  tenantID,
+		InvitedBy: authenticatedID,
+	}
+
+	var verifier Verifier
+	var target string
+	if request.Email != "" {
+		_, err := mail.ParseAddress(request.Email)
+		if err != nil {
+			JsonError(w, http.StatusBadRequest, "invalid email")
+			return
+		}
+		target = request.Email
+		params.Email = sql.NullString{String: target, Valid: true}
+		verifier = a.emailVerifier
+	} else if request.Phone != "" {
+		phone, ok := normalizePhone(request.Phone)
+		if !ok {
+			JsonError(w, http.StatusBadRequest, "phone too short/long")
+			return
+		}
+		target = phone
+		params.Phone = sql.NullString{String: target, Valid: true}
+		verifier = a.phoneVerifier
+	} else {
+		JsonError(w, http.StatusBadRequest, "expected phone or email")
+		return
+	}
+
+	code, err := generateInvitationCode()
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "couldn't generate code")
+		return
+	}
+	params.Code = code
+
+	tx, err := a.txlike.Begin(ctx)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	defer tx.Rollback(ctx)
+	queries := database.New(tx)
+
+	rpip := database.RevokePendingInvitationsParams{
+		TenantID: tenantID,
+		Email:    params.Email,
+		Phone:    params.Phone,
+	}
+	err = queries.RevokePendingInvitations(ctx, rpip)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	row, err := queries.CreateInvitation(ctx, params)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "couldn't create invitation")
+		return
+	}
+
+	// Same assumption as when creating accounts: if the code can't be sent
+	// then the email or phone is invalid.
+	err = verifier.SendVerification(target, code)
+	if err != nil {
+		JsonError(w, http.StatusBadRequest, "couldn't send invitation")
+		return
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "couldn't create invitation")
+		return
+	}
+
+	response := CreateInvitationResponse{
+		InvitationID:   row.InvitationID,
+		ExpirationDate: row.ExpirationDate,
+	}
+	JsonResp(w, http.StatusCreated, response)
+}
+
+// TenantInvitations lists the invitations sent by a tenant.
+func (a *API) TenantInvitations(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+
+	rows, err := a.db.GetTenantInvitations(ctx, tenantID)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "couldn't get invitations")
+		return
+	}
+
+	var response TenantInvitationsResponse
+	response.Invitations = make([]tenantInvitationResponse, 0, len(rows))
+	for i := range rows {
+		row := &rows[i]
+		invitation := tenantInvitationResponse{
+			InvitationID:   row.InvitationID,
+			InvitedBy:      row.InvitedBy,
+			Status:         string(row.Status),
+			ExpirationDate: row.ExpirationDate,
+		}
+		if row.Email.Valid {
+			invitation.Email = row.Email.String
+		}
+		if row.Phone.Valid {
+			invitation.Phone = row.Phone.String
+		}
+		response.Invitations = append(response.Invitations, invitation)
+	}
+
+	JsonResp(w, http.StatusOK, response)
+}
+
+// RevokeInvitation revokes a pending invitation of the tenant.
+func (a *API) RevokeInvitation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	invitationID := ctx.Value(CtxInvitationID).(uuid.UUID)
+
+	params := database.RevokeInvitationParams{
+		InvitationID: invitationID,
+		TenantID:     tenantID,
+	}
+	affected, err := a.db.RevokeInvitation(ctx, params)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "couldn't revoke invitation")
+		return
+	}
+	if affected != 1 {
+		JsonError(w, http.StatusNotFound, "invalid invitation")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Invitations lists the pending invitations of the authenticated account.
+func (a *API) Invitations(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	authenticatedID := ctx.Value(CtxAuthenticatedID).(uuid.UUID)
+
+	rows, err := a.db.GetInvitationsForAccount(ctx, authenticatedID)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "couldn't get invitations")
+		return
+	}
+
+	var response InvitationsResponse
+	response.Invitations = make([]invitationResponse, 0, len(rows))
+	for i := range rows {
+		row := &rows[i]
+		response.Invitations = append(response.Invitations, invitationResponse{
+			InvitationID:   row.InvitationID,
+			TenantID:       row.TenantID,
+			TenantName:     row.TenantName,
+			ExpirationDate: row.ExpirationDate,
+		})
+	}
+
+	JsonResp(w, http.StatusOK, response)
+}
+
+// AcceptInvitation makes the authenticated account a member of the tenant that
+// sent the invitation.
+func (a *API) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	authenticatedID := ctx.Value(CtxAuthenticatedID).(uuid.UUID)
+	invitationID := ctx.Value(CtxInvitationID).(uuid.UUID)
+	request := ctx.Value(CtxJSON).(*AcceptInvitationRequest)
+
+	params := database.AcceptInvitationParams{
+		AccountID:    authenticatedID,
+		InvitationID: invitationID,
+		Code:         request.Code,
+	}
+	tenantID, err := a.db.AcceptInvitation(ctx, params)
+	if err != nil {
+		JsonError(w, http.StatusBadRequest, "invalid invitation")
+		return
+	}
+
+	JsonResp(w, http.StatusOK, AcceptInvitationResponse{TenantID: tenantID})
+}
+
+// DeclineInvitation declines an invitation sent to the authenticated account.
+func (a *API) DeclineInvitation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	authenticatedID := ctx.Value(CtxAuthenticatedID).(uuid.UUID)
+	invitationID := ctx.Value(CtxInvitationID).(uuid.UUID)
+
+	params := database.DeclineInvitationParams{
+		InvitationID: invitationID,
+		AccountID:    authenticatedID,
+	}
+	affected, err := a.db.DeclineInvitation(ctx, params)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "couldn't decline invitation")
+		return
+	}
+	if affected != 1 {
+		JsonError(w, http.StatusNotFound, "invalid invitation")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
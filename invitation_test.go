@@ -0,0 +1,220 @@
+package schedder_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gitlab.com/vlad.anghel/schedder-api"
+)
+
+func TestCreateInvitation(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantName := "Zâna Măseluță"
+
+	tenantID := api.createTenantAndAccount(email, password, tenantName)
+	token := api.generateToken(email, password)
+
+	inviteeEmail := "invitee@example.com"
+	invitationID := api.createInvitation(token, tenantID, inviteeEmail)
+
+	unexpect(t, "", api.codes[inviteeEmail])
+
+	endpoint := fmt.Sprintf("/tenants/%s/invitations", tenantID)
+	r := httptest.NewRequest(http.MethodGet, endpoint, nil)
+	r.Header.Add("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+
+	api.ServeHTTP(w, r)
+
+	resp := w.Result()
+	var response schedder.TenantInvitationsResponse
+	err := json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect(t, "", response.Error)
+	expect(t, http.StatusOK, resp.StatusCode)
+	expect(t, 1, len(response.Invitations))
+	expect(t, invitationID, response.Invitations[0].InvitationID)
+	expect(t, inviteeEmail, response.Invitations[0].Email)
+	expect(t, "pending", response.Invitations[0].Status)
+}
+
+func TestAcceptInvitation(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantName := "Zâna Măseluță"
+
+	tenantID := api.createTenantAndAccount(email, password, tenantName)
+	token := api.generateToken(email, password)
+
+	inviteeEmail := "invitee@example.com"
+	inviteePassword := "some_password"
+	inviteeID := api.registerUserByEmail(inviteeEmail, inviteePassword)
+	api.activateUserByEmail(inviteeEmail)
+	inviteeToken := api.generateToken(inviteeEmail, inviteePassword)
+
+	invitationID := api.createInvitation(token, tenantID, inviteeEmail)
+
+	r := httptest.NewRequest(http.MethodGet, "/accounts/self/invitations", nil)
+	r.Header.Add("Authorization", "Bearer "+inviteeToken)
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, r)
+
+	var invitations schedder.InvitationsResponse
+	err := json.NewDecoder(w.Result().Body).Decode(&invitations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, 1, len(invitations.Invitations))
+	expect(t, tenantID, invitations.Invitations[0].TenantID)
+
+	endpoint := fmt.Sprintf(
+		"/accounts/self/invitations/%s/accept", invitationID,
+	)
+	request := schedder.AcceptInvitationRequest{Code: api.codes[inviteeEmail]}
+	r, err = NewJSONRequest(http.MethodPost, endpoint, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Add("Authorization", "Bearer "+inviteeToken)
+	w = httptest.NewRecorder()
+
+	api.ServeHTTP(w, r)
+
+	resp := w.Result()
+	var response schedder.AcceptInvitationResponse
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect(t, "", response.Error)
+	expect(t, http.StatusOK, resp.StatusCode)
+	expect(t, tenantID, response.TenantID)
+
+	found := false
+	for _, member := range api.tenantMembers(token, tenantID).Members {
+		if member.AccountID == inviteeID {
+			found = true
+		}
+	}
+	expect(t, true, found)
+}
+
+func TestSignUpWithInvitation(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantName := "Zâna Măseluță"
+
+	tenantID := api.createTenantAndAccount(email, password, tenantName)
+	token := api.generateToken(email, password)
+
+	inviteeEmail := "invitee@example.com"
+	inviteePassword := "some_password"
+	api.createInvitation(token, tenantID, inviteeEmail)
+
+	request := schedder.AccountCreationRequest{
+		Email:          inviteeEmail,
+		Password:       inviteePassword,
+		InvitationCode: api.codes[inviteeEmail],
+	}
+	r, err := NewJSONRequest(http.MethodPost, "/accounts", request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+
+	api.ServeHTTP(w, r)
+
+	resp := w.Result()
+	var response schedder.AccountCreationResponse
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect(t, "", response.Error)
+	expect(t, http.StatusCreated, resp.StatusCode)
+
+	// the account is already activated, no verification needed
+	api.generateToken(inviteeEmail, inviteePassword)
+
+	found := false
+	for _, member := range api.tenantMembers(token, tenantID).Members {
+		if member.AccountID == response.AccountID {
+			found = true
+		}
+	}
+	expect(t, true, found)
+}
+
+func TestAcceptRevokedInvitation(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantName := "Zâna Măseluță"
+
+	tenantID := api.createTenantAndAccount(email, password, tenantName)
+	token := api.generateToken(email, password)
+
+	inviteeEmail := "invitee@example.com"
+	inviteePassword := "some_password"
+	api.registerUserByEmail(inviteeEmail, inviteePassword)
+	api.activateUserByEmail(inviteeEmail)
+	inviteeToken := api.generateToken(inviteeEmail, inviteePassword)
+
+	invitationID := api.createInvitation(token, tenantID, inviteeEmail)
+
+	endpoint := fmt.Sprintf(
+		"/tenants/%s/invitations/%s", tenantID, invitationID,
+	)
+	r := httptest.NewRequest(http.MethodDelete, endpoint, nil)
+	r.Header.Add("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, r)
+	expect(t, http.StatusOK, w.Result().StatusCode)
+
+	endpoint = fmt.Sprintf(
+		"/accounts/self/invitations/%s/accept", invitationID,
+	)
+	request := schedder.AcceptInvitationRequest{Code: api.codes[inviteeEmail]}
+	r, err := NewJSONRequest(http.MethodPost, endpoint, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Add("Authorization", "Bearer "+inviteeToken)
+	w = httptest.NewRecorder()
+
+	api.ServeHTTP(w, r)
+
+	resp := w.Result()
+	var response schedder.Response
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect(t, "invalid invitation", response.Error)
+	expect(t, http.StatusBadRequest, resp.StatusCode)
+}
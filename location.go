@@ -0,0 +1,588 @@
+package schedder
+
+import (
+	"errors"
+	"net/http"
+	"time"
+	// Embed the time zone database, the container doesn't ship one.
+	_ "time/tzdata"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"gitlab.com/vlad.anghel/schedder-api/database"
+)
+
+// CreateLocationRequest represents a request to create a location (branch) of
+// a tenant.
+type CreateLocationRequest struct {
+	// Name represents the name of the location, unique inside the tenant.
+	Name string `json:"name"`
+	// Address represents the postal address of the location.
+	Address string `json:"address"`
+	// Latitude represents the latitude of the location, in degrees.
+	Latitude float64 `json:"latitude"`
+	// Longitude represents the longitude of the location, in degrees.
+	Longitude float64 `json:"longitude"`
+	// Timezone represents the IANA time zone of the location, like
+	// "Europe/Bucharest". The opening hours are in this time zone.
+	Timezone string `json:"timezone"`
+}
+
+// CreateLocationResponse represents the response of the location creation
+// endpoint.
+type CreateLocationResponse struct {
+	Response
+	// LocationID represents the ID of the newly created location.
+	LocationID uuid.UUID `json:"location_id"`
+}
+
+// UpdateLocationRequest represents a request to update a location, all the
+// fields are replaced.
+type UpdateLocationRequest struct {
+	// Name represents the name of the location, unique inside the tenant.
+	Name string `json:"name"`
+	// Address represents the postal address of the location.
+	Address string `json:"address"`
+	// Latitude represents the latitude of the location, in degrees.
+	Latitude float64 `json:"latitude"`
+	// Longitude represents the longitude of the location, in degrees.
+	Longitude float64 `json:"longitude"`
+	// Timezone represents the IANA time zone of the location.
+	Timezone string `json:"timezone"`
+}
+
+// openingHoursEntry represents the opening hours of a location for a weekday.
+type openingHoursEntry struct {
+	// Weekday represents the day of the week.
+	// Valid values are: 0 (Sunday), 1 (Monday) ..., 6 (Saturday)
+	Weekday time.Weekday `json:"weekday"`
+	// Opening represents the opening time, only the time of day is used and
+	// it's in the time zone of the location.
+	Opening time.Time `json:"opening"`
+	// Closing represents the closing time, only the time of day is used and
+	// it's in the time zone of the location.
+	Closing time.Time `json:"closing"`
+}
+
+// locationResponse represents a location.
+type locationResponse struct {
+	// LocationID represents the ID of the location.
+	LocationID uuid.UUID `json:"location_id"`
+	// Name represents the name of the location.
+	Name string `json:"name"`
+	// Address represents the postal address of the location.
+	Address string `json:"address"`
+	// Latitude represents the latitude of the location, in degrees.
+	Latitude float64 `json:"latitude"`
+	// Longitude represents the longitude of the location, in degrees.
+	Longitude float64 `json:"longitude"`
+	// Timezone represents the IANA time zone of the location.
+	Timezone string `json:"timezone"`
+}
+
+// LocationsResponse represents the response of the location listing endpoint.
+type LocationsResponse struct {
+	Response
+	// Locations represents the list of locations of the tenant.
+	Locations []locationResponse `json:"locations"`
+}
+
+// LocationResponse represents the response of the location details endpoint.
+type LocationResponse struct {
+	Response
+	// LocationID represents the ID of the location.
+	LocationID uuid.UUID `json:"location_id"`
+	// Name represents the name of the location.
+	Name string `json:"name"`
+	// Address represents the postal address of the location.
+	Address string `json:"address"`
+	// Latitude represents the latitude of the location, in degrees.
+	Latitude float64 `json:"latitude"`
+	// Longitude represents the longitude of the location, in degrees.
+	Longitude float64 `json:"longitude"`
+	// Timezone represents the IANA time zone of the location.
+	Timezone string `json:"timezone"`
+	// Hours represents the opening hours, a missing weekday means that the
+	// location is closed on that day. If there are no opening hours at all,
+	// then the location follows the schedules of its personnel.
+	Hours []openingHoursEntry `json:"hours"`
+}
+
+// SetOpeningHoursRequest represents a request to replace the weekly opening
+// hours of a location.
+type SetOpeningHoursRequest struct {
+	// Hours represents the opening hours, at most one entry per weekday.
+	Hours []openingHoursEntry `json:"hours"`
+}
+
+// locationPersonnelEntry represents a member assigned to a location.
+type locationPersonnelEntry struct {
+	// AccountID represents the ID of the member.
+	AccountID uuid.UUID `json:"account_id"`
+	// Name represents the name of the member.
+	Name string `json:"name"`
+}
+
+// LocationPersonnelResponse represents the response of the location
+// personnel listing endpoint.
+type LocationPersonnelResponse struct {
+	Response
+	// Personnel represents the members assigned to the location.
+	Personnel []locationPersonnelEntry `json:"personnel"`
+}
+
+// validHours checks that the opening hours have at most one entry for each
+// weekday, returning an error message for the client otherwise.
+func validHours(hours []openingHoursEntry) string {
+	var seen [7]bool
+	for _, entry := range hours {
+		if entry.Weekday < time.Sunday || entry.Weekday > time.Saturday {
+			return "invalid weekday"
+		}
+		if seen[entry.Weekday] {
+			return "duplicate weekday"
+		}
+		seen[entry.Weekday] = true
+	}
+	return ""
+}
+
+// validLocation checks the fields shared by the creation and update requests,
+// returning an error message for the client if one of them is invalid.
+func validLocation(name, address, timezone string, lat, lon float64) string {
+	runes := utf8.RuneCountInString(name)
+	if runes < 3 || runes > 80 {
+		return "invalid name"
+	}
+	runes = utf8.RuneCountInString(address)
+	if runes < 3 || runes > 200 {
+		return "invalid address"
+	}
+	if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return "invalid coordinates"
+	}
+	// LoadLocation accepts "" as UTC, but we want an explicit time zone.
+	if _, err := time.LoadLocation(timezone); timezone == "" || err != nil {
+		return "invalid timezone"
+	}
+	return ""
+}
+
+// CreateLocation creates a new location for the tenant.
+func (a *API) CreateLocation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	request := ctx.Value(CtxJSON).(*CreateLocationRequest)
+
+	msg := validLocation(
+		request.Name, request.Address, request.Timezone,
+		request.Latitude, request.Longitude,
+	)
+	if msg != "" {
+		JsonError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	clp := database.CreateLocationParams{
+		TenantID:     tenantID,
+		LocationName: request.Name,
+		Address:      request.Address,
+		Longitude:    request.Longitude,
+		Latitude:     request.Latitude,
+		Timezone:     request.Timezone,
+	}
+	locationID, err := a.db.CreateLocation(ctx, clp)
+	if err != nil {
+		JsonError(w, http.StatusBadRequest, "couldn't create location")
+		return
+	}
+
+	JsonResp(w, http.StatusCreated, CreateLocationResponse{LocationID: locationID})
+}
+
+// Locations lists the locations of the tenant.
+func (a *API) Locations(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+
+	rows, err := a.db.GetLocations(ctx, tenantID)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "couldn't get locations")
+		return
+	}
+
+	var response LocationsResponse
+	response.Locations = make([]locationResponse, 0, len(rows))
+	for _, row := range rows {
+		response.Locations = append(response.Locations, locationResponse{
+			LocationID: row.LocationID,
+			Name:       row.LocationName,
+			Address:    row.Address,
+			Latitude:   row.Latitude,
+			Longitude:  row.Longitude,
+			Timezone:   row.Timezone,
+		})
+	}
+
+	JsonResp(w, http.StatusOK, response)
+}
+
+// Location returns the details of a location, including its opening hours.
+func (a *API) Location(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	locationID := ctx.Value(CtxLocationID).(uuid.UUID)
+
+	glp := database.GetLocationParams{
+		TenantID:   tenantID,
+		LocationID: locationID,
+	}
+	row, err := a.db.GetLocation(ctx, glp)
+	if errors.Is(err, pgx.ErrNoRows) {
+		JsonError(w, http.StatusNotFound, "invalid location")
+		return
+	}
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "couldn't get location")
+		return
+	}
+
+	hours, err := a.db.GetOpeningHours(ctx, locationID)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "couldn't get location")
+		return
+	}
+
+	response := LocationResponse{
+		LocationID: row.LocationID,
+		Name:       row.LocationName,
+		Address:    row.Address,
+		Latitude:   row.Latitude,
+		Longitude:  row.Longitude,
+		Timezone:   row.Timezone,
+	}
+	response.Hours = make([]openingHoursEntry, 0, len(hours))
+	for _, h := range hours {
+		response.Hours = append(response.Hours, openingHoursEntry{
+			Weekday: h.Weekday,
+			Opening: h.OpeningTime,
+			Closing: h.ClosingTime,
+		})
+	}
+
+	JsonResp(w, http.StatusOK, response)
+}
+
+// UpdateLocation replaces the details of a location.
+func (a *API) UpdateLocation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	locationID := ctx.Value(CtxLocationID).(uuid.UUID)
+	request := ctx.Value(CtxJSON).(*UpdateLocationRequest)
+
+	msg := validLocation(
+		request.Name, request.Address, request.Timezone,
+		request.Latitude, request.Longitude,
+	)
+	if msg != "" {
+		JsonError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	ulp := database.UpdateLocationParams{
+		LocationName: request.Name,
+		Address:      request.Address,
+		Longitude:    request.Longitude,
+		Latitude:     request.Latitude,
+		Timezone:     request.Timezone,
+		TenantID:     tenantID,
+		LocationID:   locationID,
+	}
+	affected, err := a.db.UpdateLocation(ctx, ulp)
+	if err != nil {
+		JsonError(w, http.StatusBadRequest, "couldn't update location")
+		return
+	}
+	if affected != 1 {
+		JsonError(w, http.StatusNotFound, "invalid location")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// DeleteLocation deletes a location. Its photos are kept as photos of the
+// tenant. Locations that have appointments or schedules can't be deleted.
+func (a *API) DeleteLocation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	locationID := ctx.Value(CtxLocationID).(uuid.UUID)
+
+	tx, err := a.txlike.Begin(ctx)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	defer tx.Rollback(ctx)
+	queries := database.New(tx)
+
+	dlpp := database.DetachLocationPhotosParams{
+		TenantID:   tenantID,
+		LocationID: uuid.NullUUID{UUID: locationID, Valid: true},
+	}
+	err = queries.DetachLocationPhotos(ctx, dlpp)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	dlp := database.DeleteLocationParams{
+		TenantID:   tenantID,
+		LocationID: locationID,
+	}
+	affected, err := queries.DeleteLocation(ctx, dlp)
+	if isForeignKeyViolation(err) {
+		JsonError(w, http.StatusBadRequest, "location in use")
+		return
+	}
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "couldn't delete location")
+		return
+	}
+	if affected != 1 {
+		JsonError(w, http.StatusNotFound, "invalid location")
+		return
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "couldn't delete location")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// SetOpeningHours replaces the weekly opening hours of a location.
+func (a *API) SetOpeningHours(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	locationID := ctx.Value(CtxLocationID).(uuid.UUID)
+	request := ctx.Value(CtxJSON).(*SetOpeningHoursRequest)
+
+	if msg := validHours(request.Hours); msg != "" {
+		JsonError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	tx, err := a.txlike.Begin(ctx)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	defer tx.Rollback(ctx)
+	queries := database.New(tx)
+
+	glp := database.GetLocationParams{
+		TenantID:   tenantID,
+		LocationID: locationID,
+	}
+	_, err = queries.GetLocation(ctx, glp)
+	if err != nil {
+		JsonError(w, http.StatusNotFound, "invalid location")
+		return
+	}
+
+	err = queries.ClearOpeningHours(ctx, locationID)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	for _, entry := range request.Hours {
+		aohp := database.AddOpeningHoursParams{
+			LocationID:  locationID,
+			Weekday:     entry.Weekday,
+			OpeningTime: entry.Opening,
+			ClosingTime: entry.Closing,
+		}
+		err = queries.AddOpeningHours(ctx, aohp)
+		if err != nil {
+			JsonError(w, http.StatusBadRequest, "invalid hours")
+			return
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "couldn't set hours")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// AssignLocationPersonnel assigns a member of the tenant to the location.
+func (a *API) AssignLocationPersonnel(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	locationID := ctx.Value(CtxLocationID).(uuid.UUID)
+	accountID := ctx.Value(CtxAccountID).(uuid.UUID)
+
+	alpp := database.AssignLocationPersonnelParams{
+		TenantID:   tenantID,
+		LocationID: locationID,
+		AccountID:  accountID,
+	}
+	err := a.db.AssignLocationPersonnel(ctx, alpp)
+	if err != nil {
+		JsonError(w, http.StatusBadRequest, "not a member or location")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// UnassignLocationPersonnel removes a member from the location.
+func (a *API) UnassignLocationPersonnel(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	locationID := ctx.Value(CtxLocationID).(uuid.UUID)
+	accountID := ctx.Value(CtxAccountID).(uuid.UUID)
+
+	ulpp := database.UnassignLocationPersonnelParams{
+		TenantID:   tenantID,
+		LocationID: locationID,
+		AccountID:  accountID,
+	}
+	affected, err := a.db.UnassignLocationPersonnel(ctx, ulpp)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	if affected != 1 {
+		JsonError(w, http.StatusNotFound, "not assigned")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// LocationPersonnel lists the members assigned to the location.
+func (a *API) LocationPersonnel(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	locationID := ctx.Value(CtxLocationID).(uuid.UUID)
+
+	glpp := database.GetLocationPersonnelParams{
+		TenantID:   tenantID,
+		LocationID: locationID,
+	}
+	rows, err := a.db.GetLocationPersonnel(ctx, glpp)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	var response LocationPersonnelResponse
+	response.Personnel = make([]locationPersonnelEntry, 0, len(rows))
+	for _, row := range rows {
+		response.Personnel = append(response.Personnel, locationPersonnelEntry{
+			AccountID: row.AccountID,
+			Name:      row.AccountName,
+		})
+	}
+
+	JsonResp(w, http.StatusOK, response)
+}
+
+// AddLocationService offers the service at the location. Once a service is
+// offered at a location, it can be booked only at its locations.
+func (a *API) AddLocationService(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	locationID := ctx.Value(CtxLocationID).(uuid.UUID)
+	serviceID := ctx.Value(CtxServiceID).(uuid.UUID)
+
+	alsp := database.AddLocationServiceParams{
+		TenantID:   tenantID,
+		LocationID: locationID,
+		ServiceID:  serviceID,
+	}
+	err := a.db.AddLocationService(ctx, alsp)
+	if err != nil {
+		JsonError(w, http.StatusBadRequest, "invalid service or location")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// RemoveLocationService stops offering the service at the location.
+func (a *API) RemoveLocationService(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	locationID := ctx.Value(CtxLocationID).(uuid.UUID)
+	serviceID := ctx.Value(CtxServiceID).(uuid.UUID)
+
+	rlsp := database.RemoveLocationServiceParams{
+		TenantID:   tenantID,
+		LocationID: locationID,
+		ServiceID:  serviceID,
+	}
+	affected, err := a.db.RemoveLocationService(ctx, rlsp)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	if affected != 1 {
+		JsonError(w, http.StatusNotFound, "service not offered")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// LocationServices lists the services offered at the location.
+func (a *API) LocationServices(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	locationID := ctx.Value(CtxLocationID).(uuid.UUID)
+
+	glsp := database.GetLocationServicesParams{
+		TenantID:   tenantID,
+		LocationID: locationID,
+	}
+	rows, err := a.db.GetLocationServices(ctx, glsp)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	pricing, err := a.pricingFor(ctx, tenantID)
+	if err != nil {
+		JsonError(w, http.StatusNotFound, "invalid tenant")
+		return
+	}
+
+	var response ServicesResponse
+	response.Services = make([]serviceResponse, 0, len(rows))
+	for i := range rows {
+		row := &rows[i]
+		service := serviceResponse{
+			PersonnelID: row.AccountID,
+			ServiceName: row.ServiceName,
+			ServiceID:   row.ServiceID,
+			Price:       pricing.price(row.Price),
+			Total:       pricing.total(row.Price),
+		}
+		err := row.Duration.AssignTo(&service.Duration)
+		if err != nil {
+			JsonError(w, http.StatusInternalServerError, "not implemented")
+			return
+		}
+		response.Services = append(response.Services, service)
+	}
+
+	JsonResp(w, http.StatusOK, response)
+}
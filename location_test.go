@@ -0,0 +1,224 @@
+package schedder_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.com/vlad.anghel/schedder-api"
+)
+
+func TestCreateLocation(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantName := "Zâna Măseluță"
+
+	tenantID := api.createTenantAndAccount(email, password, tenantName)
+	api.publishTenant(tenantID)
+	token := api.generateToken(email, password)
+
+	locationID := api.createLocation(token, tenantID, "Centru")
+
+	endpoint := fmt.Sprintf("/tenants/%s/locations", tenantID)
+	r := httptest.NewRequest(http.MethodGet, endpoint, nil)
+	w := httptest.NewRecorder()
+
+	api.ServeHTTP(w, r)
+
+	resp := w.Result()
+	var response schedder.LocationsResponse
+	err := json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect(t, "", response.Error)
+	expect(t, http.StatusOK, resp.StatusCode)
+	expect(t, 1, len(response.Locations))
+	expect(t, locationID, response.Locations[0].LocationID)
+	expect(t, "Centru", response.Locations[0].Name)
+	expect(t, 45.6427, response.Locations[0].Latitude)
+	expect(t, 25.5887, response.Locations[0].Longitude)
+}
+
+func TestCreateLocationInvalidTimezone(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantName := "Zâna Măseluță"
+
+	tenantID := api.createTenantAndAccount(email, password, tenantName)
+	token := api.generateToken(email, password)
+
+	request := schedder.CreateLocationRequest{
+		Name:     "Centru",
+		Address:  "Strada Lungă 1, Brașov",
+		Timezone: "Europe/Atlantis",
+	}
+	endpoint := fmt.Sprintf("/tenants/%s/locations", tenantID)
+	r, err := NewJSONRequest(http.MethodPost, endpoint, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Add("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+
+	api.ServeHTTP(w, r)
+
+	resp := w.Result()
+	var response schedder.Response
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect(t, "invalid timezone", response.Error)
+	expect(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestTimetableAtLocation(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantName := "Zâna Măseluță"
+
+	tenantID := api.createTenantAndAccount(email, password, tenantName)
+	api.publishTenant(tenantID)
+	accountID := api.findAccountByEmail(email)
+	token := api.generateToken(email, password)
+
+	serviceID := api.createService(
+		token, tenantID, accountID, "control", 420, time.Hour,
+	)
+	date := localDate(time.Now())
+	starting := time.Time{}.Add(10 * time.Hour)
+	ending := starting.Add(8 * time.Hour)
+	api.setSchedule(token, accountID, tenantID, starting, ending, date.Weekday())
+
+	locationID := api.createLocation(token, tenantID, "Centru")
+	locationEndpoint := fmt.Sprintf(
+		"/tenants/%s/locations/%s", tenantID, locationID,
+	)
+	api.putAsManager(token, locationEndpoint+"/personnel/"+accountID.String())
+	api.putAsManager(token, locationEndpoint+"/services/"+serviceID.String())
+
+	// the entries of SetOpeningHoursRequest are unexported, so write the JSON
+	body := fmt.Sprintf(
+		`{"hours":[{"weekday":%d,"opening":"%s","closing":"%s"}]}`,
+		date.Weekday(),
+		time.Time{}.Add(12*time.Hour).Format(time.RFC3339),
+		time.Time{}.Add(14*time.Hour).Format(time.RFC3339),
+	)
+	r := httptest.NewRequest(
+		http.MethodPut, locationEndpoint+"/hours", strings.NewReader(body),
+	)
+	r.Header.Add("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, r)
+	expect(t, http.StatusOK, w.Result().StatusCode)
+
+	endpoint := fmt.Sprintf(
+		"/tenants/%s/services/%s/timetable", tenantID, serviceID,
+	)
+
+	// the service is offered only at the location, so it's required
+	r, err := NewJSONRequest(
+		http.MethodGet, endpoint, schedder.TimetableRequest{Date: date},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w = httptest.NewRecorder()
+	api.ServeHTTP(w, r)
+
+	var response schedder.TimetableResponse
+	err = json.NewDecoder(w.Result().Body).Decode(&response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, "location required", response.Error)
+	expect(t, http.StatusBadRequest, w.Result().StatusCode)
+
+	request := schedder.TimetableRequest{Date: date, LocationID: locationID}
+	r, err = NewJSONRequest(http.MethodGet, endpoint, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w = httptest.NewRecorder()
+	api.ServeHTTP(w, r)
+
+	response = schedder.TimetableResponse{}
+	err = json.NewDecoder(w.Result().Body).Decode(&response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, "", response.Error)
+	expect(t, http.StatusOK, w.Result().StatusCode)
+	unexpect(t, 0, len(response.Times))
+
+	// only the slots that fit inside the opening hours are left
+	for _, timestamp := range response.Times {
+		hour := timestamp.Hour()
+		if hour < 12 || hour > 13 {
+			t.Fatalf("Found time %s outside of the opening hours", timestamp)
+		}
+	}
+}
+
+func TestDeleteLocationWithSchedule(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantName := "Zâna Măseluță"
+
+	tenantID := api.createTenantAndAccount(email, password, tenantName)
+	accountID := api.findAccountByEmail(email)
+	token := api.generateToken(email, password)
+
+	locationID := api.createLocation(token, tenantID, "Centru")
+	locationEndpoint := fmt.Sprintf(
+		"/tenants/%s/locations/%s", tenantID, locationID,
+	)
+	api.putAsManager(token, locationEndpoint+"/personnel/"+accountID.String())
+
+	scheduleEndpoint := fmt.Sprintf(
+		"/tenants/%s/personnel/%s/schedule", tenantID, accountID,
+	)
+	resp := api.send(token, http.MethodPut, scheduleEndpoint, schedder.SetScheduleRequest{
+		Intervals: []schedder.ScheduleInterval{{
+			Weekday:    time.Monday,
+			Starting:   time.Time{}.Add(10 * time.Hour),
+			Ending:     time.Time{}.Add(18 * time.Hour),
+			LocationID: locationID,
+		}},
+	})
+	expect(t, http.StatusOK, resp.StatusCode)
+
+	// the interval would become valid at any location
+	resp = api.send(token, http.MethodDelete, locationEndpoint, nil)
+	expect(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = api.send(token, http.MethodPut, scheduleEndpoint, schedder.SetScheduleRequest{
+		Intervals: []schedder.ScheduleInterval{},
+	})
+	expect(t, http.StatusOK, resp.StatusCode)
+	resp = api.send(token, http.MethodDelete, locationEndpoint, nil)
+	expect(t, http.StatusOK, resp.StatusCode)
+}
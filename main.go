@@ -19,6 +19,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -38,11 +39,15 @@ type API struct {
 	mux           *chi.Mux
 	emailVerifier Verifier
 	phoneVerifier Verifier
-	photosPath    string
+	storage       BlobStorage
+	maxPhotoSize  int64
+	signer        *PhotoSigner
+	moderator     PhotoModerator
 }
 
-func (a *API) PhotosPath() string {
-	return a.photosPath
+// Storage returns the storage of the photos.
+func (a *API) Storage() BlobStorage {
+	return a.storage
 }
 
 // Response represents the base response. All other *Response types embed this
@@ -57,7 +62,7 @@ type Response struct {
 func New(
 	txlike database.TxLike,
 	emailVerifier, phoneVerifier Verifier,
-	photosPath string,
+	storage BlobStorage,
 ) *API {
 	api := new(API)
 	api.txlike = txlike
@@ -88,6 +93,8 @@ func New(
 				r.Post("/photo", api.SetProfilePhoto)
 				r.Get("/photo", api.DownloadProfilePhoto)
 				r.Delete("/photo", api.DeleteProfilePhoto)
+				r.Get("/appointments", api.Appointments)
+				r.Get("/purchases", api.Purchases)
 			})
 
 			r.Route("/sessions", func(r chi.Router) {
@@ -104,12 +111,24 @@ func New(
 				})
 			})
 
+			r.Route("/invitations", func(r chi.Router) {
+				r.Use(api.AuthenticatedEndpoint)
+				r.Get("/", api.Invitations)
+				r.Route("/{invitationID}", func(r chi.Router) {
+					r.Use(api.WithInvitationID)
+					r.With(WithJSON[AcceptInvitationRequest]).Post(
+						"/accept", api.AcceptInvitation,
+					)
+					r.Post("/decline", api.DeclineInvitation)
+				})
+			})
+
 			r.Route("/favourites", func(r chi.Router) {
 				r.Use(api.AuthenticatedEndpoint)
 				r.Get("/", api.Favourites)
 				r.Route("/{tenantID}", func(r chi.Router) {
 					r.Use(api.WithTenantID)
-					r.Post("/", api.AddFavourite)
+					r.With(api.VisibleTenantEndpoint).Post("/", api.AddFavourite)
 					r.Delete("/", api.RemoveFavourite)
 				})
 			})
@@ -118,14 +137,30 @@ func New(
 			"/by-email/{email}", api.AccountByEmailAsAdmin,
 		)
 		r.Route("/{accountID}", func(r chi.Router) {
-			r.Use(
-				api.WithAccountID,
-				api.AuthenticatedEndpoint,
-				api.AdminEndpoint,
-			)
-			r.With(WithJSON[AdminSettingRequest]).Post("/admin", api.SetAdmin)
-			r.With(WithJSON[BusinessSettingRequest]).Post(
-				"/business", api.SetBusiness,
+			r.Use(api.WithAccountID)
+			r.Get("/photo", api.DownloadAccountPhoto)
+			r.Group(func(r chi.Router) {
+				r.Use(api.AuthenticatedEndpoint, api.AdminEndpoint)
+				r.With(WithJSON[AdminSettingRequest]).Post(
+					"/admin", api.SetAdmin,
+				)
+				r.With(WithJSON[BusinessSettingRequest]).Post(
+					"/business", api.SetBusiness,
+				)
+			})
+		})
+	})
+
+	api.mux.Get("/photos/{hash}/{size}", api.DownloadSignedPhoto)
+
+	api.mux.Route("/moderation/photos", func(r chi.Router) {
+		r.Use(api.AuthenticatedEndpoint, api.AdminEndpoint)
+		r.Get("/", api.PhotoModerationQueue)
+		r.Route("/{photoID}", func(r chi.Router) {
+			r.Use(api.WithPhotoID)
+			r.Get("/", api.DownloadModeratedPhoto)
+			r.With(WithJSON[ModeratePhotoRequest]).Post(
+				"/", api.ModeratePhoto,
 			)
 		})
 	})
@@ -135,8 +170,11 @@ func New(
 			"/", api.CreateTenant,
 		)
 		r.Get("/", api.Tenants)
+		r.With(api.AuthenticatedEndpoint, api.AdminEndpoint).Get(
+			"/pending", api.PendingTenants,
+		)
 		r.Route("/{tenantID}", func(r chi.Router) {
-			r.Use(api.WithTenantID)
+			r.Use(api.WithTenantID, api.VisibleTenantEndpoint)
 			r.Group(func(r chi.Router) {
 				r.Use(
 					api.AuthenticatedEndpoint,
@@ -146,36 +184,263 @@ func New(
 					"/members", api.AddTenantMember,
 				)
 				r.Get("/members", api.TenantMembers)
+				r.Route("/members/{accountID}", func(r chi.Router) {
+					r.Use(api.WithAccountID)
+					r.Delete("/", api.RemoveTenantMember)
+					r.With(WithJSON[SetTenantManagerRequest]).Put(
+						"/manager", api.SetTenantManager,
+					)
+				})
+				r.With(WithJSON[TransferOwnershipRequest]).Post(
+					"/owner", api.TransferOwnership,
+				)
+				r.With(WithJSON[CreateInvitationRequest]).Post(
+					"/invitations", api.CreateInvitation,
+				)
+				r.Get("/invitations", api.TenantInvitations)
+				r.With(api.WithInvitationID).Delete(
+					"/invitations/{invitationID}", api.RevokeInvitation,
+				)
 				r.Post("/photos", api.AddTenantPhoto)
+				r.With(WithJSON[ReorderTenantPhotosRequest]).Put(
+					"/photos/order", api.ReorderTenantPhotos,
+				)
+				r.Delete("/photos/cover", api.ClearTenantCoverPhoto)
 				r.With(api.WithPhotoID).Delete(
 					"/photos/by-id/{photoID}", api.DeleteTenantPhoto,
 				)
+				r.With(
+					api.WithPhotoID, WithJSON[UpdateTenantPhotoRequest],
+				).Put("/photos/by-id/{photoID}", api.UpdateTenantPhoto)
+				r.With(api.WithPhotoID).Put(
+					"/photos/by-id/{photoID}/cover", api.SetTenantCoverPhoto,
+				)
+				r.With(WithJSON[SetTenantHoursRequest]).Put(
+					"/hours", api.SetTenantHours,
+				)
+				r.With(WithJSON[SetHolidayCalendarRequest]).Put(
+					"/holidays", api.SetHolidayCalendar,
+				)
+				r.With(WithJSON[SetTenantPricingRequest]).Put(
+					"/pricing", api.SetTenantPricing,
+				)
+				r.With(WithJSON[SlotGranularityRequest]).Put(
+					"/slots", api.SetSlotGranularity,
+				)
+				r.With(WithJSON[CreateClosureRequest]).Post(
+					"/closures", api.CreateClosure,
+				)
+				r.Post("/closures/ics", api.ImportClosures)
+				r.Post("/submit", api.SubmitTenant)
+				r.Get("/status", api.TenantStatus)
+				r.With(api.WithClosureID).Delete(
+					"/closures/{closureID}", api.DeleteClosure,
+				)
 			})
+			r.Group(func(r chi.Router) {
+				r.Use(api.AuthenticatedEndpoint, api.AdminEndpoint)
+				r.With(WithJSON[ModerateTenantRequest]).Post(
+					"/moderation", api.ModerateTenant,
+				)
+				r.Get("/moderation", api.TenantStatus)
+			})
+			r.Get("/hours", api.TenantHours)
+			r.Get("/pricing", api.TenantPricing)
+			r.Get("/slots", api.SlotGranularity)
+			r.Get("/closures", api.Closures)
 			r.Get("/photos", api.ListTenantPhotos)
 			r.With(api.WithPhotoID).Get(
 				"/photos/by-id/{photoID}", api.DownloadTenantPhoto,
 			)
+			r.Get("/personnel", api.Personnel)
 			r.Route("/personnel/{accountID}", func(r chi.Router) {
 				r.Use(api.WithAccountID)
+				r.With(
+					api.AuthenticatedEndpoint,
+					WithJSON[UpdatePersonnelProfileRequest],
+				).Put("/profile", api.UpdatePersonnelProfile)
 				r.Group(func(r chi.Router) {
 					r.Use(
 						api.AuthenticatedEndpoint,
 						api.TenantManagerEndpoint,
 					)
-					r.With(WithJSON[SetScheduleRequest]).Post("/schedule", api.SetSchedule)
+					r.With(WithJSON[SetScheduleRequest]).Put("/schedule", api.SetSchedule)
+					r.With(
+						api.WithIntervalID,
+						WithJSON[UpdateScheduleIntervalRequest],
+					).Put("/schedule/{intervalID}", api.UpdateScheduleInterval)
+					r.With(api.WithIntervalID).Delete(
+						"/schedule/{intervalID}", api.DeleteScheduleInterval,
+					)
+					r.Get("/exceptions", api.Exceptions)
+					r.With(WithJSON[CreateExceptionRequest]).Post(
+						"/exceptions", api.CreateException,
+					)
+					r.With(api.WithExceptionID).Delete(
+						"/exceptions/{exceptionID}", api.DeleteException,
+					)
 					r.With(WithJSON[CreateServiceRequest]).Post("/services", api.CreateService)
 				})
+				r.Group(func(r chi.Router) {
+					r.Use(api.AuthenticatedEndpoint)
+					r.Get("/time-off", api.TimeOffForPersonnel)
+					r.With(WithJSON[CreateTimeOffRequest]).Post(
+						"/time-off", api.RequestTimeOff,
+					)
+					r.With(api.WithTimeOffID).Delete(
+						"/time-off/{timeOffID}", api.WithdrawTimeOff,
+					)
+				})
+				r.Get("/schedule", api.Schedule)
 				r.Get("/services", api.ServicesForPersonnel)
 			})
 
+			r.Route("/locations", func(r chi.Router) {
+				r.Get("/", api.Locations)
+				r.With(
+					api.AuthenticatedEndpoint,
+					api.TenantManagerEndpoint,
+					WithJSON[CreateLocationRequest],
+				).Post("/", api.CreateLocation)
+				r.Route("/{locationID}", func(r chi.Router) {
+					r.Use(api.WithLocationID)
+					r.Get("/", api.Location)
+					r.Get("/photos", api.ListTenantPhotos)
+					r.Get("/personnel", api.LocationPersonnel)
+					r.Get("/services", api.LocationServices)
+					r.Group(func(r chi.Router) {
+						r.Use(
+							api.AuthenticatedEndpoint,
+							api.TenantManagerEndpoint,
+						)
+						r.With(WithJSON[UpdateLocationRequest]).Put(
+							"/", api.UpdateLocation,
+						)
+						r.Delete("/", api.DeleteLocation)
+						r.With(WithJSON[SetOpeningHoursRequest]).Put(
+							"/hours", api.SetOpeningHours,
+						)
+						r.Post("/photos", api.AddTenantPhoto)
+						r.With(api.WithAccountID).Put(
+							"/personnel/{accountID}",
+							api.AssignLocationPersonnel,
+						)
+						r.With(api.WithAccountID).Delete(
+							"/personnel/{accountID}",
+							api.UnassignLocationPersonnel,
+						)
+						r.With(api.WithServiceID).Put(
+							"/services/{serviceID}", api.AddLocationService,
+						)
+						r.With(api.WithServiceID).Delete(
+							"/services/{serviceID}", api.RemoveLocationService,
+						)
+					})
+				})
+			})
+
 			r.Route("/services", func(r chi.Router) {
 				r.Get("/", api.ServicesForTenant)
+				r.With(
+					api.AuthenticatedEndpoint,
+					api.TenantManagerEndpoint,
+					WithJSON[CreateServiceRequest],
+				).Post("/", api.CreateCatalogueService)
 				r.Route("/{serviceID}", func(r chi.Router) {
 					r.Use(api.WithServiceID)
 					r.With(api.AuthenticatedEndpoint, WithJSON[CreateAppointmentRequest]).Post("/schedule", api.CreateAppointment)
 					r.With(WithJSON[TimetableRequest]).Get("/timetable", api.Timetable)
+					r.Get("/options", api.ServiceOptions)
+					r.Get("/pricing-rules", api.PricingRules)
+					r.Group(func(r chi.Router) {
+						r.Use(
+							api.AuthenticatedEndpoint,
+							api.TenantManagerEndpoint,
+						)
+						r.With(WithJSON[UpdateServiceRequest]).Put(
+							"/", api.UpdateService,
+						)
+						r.Delete("/", api.ArchiveService)
+						r.Get("/history", api.ServiceHistory)
+						r.With(
+							api.WithAccountID,
+							WithJSON[AssignServicePersonnelRequest],
+						).Put("/personnel/{accountID}", api.AssignServicePersonnel)
+						r.With(api.WithAccountID).Delete(
+							"/personnel/{accountID}", api.UnassignServicePersonnel,
+						)
+						r.With(WithJSON[CreateServiceOptionRequest]).Post(
+							"/options", api.CreateServiceOption,
+						)
+						r.With(api.WithOptionID).Delete(
+							"/options/{optionID}", api.DeleteServiceOption,
+						)
+						r.With(WithJSON[CreatePricingRuleRequest]).Post(
+							"/pricing-rules", api.CreatePricingRule,
+						)
+						r.With(api.WithRuleID).Delete(
+							"/pricing-rules/{ruleID}", api.DeletePricingRule,
+						)
+						r.With(
+							api.WithLanguage, WithJSON[ServiceTranslationRequest],
+						).Put("/translations/{language}", api.SetServiceTranslation)
+						r.With(api.WithLanguage).Delete(
+							"/translations/{language}", api.DeleteServiceTranslation,
+						)
+					})
+				})
+
+			})
+
+			r.Route("/time-off", func(r chi.Router) {
+				r.Use(
+					api.AuthenticatedEndpoint,
+					api.TenantManagerEndpoint,
+				)
+				r.Get("/", api.PendingTimeOff)
+				r.With(
+					api.WithTimeOffID,
+					WithJSON[DecideTimeOffRequest],
+				).Put("/{timeOffID}", api.DecideTimeOff)
+			})
+
+			r.Route("/packages", func(r chi.Router) {
+				r.Get("/", api.Packages)
+				r.With(
+					api.AuthenticatedEndpoint,
+					api.TenantManagerEndpoint,
+					WithJSON[CreatePackageRequest],
+				).Post("/", api.CreatePackage)
+				r.Route("/{packageID}", func(r chi.Router) {
+					r.Use(api.WithPackageID)
+					r.With(
+						api.AuthenticatedEndpoint,
+						api.TenantManagerEndpoint,
+					).Delete("/", api.ArchivePackage)
+					r.With(api.AuthenticatedEndpoint).Post(
+						"/purchase", api.PurchasePackage,
+					)
 				})
+			})
 
+			r.Route("/categories", func(r chi.Router) {
+				r.Use(api.AuthenticatedEndpoint, api.TenantManagerEndpoint)
+				r.With(WithJSON[CreateCategoryRequest]).Post(
+					"/", api.CreateServiceCategory,
+				)
+				r.Route("/{categoryID}", func(r chi.Router) {
+					r.Use(api.WithCategoryID)
+					r.With(WithJSON[UpdateCategoryRequest]).Put(
+						"/", api.UpdateServiceCategory,
+					)
+					r.Delete("/", api.DeleteServiceCategory)
+					r.With(
+						api.WithLanguage, WithJSON[CategoryTranslationRequest],
+					).Put("/translations/{language}", api.SetCategoryTranslation)
+					r.With(api.WithLanguage).Delete(
+						"/translations/{language}", api.DeleteCategoryTranslation,
+					)
+				})
 			})
 
 			r.Route("/reviews", func(r chi.Router) {
@@ -190,8 +455,8 @@ func New(
 	api.emailVerifier = emailVerifier
 	api.phoneVerifier = phoneVerifier
 
-	api.photosPath = photosPath
-	os.MkdirAll(api.photosPath, 0777)
+	api.storage = storage
+	api.maxPhotoSize = defaultMaxPhotoSize
 
 	return api
 }
@@ -224,6 +489,33 @@ func RequiredEnv(name, example string) string {
 	return env
 }
 
+// storageFromEnv returns the storage of the photos. Photos are stored in an
+// S3-compatible bucket if SCHEDDER_S3_BUCKET is defined, otherwise in the
+// directory from SCHEDDER_PHOTOS.
+func storageFromEnv() BlobStorage {
+	bucket, found := os.LookupEnv("SCHEDDER_S3_BUCKET")
+	if !found {
+		photosPath := RequiredEnv("SCHEDDER_PHOTOS", "./data/photos")
+		storage, err := NewFSStorage(photosPath)
+		if err != nil {
+			panic(err)
+		}
+		return storage
+	}
+
+	storage, err := NewS3Storage(
+		RequiredEnv("SCHEDDER_S3_ENDPOINT", "http://localhost:9000"),
+		RequiredEnv("SCHEDDER_S3_REGION", "us-east-1"),
+		bucket,
+		RequiredEnv("SCHEDDER_S3_ACCESS_KEY", "minioadmin"),
+		RequiredEnv("SCHEDDER_S3_SECRET_KEY", "minioadmin"),
+	)
+	if err != nil {
+		panic(err)
+	}
+	return storage
+}
+
 // Run connects to Postgres, does migrations and then serves the API. Kind of
 // like a main function for this whole module.
 func Run() {
@@ -231,7 +523,7 @@ func Run() {
 		"SCHEDDER_POSTGRES", "postgres://user@localhost/schedder_db",
 	)
 
-	photosPath := RequiredEnv("SCHEDDER_PHOTOS", "./data/photos")
+	storage := storageFromEnv()
 	log.Printf("INFO: connecting to Postgres using: %#v", postgresURI)
 	stdDB, err := sql.Open("pgx", postgresURI)
 	if err != nil {
@@ -255,7 +547,17 @@ func Run() {
 	emailVerifier := WriterVerifier{os.Stdout, "email"}
 	phoneVerifier := WriterVerifier{os.Stdout, "phone"}
 
-	api := New(conn, &emailVerifier, &phoneVerifier, photosPath)
+	api := New(conn, &emailVerifier, &phoneVerifier, storage)
+	if size, found := os.LookupEnv("SCHEDDER_MAX_PHOTO_SIZE"); found {
+		maxPhotoSize, err := strconv.ParseInt(size, 10, 64)
+		if err != nil {
+			panic(err)
+		}
+		api.SetMaxPhotoSize(maxPhotoSize)
+	}
+	api.SetPhotoSigner(signerFromEnv())
+	api.SetPhotoModerator(moderatorFromEnv())
+	go api.ProcessBlobDeletions(context.Background())
 
 	server := &http.Server{
 		Addr:              ":2023",
@@ -23,6 +23,16 @@ import (
 
 var conn *pgxpool.Pool
 
+// bucharest represents the default time zone of the tenants, the one of their
+// schedules and timetables.
+var bucharest = func() *time.Location {
+	tz, err := time.LoadLocation("Europe/Bucharest")
+	if err != nil {
+		panic(err)
+	}
+	return tz
+}()
+
 func TestMain(m *testing.M) {
 	var err error
 
@@ -68,6 +78,20 @@ func unexpect[T comparable](t *testing.T, unexpected, got T) {
 	}
 }
 
+// localDate returns the midnight of the date of the time in the default time
+// zone of the tenants.
+func localDate(t time.Time) time.Time {
+	year, month, day := t.In(bucharest).Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, bucharest)
+}
+
+// hourOn returns the hour of the date in its time zone, adding the hours to
+// the midnight would be off on the days when the clocks change.
+func hourOn(date time.Time, hour int) time.Time {
+	year, month, day := date.Date()
+	return time.Date(year, month, day, hour, 0, 0, 0, date.Location())
+}
+
 type TestCodeStore map[string]string
 
 func (cs TestCodeStore) SendVerification(id, code string) error {
@@ -89,11 +113,14 @@ func BeginTx(t *testing.T) *APITX {
 		t.Fatalf("testing: BeginTx: %e", err)
 	}
 
-	photosPath := t.TempDir() + "/photos"
+	storage, err := schedder.NewFSStorage(t.TempDir() + "/photos")
+	if err != nil {
+		t.Fatalf("testing: NewFSStorage: %e", err)
+	}
 
 	api := new(APITX)
 	api.codes = make(map[string]string)
-	api.API = schedder.New(tx, api.codes, api.codes, photosPath)
+	api.API = schedder.New(tx, api.codes, api.codes, storage)
 	api.tx = tx
 	api.t = t
 	api.t.Cleanup(api.Rollback)
@@ -360,6 +387,21 @@ func (a *APITX) createTenant(token, tenantName string) uuid.UUID {
 	return response.TenantID
 }
 
+// publishTenant publishes the tenant, skipping the review by the admins.
+func (a *APITX) publishTenant(tenantID uuid.UUID) {
+	a.t.Helper()
+	stsp := database.SetTenantStatusParams{
+		Status:       database.TenantStatusPublished,
+		TenantID:     tenantID,
+		FromStatuses: []string{string(database.TenantStatusDraft)},
+	}
+	affected, err := a.DB().SetTenantStatus(context.Background(), stsp)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	expect(a.t, int64(1), affected)
+}
+
 func (a *APITX) createTenantAndAccount(
 	email, password, tenantName string,
 ) uuid.UUID {
@@ -446,11 +488,11 @@ func (a *APITX) addProfilePhoto(token string, photo io.Reader) {
 
 func (a *APITX) createService(
 	token string, tenantID uuid.UUID, personnelID uuid.UUID, name string,
-	price float64, duration time.Duration,
+	price int64, duration time.Duration,
 ) uuid.UUID {
 	request := schedder.CreateServiceRequest{
 		ServiceName: name,
-		Price: price,
+		Price: schedder.Money{Amount: price},
 		Duration: duration,
 	}
 
@@ -488,18 +530,20 @@ func (a *APITX) setSchedule(token string, personnelID uuid.UUID, tenantID uuid.U
 	a.t.Log(endpoint)
 
 	req, err := NewJSONRequest(
-		http.MethodPost,
+		http.MethodPut,
 		endpoint,
 		schedder.SetScheduleRequest{
-			Weekday: weekday,
-			Starting: starting,
-			Ending: ending,
+			Intervals: []schedder.ScheduleInterval{{
+				Weekday:  weekday,
+				Starting: starting,
+				Ending:   ending,
+			}},
 		},
 	)
-	req.Header.Add( "Authorization", "Bearer " + token)
 	if err != nil {
 		a.t.Fatal(err)
 	}
+	req.Header.Add( "Authorization", "Bearer " + token)
 	w := httptest.NewRecorder()
 
 	a.ServeHTTP(w, req)
@@ -596,3 +640,119 @@ func TestWithInvalidJson(t *testing.T) {
 		})
 	}
 }
+
+func (a *APITX) createInvitation(
+	managerToken string, tenantID uuid.UUID, email string,
+) uuid.UUID {
+	a.t.Helper()
+	request := schedder.CreateInvitationRequest{Email: email}
+	endpoint := fmt.Sprintf("/tenants/%s/invitations", tenantID)
+	r, err := NewJSONRequest(http.MethodPost, endpoint, request)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	r.Header.Add("Authorization", "Bearer "+managerToken)
+	w := httptest.NewRecorder()
+
+	a.ServeHTTP(w, r)
+
+	resp := w.Result()
+	var response schedder.CreateInvitationResponse
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	expect(a.t, "", response.Error)
+	expect(a.t, http.StatusCreated, resp.StatusCode)
+	return response.InvitationID
+}
+
+func (a *APITX) tenantMembers(
+	managerToken string, tenantID uuid.UUID,
+) schedder.TenantMembersResponse {
+	a.t.Helper()
+	endpoint := fmt.Sprintf("/tenants/%s/members", tenantID)
+	r := httptest.NewRequest(http.MethodGet, endpoint, nil)
+	r.Header.Add("Authorization", "Bearer "+managerToken)
+	w := httptest.NewRecorder()
+
+	a.ServeHTTP(w, r)
+
+	resp := w.Result()
+	var response schedder.TenantMembersResponse
+	err := json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	expect(a.t, "", response.Error)
+	expect(a.t, http.StatusOK, resp.StatusCode)
+	return response
+}
+
+func (a *APITX) createLocation(
+	managerToken string, tenantID uuid.UUID, name string,
+) uuid.UUID {
+	a.t.Helper()
+	request := schedder.CreateLocationRequest{
+		Name:      name,
+		Address:   "Strada Lungă 1, Brașov",
+		Latitude:  45.6427,
+		Longitude: 25.5887,
+		Timezone:  "Europe/Bucharest",
+	}
+	endpoint := fmt.Sprintf("/tenants/%s/locations", tenantID)
+	r, err := NewJSONRequest(http.MethodPost, endpoint, request)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	r.Header.Add("Authorization", "Bearer "+managerToken)
+	w := httptest.NewRecorder()
+
+	a.ServeHTTP(w, r)
+
+	resp := w.Result()
+	var response schedder.CreateLocationResponse
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	expect(a.t, "", response.Error)
+	expect(a.t, http.StatusCreated, resp.StatusCode)
+	return response.LocationID
+}
+
+// putAsManager sends a PUT request without a body, as a manager.
+func (a *APITX) putAsManager(managerToken string, endpoint string) {
+	a.t.Helper()
+	r := httptest.NewRequest(http.MethodPut, endpoint, nil)
+	r.Header.Add("Authorization", "Bearer "+managerToken)
+	w := httptest.NewRecorder()
+
+	a.ServeHTTP(w, r)
+
+	expect(a.t, http.StatusOK, w.Result().StatusCode)
+}
+
+// serve serves the request as the account of the token, or anonymously if the
+// token is empty.
+func (a *APITX) serve(token string, r *http.Request) *http.Response {
+	if token != "" {
+		r.Header.Add("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, r)
+	return w.Result()
+}
+
+// send sends the body as JSON, as the account of the token.
+func (a *APITX) send(token, method, endpoint string, body any) *http.Response {
+	a.t.Helper()
+	r, err := NewJSONRequest(method, endpoint, body)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return a.serve(token, r)
+}
@@ -4,32 +4,41 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
 	"gitlab.com/vlad.anghel/schedder-api/database"
 )
 
+// authenticate returns the account of the session in the Authorization
+// header, if there's a valid one.
+func (a *API) authenticate(r *http.Request) (uuid.UUID, bool) {
+	auth := r.Header.Get("Authorization")
+	parts := strings.Split(auth, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return uuid.Nil, false
+	}
+
+	token, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return uuid.Nil, false
+	}
+	authenticatedID, err := a.db.GetSessionAccount(r.Context(), token)
+	if err != nil {
+		return uuid.Nil, false
+	}
+	return authenticatedID, true
+}
+
 // AuthenticatedEndpoint is a middleware that ensures an user is authenticated.
 func (a *API) AuthenticatedEndpoint(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		auth := r.Header.Get("Authorization")
-		parts := strings.Split(auth, " ")
-		if parts[0] != "Bearer" {
-			JsonError(w, http.StatusUnauthorized, "invalid token")
-			return
-		}
-
-		tokenString := parts[1]
-		token, err := base64.RawStdEncoding.DecodeString(tokenString)
-		if err != nil {
-			JsonError(w, http.StatusUnauthorized, "invalid token")
-			return
-		}
-		authenticatedID, err := a.db.GetSessionAccount(r.Context(), token)
-		if err != nil {
+		authenticatedID, ok := a.authenticate(r)
+		if !ok {
 			JsonError(w, http.StatusUnauthorized, "invalid token")
 			return
 		}
@@ -127,12 +136,64 @@ func (a *API) TenantManagerEndpoint(next http.Handler) http.Handler {
 		}
 
 		isManager, err := a.db.IsTenantManager(r.Context(), params)
+		if errors.Is(err, pgx.ErrNoRows) {
+			JsonError(w, http.StatusForbidden, "not manager")
+			return
+		}
 		if err != nil {
 			JsonError(w, http.StatusInternalServerError, "yes")
 			return
 		}
 		if !isManager {
 			JsonError(w, http.StatusForbidden, "not manager")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// VisibleTenantEndpoint is a middleware that hides the tenants that aren't
+// published, like drafts and suspended ones, from everyone except their
+// members and the admins. NOTE: it depends on the WithTenantID middleware.
+func (a *API) VisibleTenantEndpoint(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+
+		status, err := a.db.GetTenantStatus(ctx, tenantID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			JsonError(w, http.StatusNotFound, "invalid tenant")
+			return
+		}
+		if err != nil {
+			JsonError(w, http.StatusInternalServerError, "not implemented")
+			return
+		}
+		if status.Status == database.TenantStatusPublished {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		authenticatedID, ok := a.authenticate(r)
+		if !ok {
+			JsonError(w, http.StatusNotFound, "invalid tenant")
+			return
+		}
+		itmp := database.IsTenantMemberParams{
+			TenantID: tenantID, AccountID: authenticatedID,
+		}
+		isMember, err := a.db.IsTenantMember(ctx, itmp)
+		if err != nil {
+			JsonError(w, http.StatusInternalServerError, "not implemented")
+			return
+		}
+		if !isMember {
+			admin, err := a.db.GetAdminForAccount(ctx, authenticatedID)
+			if err != nil || !admin {
+				JsonError(w, http.StatusNotFound, "invalid tenant")
+				return
+			}
 		}
 
 		next.ServeHTTP(w, r)
@@ -192,3 +253,207 @@ func (a *API) WithServiceID(next http.Handler) http.Handler {
 	})
 }
 
+
+// WithInvitationID is a middleware that ensures the invitationID URL parameter
+// is present and makes it available as an UUID in the context using
+// CtxInvitationID.
+func (a *API) WithInvitationID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		invitationString := chi.URLParam(r, "invitationID")
+
+		invitationID, err := uuid.Parse(invitationString)
+		if err != nil {
+			JsonError(w, http.StatusNotFound, "invalid invitation")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), CtxInvitationID, invitationID)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// WithLocationID is a middleware that ensures the locationID URL parameter is
+// present and makes it available as an UUID in the context using
+// CtxLocationID.
+func (a *API) WithLocationID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		locationString := chi.URLParam(r, "locationID")
+
+		locationID, err := uuid.Parse(locationString)
+		if err != nil {
+			JsonError(w, http.StatusNotFound, "invalid location")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), CtxLocationID, locationID)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// WithClosureID is a middleware that ensures the closureID URL parameter is
+// present and makes it available as an UUID in the context using
+// CtxClosureID.
+func (a *API) WithClosureID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		closureString := chi.URLParam(r, "closureID")
+
+		closureID, err := uuid.Parse(closureString)
+		if err != nil {
+			JsonError(w, http.StatusNotFound, "invalid closure")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), CtxClosureID, closureID)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// WithOptionID is a middleware that ensures the optionID URL parameter is
+// present and makes it available as an UUID in the context using CtxOptionID.
+func (a *API) WithOptionID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		optionString := chi.URLParam(r, "optionID")
+
+		optionID, err := uuid.Parse(optionString)
+		if err != nil {
+			JsonError(w, http.StatusNotFound, "invalid option")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), CtxOptionID, optionID)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// WithCategoryID is a middleware that ensures the categoryID URL parameter is
+// present and makes it available as an UUID in the context using
+// CtxCategoryID.
+func (a *API) WithCategoryID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		categoryString := chi.URLParam(r, "categoryID")
+
+		categoryID, err := uuid.Parse(categoryString)
+		if err != nil {
+			JsonError(w, http.StatusNotFound, "invalid category")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), CtxCategoryID, categoryID)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// WithPackageID is a middleware that ensures the packageID URL parameter is
+// present and makes it available as an UUID in the context using CtxPackageID.
+func (a *API) WithPackageID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		packageString := chi.URLParam(r, "packageID")
+
+		packageID, err := uuid.Parse(packageString)
+		if err != nil {
+			JsonError(w, http.StatusNotFound, "invalid package")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), CtxPackageID, packageID)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// WithRuleID is a middleware that ensures the ruleID URL parameter is present
+// and makes it available as an UUID in the context using CtxRuleID.
+func (a *API) WithRuleID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ruleString := chi.URLParam(r, "ruleID")
+
+		ruleID, err := uuid.Parse(ruleString)
+		if err != nil {
+			JsonError(w, http.StatusNotFound, "invalid rule")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), CtxRuleID, ruleID)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// WithIntervalID is a middleware that ensures the intervalID URL parameter is
+// present and makes it available as an UUID in the context using
+// CtxIntervalID.
+func (a *API) WithIntervalID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		intervalString := chi.URLParam(r, "intervalID")
+
+		intervalID, err := uuid.Parse(intervalString)
+		if err != nil {
+			JsonError(w, http.StatusNotFound, "invalid interval")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), CtxIntervalID, intervalID)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// WithExceptionID is a middleware that ensures the exceptionID URL parameter
+// is present and makes it available as an UUID in the context using
+// CtxExceptionID.
+func (a *API) WithExceptionID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		exceptionString := chi.URLParam(r, "exceptionID")
+
+		exceptionID, err := uuid.Parse(exceptionString)
+		if err != nil {
+			JsonError(w, http.StatusNotFound, "invalid exception")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), CtxExceptionID, exceptionID)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// WithTimeOffID is a middleware that ensures the timeOffID URL parameter is
+// present and makes it available as an UUID in the context using
+// CtxTimeOffID.
+func (a *API) WithTimeOffID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeOffString := chi.URLParam(r, "timeOffID")
+
+		timeOffID, err := uuid.Parse(timeOffString)
+		if err != nil {
+			JsonError(w, http.StatusNotFound, "invalid time off")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), CtxTimeOffID, timeOffID)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// WithLanguage is a middleware that ensures the language URL parameter is an
+// ISO 639-1 code and makes it available in the context using CtxLanguage.
+func (a *API) WithLanguage(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		language := chi.URLParam(r, "language")
+
+		if !validLanguage(language) {
+			JsonError(w, http.StatusNotFound, "invalid language")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), CtxLanguage, language)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
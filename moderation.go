@@ -0,0 +1,312 @@
+package schedder
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"gitlab.com/vlad.anghel/schedder-api/database"
+)
+
+// Moderation actions that admins can take on a tenant.
+const (
+	// ModerationApprove publishes a tenant pending review, or a suspended
+	// one.
+	ModerationApprove = "approve"
+	// ModerationReject sends a tenant pending review back to draft.
+	ModerationReject = "reject"
+	// ModerationSuspend hides a tenant and stops its bookings.
+	ModerationSuspend = "suspend"
+	// ModerationVerify adds the verified badge to a published tenant.
+	ModerationVerify = "verify"
+	// ModerationUnverify removes the verified badge.
+	ModerationUnverify = "unverify"
+
+	// moderationSubmit is logged when a manager submits the tenant for review.
+	moderationSubmit = "submit"
+)
+
+// statusTransition represents the effect of a moderation action on the status
+// of a tenant.
+type statusTransition struct {
+	from []string
+	to   database.TenantStatus
+	// needsReason is set for the actions that have to be explained to the
+	// managers of the tenant.
+	needsReason bool
+}
+
+// statusTransitions maps the actions that change the status of a tenant.
+var statusTransitions = map[string]statusTransition{
+	moderationSubmit: {
+		from: []string{string(database.TenantStatusDraft)},
+		to:   database.TenantStatusPendingReview,
+	},
+	ModerationApprove: {
+		from: []string{
+			string(database.TenantStatusPendingReview),
+			string(database.TenantStatusSuspended),
+		},
+		to: database.TenantStatusPublished,
+	},
+	ModerationReject: {
+		from:        []string{string(database.TenantStatusPendingReview)},
+		to:          database.TenantStatusDraft,
+		needsReason: true,
+	},
+	ModerationSuspend: {
+		from: []string{
+			string(database.TenantStatusPendingReview),
+			string(database.TenantStatusPublished),
+		},
+		to:          database.TenantStatusSuspended,
+		needsReason: true,
+	},
+}
+
+// ModerateTenantRequest represents a request of an admin to moderate a tenant.
+type ModerateTenantRequest struct {
+	// Action represents the moderation action, one of: "approve", "reject",
+	// "suspend", "verify" or "unverify".
+	Action string `json:"action"`
+	// Reason represents the explanation shown to the managers of the tenant,
+	// required for "reject" and "suspend".
+	Reason string `json:"reason,omitempty"`
+}
+
+// TenantStatusResponse represents the response of the tenant status endpoint.
+type TenantStatusResponse struct {
+	Response
+	// Status represents the status of the tenant, one of: "draft",
+	// "pending_review", "published" or "suspended".
+	Status string `json:"status"`
+	// Reason represents the reason of the last rejection or suspension.
+	Reason string `json:"reason,omitempty"`
+	// Verified represents whether the tenant has the verified badge.
+	Verified bool `json:"verified"`
+	// History represents the moderation history, the newest first.
+	History []moderationEntry `json:"history"`
+}
+
+// moderationEntry represents an entry in the moderation history of a tenant.
+type moderationEntry struct {
+	// AccountID represents the account that did the action.
+	AccountID uuid.UUID `json:"account_id"`
+	// Action represents the action, like "submit" or "approve".
+	Action string `json:"action"`
+	// Reason represents the reason given for the action.
+	Reason string `json:"reason,omitempty"`
+	// Date represents when the action was done.
+	Date time.Time `json:"date"`
+}
+
+// pendingTenantEntry represents a tenant waiting for review.
+type pendingTenantEntry struct {
+	// TenantID represents the ID of the tenant.
+	TenantID uuid.UUID `json:"tenant_id"`
+	// Name represents the name of the tenant.
+	Name string `json:"name"`
+}
+
+// PendingTenantsResponse represents the response of the review queue endpoint.
+type PendingTenantsResponse struct {
+	Response
+	// Tenants represents the tenants waiting for review.
+	Tenants []pendingTenantEntry `json:"tenants"`
+}
+
+// changeTenantStatus applies a status transition and logs it, returning an
+// error message for the client if the transition isn't possible.
+func (a *API) changeTenantStatus(
+	ctx context.Context,
+	tenantID, accountID uuid.UUID,
+	action, reason string,
+) (int, string) {
+	transition := statusTransitions[action]
+
+	nullReason := sql.NullString{String: reason, Valid: reason != ""}
+
+	tx, err := a.txlike.Begin(ctx)
+	if err != nil {
+		return http.StatusInternalServerError, "not implemented"
+	}
+	defer tx.Rollback(ctx)
+	queries := database.New(tx)
+
+	stsp := database.SetTenantStatusParams{
+		Status:       transition.to,
+		StatusReason: nullReason,
+		TenantID:     tenantID,
+		FromStatuses: transition.from,
+	}
+	affected, err := queries.SetTenantStatus(ctx, stsp)
+	if err != nil {
+		return http.StatusInternalServerError, "couldn't change status"
+	}
+	if affected != 1 {
+		return http.StatusConflict, "invalid status"
+	}
+
+	lmp := database.LogModerationParams{
+		TenantID:  tenantID,
+		AccountID: accountID,
+		Action:    action,
+		Reason:    nullReason,
+	}
+	err = queries.LogModeration(ctx, lmp)
+	if err != nil {
+		return http.StatusInternalServerError, "not implemented"
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return http.StatusInternalServerError, "couldn't change status"
+	}
+
+	return http.StatusOK, ""
+}
+
+// SubmitTenant submits a draft tenant for review by the admins.
+func (a *API) SubmitTenant(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	authenticatedID := ctx.Value(CtxAuthenticatedID).(uuid.UUID)
+
+	status, msg := a.changeTenantStatus(
+		ctx, tenantID, authenticatedID, moderationSubmit, "",
+	)
+	if msg != "" {
+		JsonError(w, status, msg)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// ModerateTenant lets an admin approve, reject, suspend or verify a tenant.
+func (a *API) ModerateTenant(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	authenticatedID := ctx.Value(CtxAuthenticatedID).(uuid.UUID)
+	request := ctx.Value(CtxJSON).(*ModerateTenantRequest)
+
+	if utf8.RuneCountInString(request.Reason) > 500 {
+		JsonError(w, http.StatusBadRequest, "invalid reason")
+		return
+	}
+
+	if request.Action == ModerationVerify ||
+		request.Action == ModerationUnverify {
+		stvp := database.SetTenantVerifiedParams{
+			Verified: request.Action == ModerationVerify,
+			TenantID: tenantID,
+		}
+		affected, err := a.db.SetTenantVerified(ctx, stvp)
+		if err != nil {
+			JsonError(w, http.StatusInternalServerError, "not implemented")
+			return
+		}
+		if affected != 1 {
+			JsonError(w, http.StatusConflict, "not published")
+			return
+		}
+
+		lmp := database.LogModerationParams{
+			TenantID:  tenantID,
+			AccountID: authenticatedID,
+			Action:    request.Action,
+			Reason: sql.NullString{
+				String: request.Reason,
+				Valid:  request.Reason != "",
+			},
+		}
+		err = a.db.LogModeration(ctx, lmp)
+		if err != nil {
+			JsonError(w, http.StatusInternalServerError, "not implemented")
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	transition, ok := statusTransitions[request.Action]
+	if !ok || request.Action == moderationSubmit {
+		JsonError(w, http.StatusBadRequest, "invalid action")
+		return
+	}
+	if transition.needsReason && request.Reason == "" {
+		JsonError(w, http.StatusBadRequest, "reason required")
+		return
+	}
+
+	status, msg := a.changeTenantStatus(
+		ctx, tenantID, authenticatedID, request.Action, request.Reason,
+	)
+	if msg != "" {
+		JsonError(w, status, msg)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// TenantStatus returns the moderation status and history of the tenant.
+func (a *API) TenantStatus(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+
+	status, err := a.db.GetTenantStatus(ctx, tenantID)
+	if err != nil {
+		JsonError(w, http.StatusNotFound, "invalid tenant")
+		return
+	}
+
+	rows, err := a.db.GetModerationLog(ctx, tenantID)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	response := TenantStatusResponse{
+		Status:   string(status.Status),
+		Reason:   status.StatusReason.String,
+		Verified: status.Verified,
+	}
+	response.History = make([]moderationEntry, 0, len(rows))
+	for _, row := range rows {
+		response.History = append(response.History, moderationEntry{
+			AccountID: row.AccountID,
+			Action:    row.Action,
+			Reason:    row.Reason.String,
+			Date:      row.CreatedAt,
+		})
+	}
+
+	JsonResp(w, http.StatusOK, response)
+}
+
+// PendingTenants lists the tenants waiting for review, for admins.
+func (a *API) PendingTenants(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	rows, err := a.db.GetTenantsPendingReview(ctx)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	var response PendingTenantsResponse
+	response.Tenants = make([]pendingTenantEntry, 0, len(rows))
+	for _, row := range rows {
+		response.Tenants = append(response.Tenants, pendingTenantEntry{
+			TenantID: row.TenantID,
+			Name:     row.TenantName,
+		})
+	}
+
+	JsonResp(w, http.StatusOK, response)
+}
@@ -0,0 +1,222 @@
+package schedder_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"gitlab.com/vlad.anghel/schedder-api"
+)
+
+// listedTenant returns the entry of the tenant in the public listing, if it's
+// listed.
+func listedTenant(t *testing.T, api *APITX, tenantID uuid.UUID) (bool, bool) {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, "/tenants", nil)
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, r)
+
+	var response schedder.TenantsResponse
+	err := json.NewDecoder(w.Result().Body).Decode(&response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tenant := range response.Tenants {
+		if tenant.TenantID == tenantID {
+			return true, tenant.Verified
+		}
+	}
+	return false, false
+}
+
+func moderate(
+	t *testing.T, api *APITX, token string, tenantID uuid.UUID,
+	request schedder.ModerateTenantRequest,
+) *http.Response {
+	t.Helper()
+	endpoint := fmt.Sprintf("/tenants/%s/moderation", tenantID)
+	r, err := NewJSONRequest(http.MethodPost, endpoint, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Add("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, r)
+	return w.Result()
+}
+
+func TestTenantModeration(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantName := "Zâna Măseluță"
+
+	tenantID := api.createTenantAndAccount(email, password, tenantName)
+	token := api.generateToken(email, password)
+
+	adminEmail := "admin@example.com"
+	adminPassword := "some_password"
+	api.registerUserByEmail(adminEmail, adminPassword)
+	api.activateUserByEmail(adminEmail)
+	api.forceAdmin(adminEmail, true)
+	adminToken := api.generateToken(adminEmail, adminPassword)
+
+	listed, _ := listedTenant(t, api, tenantID)
+	expect(t, false, listed)
+
+	endpoint := fmt.Sprintf("/tenants/%s/submit", tenantID)
+	r := httptest.NewRequest(http.MethodPost, endpoint, nil)
+	r.Header.Add("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, r)
+	expect(t, http.StatusOK, w.Result().StatusCode)
+
+	r = httptest.NewRequest(http.MethodGet, "/tenants/pending", nil)
+	r.Header.Add("Authorization", "Bearer "+adminToken)
+	w = httptest.NewRecorder()
+	api.ServeHTTP(w, r)
+
+	var pending schedder.PendingTenantsResponse
+	err := json.NewDecoder(w.Result().Body).Decode(&pending)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, tenant := range pending.Tenants {
+		if tenant.TenantID == tenantID {
+			found = true
+		}
+	}
+	expect(t, true, found)
+
+	resp := moderate(t, api, adminToken, tenantID, schedder.ModerateTenantRequest{
+		Action: schedder.ModerationApprove,
+	})
+	expect(t, http.StatusOK, resp.StatusCode)
+	resp = moderate(t, api, adminToken, tenantID, schedder.ModerateTenantRequest{
+		Action: schedder.ModerationVerify,
+	})
+	expect(t, http.StatusOK, resp.StatusCode)
+
+	listed, verified := listedTenant(t, api, tenantID)
+	expect(t, true, listed)
+	expect(t, true, verified)
+
+	endpoint = fmt.Sprintf("/tenants/%s/status", tenantID)
+	r = httptest.NewRequest(http.MethodGet, endpoint, nil)
+	r.Header.Add("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	api.ServeHTTP(w, r)
+
+	var status schedder.TenantStatusResponse
+	err = json.NewDecoder(w.Result().Body).Decode(&status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, "published", status.Status)
+	expect(t, 3, len(status.History))
+}
+
+func TestSuspendTenant(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantName := "Zâna Măseluță"
+
+	tenantID := api.createTenantAndAccount(email, password, tenantName)
+	api.publishTenant(tenantID)
+
+	adminEmail := "admin@example.com"
+	adminPassword := "some_password"
+	api.registerUserByEmail(adminEmail, adminPassword)
+	api.activateUserByEmail(adminEmail)
+	api.forceAdmin(adminEmail, true)
+	adminToken := api.generateToken(adminEmail, adminPassword)
+
+	resp := moderate(t, api, adminToken, tenantID, schedder.ModerateTenantRequest{
+		Action: schedder.ModerationSuspend,
+	})
+	var response schedder.Response
+	err := json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, "reason required", response.Error)
+	expect(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = moderate(t, api, adminToken, tenantID, schedder.ModerateTenantRequest{
+		Action: schedder.ModerationSuspend,
+		Reason: "fake reviews",
+	})
+	expect(t, http.StatusOK, resp.StatusCode)
+
+	listed, _ := listedTenant(t, api, tenantID)
+	expect(t, false, listed)
+
+	// only the members and the admins can still reach it
+	endpoint := fmt.Sprintf("/tenants/%s/services", tenantID)
+	get := func(token string) int {
+		r := httptest.NewRequest(http.MethodGet, endpoint, nil)
+		if token != "" {
+			r.Header.Add("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, r)
+		return w.Result().StatusCode
+	}
+	expect(t, http.StatusNotFound, get(""))
+	expect(t, http.StatusOK, get(api.generateToken(email, password)))
+	expect(t, http.StatusOK, get(adminToken))
+
+	customerEmail := "customer@example.com"
+	api.registerUserByEmail(customerEmail, password)
+	api.activateUserByEmail(customerEmail)
+	expect(t, http.StatusNotFound, get(api.generateToken(customerEmail, password)))
+
+	// a suspended tenant can't be rejected
+	resp = moderate(t, api, adminToken, tenantID, schedder.ModerateTenantRequest{
+		Action: schedder.ModerationReject,
+		Reason: "still fake",
+	})
+	expect(t, http.StatusConflict, resp.StatusCode)
+}
+
+func TestTooManyUnpublishedTenants(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+
+	api.createTenantAndAccount(email, password, "Zâna Măseluță 1")
+	token := api.generateToken(email, password)
+	api.createTenant(token, "Zâna Măseluță 2")
+	api.createTenant(token, "Zâna Măseluță 3")
+
+	request := schedder.CreateTenantRequest{Name: "Zâna Măseluță 4"}
+	r, err := NewJSONRequest(http.MethodPost, "/tenants", request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Add("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, r)
+
+	var response schedder.Response
+	err = json.NewDecoder(w.Result().Body).Decode(&response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, "too many unpublished tenants", response.Error)
+	expect(t, http.StatusBadRequest, w.Result().StatusCode)
+}
@@ -0,0 +1,195 @@
+package schedder
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gitlab.com/vlad.anghel/schedder-api/database"
+)
+
+// maxPrice represents the maximum price of a service or option, in minor
+// units.
+const maxPrice = 100_000_000
+
+// maxVATRate represents the maximum VAT rate, in basis points.
+const maxVATRate = 10000
+
+// currencyExponents represents the number of decimals of the minor units of
+// the supported ISO 4217 currencies.
+var currencyExponents = map[string]int{
+	"BGN": 2, "CHF": 2, "CZK": 2, "DKK": 2, "EUR": 2, "GBP": 2, "HUF": 2,
+	"ISK": 0, "JPY": 0, "MDL": 2, "NOK": 2, "PLN": 2, "RON": 2, "SEK": 2,
+	"USD": 2,
+}
+
+// Money represents an amount in the minor units of a currency, like bani for
+// RON.
+type Money struct {
+	// Amount represents the amount in minor units, like 4250 for 42.50 RON.
+	Amount int64 `json:"amount"`
+	// Currency represents the ISO 4217 code of the currency, like "RON".
+	Currency string `json:"currency"`
+}
+
+// ValidCurrency reports whether the ISO 4217 currency is supported.
+func ValidCurrency(currency string) bool {
+	_, ok := currencyExponents[currency]
+	return ok
+}
+
+// String formats the amount with the decimals of its currency, like
+// "42.50 RON".
+func (m Money) String() string {
+	exponent := currencyExponents[m.Currency]
+	if exponent == 0 {
+		return fmt.Sprintf("%d %s", m.Amount, m.Currency)
+	}
+
+	sign := ""
+	amount := m.Amount
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	unit := int64(1)
+	for i := 0; i < exponent; i++ {
+		unit *= 10
+	}
+	return fmt.Sprintf(
+		"%s%d.%0*d %s", sign, amount/unit, exponent, amount%unit, m.Currency,
+	)
+}
+
+// WithVAT returns the amount with the VAT rate in basis points added, rounded
+// half up to minor units.
+func (m Money) WithVAT(vatRate int) Money {
+	tax := (m.Amount*int64(vatRate) + maxVATRate/2) / maxVATRate
+	return Money{Amount: m.Amount + tax, Currency: m.Currency}
+}
+
+// tenantPricing represents the currency and the VAT rate of a tenant.
+type tenantPricing struct {
+	currency string
+	vatRate  int
+}
+
+// pricingFor returns the currency and the VAT rate of the tenant.
+func (a *API) pricingFor(
+	ctx context.Context, tenantID uuid.UUID,
+) (tenantPricing, error) {
+	row, err := a.db.GetTenantPricing(ctx, tenantID)
+	if err != nil {
+		return tenantPricing{}, err
+	}
+	return tenantPricing{currency: row.Currency, vatRate: int(row.VatRate)}, nil
+}
+
+// price returns the amount in the currency of the tenant.
+func (p tenantPricing) price(amount int64) Money {
+	return Money{Amount: amount, Currency: p.currency}
+}
+
+// total returns the amount in the currency of the tenant, with VAT.
+func (p tenantPricing) total(amount int64) Money {
+	return p.price(amount).WithVAT(p.vatRate)
+}
+
+// validPrice checks a price sent by a client, which must be in the currency
+// of the tenant. The currency can be left empty.
+func (p tenantPricing) validPrice(price Money) string {
+	if price.Currency != "" && price.Currency != p.currency {
+		return "invalid currency"
+	}
+	if price.Amount < 0 || price.Amount > maxPrice {
+		return "invalid price"
+	}
+	return ""
+}
+
+// validService checks the price and the duration of a service, returning an
+// error message for the client if they're invalid.
+func (p tenantPricing) validService(price Money, duration time.Duration) string {
+	msg := p.validPrice(price)
+	if msg != "" {
+		return msg
+	}
+	if duration < 0 || duration%time.Minute != 0 {
+		return "invalid duration"
+	}
+	return ""
+}
+
+// SetTenantPricingRequest represents the currency and the VAT rate of a
+// tenant.
+type SetTenantPricingRequest struct {
+	// Currency represents the ISO 4217 code of the currency of the prices.
+	// The prices aren't converted, so it can only be changed while the tenant
+	// has no services and no packages.
+	Currency string `json:"currency"`
+	// VATRate represents the VAT rate in basis points, 1900 for 19%. The
+	// prices are before VAT.
+	VATRate int `json:"vat_rate"`
+}
+
+// TenantPricingResponse represents the currency and the VAT rate of a tenant.
+type TenantPricingResponse struct {
+	Response
+	Currency string `json:"currency"`
+	VATRate  int    `json:"vat_rate"`
+}
+
+// TenantPricing returns the currency and the VAT rate of the tenant.
+func (a *API) TenantPricing(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+
+	pricing, err := a.pricingFor(ctx, tenantID)
+	if err != nil {
+		JsonError(w, http.StatusNotFound, "invalid tenant")
+		return
+	}
+
+	JsonResp(w, http.StatusOK, TenantPricingResponse{
+		Currency: pricing.currency,
+		VATRate:  pricing.vatRate,
+	})
+}
+
+// SetTenantPricing changes the currency and the VAT rate of the tenant. The
+// booked appointments keep the ones they were booked with. Changing the
+// currency is a conflict while there are prices in the old one.
+func (a *API) SetTenantPricing(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	request := ctx.Value(CtxJSON).(*SetTenantPricingRequest)
+
+	request.Currency = strings.ToUpper(request.Currency)
+	if !ValidCurrency(request.Currency) {
+		JsonError(w, http.StatusBadRequest, "invalid currency")
+		return
+	}
+	if request.VATRate < 0 || request.VATRate > maxVATRate {
+		JsonError(w, http.StatusBadRequest, "invalid VAT rate")
+		return
+	}
+
+	stpp := database.SetTenantPricingParams{
+		Currency: request.Currency,
+		VatRate:  int32(request.VATRate),
+		TenantID: tenantID,
+	}
+	affected, err := a.db.SetTenantPricing(ctx, stpp)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	if affected == 0 {
+		JsonError(w, http.StatusConflict, "tenant has prices")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,110 @@
+package schedder_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"gitlab.com/vlad.anghel/schedder-api"
+)
+
+func TestMoney(t *testing.T) {
+	t.Parallel()
+
+	price := schedder.Money{Amount: 4250, Currency: "RON"}
+	expect(t, "42.50 RON", price.String())
+	expect(t, "-0.05 EUR", schedder.Money{Amount: -5, Currency: "EUR"}.String())
+	expect(t, "1500 JPY", schedder.Money{Amount: 1500, Currency: "JPY"}.String())
+
+	// 19% of 42.50 is 8.075, rounded half up
+	expect(t, schedder.Money{Amount: 5058, Currency: "RON"}, price.WithVAT(1900))
+	expect(t, price, price.WithVAT(0))
+
+	expect(t, true, schedder.ValidCurrency("EUR"))
+	expect(t, false, schedder.ValidCurrency("eur"))
+	expect(t, false, schedder.ValidCurrency("XYZ"))
+}
+
+func TestTenantPricing(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantName := "Zâna Măseluță"
+
+	tenantID := api.createTenantAndAccount(email, password, tenantName)
+	accountID := api.findAccountByEmail(email)
+	token := api.generateToken(email, password)
+	api.publishTenant(tenantID)
+
+	tenantEndpoint := fmt.Sprintf("/tenants/%s", tenantID)
+	resp := api.send(token, http.MethodGet, tenantEndpoint+"/pricing", nil)
+	var pricing schedder.TenantPricingResponse
+	err := json.NewDecoder(resp.Body).Decode(&pricing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, "RON", pricing.Currency)
+	expect(t, 0, pricing.VATRate)
+
+	resp = api.send(
+		token, http.MethodPut, tenantEndpoint+"/pricing",
+		schedder.SetTenantPricingRequest{Currency: "XYZ", VATRate: 1900},
+	)
+	expect(t, http.StatusBadRequest, resp.StatusCode)
+	resp = api.send(
+		token, http.MethodPut, tenantEndpoint+"/pricing",
+		schedder.SetTenantPricingRequest{Currency: "RON", VATRate: 10001},
+	)
+	expect(t, http.StatusBadRequest, resp.StatusCode)
+	resp = api.send(
+		token, http.MethodPut, tenantEndpoint+"/pricing",
+		schedder.SetTenantPricingRequest{Currency: "eur", VATRate: 1900},
+	)
+	expect(t, http.StatusOK, resp.StatusCode)
+
+	// the prices aren't converted, so the currency can't change anymore
+	serviceID := api.createService(token, tenantID, accountID, "Tuns", 5000, time.Hour)
+	resp = api.send(
+		token, http.MethodPut, tenantEndpoint+"/pricing",
+		schedder.SetTenantPricingRequest{Currency: "RON", VATRate: 1900},
+	)
+	expect(t, http.StatusConflict, resp.StatusCode)
+	resp = api.send(
+		token, http.MethodPut, tenantEndpoint+"/pricing",
+		schedder.SetTenantPricingRequest{Currency: "EUR", VATRate: 900},
+	)
+	expect(t, http.StatusOK, resp.StatusCode)
+	resp = api.send(
+		token, http.MethodPut, tenantEndpoint+"/pricing",
+		schedder.SetTenantPricingRequest{Currency: "EUR", VATRate: 1900},
+	)
+	expect(t, http.StatusOK, resp.StatusCode)
+
+	// a price in another currency is rejected
+	resp = api.send(
+		token, http.MethodPut, fmt.Sprintf("%s/services/%s", tenantEndpoint, serviceID),
+		schedder.UpdateServiceRequest{
+			ServiceName: "Tuns",
+			Price:       schedder.Money{Amount: 5000, Currency: "RON"},
+			Duration:    time.Hour,
+		},
+	)
+	expect(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = api.send(token, http.MethodGet, tenantEndpoint+"/services", nil)
+	var services schedder.ServicesForTenantResponse
+	err = json.NewDecoder(resp.Body).Decode(&services)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, 1, len(services.Categories))
+	expect(t, 1, len(services.Categories[0].Services))
+	service := services.Categories[0].Services[0]
+	expect(t, schedder.Money{Amount: 5000, Currency: "EUR"}, service.Price)
+	expect(t, schedder.Money{Amount: 5950, Currency: "EUR"}, service.Total)
+}
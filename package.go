@@ -0,0 +1,387 @@
+package schedder
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"gitlab.com/vlad.anghel/schedder-api/database"
+)
+
+// maxPackageQuantity represents the maximum number of appointments of a
+// service in a package.
+const maxPackageQuantity = 100
+
+var (
+	// errInvalidPurchase is returned when the purchase isn't one of the
+	// customer or doesn't include the service.
+	errInvalidPurchase = errors.New("invalid purchase")
+	// errNoCredits is returned when all the credits for the service were
+	// used.
+	errNoCredits = errors.New("no credits left")
+)
+
+// packageServiceEntry represents a service included in a package.
+type packageServiceEntry struct {
+	ServiceID uuid.UUID `json:"service_id"`
+	// Quantity represents the number of appointments of the service.
+	Quantity int `json:"quantity"`
+}
+
+// CreatePackageRequest represents a new package of services sold together.
+type CreatePackageRequest struct {
+	// PackageName represents the name of the package, like "Bridal package".
+	PackageName string `json:"package_name"`
+	Description string `json:"description"`
+	// Price represents the price of the whole package before VAT, in the
+	// currency of the tenant.
+	Price    Money                 `json:"price"`
+	Services []packageServiceEntry `json:"services"`
+}
+
+// CreatePackageResponse represents the response of the package creation
+// endpoint.
+type CreatePackageResponse struct {
+	Response
+	PackageID uuid.UUID `json:"package_id"`
+}
+
+// packageContentEntry represents a service included in a package, with its
+// name.
+type packageContentEntry struct {
+	ServiceID   uuid.UUID `json:"service_id"`
+	ServiceName string    `json:"service_name"`
+	Quantity    int       `json:"quantity"`
+}
+
+// packageEntry represents a package of a tenant.
+type packageEntry struct {
+	PackageID   uuid.UUID `json:"package_id"`
+	PackageName string    `json:"package_name"`
+	Description string    `json:"description"`
+	Price       Money     `json:"price"`
+	// Total represents the price with VAT.
+	Total    Money                 `json:"total"`
+	Services []packageContentEntry `json:"services"`
+}
+
+// PackagesResponse represents the packages sold by a tenant.
+type PackagesResponse struct {
+	Response
+	Packages []packageEntry `json:"packages"`
+}
+
+// PurchasePackageResponse represents the response of the package purchase
+// endpoint.
+type PurchasePackageResponse struct {
+	Response
+	PurchaseID uuid.UUID `json:"purchase_id"`
+	Price      Money     `json:"price"`
+	// Total represents the price with VAT.
+	Total Money `json:"total"`
+}
+
+// creditEntry represents the credits of a purchase for a service.
+type creditEntry struct {
+	ServiceID   uuid.UUID `json:"service_id"`
+	ServiceName string    `json:"service_name"`
+	Quantity    int       `json:"quantity"`
+	// Used represents the credits used by appointments which weren't
+	// cancelled.
+	Used      int `json:"used"`
+	Remaining int `json:"remaining"`
+}
+
+// purchaseEntry represents a package bought by the customer. The name, price
+// and VAT rate are the ones from when the package was bought.
+type purchaseEntry struct {
+	PurchaseID  uuid.UUID `json:"purchase_id"`
+	PackageID   uuid.UUID `json:"package_id"`
+	TenantID    uuid.UUID `json:"tenant_id"`
+	PackageName string    `json:"package_name"`
+	Price       Money     `json:"price"`
+	// Total represents the price with the VAT rate from when the package was
+	// bought.
+	Total       Money         `json:"total"`
+	VATRate     int           `json:"vat_rate"`
+	PurchasedAt time.Time     `json:"purchased_at"`
+	Credits     []creditEntry `json:"credits"`
+}
+
+// PurchasesResponse represents the response of the purchases endpoint.
+type PurchasesResponse struct {
+	Response
+	Purchases []purchaseEntry `json:"purchases"`
+}
+
+// CreatePackage adds a package of services of the catalogue to the tenant.
+func (a *API) CreatePackage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	request := ctx.Value(CtxJSON).(*CreatePackageRequest)
+
+	if request.PackageName == "" {
+		JsonError(w, http.StatusBadRequest, "invalid package name")
+		return
+	}
+	if len(request.Services) == 0 {
+		JsonError(w, http.StatusBadRequest, "package needs services")
+		return
+	}
+	seen := make(map[uuid.UUID]bool, len(request.Services))
+	for _, service := range request.Services {
+		if seen[service.ServiceID] {
+			JsonError(w, http.StatusBadRequest, "invalid service")
+			return
+		}
+		seen[service.ServiceID] = true
+		if service.Quantity < 1 || service.Quantity > maxPackageQuantity {
+			JsonError(w, http.StatusBadRequest, "invalid quantity")
+			return
+		}
+	}
+
+	pricing, err := a.pricingFor(ctx, tenantID)
+	if err != nil {
+		JsonError(w, http.StatusNotFound, "invalid tenant")
+		return
+	}
+	msg := pricing.validPrice(request.Price)
+	if msg != "" {
+		JsonError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	tx, err := a.txlike.Begin(ctx)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	defer tx.Rollback(ctx)
+	queries := database.New(tx)
+
+	cpp := database.CreatePackageParams{
+		TenantID:    tenantID,
+		PackageName: request.PackageName,
+		Description: request.Description,
+		Price:       request.Price.Amount,
+	}
+	packageID, err := queries.CreatePackage(ctx, cpp)
+	if err != nil {
+		JsonError(w, http.StatusBadRequest, "couldn't create package")
+		return
+	}
+
+	for _, service := range request.Services {
+		apsp := database.AddPackageServiceParams{
+			PackageID: packageID,
+			Quantity:  int32(service.Quantity),
+			TenantID:  tenantID,
+			ServiceID: service.ServiceID,
+		}
+		affected, err := queries.AddPackageService(ctx, apsp)
+		if err != nil {
+			JsonError(w, http.StatusInternalServerError, "not implemented")
+			return
+		}
+		if affected == 0 {
+			JsonError(w, http.StatusBadRequest, "invalid service")
+			return
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	response := CreatePackageResponse{PackageID: packageID}
+	JsonResp(w, http.StatusCreated, response)
+}
+
+// ArchivePackage stops the sales of a package, the credits which were bought
+// can still be used.
+func (a *API) ArchivePackage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	packageID := ctx.Value(CtxPackageID).(uuid.UUID)
+
+	app := database.ArchivePackageParams{
+		TenantID:  tenantID,
+		PackageID: packageID,
+	}
+	affected, err := a.db.ArchivePackage(ctx, app)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	if affected == 0 {
+		JsonError(w, http.StatusNotFound, "invalid package")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Packages lists the packages sold by the tenant.
+func (a *API) Packages(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+
+	pricing, err := a.pricingFor(ctx, tenantID)
+	if err != nil {
+		JsonError(w, http.StatusNotFound, "invalid tenant")
+		return
+	}
+
+	rows, err := a.db.GetPackages(ctx, tenantID)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	serviceRows, err := a.db.GetPackageServicesForTenant(ctx, tenantID)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	contents := make(map[uuid.UUID][]packageContentEntry)
+	for _, row := range serviceRows {
+		contents[row.PackageID] = append(contents[row.PackageID], packageContentEntry{
+			ServiceID:   row.ServiceID,
+			ServiceName: row.ServiceName,
+			Quantity:    int(row.Quantity),
+		})
+	}
+
+	var response PackagesResponse
+	response.Packages = make([]packageEntry, 0, len(rows))
+	for _, row := range rows {
+		response.Packages = append(response.Packages, packageEntry{
+			PackageID:   row.PackageID,
+			PackageName: row.PackageName,
+			Description: row.Description,
+			Price:       pricing.price(row.Price),
+			Total:       pricing.total(row.Price),
+			Services:    contents[row.PackageID],
+		})
+	}
+
+	JsonResp(w, http.StatusOK, response)
+}
+
+// PurchasePackage buys a package for the authenticated account, its services
+// can then be booked with the credits of the purchase.
+func (a *API) PurchasePackage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	packageID := ctx.Value(CtxPackageID).(uuid.UUID)
+	authenticatedID := ctx.Value(CtxAuthenticatedID).(uuid.UUID)
+
+	status, err := a.db.GetTenantStatus(ctx, tenantID)
+	if err != nil {
+		JsonError(w, http.StatusNotFound, "invalid tenant")
+		return
+	}
+	if status.Status != database.TenantStatusPublished {
+		JsonError(w, http.StatusBadRequest, errTenantNotBookable.Error())
+		return
+	}
+
+	tx, err := a.txlike.Begin(ctx)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	defer tx.Rollback(ctx)
+	queries := database.New(tx)
+
+	cpp := database.CreatePurchaseParams{
+		AccountID: authenticatedID,
+		TenantID:  tenantID,
+		PackageID: packageID,
+	}
+	purchase, err := queries.CreatePurchase(ctx, cpp)
+	if errors.Is(err, pgx.ErrNoRows) {
+		JsonError(w, http.StatusNotFound, "invalid package")
+		return
+	}
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	apcp := database.AddPurchaseCreditsParams{
+		PurchaseID: purchase.PurchaseID,
+		PackageID:  packageID,
+	}
+	err = queries.AddPurchaseCredits(ctx, apcp)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	price := Money{Amount: purchase.Price, Currency: purchase.Currency}
+	JsonResp(w, http.StatusCreated, PurchasePackageResponse{
+		PurchaseID: purchase.PurchaseID,
+		Price:      price,
+		Total:      price.WithVAT(int(purchase.VatRate)),
+	})
+}
+
+// Purchases lists the packages bought by the authenticated account, newest
+// first, with the credits left.
+func (a *API) Purchases(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	authenticatedID := ctx.Value(CtxAuthenticatedID).(uuid.UUID)
+
+	rows, err := a.db.GetPurchasesForAccount(ctx, authenticatedID)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	creditRows, err := a.db.GetPurchaseCreditsForAccount(ctx, authenticatedID)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	credits := make(map[uuid.UUID][]creditEntry)
+	for _, row := range creditRows {
+		credits[row.PurchaseID] = append(credits[row.PurchaseID], creditEntry{
+			ServiceID:   row.ServiceID,
+			ServiceName: row.ServiceName,
+			Quantity:    int(row.Quantity),
+			Used:        int(row.Used),
+			Remaining:   int(row.Quantity - row.Used),
+		})
+	}
+
+	var response PurchasesResponse
+	response.Purchases = make([]purchaseEntry, 0, len(rows))
+	for _, row := range rows {
+		price := Money{Amount: row.Price, Currency: row.Currency}
+		response.Purchases = append(response.Purchases, purchaseEntry{
+			PurchaseID:  row.PurchaseID,
+			PackageID:   row.PackageID,
+			TenantID:    row.TenantID,
+			PackageName: row.PackageName,
+			Price:       price,
+			Total:       price.WithVAT(int(row.VatRate)),
+			VATRate:     int(row.VatRate),
+			PurchasedAt: row.PurchasedAt,
+			Credits:     credits[row.PurchaseID],
+		})
+	}
+
+	JsonResp(w, http.StatusOK, response)
+}
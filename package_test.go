@@ -0,0 +1,145 @@
+package schedder_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gitlab.com/vlad.anghel/schedder-api"
+)
+
+func TestPackages(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantName := "Zâna Măseluță"
+
+	tenantID := api.createTenantAndAccount(email, password, tenantName)
+	accountID := api.findAccountByEmail(email)
+	token := api.generateToken(email, password)
+	api.publishTenant(tenantID)
+	massage := api.createService(token, tenantID, accountID, "Masaj", 10000, time.Hour)
+	manicure := api.createService(token, tenantID, accountID, "Manichiură", 5000, time.Hour)
+
+	today := localDate(time.Now())
+	starting := hourOn(today, 10)
+	api.setSchedule(token, accountID, tenantID, starting, starting.Add(8*time.Hour), today.Weekday())
+
+	tenantEndpoint := fmt.Sprintf("/tenants/%s", tenantID)
+	resp := api.send(token, http.MethodPost, tenantEndpoint+"/packages", schedder.CreatePackageRequest{
+		PackageName: "2 masaje",
+		Price:       schedder.Money{Amount: 15000},
+		Services:    nil,
+	})
+	expect(t, http.StatusBadRequest, resp.StatusCode)
+
+	request := schedder.CreatePackageRequest{
+		PackageName: "2 masaje",
+		Price:       schedder.Money{Amount: 15000},
+	}
+	request.Services = append(request.Services, struct {
+		ServiceID uuid.UUID `json:"service_id"`
+		Quantity  int       `json:"quantity"`
+	}{massage, 2})
+	resp = api.send(token, http.MethodPost, tenantEndpoint+"/packages", request)
+	var created schedder.CreatePackageResponse
+	err := json.NewDecoder(resp.Body).Decode(&created)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, "", created.Error)
+	expect(t, http.StatusCreated, resp.StatusCode)
+
+	resp = api.send(token, http.MethodGet, tenantEndpoint+"/packages", nil)
+	var packages schedder.PackagesResponse
+	err = json.NewDecoder(resp.Body).Decode(&packages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, 1, len(packages.Packages))
+	expect(t, int64(15000), packages.Packages[0].Price.Amount)
+	expect(t, 1, len(packages.Packages[0].Services))
+	expect(t, 2, packages.Packages[0].Services[0].Quantity)
+
+	packageEndpoint := fmt.Sprintf("%s/packages/%s", tenantEndpoint, created.PackageID)
+	resp = api.send(token, http.MethodPost, packageEndpoint+"/purchase", nil)
+	var purchase schedder.PurchasePackageResponse
+	err = json.NewDecoder(resp.Body).Decode(&purchase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, http.StatusCreated, resp.StatusCode)
+	expect(t, int64(15000), purchase.Price.Amount)
+
+	book := func(serviceID uuid.UUID, at time.Time) *http.Response {
+		return api.send(
+			token, http.MethodPost,
+			fmt.Sprintf("%s/services/%s/schedule", tenantEndpoint, serviceID),
+			schedder.CreateAppointmentRequest{
+				Starting:   at,
+				PurchaseID: purchase.PurchaseID,
+			},
+		)
+	}
+
+	// the package doesn't include the manicure
+	resp = book(manicure, starting)
+	expect(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = book(massage, starting)
+	var booked schedder.CreateAppointmentResponse
+	err = json.NewDecoder(resp.Body).Decode(&booked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, http.StatusCreated, resp.StatusCode)
+	expect(t, int64(0), booked.Price.Amount)
+
+	// the service can't be archived while it's owed
+	serviceEndpoint := fmt.Sprintf("%s/services/%s", tenantEndpoint, massage)
+	resp = api.send(token, http.MethodDelete, serviceEndpoint, nil)
+	expect(t, http.StatusConflict, resp.StatusCode)
+	resp = book(massage, starting.Add(2*time.Hour))
+	expect(t, http.StatusCreated, resp.StatusCode)
+	resp = book(massage, starting.Add(4*time.Hour))
+	expect(t, http.StatusBadRequest, resp.StatusCode)
+
+	// archived packages can't be bought, the credits are kept
+	resp = api.send(token, http.MethodDelete, packageEndpoint, nil)
+	expect(t, http.StatusOK, resp.StatusCode)
+	resp = api.send(token, http.MethodPost, packageEndpoint+"/purchase", nil)
+	expect(t, http.StatusNotFound, resp.StatusCode)
+
+	resp = api.send(token, http.MethodGet, "/accounts/self/purchases", nil)
+	var purchases schedder.PurchasesResponse
+	err = json.NewDecoder(resp.Body).Decode(&purchases)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, 1, len(purchases.Purchases))
+	expect(t, "2 masaje", purchases.Purchases[0].PackageName)
+	expect(t, 1, len(purchases.Purchases[0].Credits))
+	expect(t, 2, purchases.Purchases[0].Credits[0].Used)
+	expect(t, 0, purchases.Purchases[0].Credits[0].Remaining)
+
+	resp = api.send(token, http.MethodGet, "/accounts/self/appointments", nil)
+	var appointments schedder.AppointmentsResponse
+	err = json.NewDecoder(resp.Body).Decode(&appointments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, 2, len(appointments.Appointments))
+	for _, appointment := range appointments.Appointments {
+		expect(t, purchase.PurchaseID, appointment.PurchaseID)
+	}
+
+	// the credits are used up and the package can't be bought anymore
+	resp = api.send(token, http.MethodDelete, serviceEndpoint, nil)
+	expect(t, http.StatusOK, resp.StatusCode)
+}
@@ -0,0 +1,175 @@
+package schedder
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"gitlab.com/vlad.anghel/schedder-api/database"
+)
+
+// personnelProfileEntry represents the public profile of a member.
+type personnelProfileEntry struct {
+	// AccountID represents the ID of the member.
+	AccountID uuid.UUID `json:"account_id"`
+	// DisplayName represents the name shown to the customers, the name of
+	// the account if the member didn't choose one.
+	DisplayName string `json:"display_name"`
+	// Bio represents a short presentation of the member.
+	Bio string `json:"bio"`
+	// Specialties represents what the member is good at, like "fade".
+	Specialties []string `json:"specialties"`
+	// Rating represents the average rating of the reviews of the
+	// appointments done by the member, it's 0 if there are no reviews.
+	Rating float64 `json:"rating"`
+	// ReviewCount represents the number of reviews of the rating.
+	ReviewCount int `json:"review_count"`
+	// PhotoURL represents the path of the profile photo, it's empty if the
+	// member has no photo.
+	PhotoURL string `json:"photo_url"`
+}
+
+// PersonnelResponse represents the response of the public personnel listing
+// endpoint.
+type PersonnelResponse struct {
+	Response
+	// Personnel represents the members of the tenant.
+	Personnel []personnelProfileEntry `json:"personnel"`
+}
+
+// UpdatePersonnelProfileRequest represents a request to update the public
+// profile of a member, all the fields are replaced.
+type UpdatePersonnelProfileRequest struct {
+	// DisplayName represents the name shown to the customers, at most 80
+	// characters. The name of the account is shown if it's empty.
+	DisplayName string `json:"display_name"`
+	// Bio represents a short presentation, at most 1000 characters.
+	Bio string `json:"bio"`
+	// Specialties represents at most 10 specialties, each at most 40
+	// characters.
+	Specialties []string `json:"specialties"`
+}
+
+// validPersonnelProfile checks the profile, returning an error message for the
+// client if it's invalid.
+func validPersonnelProfile(request *UpdatePersonnelProfileRequest) string {
+	if utf8.RuneCountInString(request.DisplayName) > 80 {
+		return "invalid display name"
+	}
+	if utf8.RuneCountInString(request.Bio) > 1000 {
+		return "invalid bio"
+	}
+	if len(request.Specialties) > 10 {
+		return "too many specialties"
+	}
+	for _, specialty := range request.Specialties {
+		runes := utf8.RuneCountInString(specialty)
+		if strings.TrimSpace(specialty) == "" || runes > 40 {
+			return "invalid specialty"
+		}
+	}
+	return ""
+}
+
+// accountPhotoURL returns the path of the public profile photo of an account.
+func accountPhotoURL(accountID uuid.UUID) string {
+	return fmt.Sprintf("/accounts/%s/photo?size=%s", accountID, PhotoSizeMedium)
+}
+
+// Personnel lists the public profiles of the members of a tenant.
+func (a *API) Personnel(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+
+	rows, err := a.db.GetPublicPersonnel(ctx, tenantID)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	var response PersonnelResponse
+	response.Personnel = make([]personnelProfileEntry, 0, len(rows))
+	for _, row := range rows {
+		entry := personnelProfileEntry{
+			AccountID:   row.AccountID,
+			DisplayName: row.DisplayName,
+			Bio:         row.Bio,
+			Specialties: row.Specialties,
+		}
+		if entry.Specialties == nil {
+			entry.Specialties = []string{}
+		}
+		if row.Rating.Valid {
+			entry.Rating = row.Rating.Float64
+		}
+		if row.ReviewCount.Valid {
+			entry.ReviewCount = int(row.ReviewCount.Int64)
+		}
+		if row.Sha256sum != nil {
+			// only the approved photos are listed
+			entry.PhotoURL = a.photoURL(
+				row.Sha256sum, PhotoSizeMedium, database.PhotoStatusApproved,
+				accountPhotoURL(row.AccountID),
+			)
+		}
+		response.Personnel = append(response.Personnel, entry)
+	}
+
+	JsonResp(w, http.StatusOK, response)
+}
+
+// UpdatePersonnelProfile replaces the public profile of a member, it can be
+// done by the member or by a manager of the tenant.
+func (a *API) UpdatePersonnelProfile(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	accountID := ctx.Value(CtxAccountID).(uuid.UUID)
+	authenticatedID := ctx.Value(CtxAuthenticatedID).(uuid.UUID)
+	request := ctx.Value(CtxJSON).(*UpdatePersonnelProfileRequest)
+
+	if authenticatedID != accountID {
+		itmp := database.IsTenantManagerParams{
+			TenantID: tenantID, AccountID: authenticatedID,
+		}
+		isManager, err := a.db.IsTenantManager(ctx, itmp)
+		if err != nil || !isManager {
+			JsonError(w, http.StatusForbidden, "not manager")
+			return
+		}
+	}
+
+	msg := validPersonnelProfile(request)
+	if msg != "" {
+		JsonError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	specialties := request.Specialties
+	if specialties == nil {
+		specialties = []string{}
+	}
+	upp := database.UpdatePersonnelProfileParams{
+		DisplayName: sql.NullString{
+			String: request.DisplayName,
+			Valid:  request.DisplayName != "",
+		},
+		Bio:         request.Bio,
+		Specialties: specialties,
+		TenantID:    tenantID,
+		AccountID:   accountID,
+	}
+	affected, err := a.db.UpdatePersonnelProfile(ctx, upp)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	if affected == 0 {
+		JsonError(w, http.StatusNotFound, "not a member")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
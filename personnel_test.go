@@ -0,0 +1,217 @@
+package schedder_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gitlab.com/vlad.anghel/schedder-api"
+)
+
+func (a *APITX) downloadAccountPhoto(accountID uuid.UUID) int {
+	endpoint := fmt.Sprintf("/accounts/%s/photo", accountID)
+	r := httptest.NewRequest(http.MethodGet, endpoint, nil)
+	w := httptest.NewRecorder()
+
+	a.ServeHTTP(w, r)
+
+	return w.Result().StatusCode
+}
+
+func TestPersonnel(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantName := "Zâna Măseluță"
+
+	tenantID := api.createTenantAndAccount(email, password, tenantName)
+	token := api.generateToken(email, password)
+	accountID := api.findAccountByEmail(email)
+	api.addProfilePhoto(token, bytes.NewReader(testImage(t, 10, 10)))
+
+	request := schedder.UpdatePersonnelProfileRequest{
+		DisplayName: "Ionuț",
+		Bio:         "Frizer de 10 ani",
+		Specialties: []string{"fade", "barbă"},
+	}
+	endpoint := fmt.Sprintf(
+		"/tenants/%s/personnel/%s/profile", tenantID, accountID,
+	)
+	r, err := NewJSONRequest(http.MethodPut, endpoint, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, r)
+	expect(t, http.StatusOK, w.Result().StatusCode)
+
+	// the tenant isn't published yet
+	expect(t, http.StatusNotFound, api.downloadAccountPhoto(accountID))
+	r = httptest.NewRequest(
+		http.MethodGet, fmt.Sprintf("/tenants/%s/personnel", tenantID), nil,
+	)
+	w = httptest.NewRecorder()
+	api.ServeHTTP(w, r)
+	expect(t, http.StatusNotFound, w.Result().StatusCode)
+
+	api.publishTenant(tenantID)
+
+	r = httptest.NewRequest(
+		http.MethodGet, fmt.Sprintf("/tenants/%s/personnel", tenantID), nil,
+	)
+	w = httptest.NewRecorder()
+	api.ServeHTTP(w, r)
+	resp := w.Result()
+
+	var response schedder.PersonnelResponse
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect(t, "", response.Error)
+	expect(t, http.StatusOK, resp.StatusCode)
+	expect(t, 1, len(response.Personnel))
+	profile := response.Personnel[0]
+	expect(t, accountID, profile.AccountID)
+	expect(t, "Ionuț", profile.DisplayName)
+	expect(t, "Frizer de 10 ani", profile.Bio)
+	expect(t, 2, len(profile.Specialties))
+	expect(t, "fade", profile.Specialties[0])
+	expect(t, 0, profile.ReviewCount)
+
+	r = httptest.NewRequest(http.MethodGet, profile.PhotoURL, nil)
+	w = httptest.NewRecorder()
+	api.ServeHTTP(w, r)
+	expect(t, http.StatusOK, w.Result().StatusCode)
+}
+
+func TestUpdatePersonnelProfileForbidden(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	tenantID := api.createTenantAndAccount(
+		"manager@example.com", "hackmenow", "Zâna Măseluță",
+	)
+	managerID := api.findAccountByEmail("manager@example.com")
+	api.publishTenant(tenantID)
+
+	api.registerUserByEmail("customer@example.com", "hackmenow")
+	api.activateUserByEmail("customer@example.com")
+	token := api.generateToken("customer@example.com", "hackmenow")
+
+	request := schedder.UpdatePersonnelProfileRequest{DisplayName: "Hacker"}
+	endpoint := fmt.Sprintf(
+		"/tenants/%s/personnel/%s/profile", tenantID, managerID,
+	)
+	r, err := NewJSONRequest(http.MethodPut, endpoint, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, r)
+	expect(t, http.StatusForbidden, w.Result().StatusCode)
+}
+
+func TestDownloadAccountPhotoOfCustomer(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "customer@example.com"
+	password := "hackmenow"
+	accountID := api.registerUserByEmail(email, password)
+	api.activateUserByEmail(email)
+	token := api.generateToken(email, password)
+	api.addProfilePhoto(token, bytes.NewReader(testImage(t, 10, 10)))
+
+	expect(t, http.StatusNotFound, api.downloadAccountPhoto(accountID))
+}
+
+func TestPersonnelRatings(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	password := "hackmenow"
+	tenantID := api.createTenantAndAccount(
+		"manager@example.com", password, "Zâna Măseluță",
+	)
+	managerID := api.findAccountByEmail("manager@example.com")
+	managerToken := api.generateToken("manager@example.com", password)
+	api.publishTenant(tenantID)
+
+	stylistID := api.registerUserByEmail("stylist@example.com", password)
+	api.activateUserByEmail("stylist@example.com")
+	api.addTenantMember(managerToken, tenantID, stylistID)
+	serviceID := api.createService(managerToken, tenantID, stylistID, "Tuns", 5000, time.Hour)
+	tomorrow := localDate(time.Now()).AddDate(0, 0, 1)
+	starting := hourOn(tomorrow, 10)
+	api.setSchedule(managerToken, stylistID, tenantID, starting, starting.Add(2*time.Hour), tomorrow.Weekday())
+
+	api.registerUserByEmail("customer@example.com", password)
+	api.activateUserByEmail("customer@example.com")
+	token := api.generateToken("customer@example.com", password)
+
+	tenantEndpoint := fmt.Sprintf("/tenants/%s", tenantID)
+	resp := api.send(
+		token, http.MethodPost,
+		fmt.Sprintf("%s/services/%s/schedule", tenantEndpoint, serviceID),
+		schedder.CreateAppointmentRequest{Starting: starting},
+	)
+	var booked schedder.CreateAppointmentResponse
+	err := json.NewDecoder(resp.Body).Decode(&booked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, http.StatusCreated, resp.StatusCode)
+
+	// only past appointments can be reviewed, so move it to the past
+	_, err = api.tx.Exec(
+		context.Background(),
+		"UPDATE appointments SET starting = starting - interval '2 days' "+
+			"WHERE appointment_id = $1",
+		booked.AppointmentID,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp = api.send(
+		token, http.MethodPost, tenantEndpoint+"/reviews",
+		schedder.CreateReviewRequest{Message: "Foarte bine", Rating: 4},
+	)
+	expect(t, http.StatusCreated, resp.StatusCode)
+
+	// the review rates the member who did the appointment, not the others
+	resp = api.send("", http.MethodGet, tenantEndpoint+"/personnel", nil)
+	var response schedder.PersonnelResponse
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, 2, len(response.Personnel))
+	for _, profile := range response.Personnel {
+		switch profile.AccountID {
+		case stylistID:
+			expect(t, 1, profile.ReviewCount)
+			expect(t, 4.0, profile.Rating)
+		case managerID:
+			expect(t, 0, profile.ReviewCount)
+		default:
+			t.Fatalf("unexpected member %s", profile.AccountID)
+		}
+	}
+}
@@ -0,0 +1,356 @@
+package schedder
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"math/bits"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"gitlab.com/vlad.anghel/schedder-api/database"
+	"golang.org/x/image/draw"
+)
+
+// Moderation statuses of a photo. Only approved photos are public, pending
+// ones wait in the queue of the admins and are shown only to their owners.
+const (
+	PhotoStatusPending  = string(database.PhotoStatusPending)
+	PhotoStatusApproved = string(database.PhotoStatusApproved)
+	PhotoStatusRejected = string(database.PhotoStatusRejected)
+)
+
+// ModeratedPhoto represents an uploaded photo, as seen by a PhotoModerator.
+type ModeratedPhoto struct {
+	// Image represents the medium variant of the photo.
+	Image image.Image
+	// PerceptualHash represents the PerceptualHash of Image.
+	PerceptualHash uint64
+}
+
+// PhotoVerdict represents the decision of a PhotoModerator.
+type PhotoVerdict struct {
+	// Status represents the moderation status, one of PhotoStatusPending,
+	// PhotoStatusApproved or PhotoStatusRejected.
+	Status string
+	// Reason represents why the photo was flagged or rejected, shown to the
+	// admins.
+	Reason string
+}
+
+// PhotoModerator decides whether uploaded photos can be shown. It's called
+// for every tenant and profile photo, before the upload is committed.
+type PhotoModerator interface {
+	ModeratePhoto(ctx context.Context, photo ModeratedPhoto) (PhotoVerdict, error)
+}
+
+// SetPhotoModerator sets the moderator of the uploaded photos. Without one
+// every photo is approved.
+func (a *API) SetPhotoModerator(moderator PhotoModerator) {
+	a.moderator = moderator
+}
+
+// PerceptualHash returns the difference hash of the image: the image is
+// scaled down to 9x8 gray pixels and every bit tells whether a pixel is
+// brighter than its right neighbour. Resized or recompressed copies of an
+// image have hashes that differ in only a few bits.
+func PerceptualHash(img image.Image) uint64 {
+	small := image.NewGray(image.Rect(0, 0, 9, 8))
+	draw.BiLinear.Scale(small, small.Bounds(), img, img.Bounds(), draw.Src, nil)
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if small.GrayAt(x, y).Y > small.GrayAt(x+1, y).Y {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// HashBlocklist is a PhotoModerator that rejects the photos similar to known
+// bad images, by comparing their perceptual hashes.
+type HashBlocklist struct {
+	hashes []uint64
+	// RejectDistance represents the maximum number of different bits for a
+	// photo to be rejected.
+	RejectDistance int
+	// ReviewDistance represents the maximum number of different bits for a
+	// photo to be sent to the admins, it should be at least RejectDistance.
+	ReviewDistance int
+}
+
+// NewHashBlocklist returns a blocklist of the perceptual hashes, with the
+// default distances.
+func NewHashBlocklist(hashes []uint64) *HashBlocklist {
+	return &HashBlocklist{hashes: hashes, RejectDistance: 4, ReviewDistance: 10}
+}
+
+// LoadHashBlocklist reads a blocklist with a hex encoded perceptual hash on
+// every line. Empty lines and lines starting with # are ignored.
+func LoadHashBlocklist(r io.Reader) (*HashBlocklist, error) {
+	var hashes []uint64
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		hash, err := strconv.ParseUint(text, 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid hash %q", line, text)
+		}
+		hashes = append(hashes, hash)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return NewHashBlocklist(hashes), nil
+}
+
+// ModeratePhoto implements PhotoModerator.
+func (b *HashBlocklist) ModeratePhoto(
+	ctx context.Context, photo ModeratedPhoto,
+) (PhotoVerdict, error) {
+	closest := 65
+	var match uint64
+	for _, hash := range b.hashes {
+		distance := bits.OnesCount64(hash ^ photo.PerceptualHash)
+		if distance < closest {
+			closest, match = distance, hash
+		}
+	}
+
+	switch {
+	case closest <= b.RejectDistance:
+		return PhotoVerdict{
+			Status: PhotoStatusRejected,
+			Reason: fmt.Sprintf("blocklisted image %016x", match),
+		}, nil
+	case closest <= b.ReviewDistance:
+		return PhotoVerdict{
+			Status: PhotoStatusPending,
+			Reason: fmt.Sprintf(
+				"similar to blocklisted image %016x (distance %d)",
+				match, closest,
+			),
+		}, nil
+	default:
+		return PhotoVerdict{Status: PhotoStatusApproved}, nil
+	}
+}
+
+// moderatorFromEnv returns the blocklist from the file in
+// SCHEDDER_PHOTO_BLOCKLIST, or nil if it isn't defined.
+func moderatorFromEnv() PhotoModerator {
+	path, found := os.LookupEnv("SCHEDDER_PHOTO_BLOCKLIST")
+	if !found {
+		return nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+
+	blocklist, err := LoadHashBlocklist(file)
+	if err != nil {
+		panic(err)
+	}
+	log.Printf("INFO: loaded %d blocklisted photos", len(blocklist.hashes))
+	return blocklist
+}
+
+// moderatePhoto hashes the uploaded photo and asks the moderator for its
+// status. If the moderator fails the photo waits for the admins.
+func (a *API) moderatePhoto(ctx context.Context, photo *uploadedPhoto) error {
+	file := photo.variants[PhotoSizeMedium]
+	_, err := file.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return err
+	}
+
+	photo.perceptualHash = PerceptualHash(img)
+	photo.status = database.PhotoStatusApproved
+	if a.moderator == nil {
+		return nil
+	}
+
+	verdict, err := a.moderator.ModeratePhoto(ctx, ModeratedPhoto{
+		Image:          img,
+		PerceptualHash: photo.perceptualHash,
+	})
+	if err != nil {
+		log.Printf("WARN: couldn't moderate photo: %v", err)
+		verdict = PhotoVerdict{
+			Status: PhotoStatusPending,
+			Reason: "moderation failed",
+		}
+	}
+
+	switch verdict.Status {
+	case PhotoStatusPending, PhotoStatusApproved, PhotoStatusRejected:
+	default:
+		return fmt.Errorf("invalid moderation status %q", verdict.Status)
+	}
+	photo.status = database.PhotoStatus(verdict.Status)
+	photo.reason = sql.NullString{
+		String: verdict.Reason,
+		Valid:  verdict.Reason != "",
+	}
+	return nil
+}
+
+// photoModerationEntry represents a photo in the moderation queue.
+type photoModerationEntry struct {
+	// PhotoID represents the ID of the photo.
+	PhotoID uuid.UUID `json:"photo_id"`
+	// Status represents the moderation status of the photo.
+	Status string `json:"status"`
+	// Reason represents why the photo was flagged or rejected.
+	Reason string `json:"reason,omitempty"`
+	// PerceptualHash represents the hex encoded perceptual hash of the photo,
+	// to be added to the blocklist.
+	PerceptualHash string `json:"perceptual_hash,omitempty"`
+	// UploadedAt represents when the photo was uploaded.
+	UploadedAt time.Time `json:"uploaded_at"`
+	// Kind represents where the photo is used, "tenant" for the gallery of a
+	// tenant or "profile" for the profile photo of an account.
+	Kind string `json:"kind"`
+	// OwnerID represents the ID of the tenant or of the account.
+	OwnerID uuid.UUID `json:"owner_id"`
+	// URL represents the path of the photo, for the admins.
+	URL string `json:"url"`
+}
+
+// PhotoModerationQueueResponse represents the response of the photo
+// moderation queue endpoint.
+type PhotoModerationQueueResponse struct {
+	Response
+	// Photos represents the oldest 100 photos with the status, oldest first.
+	Photos []photoModerationEntry `json:"photos"`
+}
+
+// ModeratePhotoRequest represents a decision of an admin about a photo.
+type ModeratePhotoRequest struct {
+	// Status represents the new status, "approved" or "rejected", or
+	// "pending" to send it back to the queue.
+	Status string `json:"status"`
+	// Reason represents why the photo was rejected, at most 500 characters.
+	Reason string `json:"reason"`
+}
+
+// PhotoModerationQueue lists the photos waiting for the admins, or the ones
+// with the status from the status query parameter.
+func (a *API) PhotoModerationQueue(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = PhotoStatusPending
+	}
+	switch status {
+	case PhotoStatusPending, PhotoStatusApproved, PhotoStatusRejected:
+	default:
+		JsonError(w, http.StatusBadRequest, "invalid status")
+		return
+	}
+
+	rows, err := a.db.GetPhotoModerationQueue(ctx, database.PhotoStatus(status))
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	var response PhotoModerationQueueResponse
+	response.Photos = make([]photoModerationEntry, 0, len(rows))
+	for _, row := range rows {
+		entry := photoModerationEntry{
+			PhotoID:    row.PhotoID,
+			Status:     string(row.ModerationStatus),
+			Reason:     row.ModerationReason.String,
+			UploadedAt: row.UploadedAt,
+			Kind:       "profile",
+			OwnerID:    row.OwnerID,
+			URL:        fmt.Sprintf("/moderation/photos/%s", row.PhotoID),
+		}
+		if row.PerceptualHash.Valid {
+			entry.PerceptualHash = fmt.Sprintf(
+				"%016x", uint64(row.PerceptualHash.Int64),
+			)
+		}
+		if row.IsTenantPhoto {
+			entry.Kind = "tenant"
+		}
+		response.Photos = append(response.Photos, entry)
+	}
+
+	JsonResp(w, http.StatusOK, response)
+}
+
+// DownloadModeratedPhoto writes any photo, whatever its status, for the
+// admins.
+func (a *API) DownloadModeratedPhoto(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	photoID := ctx.Value(CtxPhotoID).(uuid.UUID)
+
+	photo, err := a.db.GetPhotoForModeration(ctx, photoID)
+	if err != nil {
+		JsonError(w, http.StatusNotFound, "no photo")
+		return
+	}
+
+	a.servePhoto(w, r, photo.Sha256sum, photo.MimeType, false)
+}
+
+// ModeratePhoto approves or rejects a photo.
+func (a *API) ModeratePhoto(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	photoID := ctx.Value(CtxPhotoID).(uuid.UUID)
+	request := ctx.Value(CtxJSON).(*ModeratePhotoRequest)
+
+	switch request.Status {
+	case PhotoStatusPending, PhotoStatusApproved, PhotoStatusRejected:
+	default:
+		JsonError(w, http.StatusBadRequest, "invalid status")
+		return
+	}
+	if utf8.RuneCountInString(request.Reason) > 500 {
+		JsonError(w, http.StatusBadRequest, "invalid reason")
+		return
+	}
+
+	mpp := database.ModeratePhotoParams{
+		PhotoID:          photoID,
+		ModerationStatus: database.PhotoStatus(request.Status),
+		ModerationReason: sql.NullString{
+			String: request.Reason,
+			Valid:  request.Reason != "",
+		},
+	}
+	affected, err := a.db.ModeratePhoto(ctx, mpp)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	if affected == 0 {
+		JsonError(w, http.StatusNotFound, "no photo")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
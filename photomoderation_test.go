@@ -0,0 +1,252 @@
+package schedder_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gitlab.com/vlad.anghel/schedder-api"
+)
+
+// verdictModerator gives the same verdict for every photo.
+type verdictModerator schedder.PhotoVerdict
+
+// ModeratePhoto implements schedder.PhotoModerator.
+func (m verdictModerator) ModeratePhoto(
+	ctx context.Context, photo schedder.ModeratedPhoto,
+) (schedder.PhotoVerdict, error) {
+	return schedder.PhotoVerdict(m), nil
+}
+
+// mirroredTestImage returns a JPEG getting darker from left to right, unlike
+// testImage.
+func mirroredTestImage(t *testing.T, width, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{uint8(255 - x), uint8(y), 0, 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, img, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestHashBlocklist(t *testing.T) {
+	t.Parallel()
+
+	decode := func(data []byte) image.Image {
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return img
+	}
+	small := schedder.PerceptualHash(decode(testImage(t, 50, 50)))
+	large := schedder.PerceptualHash(decode(testImage(t, 200, 200)))
+	mirrored := schedder.PerceptualHash(decode(mirroredTestImage(t, 50, 50)))
+
+	blocklist, err := schedder.LoadHashBlocklist(strings.NewReader(
+		fmt.Sprintf("# known bad images\n\n%016x\n", small),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	verdict, err := blocklist.ModeratePhoto(
+		ctx, schedder.ModeratedPhoto{PerceptualHash: large},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, schedder.PhotoStatusRejected, verdict.Status)
+
+	// a few different bits are left for the admins
+	verdict, err = blocklist.ModeratePhoto(
+		ctx, schedder.ModeratedPhoto{PerceptualHash: small ^ 0x7f},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, schedder.PhotoStatusPending, verdict.Status)
+
+	verdict, err = blocklist.ModeratePhoto(
+		ctx, schedder.ModeratedPhoto{PerceptualHash: mirrored},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, schedder.PhotoStatusApproved, verdict.Status)
+
+	_, err = schedder.LoadHashBlocklist(strings.NewReader("not a hash\n"))
+	if err == nil {
+		t.Fatal("expected an error for an invalid hash")
+	}
+}
+
+func TestPhotoModeration(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantName := "Zâna Măseluță"
+
+	tenantID := api.createTenantAndAccount(email, password, tenantName)
+	api.publishTenant(tenantID)
+	token := api.generateToken(email, password)
+	api.forceAdmin(email, true)
+
+	img, err := jpeg.Decode(bytes.NewReader(testImage(t, 50, 50)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocked := schedder.PerceptualHash(img)
+	api.SetPhotoModerator(schedder.NewHashBlocklist([]uint64{blocked}))
+
+	endpoint := fmt.Sprintf("/tenants/%s/photos", tenantID)
+	r := httptest.NewRequest(
+		http.MethodPost, endpoint, bytes.NewReader(testImage(t, 50, 50)),
+	)
+	r.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, r)
+
+	resp := w.Result()
+	var added schedder.AddTenantPhotoResponse
+	err = json.NewDecoder(resp.Body).Decode(&added)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, http.StatusCreated, resp.StatusCode)
+	expect(t, schedder.PhotoStatusRejected, added.Status)
+
+	allowed := api.addTenantPhoto(
+		token, tenantID, bytes.NewReader(mirroredTestImage(t, 50, 50)),
+	)
+
+	// the rejected photo is hidden
+	photos := api.listTenantPhotos(tenantID)
+	expect(t, 1, len(photos.Photos))
+	expect(t, allowed, photos.Photos[0].PhotoID)
+
+	queue := func(status string) schedder.PhotoModerationQueueResponse {
+		r := httptest.NewRequest(
+			http.MethodGet, "/moderation/photos?status="+status, nil,
+		)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, r)
+
+		resp := w.Result()
+		var response schedder.PhotoModerationQueueResponse
+		err := json.NewDecoder(resp.Body).Decode(&response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect(t, "", response.Error)
+		expect(t, http.StatusOK, resp.StatusCode)
+		return response
+	}
+
+	rejected := queue(schedder.PhotoStatusRejected)
+	found := false
+	for _, photo := range rejected.Photos {
+		if photo.PhotoID == added.PhotoID {
+			found = true
+			expect(t, "tenant", photo.Kind)
+			expect(t, tenantID, photo.OwnerID)
+			expect(t, fmt.Sprintf("%016x", blocked), photo.PerceptualHash)
+		}
+	}
+	if !found {
+		t.Fatalf("didn't find photo %s in the queue", added.PhotoID)
+	}
+
+	// the admin overrules the blocklist
+	request := schedder.ModeratePhotoRequest{Status: schedder.PhotoStatusApproved}
+	r, err = NewJSONRequest(
+		http.MethodPost,
+		fmt.Sprintf("/moderation/photos/%s", added.PhotoID),
+		request,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	api.ServeHTTP(w, r)
+	expect(t, http.StatusOK, w.Result().StatusCode)
+
+	photos = api.listTenantPhotos(tenantID)
+	expect(t, 2, len(photos.Photos))
+
+	// the pending photos are shown only to the managers and the admins
+	api.SetPhotoModerator(verdictModerator{Status: schedder.PhotoStatusPending})
+	pending := api.addTenantPhoto(
+		token, tenantID, bytes.NewReader(testImage(t, 60, 60)),
+	)
+	photos = api.listTenantPhotos(tenantID)
+	expect(t, 2, len(photos.Photos))
+
+	r = httptest.NewRequest(http.MethodGet, endpoint, nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	api.ServeHTTP(w, r)
+	photos = schedder.ListTenantPhotosResponse{}
+	err = json.NewDecoder(w.Result().Body).Decode(&photos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, 3, len(photos.Photos))
+	expect(t, pending, photos.Photos[2].PhotoID)
+	expect(t, schedder.PhotoStatusPending, photos.Photos[2].Status)
+
+	photoEndpoint := fmt.Sprintf("%s/by-id/%s", endpoint, pending)
+	r = httptest.NewRequest(http.MethodGet, photoEndpoint, nil)
+	w = httptest.NewRecorder()
+	api.ServeHTTP(w, r)
+	expect(t, http.StatusNotFound, w.Result().StatusCode)
+
+	r = httptest.NewRequest(http.MethodGet, photoEndpoint, nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	api.ServeHTTP(w, r)
+	expect(t, http.StatusOK, w.Result().StatusCode)
+	expect(t, "private, no-cache", w.Result().Header.Get("Cache-Control"))
+
+	// only the approved photos get signed URLs, the pending one is fetched
+	// through the endpoint that checks the caller
+	api.SetPhotoSigner(newPhotoSigner(t, "key"))
+	r = httptest.NewRequest(http.MethodGet, endpoint, nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	api.ServeHTTP(w, r)
+	photos = schedder.ListTenantPhotosResponse{}
+	err = json.NewDecoder(w.Result().Body).Decode(&photos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, 3, len(photos.Photos))
+	if !strings.HasPrefix(photos.Photos[0].ThumbnailURL, "/photos/") {
+		t.Fatalf("expected a signed URL, got %s", photos.Photos[0].ThumbnailURL)
+	}
+	expect(
+		t, photoEndpoint+"?size="+schedder.PhotoSizeThumbnail,
+		photos.Photos[2].ThumbnailURL,
+	)
+}
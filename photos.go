@@ -2,290 +2,751 @@ package schedder
 
 import (
 	"bytes"
-	"crypto/sha256"
+	"context"
+	"database/sql"
 	"encoding/hex"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
-	"os"
+	"time"
+	"unicode/utf8"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
 	"gitlab.com/vlad.anghel/schedder-api/database"
 )
 
 type AddTenantPhotoResponse struct {
 	Response
 	PhotoID uuid.UUID `json:"photo_id"`
+	// Status represents the moderation status of the photo, only approved
+	// photos are public.
+	Status string `json:"moderation_status"`
 }
 
+// tenantPhotoEntry represents a photo of the tenant's gallery.
+type tenantPhotoEntry struct {
+	// PhotoID represents the ID of the photo.
+	PhotoID uuid.UUID `json:"photo_id"`
+	// Position represents the position of the photo in the gallery, the
+	// photos are listed in this order.
+	Position int `json:"position"`
+	// Caption represents the caption of the photo, it may be empty.
+	Caption string `json:"caption"`
+	// AltText represents the text alternative of the photo, for screen
+	// readers.
+	AltText string `json:"alt_text"`
+	// Cover represents whether the photo is the cover of the tenant.
+	Cover bool `json:"cover"`
+	// Status represents the moderation status, "pending" or "approved".
+	// The pending photos are listed only for the managers and the admins.
+	Status string `json:"moderation_status"`
+	// Services represents the IDs of the services tagged in the photo.
+	Services []uuid.UUID `json:"services"`
+	// Personnel represents the IDs of the members tagged in the photo.
+	Personnel []uuid.UUID `json:"personnel"`
+	// ThumbnailURL represents the path of the thumbnail variant.
+	ThumbnailURL string `json:"thumbnail_url"`
+	// MediumURL represents the path of the medium variant.
+	MediumURL string `json:"medium_url"`
+	// OriginalURL represents the path of the original variant.
+	OriginalURL string `json:"original_url"`
+}
+
+// ListTenantPhotosResponse represents the response of the photo listing
+// endpoint.
 type ListTenantPhotosResponse struct {
 	Response
-	Photos []uuid.UUID `json:"photo_ids"`
+	// PhotoIDs represents the IDs of the photos of the gallery, in order. It's
+	// kept for the clients that predate Photos.
+	PhotoIDs []uuid.UUID `json:"photo_ids"`
+	// Photos represents the photos of the gallery, in order.
+	Photos []tenantPhotoEntry `json:"photos"`
 }
 
-func (a *API) AddTenantPhoto(w http.ResponseWriter, r *http.Request) {
+// UpdateTenantPhotoRequest represents a request to update the metadata of a
+// photo of the tenant, all the fields are replaced.
+type UpdateTenantPhotoRequest struct {
+	// Caption represents the caption of the photo, at most 300 characters.
+	Caption string `json:"caption"`
+	// AltText represents the text alternative of the photo, at most 300
+	// characters.
+	AltText string `json:"alt_text"`
+	// Services represents the IDs of the services of the tenant shown in
+	// the photo.
+	Services []uuid.UUID `json:"services"`
+	// Personnel represents the IDs of the members of the tenant shown in
+	// the photo.
+	Personnel []uuid.UUID `json:"personnel"`
+}
 
-	ctx := r.Context()
+// ReorderTenantPhotosRequest represents a request to reorder the gallery.
+type ReorderTenantPhotosRequest struct {
+	// Photos represents the IDs of the photos in their new order. The photos
+	// that are missing keep their relative order, after the listed ones.
+	Photos []uuid.UUID `json:"photo_ids"`
+}
 
-	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+// maxPhotoTextLength represents the maximum length of a caption or of an
+// alternative text, in characters.
+const maxPhotoTextLength = 300
 
-	part := make([]byte, 512)
-	n, err := r.Body.Read(part)
-	if err != nil {
-		JsonError(w, http.StatusInternalServerError, "not implemented")
-		return
-	}
-	if n != 512 {
-		JsonError(w, http.StatusBadRequest, "invalid image")
-		return
+// photoKey returns the key of a variant of the photo with the hash. The
+// original keeps the key used before there were variants.
+func photoKey(hash []byte, size string) string {
+	encoded := hex.EncodeToString(hash)
+	if size == PhotoSizeOriginal {
+		return encoded
 	}
+	return encoded + "-" + size
+}
 
-	content := http.DetectContentType(part)
+// writePhoto stores all the variants of the uploaded photo.
+func (a *API) writePhoto(ctx context.Context, photo *uploadedPhoto) error {
+	for size, file := range photo.variants {
+		stat, err := file.Stat()
+		if err != nil {
+			return err
+		}
+		_, err = file.Seek(0, io.SeekStart)
+		if err != nil {
+			return err
+		}
 
-	reader := io.MultiReader(bytes.NewReader(part), r.Body)
+		key := photoKey(photo.checksum[:], size)
+		err = a.storage.Put(ctx, key, file, stat.Size())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	if content != "image/jpeg" && content != "image/png" {
-		JsonError(w, http.StatusBadRequest, "invalid image")
-		return
+// removePhoto removes all the variants of the photo with the hash.
+func (a *API) removePhoto(ctx context.Context, hash []byte) error {
+	for size := range photoSizes {
+		err := a.storage.Delete(ctx, photoKey(hash, size))
+		if err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	data, err := io.ReadAll(reader)
-	if err != nil {
-		JsonError(w, http.StatusInternalServerError, "not implemented")
+// immutablePhotoCacheControl is sent for the approved photos. Their variants
+// are addressed by the content hash and never change, the pending and rejected
+// photos aren't served publicly at all.
+const immutablePhotoCacheControl = "public, max-age=31536000, immutable"
+
+// servePhoto writes the variant of the photo with the hash selected by the
+// size query parameter, the original by default. Variants never change, so
+// the key is used as a strong ETag, which makes conditional and range requests
+// possible. Public photos can also be cached by shared caches for good.
+func (a *API) servePhoto(
+	w http.ResponseWriter, r *http.Request,
+	hash []byte, mimeType sql.NullString, public bool,
+) {
+	size := r.URL.Query().Get("size")
+	if size == "" {
+		size = PhotoSizeOriginal
+	}
+	if _, ok := photoSizes[size]; !ok {
+		JsonError(w, http.StatusBadRequest, "invalid size")
 		return
 	}
 
-	checksum := sha256.Sum256(data)
-
-	atpp := database.AddTenantPhotoParams{
-		Sha256sum: checksum[:],
-		TenantID:  tenantID,
+	cacheControl := "private, no-cache"
+	if public {
+		cacheControl = immutablePhotoCacheControl
 	}
+	a.serveVariant(w, r, hash, size, mimeType, cacheControl)
+}
 
-	photoID, err := a.db.AddTenantPhoto(ctx, atpp)
+// serveVariant writes a variant of the photo with the hash, falling back to
+// the original if the variant is missing.
+func (a *API) serveVariant(
+	w http.ResponseWriter, r *http.Request,
+	hash []byte, size string, mimeType sql.NullString, cacheControl string,
+) {
+	ctx := r.Context()
+	key := photoKey(hash, size)
+	blob, err := a.storage.Get(ctx, key)
+	if errors.Is(err, ErrBlobNotFound) && size != PhotoSizeOriginal {
+		// Photos uploaded before there were variants have only the original.
+		key = photoKey(hash, PhotoSizeOriginal)
+		blob, err = a.storage.Get(ctx, key)
+	}
 	if err != nil {
-		JsonError(w, http.StatusInternalServerError, "not implemented")
+		// Here we return InternalServerError because if the photo is missing
+		// from the storage clearly there are some other issues.
+		JsonError(w, http.StatusInternalServerError, "invalid photo hash")
 		return
 	}
+	defer blob.Close()
 
-	count, err := a.db.CountPhotosWithHash(ctx, checksum[:])
-	if err != nil {
-		JsonError(w, http.StatusInternalServerError, "not implemented")
-		return
+	// Range requests need to seek, blobs that can't are small enough to be
+	// read in memory.
+	content, ok := blob.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(blob)
+		if err != nil {
+			JsonError(w, http.StatusInternalServerError, "not implemented")
+			return
+		}
+		content = bytes.NewReader(data)
+	}
+
+	// Photos uploaded before the type was stored are detected by
+	// http.ServeContent.
+	if mimeType.Valid {
+		w.Header().Set("Content-Type", mimeType.String)
 	}
+	w.Header().Set("ETag", `"`+key+`"`)
+	w.Header().Set("Cache-Control", cacheControl)
+
+	http.ServeContent(w, r, "", time.Time{}, content)
+}
+
+func (a *API) AddTenantPhoto(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
 
-	if count == 1 {
-		encoded := hex.EncodeToString(checksum[:])
-		os.WriteFile(a.photosPath+encoded, data, 0777)
+	var photoID uuid.UUID
+	var status database.PhotoStatus
+	ok := a.uploadPhoto(w, r, func(
+		ctx context.Context, queries *database.Queries, photo *uploadedPhoto,
+	) error {
+		var err error
+		status = photo.status
+		// The same handler is mounted under a location, in which case the
+		// photo belongs to that location.
+		if locationID, ok := ctx.Value(CtxLocationID).(uuid.UUID); ok {
+			alpp := database.AddLocationPhotoParams{
+				TenantID:   tenantID,
+				LocationID: locationID,
+				Sha256sum:  photo.checksum[:],
+				MimeType:   photo.mimeType,
+
+				ModerationStatus: photo.status,
+				ModerationReason: photo.reason,
+				PerceptualHash:   int64(photo.perceptualHash),
+			}
+			photoID, err = queries.AddLocationPhoto(ctx, alpp)
+		} else {
+			atpp := database.AddTenantPhotoParams{
+				Sha256sum: photo.checksum[:],
+				MimeType:  photo.mimeType,
+				TenantID:  tenantID,
+
+				ModerationStatus: photo.status,
+				ModerationReason: photo.reason,
+				PerceptualHash:   int64(photo.perceptualHash),
+			}
+			photoID, err = queries.AddTenantPhoto(ctx, atpp)
+		}
+		return err
+	})
+	if !ok {
+		return
 	}
 
-	JsonResp(w, http.StatusCreated, AddTenantPhotoResponse{PhotoID: photoID})
+	response := AddTenantPhotoResponse{PhotoID: photoID, Status: string(status)}
+	JsonResp(w, http.StatusCreated, response)
+}
+
+// tenantPhotoURL returns the path of the variant of a photo of the tenant.
+func tenantPhotoURL(tenantID, photoID uuid.UUID, size string) string {
+	return fmt.Sprintf(
+		"/tenants/%s/photos/by-id/%s?size=%s", tenantID, photoID, size,
+	)
+}
+
+// photoURL returns the signed URL of the variant of the photo with the hash,
+// or the unsigned one if URLs aren't signed. Only the approved photos are
+// signed, the signed URLs are served to anyone, while the unsigned ones check
+// who can see the photo.
+func (a *API) photoURL(
+	hash []byte, size string, status database.PhotoStatus, unsigned string,
+) string {
+	if a.signer == nil || status != database.PhotoStatusApproved {
+		return unsigned
+	}
+	return a.signer.URL(hash, size)
+}
+
+// seesPendingTenantPhotos reports whether the caller can see the photos of the
+// tenant waiting for the admins, only the managers and the admins can.
+func (a *API) seesPendingTenantPhotos(r *http.Request, tenantID uuid.UUID) bool {
+	authenticatedID, ok := a.authenticate(r)
+	if !ok {
+		return false
+	}
+	itmp := database.IsTenantManagerParams{
+		TenantID: tenantID, AccountID: authenticatedID,
+	}
+	isManager, err := a.db.IsTenantManager(r.Context(), itmp)
+	if err == nil && isManager {
+		return true
+	}
+	admin, err := a.db.GetAdminForAccount(r.Context(), authenticatedID)
+	return err == nil && admin
 }
 
+// ListTenantPhotos lists the approved photos of the gallery, the managers and
+// the admins also see the pending ones.
 func (a *API) ListTenantPhotos(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	includePending := a.seesPendingTenantPhotos(r, tenantID)
+
+	photos := []tenantPhotoEntry{}
+	var hashes [][]byte
+	if locationID, ok := ctx.Value(CtxLocationID).(uuid.UUID); ok {
+		llpp := database.ListLocationPhotosParams{
+			TenantID:       tenantID,
+			LocationID:     locationID,
+			IncludePending: includePending,
+		}
+		rows, err := a.db.ListLocationPhotos(ctx, llpp)
+		if err != nil {
+			JsonError(w, http.StatusInternalServerError, "not implemented")
+			return
+		}
+		for _, row := range rows {
+			photos = append(photos, tenantPhotoEntry{
+				PhotoID:  row.PhotoID,
+				Position: int(row.Position),
+				Caption:  row.Caption,
+				AltText:  row.AltText,
+				Cover:    row.IsCover,
+				Status:   string(row.ModerationStatus),
+			})
+			hashes = append(hashes, row.Sha256sum)
+		}
+	} else {
+		ltpp := database.ListTenantPhotosParams{
+			TenantID:       tenantID,
+			IncludePending: includePending,
+		}
+		rows, err := a.db.ListTenantPhotos(ctx, ltpp)
+		if err != nil {
+			JsonError(w, http.StatusInternalServerError, "not implemented")
+			return
+		}
+		for _, row := range rows {
+			photos = append(photos, tenantPhotoEntry{
+				PhotoID:  row.PhotoID,
+				Position: int(row.Position),
+				Caption:  row.Caption,
+				AltText:  row.AltText,
+				Cover:    row.IsCover,
+				Status:   string(row.ModerationStatus),
+			})
+			hashes = append(hashes, row.Sha256sum)
+		}
+	}
 
-	photos, err := a.db.ListTenantPhotos(ctx, tenantID)
+	services, err := a.db.ListTenantPhotoServices(ctx, tenantID)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	personnel, err := a.db.ListTenantPhotoPersonnel(ctx, tenantID)
 	if err != nil {
 		JsonError(w, http.StatusInternalServerError, "not implemented")
 		return
 	}
 
+	byID := make(map[uuid.UUID]*tenantPhotoEntry, len(photos))
+	for i := range photos {
+		photo := &photos[i]
+		photo.Services = []uuid.UUID{}
+		photo.Personnel = []uuid.UUID{}
+		status := database.PhotoStatus(photo.Status)
+		photo.ThumbnailURL = a.photoURL(
+			hashes[i], PhotoSizeThumbnail, status,
+			tenantPhotoURL(tenantID, photo.PhotoID, PhotoSizeThumbnail),
+		)
+		photo.MediumURL = a.photoURL(
+			hashes[i], PhotoSizeMedium, status,
+			tenantPhotoURL(tenantID, photo.PhotoID, PhotoSizeMedium),
+		)
+		photo.OriginalURL = a.photoURL(
+			hashes[i], PhotoSizeOriginal, status,
+			tenantPhotoURL(tenantID, photo.PhotoID, PhotoSizeOriginal),
+		)
+		byID[photo.PhotoID] = photo
+	}
+	for _, tag := range services {
+		if photo, ok := byID[tag.PhotoID]; ok {
+			photo.Services = append(photo.Services, tag.ServiceID)
+		}
+	}
+	for _, tag := range personnel {
+		if photo, ok := byID[tag.PhotoID]; ok {
+			photo.Personnel = append(photo.Personnel, tag.AccountID)
+		}
+	}
+
 	var response ListTenantPhotosResponse
+	response.PhotoIDs = make([]uuid.UUID, 0, len(photos))
+	for _, photo := range photos {
+		response.PhotoIDs = append(response.PhotoIDs, photo.PhotoID)
+	}
 	response.Photos = photos
 
 	JsonResp(w, http.StatusOK, response)
 }
 
-func (a *API) DownloadTenantPhoto(w http.ResponseWriter, r *http.Request) {
+// UpdateTenantPhoto replaces the caption, the alternative text and the tags
+// of a photo of the tenant.
+func (a *API) UpdateTenantPhoto(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
-	photoIDParameter := chi.URLParam(r, "photoID")
-	photoID, err := uuid.Parse(photoIDParameter)
-	if err != nil {
-		JsonError(w, http.StatusBadRequest, "invalid photoID")
+	photoID := ctx.Value(CtxPhotoID).(uuid.UUID)
+	request := ctx.Value(CtxJSON).(*UpdateTenantPhotoRequest)
+
+	if utf8.RuneCountInString(request.Caption) > maxPhotoTextLength {
+		JsonError(w, http.StatusBadRequest, "invalid caption")
 		return
 	}
-
-	gtphp := database.GetTenantPhotoHashParams{
-		TenantID: tenantID, PhotoID: photoID,
+	if utf8.RuneCountInString(request.AltText) > maxPhotoTextLength {
+		JsonError(w, http.StatusBadRequest, "invalid alt text")
+		return
 	}
 
-	hash, err := a.db.GetTenantPhotoHash(ctx, gtphp)
+	tx, err := a.txlike.Begin(ctx)
 	if err != nil {
-		JsonError(w, http.StatusNotFound, "invalid photoID")
+		JsonError(w, http.StatusInternalServerError, "not implemented")
 		return
 	}
+	defer tx.Rollback(ctx)
+	queries := database.New(tx)
 
-	file, err := os.Open(a.photosPath + hex.EncodeToString(hash))
+	utpp := database.UpdateTenantPhotoParams{
+		TenantID: tenantID,
+		PhotoID:  photoID,
+		Caption:  request.Caption,
+		AltText:  request.AltText,
+	}
+	affected, err := queries.UpdateTenantPhoto(ctx, utpp)
 	if err != nil {
-		// Here we return InternalServerError because if the photo is missing
-		// from the filesystem clearly there are some other issues.
-		JsonError(w, http.StatusInternalServerError, "invalid photo hash")
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	if affected == 0 {
+		JsonError(w, http.StatusNotFound, "no photo")
 		return
 	}
 
-	stat, err := file.Stat()
+	ctpsp := database.ClearTenantPhotoServicesParams{
+		TenantID: tenantID,
+		PhotoID:  photoID,
+	}
+	err = queries.ClearTenantPhotoServices(ctx, ctpsp)
 	if err != nil {
 		JsonError(w, http.StatusInternalServerError, "not implemented")
 		return
 	}
-
-	written, err := io.Copy(w, file)
+	ctppp := database.ClearTenantPhotoPersonnelParams{
+		TenantID: tenantID,
+		PhotoID:  photoID,
+	}
+	err = queries.ClearTenantPhotoPersonnel(ctx, ctppp)
 	if err != nil {
 		JsonError(w, http.StatusInternalServerError, "not implemented")
 		return
 	}
 
-	if written != stat.Size() {
+	for _, serviceID := range request.Services {
+		ttpsp := database.TagTenantPhotoServiceParams{
+			TenantID:  tenantID,
+			PhotoID:   photoID,
+			ServiceID: serviceID,
+		}
+		err = queries.TagTenantPhotoService(ctx, ttpsp)
+		if err != nil {
+			// The service doesn't exist or belongs to another tenant.
+			JsonError(w, http.StatusBadRequest, "invalid service")
+			return
+		}
+	}
+	for _, accountID := range request.Personnel {
+		ttppp := database.TagTenantPhotoPersonnelParams{
+			TenantID:  tenantID,
+			PhotoID:   photoID,
+			AccountID: accountID,
+		}
+		err = queries.TagTenantPhotoPersonnel(ctx, ttppp)
+		if err != nil {
+			// The account isn't a member of the tenant.
+			JsonError(w, http.StatusBadRequest, "invalid personnel")
+			return
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
 		JsonError(w, http.StatusInternalServerError, "not implemented")
 		return
 	}
+
+	w.WriteHeader(http.StatusOK)
 }
 
-func (a *API) DeleteTenantPhoto(w http.ResponseWriter, r *http.Request) {
+// ReorderTenantPhotos changes the order of the photos in the gallery.
+func (a *API) ReorderTenantPhotos(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
-	photoID := ctx.Value(CtxPhotoID).(uuid.UUID)
-	dtpp := database.DeleteTenantPhotoParams{
-		PhotoID: photoID,
-		TenantID: tenantID,
-	}
-	hash, err := a.db.DeleteTenantPhoto(ctx, dtpp)
+	request := ctx.Value(CtxJSON).(*ReorderTenantPhotosRequest)
+
+	tx, err := a.txlike.Begin(ctx)
 	if err != nil {
-		JsonError(w, http.StatusNotFound, "no photo")
+		JsonError(w, http.StatusInternalServerError, "not implemented")
 		return
 	}
+	defer tx.Rollback(ctx)
+	queries := database.New(tx)
 
-	count, err := a.db.CountPhotosWithHash(ctx, hash)
+	ltpp := database.ListTenantPhotosParams{
+		TenantID:       tenantID,
+		IncludePending: true,
+	}
+	rows, err := queries.ListTenantPhotos(ctx, ltpp)
 	if err != nil {
 		JsonError(w, http.StatusInternalServerError, "not implemented")
 		return
 	}
-
-	if count == 0 {
-		encoded := hex.EncodeToString(hash)
-		err = os.Remove(a.photosPath + encoded)
-		if err != nil {
-			JsonError(w, http.StatusInternalServerError, "not implemented")
+	existing := make(map[uuid.UUID]bool, len(rows))
+	for _, row := range rows {
+		existing[row.PhotoID] = true
+	}
+	seen := make(map[uuid.UUID]bool, len(request.Photos))
+	for _, photoID := range request.Photos {
+		if !existing[photoID] {
+			JsonError(w, http.StatusBadRequest, "invalid photo")
+			return
+		}
+		if seen[photoID] {
+			JsonError(w, http.StatusBadRequest, "duplicate photo")
 			return
 		}
+		seen[photoID] = true
 	}
-	w.WriteHeader(http.StatusOK)
-}
-func (a *API) SetProfilePhoto(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	authenticatedID := ctx.Value(CtxAuthenticatedID).(uuid.UUID)
-	part := make([]byte, 512)
-	n, err := r.Body.Read(part)
+
+	rtpp := database.ReorderTenantPhotosParams{
+		TenantID: tenantID,
+		PhotoIds: request.Photos,
+	}
+	err = queries.ReorderTenantPhotos(ctx, rtpp)
 	if err != nil {
 		JsonError(w, http.StatusInternalServerError, "not implemented")
 		return
 	}
-	if n != 512 {
-		JsonError(w, http.StatusBadRequest, "invalid image")
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
 		return
 	}
 
-	content := http.DetectContentType(part)
+	w.WriteHeader(http.StatusOK)
+}
 
-	reader := io.MultiReader(bytes.NewReader(part), r.Body)
+// SetTenantCoverPhoto makes the photo the cover of the tenant, replacing the
+// previous one.
+func (a *API) SetTenantCoverPhoto(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	photoID := ctx.Value(CtxPhotoID).(uuid.UUID)
 
-	if content != "image/jpeg" && content != "image/png" {
-		JsonError(w, http.StatusBadRequest, "invalid image")
+	tx, err := a.txlike.Begin(ctx)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
 		return
 	}
+	defer tx.Rollback(ctx)
+	queries := database.New(tx)
 
-	data, err := io.ReadAll(reader)
+	// The old cover is cleared first, there can be only one.
+	err = queries.ClearTenantCoverPhoto(ctx, tenantID)
 	if err != nil {
 		JsonError(w, http.StatusInternalServerError, "not implemented")
 		return
 	}
 
-	checksum := sha256.Sum256(data)
-	args := database.SetProfilePhotoParams{
-		AccountID: authenticatedID,
-		Sha256sum: checksum[:],
+	stcpp := database.SetTenantCoverPhotoParams{
+		TenantID: tenantID,
+		PhotoID:  photoID,
 	}
-
-	err = a.db.SetProfilePhoto(ctx, args)
+	affected, err := queries.SetTenantCoverPhoto(ctx, stcpp)
 	if err != nil {
 		JsonError(w, http.StatusInternalServerError, "not implemented")
 		return
 	}
+	if affected == 0 {
+		JsonError(w, http.StatusNotFound, "no photo")
+		return
+	}
 
-	count, err := a.db.CountPhotosWithHash(ctx, checksum[:])
+	err = tx.Commit(ctx)
 	if err != nil {
 		JsonError(w, http.StatusInternalServerError, "not implemented")
 		return
 	}
-	if count == 1 {
-		encoded := hex.EncodeToString(checksum[:])
-		os.WriteFile(a.photosPath+encoded, data, 0777)
-	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
-func (a *API) DownloadProfilePhoto(w http.ResponseWriter, r *http.Request) {
+// ClearTenantCoverPhoto removes the cover of the tenant, if any.
+func (a *API) ClearTenantCoverPhoto(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	authenticatedID := ctx.Value(CtxAuthenticatedID).(uuid.UUID)
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
 
-	hash, err := a.db.GetProfilePhotoHash(ctx, authenticatedID)
+	err := a.db.ClearTenantCoverPhoto(ctx, tenantID)
 	if err != nil {
-		JsonError(w, http.StatusBadRequest, "no photo")
+		JsonError(w, http.StatusInternalServerError, "not implemented")
 		return
 	}
 
-	encoded := hex.EncodeToString(hash)
-	file, err := os.Open(a.photosPath + encoded)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (a *API) DownloadTenantPhoto(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	photoIDParameter := chi.URLParam(r, "photoID")
+	photoID, err := uuid.Parse(photoIDParameter)
 	if err != nil {
-		JsonError(w, http.StatusInternalServerError, "not implemented")
+		JsonError(w, http.StatusBadRequest, "invalid photoID")
 		return
 	}
 
-	stat, err := file.Stat()
+	includePending := a.seesPendingTenantPhotos(r, tenantID)
+	gtphp := database.GetTenantPhotoHashParams{
+		TenantID:       tenantID,
+		PhotoID:        photoID,
+		IncludePending: includePending,
+	}
+
+	photo, err := a.db.GetTenantPhotoHash(ctx, gtphp)
 	if err != nil {
-		JsonError(w, http.StatusInternalServerError, "not implemented")
+		JsonError(w, http.StatusNotFound, "invalid photoID")
 		return
 	}
 
-	written, err := io.Copy(w, file)
+	// A photo ID always refers to the same image, but the pending photos
+	// aren't for the shared caches.
+	public := photo.ModerationStatus == database.PhotoStatusApproved
+	a.servePhoto(w, r, photo.Sha256sum, photo.MimeType, public)
+}
+
+func (a *API) DeleteTenantPhoto(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	photoID := ctx.Value(CtxPhotoID).(uuid.UUID)
+	dtpp := database.DeleteTenantPhotoParams{
+		PhotoID: photoID,
+		TenantID: tenantID,
+	}
+	hash, err := a.db.DeleteTenantPhoto(ctx, dtpp)
 	if err != nil {
-		JsonError(w, http.StatusInternalServerError, "not implemented")
+		JsonError(w, http.StatusNotFound, "no photo")
 		return
 	}
 
-	if written != stat.Size() {
-		JsonError(w, http.StatusInternalServerError, "not implemented")
+	a.deleteUnreferencedBlobs(ctx, hash)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (a *API) SetProfilePhoto(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	authenticatedID := ctx.Value(CtxAuthenticatedID).(uuid.UUID)
+
+	var oldHash []byte
+	ok := a.uploadPhoto(w, r, func(
+		ctx context.Context, queries *database.Queries, photo *uploadedPhoto,
+	) error {
+		// The old photo is replaced, so it doesn't keep its blob alive.
+		var err error
+		oldHash, err = queries.DeleteProfilePhoto(ctx, authenticatedID)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+
+		args := database.SetProfilePhotoParams{
+			AccountID: authenticatedID,
+			Sha256sum: photo.checksum[:],
+			MimeType:  photo.mimeType,
+
+			ModerationStatus: photo.status,
+			ModerationReason: photo.reason,
+			PerceptualHash:   int64(photo.perceptualHash),
+		}
+		return queries.SetProfilePhoto(ctx, args)
+	})
+	if !ok {
 		return
 	}
+
+	if oldHash != nil {
+		a.deleteUnreferencedBlobs(ctx, oldHash)
+	}
+
+	w.WriteHeader(http.StatusOK)
 }
 
-func (a *API) DeleteProfilePhoto(w http.ResponseWriter, r *http.Request) {
+func (a *API) DownloadProfilePhoto(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	authenticatedID := ctx.Value(CtxAuthenticatedID).(uuid.UUID)
-	hash, err := a.db.DeleteProfilePhoto(ctx, authenticatedID)
+
+	photo, err := a.db.GetProfilePhotoHash(ctx, authenticatedID)
 	if err != nil {
 		JsonError(w, http.StatusBadRequest, "no photo")
 		return
 	}
 
-	count, err := a.db.CountPhotosWithHash(ctx, hash)
+	// The URL stays the same when the profile photo changes.
+	a.servePhoto(w, r, photo.Sha256sum, photo.MimeType, false)
+}
+
+// DownloadAccountPhoto writes the profile photo of another account. Only the
+// approved photos of the members of published tenants are public, the
+// customers' stay private. The pending photo is shown to its owner and to the
+// admins.
+func (a *API) DownloadAccountPhoto(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	accountID := ctx.Value(CtxAccountID).(uuid.UUID)
+
+	gppphp := database.GetPublicProfilePhotoHashParams{AccountID: accountID}
+	if authenticatedID, ok := a.authenticate(r); ok {
+		admin, err := a.db.GetAdminForAccount(ctx, authenticatedID)
+		gppphp.IncludePending = authenticatedID == accountID ||
+			(err == nil && admin)
+	}
+	photo, err := a.db.GetPublicProfilePhotoHash(ctx, gppphp)
 	if err != nil {
-		JsonError(w, http.StatusInternalServerError, "not implemented")
+		JsonError(w, http.StatusNotFound, "no photo")
 		return
 	}
 
-	if count == 0 {
-		encoded := hex.EncodeToString(hash)
-		err = os.Remove(a.photosPath + encoded)
-		if err != nil {
-			JsonError(w, http.StatusInternalServerError, "not implemented")
-			return
-		}
+	a.servePhoto(w, r, photo.Sha256sum, photo.MimeType, false)
+}
+
+func (a *API) DeleteProfilePhoto(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	authenticatedID := ctx.Value(CtxAuthenticatedID).(uuid.UUID)
+	hash, err := a.db.DeleteProfilePhoto(ctx, authenticatedID)
+	if err != nil {
+		JsonError(w, http.StatusBadRequest, "no photo")
+		return
 	}
+
+	a.deleteUnreferencedBlobs(ctx, hash)
+
 	w.WriteHeader(http.StatusOK)
 }
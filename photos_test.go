@@ -1,15 +1,23 @@
 package schedder_test
 
 import (
+	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"gitlab.com/vlad.anghel/schedder-api"
@@ -93,9 +101,9 @@ func TestListTenantPhotos(t *testing.T) {
 	expect(t, http.StatusOK, resp.StatusCode)
 
 	found := false
-	for _, ID := range response.Photos {
-		t.Logf("photo %s", ID)
-		if ID == photoID {
+	for _, photo := range response.Photos {
+		t.Logf("photo %s", photo.PhotoID)
+		if photo.PhotoID == photoID {
 			found = true
 		}
 	}
@@ -103,6 +111,7 @@ func TestListTenantPhotos(t *testing.T) {
 	if !found {
 		t.Fatalf("Didn't find photo %s", photoID)
 	}
+	expect(t, len(response.Photos), len(response.PhotoIDs))
 }
 
 func TestDownloadTenantPhoto(t *testing.T) {
@@ -151,7 +160,7 @@ func TestDownloadTenantPhoto(t *testing.T) {
 	sum := sha256.Sum256(data)
 	checksum := hex.EncodeToString(sum[:])
 
-	_, err = os.Stat(api.PhotosPath() + checksum)
+	_, err = api.Storage().Stat(context.Background(), checksum)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -238,7 +247,7 @@ func TestDownloadProfilePhoto(t *testing.T) {
 	sum := sha256.Sum256(data)
 	checksum := hex.EncodeToString(sum[:])
 
-	_, err = os.Stat(api.PhotosPath() + checksum)
+	_, err = api.Storage().Stat(context.Background(), checksum)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -394,3 +403,518 @@ func TestDeleteTenantPhotoWithoutPhoto(t *testing.T) {
 
 	expect(t, http.StatusNotFound, resp.StatusCode)
 }
+
+// testImage encodes a width x height opaque JPEG.
+func testImage(t *testing.T, width, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 0, 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, img, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDownloadTenantPhotoSizes(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantName := "Zâna Măseluță"
+
+	tenantID := api.createTenantAndAccount(email, password, tenantName)
+	api.publishTenant(tenantID)
+	token := api.generateToken(email, password)
+
+	photoID := api.addTenantPhoto(
+		token, tenantID, bytes.NewReader(testImage(t, 2000, 1000)),
+	)
+
+	sizes := map[string]image.Point{
+		"":                          {2000, 1000},
+		schedder.PhotoSizeOriginal:  {2000, 1000},
+		schedder.PhotoSizeMedium:    {1024, 512},
+		schedder.PhotoSizeThumbnail: {256, 128},
+	}
+	for size, dimensions := range sizes {
+		endpoint := fmt.Sprintf(
+			"/tenants/%s/photos/by-id/%s?size=%s", tenantID, photoID, size,
+		)
+		r := httptest.NewRequest(http.MethodGet, endpoint, nil)
+		w := httptest.NewRecorder()
+
+		api.ServeHTTP(w, r)
+
+		resp := w.Result()
+		expect(t, http.StatusOK, resp.StatusCode)
+
+		config, format, err := image.DecodeConfig(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect(t, "jpeg", format)
+		expect(t, dimensions, image.Pt(config.Width, config.Height))
+	}
+
+	endpoint := fmt.Sprintf(
+		"/tenants/%s/photos/by-id/%s?size=huge", tenantID, photoID,
+	)
+	r := httptest.NewRequest(http.MethodGet, endpoint, nil)
+	w := httptest.NewRecorder()
+
+	api.ServeHTTP(w, r)
+
+	resp := w.Result()
+
+	var response schedder.Response
+	err := json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect(t, http.StatusBadRequest, resp.StatusCode)
+	expect(t, "invalid size", response.Error)
+}
+
+func TestSetProfilePhotoStripsExif(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+
+	api.registerUserByEmail(email, password)
+	api.activateUserByEmail(email)
+	token := api.generateToken(email, password)
+
+	// An APP1 segment with the orientation rotated 90 degrees clockwise.
+	exif := []byte(
+		"Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08\x00\x01" +
+			"\x01\x12\x00\x03\x00\x00\x00\x01\x00\x06\x00\x00" +
+			"\x00\x00\x00\x00",
+	)
+	segment := append([]byte{0xFF, 0xE1, 0, byte(len(exif) + 2)}, exif...)
+	data := testImage(t, 200, 100)
+	data = append(data[:2], append(segment, data[2:]...)...)
+
+	api.addProfilePhoto(token, bytes.NewReader(data))
+
+	r := httptest.NewRequest(http.MethodGet, "/accounts/self/photo", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+
+	api.ServeHTTP(w, r)
+
+	resp := w.Result()
+	expect(t, http.StatusOK, resp.StatusCode)
+
+	downloaded, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(downloaded, []byte("Exif")) {
+		t.Fatal("EXIF wasn't stripped")
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(downloaded))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, image.Pt(100, 200), image.Pt(config.Width, config.Height))
+}
+
+func TestAddTenantPhotoInvalidImage(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantName := "Zâna Măseluță"
+
+	tenantID := api.createTenantAndAccount(email, password, tenantName)
+	token := api.generateToken(email, password)
+
+	endpoint := fmt.Sprintf("/tenants/%s/photos", tenantID)
+	body := strings.NewReader(strings.Repeat("not an image ", 100))
+	r := httptest.NewRequest(http.MethodPost, endpoint, body)
+	r.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+
+	api.ServeHTTP(w, r)
+
+	resp := w.Result()
+
+	var response schedder.Response
+	err := json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect(t, http.StatusBadRequest, resp.StatusCode)
+	expect(t, "invalid image", response.Error)
+}
+
+func TestDownloadTenantPhotoCaching(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantName := "Zâna Măseluță"
+
+	tenantID := api.createTenantAndAccount(email, password, tenantName)
+	api.publishTenant(tenantID)
+	token := api.generateToken(email, password)
+
+	data := testImage(t, 300, 200)
+	photoID := api.addTenantPhoto(token, tenantID, bytes.NewReader(data))
+
+	endpoint := fmt.Sprintf("/tenants/%s/photos/by-id/%s", tenantID, photoID)
+	r := httptest.NewRequest(http.MethodGet, endpoint, nil)
+	w := httptest.NewRecorder()
+
+	api.ServeHTTP(w, r)
+
+	resp := w.Result()
+	expect(t, http.StatusOK, resp.StatusCode)
+	expect(t, "image/jpeg", resp.Header.Get("Content-Type"))
+	expect(
+		t, "public, max-age=31536000, immutable",
+		resp.Header.Get("Cache-Control"),
+	)
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, fmt.Sprint(len(body)), resp.Header.Get("Content-Length"))
+
+	etag := resp.Header.Get("ETag")
+	sum := sha256.Sum256(body)
+	expect(t, `"`+hex.EncodeToString(sum[:])+`"`, etag)
+
+	r = httptest.NewRequest(http.MethodGet, endpoint, nil)
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+
+	api.ServeHTTP(w, r)
+
+	resp = w.Result()
+	expect(t, http.StatusNotModified, resp.StatusCode)
+
+	r = httptest.NewRequest(http.MethodGet, endpoint, nil)
+	r.Header.Set("Range", "bytes=0-9")
+	w = httptest.NewRecorder()
+
+	api.ServeHTTP(w, r)
+
+	resp = w.Result()
+	expect(t, http.StatusPartialContent, resp.StatusCode)
+	expect(
+		t, fmt.Sprintf("bytes 0-9/%d", len(body)),
+		resp.Header.Get("Content-Range"),
+	)
+
+	part, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, string(body[:10]), string(part))
+}
+
+func TestDownloadProfilePhotoRevalidates(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+
+	api.registerUserByEmail(email, password)
+	api.activateUserByEmail(email)
+	token := api.generateToken(email, password)
+
+	api.addProfilePhoto(token, bytes.NewReader(testImage(t, 300, 200)))
+
+	r := httptest.NewRequest(http.MethodGet, "/accounts/self/photo", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+
+	api.ServeHTTP(w, r)
+
+	resp := w.Result()
+	expect(t, http.StatusOK, resp.StatusCode)
+	expect(t, "private, no-cache", resp.Header.Get("Cache-Control"))
+	etag := resp.Header.Get("ETag")
+
+	// A new profile photo must not match the old ETag.
+	api.addProfilePhoto(token, bytes.NewReader(testImage(t, 200, 300)))
+
+	r = httptest.NewRequest(http.MethodGet, "/accounts/self/photo", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+
+	api.ServeHTTP(w, r)
+
+	resp = w.Result()
+	expect(t, http.StatusOK, resp.StatusCode)
+	unexpect(t, etag, resp.Header.Get("ETag"))
+}
+
+func TestDeleteTenantPhotoRemovesBlob(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantName := "Zâna Măseluță"
+
+	tenantID := api.createTenantAndAccount(email, password, tenantName)
+	api.publishTenant(tenantID)
+	token := api.generateToken(email, password)
+
+	// Both photos share the same blob.
+	data := testImage(t, 64, 48)
+	firstID := api.addTenantPhoto(token, tenantID, bytes.NewReader(data))
+	secondID := api.addTenantPhoto(token, tenantID, bytes.NewReader(data))
+
+	endpoint := fmt.Sprintf("/tenants/%s/photos/by-id/%s", tenantID, firstID)
+	r := httptest.NewRequest(http.MethodGet, endpoint, nil)
+	w := httptest.NewRecorder()
+
+	api.ServeHTTP(w, r)
+
+	resp := w.Result()
+	expect(t, http.StatusOK, resp.StatusCode)
+	key := strings.Trim(resp.Header.Get("ETag"), `"`)
+
+	ctx := context.Background()
+	for i, photoID := range []uuid.UUID{firstID, secondID} {
+		endpoint := fmt.Sprintf(
+			"/tenants/%s/photos/by-id/%s", tenantID, photoID,
+		)
+		r := httptest.NewRequest(http.MethodDelete, endpoint, nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+
+		api.ServeHTTP(w, r)
+
+		expect(t, http.StatusOK, w.Result().StatusCode)
+
+		_, err := api.Storage().Stat(ctx, key)
+		if i == 0 && err != nil {
+			t.Fatalf("blob deleted while still referenced: %v", err)
+		}
+		if i == 1 {
+			expect(t, schedder.ErrBlobNotFound, err)
+		}
+	}
+
+	_, err := api.Storage().Stat(ctx, key+"-"+schedder.PhotoSizeThumbnail)
+	expect(t, schedder.ErrBlobNotFound, err)
+}
+
+func TestAddTenantPhotoMultipart(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantName := "Zâna Măseluță"
+
+	tenantID := api.createTenantAndAccount(email, password, tenantName)
+	token := api.generateToken(email, password)
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	err := form.WriteField("caption", "ignored")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part, err := form.CreateFormFile("photo", "photo.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = part.Write(testImage(t, 30, 20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = form.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	endpoint := fmt.Sprintf("/tenants/%s/photos", tenantID)
+	r := httptest.NewRequest(http.MethodPost, endpoint, &body)
+	r.Header.Set("Authorization", "Bearer "+token)
+	r.Header.Set("Content-Type", form.FormDataContentType())
+	w := httptest.NewRecorder()
+
+	api.ServeHTTP(w, r)
+
+	resp := w.Result()
+
+	var response schedder.AddTenantPhotoResponse
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect(t, "", response.Error)
+	expect(t, http.StatusCreated, resp.StatusCode)
+	unexpect(t, uuid.Nil, response.PhotoID)
+}
+
+func TestAddTenantPhotoTooLarge(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantName := "Zâna Măseluță"
+
+	tenantID := api.createTenantAndAccount(email, password, tenantName)
+	token := api.generateToken(email, password)
+
+	data := testImage(t, 300, 200)
+	api.SetMaxPhotoSize(int64(len(data) - 1))
+
+	endpoint := fmt.Sprintf("/tenants/%s/photos", tenantID)
+	r := httptest.NewRequest(http.MethodPost, endpoint, bytes.NewReader(data))
+	r.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+
+	api.ServeHTTP(w, r)
+
+	resp := w.Result()
+
+	var response schedder.Response
+	err := json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+	expect(t, "photo too large", response.Error)
+}
+
+func (a *APITX) listTenantPhotos(
+	tenantID uuid.UUID,
+) schedder.ListTenantPhotosResponse {
+	endpoint := fmt.Sprintf("/tenants/%s/photos", tenantID)
+	r := httptest.NewRequest(http.MethodGet, endpoint, nil)
+	w := httptest.NewRecorder()
+
+	a.ServeHTTP(w, r)
+
+	resp := w.Result()
+	var response schedder.ListTenantPhotosResponse
+	err := json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	expect(a.t, "", response.Error)
+	expect(a.t, http.StatusOK, resp.StatusCode)
+	return response
+}
+
+func TestTenantPhotoGallery(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantName := "Zâna Măseluță"
+
+	tenantID := api.createTenantAndAccount(email, password, tenantName)
+	api.publishTenant(tenantID)
+	token := api.generateToken(email, password)
+	accountID := api.findAccountByEmail(email)
+	serviceID := api.createService(
+		token, tenantID, accountID, "Tuns", 5000, 30*time.Minute,
+	)
+
+	first := api.addTenantPhoto(
+		token, tenantID, bytes.NewReader(testImage(t, 10, 10)),
+	)
+	second := api.addTenantPhoto(
+		token, tenantID, bytes.NewReader(testImage(t, 20, 20)),
+	)
+
+	// photos are listed in the order they were added
+	response := api.listTenantPhotos(tenantID)
+	expect(t, 2, len(response.Photos))
+	expect(t, first, response.Photos[0].PhotoID)
+	expect(t, second, response.Photos[1].PhotoID)
+
+	photoEndpoint := fmt.Sprintf("/tenants/%s/photos/by-id/%s", tenantID, second)
+	resp := api.send(token, http.MethodPut, photoEndpoint, schedder.UpdateTenantPhotoRequest{
+		Caption:   "Tunsoare scurtă",
+		AltText:   "Un client după tuns",
+		Services:  []uuid.UUID{serviceID},
+		Personnel: []uuid.UUID{accountID},
+	})
+	expect(t, http.StatusOK, resp.StatusCode)
+
+	resp = api.send(token, http.MethodPut, photoEndpoint, schedder.UpdateTenantPhotoRequest{
+		Services: []uuid.UUID{uuid.New()},
+	})
+	expect(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = api.send(
+		token, http.MethodPut,
+		fmt.Sprintf("/tenants/%s/photos/order", tenantID),
+		schedder.ReorderTenantPhotosRequest{Photos: []uuid.UUID{second}},
+	)
+	expect(t, http.StatusOK, resp.StatusCode)
+
+	resp = api.send(token, http.MethodPut, photoEndpoint+"/cover", nil)
+	expect(t, http.StatusOK, resp.StatusCode)
+
+	response = api.listTenantPhotos(tenantID)
+	expect(t, 2, len(response.Photos))
+	photo := response.Photos[0]
+	expect(t, second, photo.PhotoID)
+	expect(t, first, response.Photos[1].PhotoID)
+	expect(t, "Tunsoare scurtă", photo.Caption)
+	expect(t, "Un client după tuns", photo.AltText)
+	expect(t, true, photo.Cover)
+	expect(t, false, response.Photos[1].Cover)
+	expect(t, 1, len(photo.Services))
+	expect(t, serviceID, photo.Services[0])
+	expect(t, 1, len(photo.Personnel))
+	expect(t, accountID, photo.Personnel[0])
+
+	// the URLs are ready to be used
+	r := httptest.NewRequest(http.MethodGet, photo.ThumbnailURL, nil)
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, r)
+	expect(t, http.StatusOK, w.Result().StatusCode)
+
+	// the cover moves to the other photo
+	resp = api.send(
+		token, http.MethodPut,
+		fmt.Sprintf("/tenants/%s/photos/by-id/%s/cover", tenantID, first),
+		nil,
+	)
+	expect(t, http.StatusOK, resp.StatusCode)
+	response = api.listTenantPhotos(tenantID)
+	expect(t, false, response.Photos[0].Cover)
+	expect(t, true, response.Photos[1].Cover)
+}
@@ -0,0 +1,375 @@
+package schedder
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"gitlab.com/vlad.anghel/schedder-api/database"
+)
+
+// Kinds of pricing adjustments.
+const (
+	PricingPercentage = string(database.PricingAdjustmentKindPercentage)
+	PricingFixed      = string(database.PricingAdjustmentKindFixed)
+)
+
+// everyWeekday represents the weekdays bitmask of the rules that apply on any
+// day, bit 0 is Sunday like time.Weekday.
+const everyWeekday = 1<<7 - 1
+
+// maxPercentage represents the maximum percentage adjustment, in basis points,
+// a discount can make the service free and a surcharge can double its price.
+const maxPercentage = 10000
+
+// CreatePricingRuleRequest represents a new pricing rule of a service. The
+// rule applies to the appointments starting inside all of its conditions, in
+// the time zone of the tenant. The conditions left empty always hold.
+type CreatePricingRuleRequest struct {
+	// RuleName represents the name of the rule, like "Saturday surcharge".
+	RuleName string `json:"rule_name"`
+	// Weekdays represents the weekdays when the rule applies, 0 for Sunday.
+	Weekdays []time.Weekday `json:"weekdays"`
+	// WindowStart and WindowEnd represent the local time of day when the
+	// rule applies, as the time since midnight. The end isn't included, and
+	// zero means midnight at the end of the day.
+	WindowStart time.Duration `json:"window_start"`
+	WindowEnd   time.Duration `json:"window_end"`
+	// ValidFrom and ValidUntil represent the first and the last local date
+	// when the rule applies, only the date is used.
+	ValidFrom  time.Time `json:"valid_from"`
+	ValidUntil time.Time `json:"valid_until"`
+	// Kind represents the kind of the adjustment, "percentage" or "fixed".
+	Kind string `json:"kind"`
+	// Adjustment represents the change of the price, in basis points of the
+	// price of the service for percentages, -1500 for 15% off, or in minor
+	// units of the tenant currency for fixed ones.
+	Adjustment int64 `json:"adjustment"`
+}
+
+// CreatePricingRuleResponse represents the response of the pricing rule
+// creation endpoint.
+type CreatePricingRuleResponse struct {
+	Response
+	RuleID uuid.UUID `json:"rule_id"`
+}
+
+// pricingRuleEntry represents a pricing rule of a service. The unlimited
+// dates are the zero time.
+type pricingRuleEntry struct {
+	RuleID      uuid.UUID      `json:"rule_id"`
+	RuleName    string         `json:"rule_name"`
+	Weekdays    []time.Weekday `json:"weekdays"`
+	WindowStart time.Duration  `json:"window_start"`
+	WindowEnd   time.Duration  `json:"window_end"`
+	ValidFrom   time.Time      `json:"valid_from"`
+	ValidUntil  time.Time      `json:"valid_until"`
+	Kind        string         `json:"kind"`
+	Adjustment  int64          `json:"adjustment"`
+}
+
+// PricingRulesResponse represents the pricing rules of a service.
+type PricingRulesResponse struct {
+	Response
+	Rules []pricingRuleEntry `json:"rules"`
+}
+
+// pricingRule represents a pricing rule, see CreatePricingRuleRequest.
+type pricingRule struct {
+	weekdays    int32
+	windowStart time.Duration
+	windowEnd   time.Duration
+	validFrom   sql.NullTime
+	validUntil  sql.NullTime
+	percentage  bool
+	adjustment  int64
+}
+
+// pricingRules represents the pricing rules of a service, in the time zone of
+// the tenant.
+type pricingRules struct {
+	timezone *time.Location
+	rules    []pricingRule
+}
+
+// applies reports whether the rule applies to an appointment starting at the
+// local time.
+func (p *pricingRule) applies(local time.Time) bool {
+	if p.weekdays&(1<<local.Weekday()) == 0 {
+		return false
+	}
+
+	// the wall clock, which isn't the time since midnight on DST changes
+	timeOfDay := time.Duration(local.Hour())*time.Hour +
+		time.Duration(local.Minute())*time.Minute +
+		time.Duration(local.Second())*time.Second
+	if timeOfDay < p.windowStart || timeOfDay >= p.windowEnd {
+		return false
+	}
+
+	// the dates are read from the database at midnight UTC
+	year, month, day := local.Date()
+	date := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	if p.validFrom.Valid && date.Before(p.validFrom.Time) {
+		return false
+	}
+	if p.validUntil.Valid && date.After(p.validUntil.Time) {
+		return false
+	}
+	return true
+}
+
+// priceAt returns the price of the service for an appointment starting at the
+// time. All the rules that apply are added up, the percentages are of the
+// price before the rules, rounded half up to minor units. The price can't go
+// below zero.
+func (p *pricingRules) priceAt(price int64, starting time.Time) int64 {
+	if p == nil {
+		return price
+	}
+
+	local := starting.In(p.timezone)
+	var basisPoints, fixed int64
+	for i := range p.rules {
+		rule := &p.rules[i]
+		if !rule.applies(local) {
+			continue
+		}
+		if rule.percentage {
+			basisPoints += rule.adjustment
+		} else {
+			fixed += rule.adjustment
+		}
+	}
+
+	// floor division, so that negative amounts are rounded half up too
+	change := price*basisPoints + maxPercentage/2
+	adjustment := change / maxPercentage
+	if change%maxPercentage < 0 {
+		adjustment--
+	}
+
+	price += adjustment + fixed
+	if price < 0 {
+		return 0
+	}
+	return price
+}
+
+// pricingRulesFor returns the pricing rules of the service, with the time zone
+// of the tenant.
+func (a *API) pricingRulesFor(
+	ctx context.Context, tenantID, serviceID uuid.UUID,
+) (*pricingRules, error) {
+	calendar, err := a.db.GetTenantCalendar(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	rules := new(pricingRules)
+	rules.timezone, err = time.LoadLocation(calendar.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	gprp := database.GetPricingRulesParams{
+		TenantID:  tenantID,
+		ServiceID: serviceID,
+	}
+	rows, err := a.db.GetPricingRules(ctx, gprp)
+	if err != nil {
+		return nil, err
+	}
+	for i := range rows {
+		row := &rows[i]
+		rule := pricingRule{
+			weekdays:   row.Weekdays,
+			validFrom:  row.ValidFrom,
+			validUntil: row.ValidUntil,
+			percentage: row.Kind == database.PricingAdjustmentKindPercentage,
+			adjustment: row.Adjustment,
+		}
+		err := row.WindowStart.AssignTo(&rule.windowStart)
+		if err != nil {
+			return nil, err
+		}
+		err = row.WindowEnd.AssignTo(&rule.windowEnd)
+		if err != nil {
+			return nil, err
+		}
+		rules.rules = append(rules.rules, rule)
+	}
+
+	return rules, nil
+}
+
+// ruleDate returns the date of the time as a nullable date, the zero time
+// means no limit.
+func ruleDate(t time.Time) sql.NullTime {
+	if t.IsZero() {
+		return sql.NullTime{}
+	}
+	year, month, day := t.Date()
+	return sql.NullTime{
+		Time:  time.Date(year, month, day, 0, 0, 0, 0, time.UTC),
+		Valid: true,
+	}
+}
+
+// validPricingRule checks a pricing rule, returning an error message for the
+// client if it's invalid.
+func validPricingRule(request *CreatePricingRuleRequest) string {
+	if request.RuleName == "" {
+		return "invalid rule name"
+	}
+	for _, weekday := range request.Weekdays {
+		if weekday < time.Sunday || weekday > time.Saturday {
+			return "invalid weekday"
+		}
+	}
+	start, end := request.WindowStart, request.WindowEnd
+	if start%time.Minute != 0 || end%time.Minute != 0 ||
+		start < 0 || end > 24*time.Hour || start >= end {
+		return "invalid time window"
+	}
+	if !request.ValidFrom.IsZero() && !request.ValidUntil.IsZero() &&
+		ruleDate(request.ValidFrom).Time.After(ruleDate(request.ValidUntil).Time) {
+		return "invalid dates"
+	}
+	switch request.Kind {
+	case PricingPercentage:
+		if request.Adjustment < -maxPercentage || request.Adjustment > maxPercentage {
+			return "invalid adjustment"
+		}
+	case PricingFixed:
+		if request.Adjustment < -maxPrice || request.Adjustment > maxPrice {
+			return "invalid adjustment"
+		}
+	default:
+		return "invalid kind"
+	}
+	return ""
+}
+
+// CreatePricingRule adds a pricing rule to a service. The booked appointments
+// keep their price.
+func (a *API) CreatePricingRule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	serviceID := ctx.Value(CtxServiceID).(uuid.UUID)
+	request := ctx.Value(CtxJSON).(*CreatePricingRuleRequest)
+
+	if request.WindowEnd == 0 {
+		request.WindowEnd = 24 * time.Hour
+	}
+	msg := validPricingRule(request)
+	if msg != "" {
+		JsonError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	cprp := database.CreatePricingRuleParams{
+		RuleName:   request.RuleName,
+		Weekdays:   everyWeekday,
+		ValidFrom:  ruleDate(request.ValidFrom),
+		ValidUntil: ruleDate(request.ValidUntil),
+		Kind:       database.PricingAdjustmentKind(request.Kind),
+		Adjustment: request.Adjustment,
+		TenantID:   tenantID,
+		ServiceID:  serviceID,
+	}
+	if len(request.Weekdays) > 0 {
+		cprp.Weekdays = 0
+		for _, weekday := range request.Weekdays {
+			cprp.Weekdays |= 1 << weekday
+		}
+	}
+	cprp.WindowStart.Set(request.WindowStart)
+	cprp.WindowEnd.Set(request.WindowEnd)
+
+	ruleID, err := a.db.CreatePricingRule(ctx, cprp)
+	if err != nil {
+		JsonError(w, http.StatusBadRequest, "couldn't create rule")
+		return
+	}
+
+	response := CreatePricingRuleResponse{RuleID: ruleID}
+	JsonResp(w, http.StatusCreated, response)
+}
+
+// DeletePricingRule deletes a pricing rule, the booked appointments keep their
+// price.
+func (a *API) DeletePricingRule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	serviceID := ctx.Value(CtxServiceID).(uuid.UUID)
+	ruleID := ctx.Value(CtxRuleID).(uuid.UUID)
+
+	dprp := database.DeletePricingRuleParams{
+		TenantID:  tenantID,
+		ServiceID: serviceID,
+		RuleID:    ruleID,
+	}
+	affected, err := a.db.DeletePricingRule(ctx, dprp)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	if affected == 0 {
+		JsonError(w, http.StatusNotFound, "invalid rule")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// PricingRules lists the pricing rules of a service, oldest first.
+func (a *API) PricingRules(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	serviceID := ctx.Value(CtxServiceID).(uuid.UUID)
+
+	gprp := database.GetPricingRulesParams{
+		TenantID:  tenantID,
+		ServiceID: serviceID,
+	}
+	rows, err := a.db.GetPricingRules(ctx, gprp)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	var response PricingRulesResponse
+	response.Rules = make([]pricingRuleEntry, 0, len(rows))
+	for i := range rows {
+		row := &rows[i]
+		rule := pricingRuleEntry{
+			RuleID:     row.RuleID,
+			RuleName:   row.RuleName,
+			Weekdays:   []time.Weekday{},
+			ValidFrom:  row.ValidFrom.Time,
+			ValidUntil: row.ValidUntil.Time,
+			Kind:       string(row.Kind),
+			Adjustment: row.Adjustment,
+		}
+		for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+			if row.Weekdays&(1<<weekday) != 0 {
+				rule.Weekdays = append(rule.Weekdays, weekday)
+			}
+		}
+		err := row.WindowStart.AssignTo(&rule.WindowStart)
+		if err != nil {
+			JsonError(w, http.StatusInternalServerError, "not implemented")
+			return
+		}
+		err = row.WindowEnd.AssignTo(&rule.WindowEnd)
+		if err != nil {
+			JsonError(w, http.StatusInternalServerError, "not implemented")
+			return
+		}
+		response.Rules = append(response.Rules, rule)
+	}
+
+	JsonResp(w, http.StatusOK, response)
+}
@@ -0,0 +1,155 @@
+package schedder_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"gitlab.com/vlad.anghel/schedder-api"
+)
+
+func TestPricingRules(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantName := "Zâna Măseluță"
+
+	tenantID := api.createTenantAndAccount(email, password, tenantName)
+	accountID := api.findAccountByEmail(email)
+	token := api.generateToken(email, password)
+	api.publishTenant(tenantID)
+	serviceID := api.createService(token, tenantID, accountID, "Masaj", 10000, time.Hour)
+
+	// the rules are evaluated in the time zone of the tenant, the default one
+	// like the schedule
+	today := localDate(time.Now())
+	starting := hourOn(today, 10)
+	api.setSchedule(token, accountID, tenantID, starting, starting.Add(3*time.Hour), today.Weekday())
+
+	tenantEndpoint := fmt.Sprintf("/tenants/%s", tenantID)
+	serviceEndpoint := fmt.Sprintf("%s/services/%s", tenantEndpoint, serviceID)
+	createRule := func(request schedder.CreatePricingRuleRequest) *http.Response {
+		return api.send(token, http.MethodPost, serviceEndpoint+"/pricing-rules", request)
+	}
+	resp := createRule(schedder.CreatePricingRuleRequest{
+		RuleName:   "Gratis",
+		Kind:       schedder.PricingPercentage,
+		Adjustment: -10001,
+	})
+	expect(t, http.StatusBadRequest, resp.StatusCode)
+	resp = createRule(schedder.CreatePricingRuleRequest{
+		RuleName:    "Seara",
+		WindowStart: 18 * time.Hour,
+		WindowEnd:   10 * time.Hour,
+		Kind:        schedder.PricingFixed,
+		Adjustment:  500,
+	})
+	expect(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = createRule(schedder.CreatePricingRuleRequest{
+		RuleName:    "Dimineața",
+		Weekdays:    []time.Weekday{today.Weekday()},
+		WindowStart: 10 * time.Hour,
+		WindowEnd:   11 * time.Hour,
+		Kind:        schedder.PricingPercentage,
+		Adjustment:  -2000,
+	})
+	var morning schedder.CreatePricingRuleResponse
+	err := json.NewDecoder(resp.Body).Decode(&morning)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, "", morning.Error)
+	expect(t, http.StatusCreated, resp.StatusCode)
+	resp = createRule(schedder.CreatePricingRuleRequest{
+		RuleName:   "Azi",
+		ValidFrom:  today,
+		ValidUntil: today,
+		Kind:       schedder.PricingFixed,
+		Adjustment: 500,
+	})
+	expect(t, http.StatusCreated, resp.StatusCode)
+	resp = createRule(schedder.CreatePricingRuleRequest{
+		RuleName:   "De mâine",
+		ValidFrom:  today.AddDate(0, 0, 1),
+		Kind:       schedder.PricingPercentage,
+		Adjustment: 5000,
+	})
+	expect(t, http.StatusCreated, resp.StatusCode)
+
+	resp = api.send(token, http.MethodGet, serviceEndpoint+"/pricing-rules", nil)
+	var rules schedder.PricingRulesResponse
+	err = json.NewDecoder(resp.Body).Decode(&rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, 3, len(rules.Rules))
+	expect(t, 1, len(rules.Rules[0].Weekdays))
+	expect(t, today.Weekday(), rules.Rules[0].Weekdays[0])
+	expect(t, 7, len(rules.Rules[1].Weekdays))
+	expect(t, 24*time.Hour, rules.Rules[1].WindowEnd)
+
+	timetable := func() map[int]int64 {
+		resp := api.send(
+			token, http.MethodGet, serviceEndpoint+"/timetable",
+			schedder.TimetableRequest{Date: starting},
+		)
+		var response schedder.TimetableResponse
+		err := json.NewDecoder(resp.Body).Decode(&response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect(t, "", response.Error)
+		prices := make(map[int]int64, len(response.Slots))
+		for _, slot := range response.Slots {
+			local := slot.Starting.In(bucharest)
+			minutes := local.Hour()*60 + local.Minute()
+			prices[minutes] = slot.Price.Amount
+		}
+		return prices
+	}
+
+	// 20% off until 11:00 and 5 RON more all day
+	prices := timetable()
+	expect(t, 5, len(prices))
+	expect(t, int64(8500), prices[600])
+	expect(t, int64(8500), prices[630])
+	expect(t, int64(10500), prices[660])
+	expect(t, int64(10500), prices[720])
+
+	resp = api.send(
+		token, http.MethodPost, serviceEndpoint+"/schedule",
+		schedder.CreateAppointmentRequest{Starting: starting.Add(30 * time.Minute)},
+	)
+	var booked schedder.CreateAppointmentResponse
+	err = json.NewDecoder(resp.Body).Decode(&booked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, http.StatusCreated, resp.StatusCode)
+	expect(t, int64(8500), booked.Price.Amount)
+
+	// the booked appointments keep their price
+	resp = api.send(
+		token, http.MethodDelete,
+		fmt.Sprintf("%s/pricing-rules/%s", serviceEndpoint, morning.RuleID), nil,
+	)
+	expect(t, http.StatusOK, resp.StatusCode)
+	prices = timetable()
+	expect(t, 2, len(prices))
+	expect(t, int64(10500), prices[690])
+
+	resp = api.send(token, http.MethodGet, "/accounts/self/appointments", nil)
+	var appointments schedder.AppointmentsResponse
+	err = json.NewDecoder(resp.Body).Decode(&appointments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, 1, len(appointments.Appointments))
+	expect(t, int64(8500), appointments.Appointments[0].Price.Amount)
+}
@@ -58,6 +58,7 @@ func TestReviews(t *testing.T) {
 	tenantName := "Zana Maseluta"
 
 	tenantID := api.createTenantAndAccount(email, password, tenantName)
+	api.publishTenant(tenantID)
 	token := api.generateToken(email, password)
 	message := strings.Repeat("lorem ipsum", 340)
 	rating := 4
@@ -0,0 +1,314 @@
+package schedder
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// emptyPayloadHash is the hex encoded sha256 of an empty payload.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3Storage is a BlobStorage that keeps the blobs as objects in a bucket of an
+// S3-compatible service, like AWS S3 or MinIO. Requests use path-style URLs
+// and are signed with AWS Signature Version 4.
+type S3Storage struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+
+	// Client represents the HTTP client used for the requests.
+	Client *http.Client
+	// Now returns the time used for signing, it's time.Now by default.
+	Now func() time.Time
+}
+
+// NewS3Storage returns a storage that keeps the blobs in the bucket. The
+// endpoint is the URL of the service, like "https://s3.eu-central-1.amazonaws.com"
+// or "http://localhost:9000" for MinIO.
+func NewS3Storage(
+	endpoint, region, bucket, accessKey, secretKey string,
+) (*S3Storage, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("s3: invalid endpoint %q", endpoint)
+	}
+	if bucket == "" {
+		return nil, fmt.Errorf("s3: missing bucket")
+	}
+
+	return &S3Storage{
+		endpoint:  u,
+		region:    region,
+		bucket:    bucket,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		Client:    http.DefaultClient,
+		Now:       time.Now,
+	}, nil
+}
+
+// do sends a signed request for the object with the key.
+func (s *S3Storage) do(
+	ctx context.Context, method, key string, body io.Reader, size int64,
+) (*http.Response, error) {
+	if !validBlobKey(key) {
+		return nil, errInvalidBlobKey
+	}
+	return s.request(ctx, method, "/"+key, nil, body, size)
+}
+
+// request sends a signed request for the path inside the bucket.
+func (s *S3Storage) request(
+	ctx context.Context,
+	method, path string, query url.Values,
+	body io.Reader, size int64,
+) (*http.Response, error) {
+	u := *s.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.bucket + path
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	payloadHash := emptyPayloadHash
+	if body != nil {
+		req.ContentLength = size
+		if size == 0 {
+			req.Body = http.NoBody
+		}
+		// The payload is streamed, so it isn't part of the signature.
+		payloadHash = "UNSIGNED-PAYLOAD"
+	}
+	signV4(req, s.accessKey, s.secretKey, s.region, payloadHash, s.Now())
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrBlobNotFound
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("s3: %s %s: %s", method, path, resp.Status)
+	}
+
+	return resp, nil
+}
+
+// Put implements BlobStorage.
+func (s *S3Storage) Put(
+	ctx context.Context, key string, r io.Reader, size int64,
+) error {
+	resp, err := s.do(ctx, http.MethodPut, key, r, size)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Get implements BlobStorage.
+func (s *S3Storage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Delete implements BlobStorage.
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, 0)
+	if errors.Is(err, ErrBlobNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Stat implements BlobStorage.
+func (s *S3Storage) Stat(ctx context.Context, key string) (BlobInfo, error) {
+	resp, err := s.do(ctx, http.MethodHead, key, nil, 0)
+	if err != nil {
+		return BlobInfo{}, err
+	}
+	resp.Body.Close()
+
+	modified, err := http.ParseTime(resp.Header.Get("Last-Modified"))
+	if err != nil {
+		return BlobInfo{}, err
+	}
+	return BlobInfo{Size: resp.ContentLength, Modified: modified}, nil
+}
+
+// s3ListResult represents the response of ListObjectsV2, as described by
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_ListObjectsV2.html.
+type s3ListResult struct {
+	IsTruncated           bool
+	NextContinuationToken string
+	Contents              []struct {
+		Key          string
+		Size         int64
+		LastModified time.Time
+	}
+}
+
+// List implements BlobStorage. Objects with keys that this storage wouldn't
+// write are skipped.
+func (s *S3Storage) List(
+	ctx context.Context, fn func(key string, info BlobInfo) error,
+) error {
+	query := url.Values{"list-type": {"2"}}
+	for {
+		resp, err := s.request(ctx, http.MethodGet, "", query, nil, 0)
+		if err != nil {
+			return err
+		}
+
+		var result s3ListResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		for _, object := range result.Contents {
+			if !validBlobKey(object.Key) {
+				continue
+			}
+			info := BlobInfo{Size: object.Size, Modified: object.LastModified}
+			err = fn(object.Key, info)
+			if err != nil {
+				return err
+			}
+		}
+
+		if !result.IsTruncated {
+			return nil
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+}
+
+// canonicalQuery returns the query string in the canonical form of the
+// signature: sorted by name and then by value, with everything but the
+// unreserved characters percent-encoded. Unlike url.Values.Encode, spaces are
+// encoded as %20.
+func canonicalQuery(query url.Values) string {
+	escape := func(s string) string {
+		return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+	}
+
+	escaped := make(map[string][]string, len(query))
+	names := make([]string, 0, len(query))
+	for name, values := range query {
+		name = escape(name)
+		for _, value := range values {
+			escaped[name] = append(escaped[name], escape(value))
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var pairs []string
+	for _, name := range names {
+		values := escaped[name]
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, name+"="+value)
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of the data.
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// signV4 signs the request for S3 as described by
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html.
+// All the headers already set on the request are signed. The query, if any,
+// must already be in the canonical form.
+func signV4(
+	req *http.Request,
+	accessKey, secretKey, region, payloadHash string,
+	now time.Time,
+) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(
+			strings.Join(values, ","),
+		)
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature,
+	))
+}
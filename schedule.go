@@ -1,40 +1,434 @@
 package schedder
 
 import (
+	"context"
 	"net/http"
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
 	"gitlab.com/vlad.anghel/schedder-api/database"
 )
 
-type SetScheduleRequest struct {
-	// Weekday represents the day of the week for which to set the schedule.
+// ScheduleInterval represents an interval when a member works on a
+// weekday.
+type ScheduleInterval struct {
+	// Weekday represents the day of the week of the interval.
 	// Valid values are: 0 (Sunday), 1 (Monday) ..., 6 (Saturday)
-	Weekday  time.Weekday
-	Starting time.Time
-	Ending   time.Time
+	Weekday time.Weekday `json:"weekday"`
+	// Starting and Ending represent the time of day of the interval, only the
+	// time of day is used and it's in the time zone of the tenant.
+	Starting time.Time `json:"starting"`
+	Ending   time.Time `json:"ending"`
+	// LocationID represents the location where the personnel works during
+	// the interval. It's optional, if it's missing then the personnel can
+	// work at any of their locations.
+	LocationID uuid.UUID `json:"location_id,omitempty"`
+}
+
+// SetScheduleRequest represents the weekly schedule of a member, it replaces
+// the whole schedule. The intervals of a weekday can't overlap, like 9-13 and
+// 15-19 for a split shift.
+type SetScheduleRequest struct {
+	Intervals []ScheduleInterval `json:"intervals"`
+}
+
+// UpdateScheduleIntervalRequest represents the new weekday, times and
+// location of an interval of the schedule, all the fields are replaced.
+type UpdateScheduleIntervalRequest struct {
+	Weekday    time.Weekday `json:"weekday"`
+	Starting   time.Time    `json:"starting"`
+	Ending     time.Time    `json:"ending"`
+	LocationID uuid.UUID    `json:"location_id,omitempty"`
+}
+
+// scheduleEntry represents an interval of the schedule of a member.
+type scheduleEntry struct {
+	IntervalID uuid.UUID    `json:"interval_id"`
+	Weekday    time.Weekday `json:"weekday"`
+	Starting   time.Time    `json:"starting"`
+	Ending     time.Time    `json:"ending"`
+	// LocationID represents the location, it's the nil UUID if the member
+	// can work at any of their locations.
+	LocationID uuid.UUID `json:"location_id"`
+}
+
+// ScheduleResponse represents the weekly schedule of a member.
+type ScheduleResponse struct {
+	Response
+	// Intervals represents the intervals, ordered by weekday and time.
+	Intervals []scheduleEntry `json:"intervals"`
+}
+
+// timeOfDay returns the time since midnight of the wall clock as written, the
+// times of the schedules are local times in the time zone of the tenant.
+func timeOfDay(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour +
+		time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second
+}
+
+// validSchedule checks that the intervals are on valid weekdays, that they
+// end after they start and that the ones of the same weekday don't overlap,
+// returning an error message for the client otherwise.
+func validSchedule(intervals []ScheduleInterval) string {
+	sorted := make([]ScheduleInterval, len(intervals))
+	copy(sorted, intervals)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Weekday != sorted[j].Weekday {
+			return sorted[i].Weekday < sorted[j].Weekday
+		}
+		return timeOfDay(sorted[i].Starting) < timeOfDay(sorted[j].Starting)
+	})
+
+	for i, entry := range sorted {
+		if entry.Weekday < time.Sunday || entry.Weekday > time.Saturday {
+			return "invalid weekday"
+		}
+		if timeOfDay(entry.Starting) >= timeOfDay(entry.Ending) {
+			return "invalid interval"
+		}
+		if i > 0 && sorted[i-1].Weekday == entry.Weekday &&
+			timeOfDay(sorted[i-1].Ending) > timeOfDay(entry.Starting) {
+			return "overlapping intervals"
+		}
+	}
+	return ""
+}
+
+// validScheduleLocations checks that the member is assigned to the locations
+// of the intervals, returning an error message for the client otherwise.
+func validScheduleLocations(
+	ctx context.Context, queries *database.Queries,
+	tenantID, accountID uuid.UUID,
+	intervals []ScheduleInterval,
+) (string, error) {
+	seen := make(map[uuid.UUID]bool)
+	for _, entry := range intervals {
+		if entry.LocationID == uuid.Nil || seen[entry.LocationID] {
+			continue
+		}
+		seen[entry.LocationID] = true
+
+		ilpp := database.IsLocationPersonnelParams{
+			TenantID:   tenantID,
+			LocationID: entry.LocationID,
+			AccountID:  accountID,
+		}
+		assigned, err := queries.IsLocationPersonnel(ctx, ilpp)
+		if err != nil {
+			return "", err
+		}
+		if !assigned {
+			return "not assigned to location", nil
+		}
+	}
+	return "", nil
+}
+
+// isMember reports whether the account is a member of the tenant, the
+// schedules of the other accounts can't be changed by its managers.
+func isMember(
+	ctx context.Context, queries *database.Queries, tenantID, accountID uuid.UUID,
+) (bool, error) {
+	itmp := database.IsTenantMemberParams{
+		TenantID:  tenantID,
+		AccountID: accountID,
+	}
+	return queries.IsTenantMember(ctx, itmp)
+}
+
+// updateFutureConflicts recomputes which appointments of the member from today
+// on are outside their working hours, after a change of the weekly schedule.
+func updateFutureConflicts(
+	ctx context.Context, queries *database.Queries, tenantID, accountID uuid.UUID,
+) error {
+	tz, err := tenantTimezone(ctx, queries, tenantID)
+	if err != nil {
+		return err
+	}
+	_, err = updateConflicts(
+		ctx, queries, tenantID, accountID, dateOf(time.Now(), tz), time.Time{},
+	)
+	return err
 }
 
+// SetSchedule replaces the weekly schedule of a member at the tenant. The
+// booked appointments are kept, flagged if they're outside the new hours.
 func (a *API) SetSchedule(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
 	accountID := ctx.Value(CtxAccountID).(uuid.UUID)
 	request := ctx.Value(CtxJSON).(*SetScheduleRequest)
 
-	if request.Weekday < time.Sunday || request.Weekday > time.Saturday  {
-		JsonError(w, http.StatusBadRequest, "invalid weekday")
+	if msg := validSchedule(request.Intervals); msg != "" {
+		JsonError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	tx, err := a.txlike.Begin(ctx)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	defer tx.Rollback(ctx)
+	queries := database.New(tx)
+
+	err = queries.LockSchedule(ctx, accountID)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	member, err := isMember(ctx, queries, tenantID, accountID)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	if !member {
+		JsonError(w, http.StatusNotFound, "not a member")
+		return
+	}
+
+	msg, err := validScheduleLocations(
+		ctx, queries, tenantID, accountID, request.Intervals,
+	)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	if msg != "" {
+		JsonError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	csp := database.ClearScheduleParams{
+		TenantID:  tenantID,
+		AccountID: accountID,
+	}
+	err = queries.ClearSchedule(ctx, csp)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	for _, entry := range request.Intervals {
+		asip := database.AddScheduleIntervalParams{
+			TenantID:     tenantID,
+			AccountID:    accountID,
+			Weekday:      entry.Weekday,
+			StartingTime: entry.Starting,
+			EndingTime:   entry.Ending,
+			LocationID: uuid.NullUUID{
+				UUID:  entry.LocationID,
+				Valid: entry.LocationID != uuid.Nil,
+			},
+		}
+		_, err = queries.AddScheduleInterval(ctx, asip)
+		if err != nil {
+			JsonError(w, http.StatusBadRequest, "invalid interval")
+			return
+		}
+	}
+
+	err = updateFutureConflicts(ctx, queries, tenantID, accountID)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "couldn't set schedule")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Schedule returns the weekly schedule of a member at the tenant.
+func (a *API) Schedule(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	accountID := ctx.Value(CtxAccountID).(uuid.UUID)
+
+	gsp := database.GetScheduleParams{
+		TenantID:  tenantID,
+		AccountID: accountID,
+	}
+	rows, err := a.db.GetSchedule(ctx, gsp)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	var response ScheduleResponse
+	response.Intervals = make([]scheduleEntry, 0, len(rows))
+	for _, row := range rows {
+		response.Intervals = append(response.Intervals, scheduleEntry{
+			IntervalID: row.IntervalID,
+			Weekday:    row.Weekday,
+			Starting:   row.StartingTime,
+			Ending:     row.EndingTime,
+			LocationID: row.LocationID.UUID,
+		})
+	}
+
+	JsonResp(w, http.StatusOK, response)
+}
+
+// UpdateScheduleInterval changes an interval of the schedule of a member, it
+// can't overlap the other intervals of the weekday.
+func (a *API) UpdateScheduleInterval(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	accountID := ctx.Value(CtxAccountID).(uuid.UUID)
+	intervalID := ctx.Value(CtxIntervalID).(uuid.UUID)
+	request := ctx.Value(CtxJSON).(*UpdateScheduleIntervalRequest)
+
+	updated := ScheduleInterval{
+		Weekday:    request.Weekday,
+		Starting:   request.Starting,
+		Ending:     request.Ending,
+		LocationID: request.LocationID,
+	}
+
+	tx, err := a.txlike.Begin(ctx)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	defer tx.Rollback(ctx)
+	queries := database.New(tx)
+
+	// the other intervals can't change until the updated one is written
+	err = queries.LockSchedule(ctx, accountID)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	gsp := database.GetScheduleParams{
+		TenantID:  tenantID,
+		AccountID: accountID,
+	}
+	rows, err := queries.GetSchedule(ctx, gsp)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	intervals := []ScheduleInterval{updated}
+	found := false
+	for _, row := range rows {
+		if row.IntervalID == intervalID {
+			found = true
+			continue
+		}
+		intervals = append(intervals, ScheduleInterval{
+			Weekday:  row.Weekday,
+			Starting: row.StartingTime,
+			Ending:   row.EndingTime,
+		})
+	}
+	if !found {
+		JsonError(w, http.StatusNotFound, "invalid interval")
+		return
+	}
+	if msg := validSchedule(intervals); msg != "" {
+		JsonError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	msg, err := validScheduleLocations(
+		ctx, queries, tenantID, accountID, intervals[:1],
+	)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
 		return
 	}
-	ssp := database.SetScheduleParams{
-		AccountID:    accountID,
-		Weekday:      request.Weekday,
-		StartingTime: request.Starting,
-		EndingTime:   request.Ending,
+	if msg != "" {
+		JsonError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	usip := database.UpdateScheduleIntervalParams{
+		Weekday:      updated.Weekday,
+		StartingTime: updated.Starting,
+		EndingTime:   updated.Ending,
+		LocationID: uuid.NullUUID{
+			UUID:  updated.LocationID,
+			Valid: updated.LocationID != uuid.Nil,
+		},
+		TenantID:   tenantID,
+		AccountID:  accountID,
+		IntervalID: intervalID,
+	}
+	_, err = queries.UpdateScheduleInterval(ctx, usip)
+	if err != nil {
+		JsonError(w, http.StatusBadRequest, "invalid interval")
+		return
+	}
+
+	err = updateFutureConflicts(ctx, queries, tenantID, accountID)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "couldn't update interval")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// DeleteScheduleInterval deletes an interval of the schedule of a member. The
+// booked appointments are kept, flagged if they're outside the new hours.
+func (a *API) DeleteScheduleInterval(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	accountID := ctx.Value(CtxAccountID).(uuid.UUID)
+	intervalID := ctx.Value(CtxIntervalID).(uuid.UUID)
+
+	tx, err := a.txlike.Begin(ctx)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	defer tx.Rollback(ctx)
+	queries := database.New(tx)
+
+	err = queries.LockSchedule(ctx, accountID)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	dsip := database.DeleteScheduleIntervalParams{
+		TenantID:   tenantID,
+		AccountID:  accountID,
+		IntervalID: intervalID,
+	}
+	affected, err := queries.DeleteScheduleInterval(ctx, dsip)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	if affected == 0 {
+		JsonError(w, http.StatusNotFound, "invalid interval")
+		return
+	}
+
+	err = updateFutureConflicts(ctx, queries, tenantID, accountID)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
 	}
 
-	err := a.db.SetSchedule(ctx, ssp)
+	err = tx.Commit(ctx)
 	if err != nil {
-		JsonError(w, http.StatusInternalServerError, err.Error())
+		JsonError(w, http.StatusInternalServerError, "couldn't delete interval")
 		return
 	}
 
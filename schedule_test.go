@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -46,21 +47,14 @@ func TestSetSchedule(t *testing.T) {
 
 	t.Log(endpoint)
 
-	req, err := NewJSONRequest(
-		http.MethodPost,
-		endpoint,
-		schedder.SetScheduleRequest{
-			Weekday: weekday,
-			Starting: starting,
-			Ending: ending,
-		},
+	body := fmt.Sprintf(
+		`{"intervals":[{"weekday":%d,"starting":"%s","ending":"%s"}]}`,
+		weekday, starting.Format(time.RFC3339), ending.Format(time.RFC3339),
 	)
+	req := httptest.NewRequest(http.MethodPut, endpoint, strings.NewReader(body))
 	req.Header.Add(
 		"Authorization", "Bearer " + api.generateToken(email, password),
 	)
-	if err != nil {
-		t.Fatal(err)
-	}
 	w := httptest.NewRecorder()
 
 	api.ServeHTTP(w, req)
@@ -78,3 +72,245 @@ func TestSetSchedule(t *testing.T) {
 		t.Fatalf("Result: %s: %s", resp.Status, response.Error)
 	}
 }
+
+func TestScheduleIntervals(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantName := "Zâna Măseluță"
+
+	tenantID := api.createTenantAndAccount(email, password, tenantName)
+	accountID := api.findAccountByEmail(email)
+	token := api.generateToken(email, password)
+	api.publishTenant(tenantID)
+	serviceID := api.createService(token, tenantID, accountID, "Tuns", 5000, time.Hour)
+
+	today := localDate(time.Now())
+	weekday := today.Weekday()
+	at := func(hour int) time.Time {
+		return hourOn(today, hour)
+	}
+
+	interval := func(from, to int) schedder.ScheduleInterval {
+		return schedder.ScheduleInterval{
+			Weekday:  weekday,
+			Starting: at(from),
+			Ending:   at(to),
+		}
+	}
+	endpoint := fmt.Sprintf(
+		"/tenants/%s/personnel/%s/schedule", tenantID, accountID,
+	)
+	resp := api.send(token, http.MethodPut, endpoint, schedder.SetScheduleRequest{
+		Intervals: []schedder.ScheduleInterval{interval(9, 13), interval(12, 15)},
+	})
+	expect(t, http.StatusBadRequest, resp.StatusCode)
+	resp = api.send(token, http.MethodPut, endpoint, schedder.SetScheduleRequest{
+		Intervals: []schedder.ScheduleInterval{interval(15, 17), interval(9, 11)},
+	})
+	expect(t, http.StatusOK, resp.StatusCode)
+
+	schedule := func() schedder.ScheduleResponse {
+		resp := api.send(token, http.MethodGet, endpoint, nil)
+		var response schedder.ScheduleResponse
+		err := json.NewDecoder(resp.Body).Decode(&response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect(t, "", response.Error)
+		return response
+	}
+	response := schedule()
+	expect(t, 2, len(response.Intervals))
+	expect(t, 9, response.Intervals[0].Starting.Hour())
+	expect(t, 15, response.Intervals[1].Starting.Hour())
+
+	// the break between the intervals isn't free
+	timetable := func() []int {
+		resp := api.send(
+			token, http.MethodGet,
+			fmt.Sprintf("/tenants/%s/services/%s/timetable", tenantID, serviceID),
+			schedder.TimetableRequest{Date: at(0)},
+		)
+		var response schedder.TimetableResponse
+		err := json.NewDecoder(resp.Body).Decode(&response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect(t, "", response.Error)
+		minutes := make([]int, 0, len(response.Times))
+		for _, slot := range response.Times {
+			minutes = append(minutes, slot.Hour()*60+slot.Minute())
+		}
+		return minutes
+	}
+	expect(t, fmt.Sprint([]int{540, 570, 600, 900, 930, 960}), fmt.Sprint(timetable()))
+
+	// an update can't overlap the other intervals of the weekday
+	intervalEndpoint := fmt.Sprintf("%s/%s", endpoint, response.Intervals[0].IntervalID)
+	update := func(from, to int) schedder.UpdateScheduleIntervalRequest {
+		return schedder.UpdateScheduleIntervalRequest{
+			Weekday:  weekday,
+			Starting: at(from),
+			Ending:   at(to),
+		}
+	}
+	resp = api.send(token, http.MethodPut, intervalEndpoint, update(9, 16))
+	expect(t, http.StatusBadRequest, resp.StatusCode)
+	resp = api.send(token, http.MethodPut, intervalEndpoint, update(10, 12))
+	expect(t, http.StatusOK, resp.StatusCode)
+	expect(t, fmt.Sprint([]int{600, 630, 660, 900, 930, 960}), fmt.Sprint(timetable()))
+
+	resp = api.send(token, http.MethodDelete, intervalEndpoint, nil)
+	expect(t, http.StatusOK, resp.StatusCode)
+	resp = api.send(token, http.MethodDelete, intervalEndpoint, nil)
+	expect(t, http.StatusNotFound, resp.StatusCode)
+	expect(t, 1, len(schedule().Intervals))
+	expect(t, fmt.Sprint([]int{900, 930, 960}), fmt.Sprint(timetable()))
+}
+
+func TestScheduleOfForeignAccount(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantID := api.createTenantAndAccount(email, password, "Zâna Măseluță")
+	accountID := api.findAccountByEmail(email)
+	token := api.generateToken(email, password)
+
+	starting := time.Time{}.Add(10 * time.Hour)
+	api.setSchedule(token, accountID, tenantID, starting, starting.Add(8*time.Hour), time.Monday)
+	endpoint := fmt.Sprintf(
+		"/tenants/%s/personnel/%s/schedule", tenantID, accountID,
+	)
+	schedule := func() schedder.ScheduleResponse {
+		resp := api.send(token, http.MethodGet, endpoint, nil)
+		var response schedder.ScheduleResponse
+		err := json.NewDecoder(resp.Body).Decode(&response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect(t, "", response.Error)
+		return response
+	}
+	intervalID := schedule().Intervals[0].IntervalID
+
+	// the manager of another tenant can't touch the schedule of the account
+	otherEmail := "other@example.com"
+	otherTenantID := api.createTenantAndAccount(otherEmail, password, "Altă Zână")
+	otherToken := api.generateToken(otherEmail, password)
+	foreignEndpoint := fmt.Sprintf(
+		"/tenants/%s/personnel/%s/schedule", otherTenantID, accountID,
+	)
+
+	resp := api.send(otherToken, http.MethodPut, foreignEndpoint, schedder.SetScheduleRequest{
+		Intervals: []schedder.ScheduleInterval{},
+	})
+	expect(t, http.StatusNotFound, resp.StatusCode)
+	resp = api.send(
+		otherToken, http.MethodPut, fmt.Sprintf("%s/%s", foreignEndpoint, intervalID),
+		schedder.UpdateScheduleIntervalRequest{
+			Weekday:  time.Monday,
+			Starting: starting,
+			Ending:   starting.Add(time.Hour),
+		},
+	)
+	expect(t, http.StatusNotFound, resp.StatusCode)
+	resp = api.send(
+		otherToken, http.MethodDelete, fmt.Sprintf("%s/%s", foreignEndpoint, intervalID), nil,
+	)
+	expect(t, http.StatusNotFound, resp.StatusCode)
+
+	// nor through the endpoint of the tenant of the account
+	resp = api.send(otherToken, http.MethodPut, endpoint, schedder.SetScheduleRequest{
+		Intervals: []schedder.ScheduleInterval{},
+	})
+	expect(t, http.StatusForbidden, resp.StatusCode)
+
+	response := schedule()
+	expect(t, 1, len(response.Intervals))
+	expect(t, 18, response.Intervals[0].Ending.Hour())
+}
+
+func TestScheduleChangeConflicts(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantID := api.createTenantAndAccount(email, password, "Zâna Măseluță")
+	accountID := api.findAccountByEmail(email)
+	token := api.generateToken(email, password)
+	api.publishTenant(tenantID)
+	serviceID := api.createService(token, tenantID, accountID, "Tuns", 5000, time.Hour)
+
+	today := localDate(time.Now())
+	weekday := today.Weekday()
+	at := func(hour int) time.Time {
+		return hourOn(today, hour)
+	}
+	api.setSchedule(token, accountID, tenantID, at(10), at(13), weekday)
+
+	resp := api.send(
+		token, http.MethodPost,
+		fmt.Sprintf("/tenants/%s/services/%s/schedule", tenantID, serviceID),
+		schedder.CreateAppointmentRequest{Starting: at(10)},
+	)
+	expect(t, http.StatusCreated, resp.StatusCode)
+
+	conflicting := func() bool {
+		resp := api.send(token, http.MethodGet, "/accounts/self/appointments", nil)
+		var response schedder.AppointmentsResponse
+		err := json.NewDecoder(resp.Body).Decode(&response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect(t, 1, len(response.Appointments))
+		return response.Appointments[0].Conflicting
+	}
+	expect(t, false, conflicting())
+
+	endpoint := fmt.Sprintf(
+		"/tenants/%s/personnel/%s/schedule", tenantID, accountID,
+	)
+	resp = api.send(token, http.MethodGet, endpoint, nil)
+	var schedule schedder.ScheduleResponse
+	err := json.NewDecoder(resp.Body).Decode(&schedule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	intervalEndpoint := fmt.Sprintf("%s/%s", endpoint, schedule.Intervals[0].IntervalID)
+	update := func(from, to int) schedder.UpdateScheduleIntervalRequest {
+		return schedder.UpdateScheduleIntervalRequest{
+			Weekday:  weekday,
+			Starting: at(from),
+			Ending:   at(to),
+		}
+	}
+
+	// the booked appointment is flagged as soon as the hours leave it outside
+	resp = api.send(token, http.MethodPut, intervalEndpoint, update(11, 13))
+	expect(t, http.StatusOK, resp.StatusCode)
+	expect(t, true, conflicting())
+	resp = api.send(token, http.MethodPut, intervalEndpoint, update(10, 13))
+	expect(t, http.StatusOK, resp.StatusCode)
+	expect(t, false, conflicting())
+
+	resp = api.send(token, http.MethodDelete, intervalEndpoint, nil)
+	expect(t, http.StatusOK, resp.StatusCode)
+	expect(t, true, conflicting())
+
+	resp = api.send(token, http.MethodPut, endpoint, schedder.SetScheduleRequest{
+		Intervals: []schedder.ScheduleInterval{
+			{Weekday: weekday, Starting: at(9), Ending: at(12)},
+		},
+	})
+	expect(t, http.StatusOK, resp.StatusCode)
+	expect(t, false, conflicting())
+}
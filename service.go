@@ -1,31 +1,133 @@
 package schedder
 
 import (
-	"math"
+	"database/sql"
 	"net/http"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgtype"
 	"gitlab.com/vlad.anghel/schedder-api/database"
 )
 
 type CreateServiceRequest struct {
-	ServiceName string        `json:"service_name"`
-	Price       float64       `json:"price"`
-	Duration    time.Duration `json:"duration"`
+	ServiceName string `json:"service_name"`
+	// Price represents the price before VAT, in the currency of the tenant.
+	Price    Money         `json:"price"`
+	Duration time.Duration `json:"duration"`
+	// BufferBefore represents the time blocked before every appointment, like
+	// for preparing.
+	BufferBefore time.Duration `json:"buffer_before"`
+	// BufferAfter represents the time blocked after every appointment, like
+	// for cleaning up.
+	BufferAfter time.Duration `json:"buffer_after"`
+	// Description represents what the service includes.
+	Description string `json:"description"`
+	// CategoryID represents the category of the service, if it has one.
+	CategoryID uuid.UUID `json:"category_id,omitempty"`
 }
 
+// maxBuffer represents the maximum buffer before or after an appointment.
+const maxBuffer = 4 * time.Hour
+
 type CreateServiceResponse struct {
 	Response
 	ServiceID uuid.UUID `json:"service_id"`
 }
 
-type serviceResponse struct {
-	PersonnelID uuid.UUID     `json:"personnel_id"`
-	ServiceID   uuid.UUID     `json:"service_id"`
+// AssignServicePersonnelRequest represents the price and duration of a
+// service for a member, the zero values mean the ones of the catalogue.
+type AssignServicePersonnelRequest struct {
+	Price    Money         `json:"price"`
+	Duration time.Duration `json:"duration"`
+}
+
+// servicePersonnelEntry represents a member doing a service, with their price
+// and duration.
+type servicePersonnelEntry struct {
+	PersonnelID uuid.UUID `json:"personnel_id"`
+	Price       Money     `json:"price"`
+	// Total represents the price with VAT.
+	Total    Money         `json:"total"`
+	Duration time.Duration `json:"duration"`
+}
+
+// catalogueServiceEntry represents a service of the catalogue of a tenant.
+type catalogueServiceEntry struct {
+	ServiceID   uuid.UUID `json:"service_id"`
+	ServiceName string    `json:"service_name"`
+	Description string    `json:"description"`
+	Price       Money     `json:"price"`
+	// Total represents the price with VAT.
+	Total        Money         `json:"total"`
+	Duration     time.Duration `json:"duration"`
+	BufferBefore time.Duration `json:"buffer_before"`
+	BufferAfter  time.Duration `json:"buffer_after"`
+	// Personnel represents the members doing the service.
+	Personnel []servicePersonnelEntry `json:"personnel"`
+	Variants  []serviceOptionEntry    `json:"variants"`
+	AddOns    []serviceOptionEntry    `json:"addons"`
+}
+
+// serviceCategoryEntry represents a category of the catalogue of a tenant,
+// with its services.
+type serviceCategoryEntry struct {
+	// CategoryID represents the category, it's the nil UUID for the services
+	// without one, which come last.
+	CategoryID   uuid.UUID               `json:"category_id"`
+	CategoryName string                  `json:"category_name"`
+	Position     int                     `json:"position"`
+	Services     []catalogueServiceEntry `json:"services"`
+}
+
+// ServicesForTenantResponse represents the catalogue of a tenant, grouped by
+// category.
+type ServicesForTenantResponse struct {
+	Response
+	// Language represents the language of the names and descriptions which
+	// are translated to it, it's empty if none was requested.
+	Language   string                 `json:"language"`
+	Categories []serviceCategoryEntry `json:"categories"`
+}
+
+// UpdateServiceRequest represents the new name, price, duration, buffers,
+// description and category of a service, all the fields are replaced.
+type UpdateServiceRequest struct {
+	ServiceName  string        `json:"service_name"`
+	Price        Money         `json:"price"`
+	Duration     time.Duration `json:"duration"`
+	BufferBefore time.Duration `json:"buffer_before"`
+	BufferAfter  time.Duration `json:"buffer_after"`
+	Description  string        `json:"description"`
+	CategoryID   uuid.UUID     `json:"category_id,omitempty"`
+}
+
+// serviceVersionEntry represents a version of a service.
+type serviceVersionEntry struct {
+	// Version represents the number of the version, starting from 1.
+	Version     int           `json:"version"`
 	ServiceName string        `json:"service_name"`
-	Price       float64       `json:"price"`
+	Price       Money         `json:"price"`
 	Duration    time.Duration `json:"duration"`
+	// ValidFrom represents when the version replaced the previous one.
+	ValidFrom time.Time `json:"valid_from"`
+}
+
+// ServiceHistoryResponse represents the response of the service history
+// endpoint.
+type ServiceHistoryResponse struct {
+	Response
+	Versions []serviceVersionEntry `json:"versions"`
+}
+
+type serviceResponse struct {
+	PersonnelID uuid.UUID `json:"personnel_id"`
+	ServiceID   uuid.UUID `json:"service_id"`
+	ServiceName string    `json:"service_name"`
+	Price       Money     `json:"price"`
+	// Total represents the price with VAT.
+	Total    Money         `json:"total"`
+	Duration time.Duration `json:"duration"`
 }
 
 type ServicesResponse struct {
@@ -33,32 +135,134 @@ type ServicesResponse struct {
 	Services []serviceResponse `json:"services"`
 }
 
+// validBuffers checks the buffers of a service, returning an error message for
+// the client if they're invalid.
+func validBuffers(before, after time.Duration) string {
+	for _, buffer := range []time.Duration{before, after} {
+		if buffer < 0 || buffer > maxBuffer || buffer%time.Minute != 0 {
+			return "invalid buffer"
+		}
+	}
+	return ""
+}
+
+// createServiceParams validates the request and returns the parameters of
+// the new service, or an error message for the client.
+func createServiceParams(
+	tenantID uuid.UUID, pricing tenantPricing, request *CreateServiceRequest,
+) (database.CreateServiceParams, string) {
+	params := database.CreateServiceParams{
+		TenantID:    tenantID,
+		ServiceName: request.ServiceName,
+		Price:       request.Price.Amount,
+		Description: request.Description,
+		CategoryID: uuid.NullUUID{
+			UUID:  request.CategoryID,
+			Valid: request.CategoryID != uuid.Nil,
+		},
+	}
+	msg := pricing.validService(request.Price, request.Duration)
+	if msg == "" {
+		msg = validBuffers(request.BufferBefore, request.BufferAfter)
+	}
+	if msg != "" {
+		return params, msg
+	}
+	params.Duration.Set(request.Duration)
+	params.BufferBefore.Set(request.BufferBefore)
+	params.BufferAfter.Set(request.BufferAfter)
+	return params, ""
+}
+
+// CreateService creates a service in the catalogue of the tenant and assigns
+// it to the member.
 func (a *API) CreateService(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
 	accountID := ctx.Value(CtxAccountID).(uuid.UUID)
 	request := ctx.Value(CtxJSON).(*CreateServiceRequest)
 
-	if request.Price < 0 || request.Price > 1000000 {
-		JsonError(w, http.StatusBadRequest, "invalid price")
+	pricing, err := a.pricingFor(ctx, tenantID)
+	if err != nil {
+		JsonError(w, http.StatusNotFound, "invalid tenant")
+		return
 	}
 
-	request.Price = math.Round(request.Price*100) / 100
+	params, msg := createServiceParams(tenantID, pricing, request)
+	if msg != "" {
+		JsonError(w, http.StatusBadRequest, msg)
+		return
+	}
 
-	if request.Duration%time.Minute != 0 {
-		JsonError(w, http.StatusBadRequest, "invalid duration")
+	tx, err := a.txlike.Begin(ctx)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
 		return
 	}
+	defer tx.Rollback(ctx)
+	queries := database.New(tx)
 
-	params := database.CreateServiceParams{
-		TenantID:    tenantID,
-		AccountID:   accountID,
-		ServiceName: request.ServiceName,
+	serviceID, err := queries.CreateService(ctx, params)
+	if isUniqueViolation(err) {
+		JsonError(w, http.StatusConflict, "duplicate service name")
+		return
+	}
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	aspp := database.AssignServicePersonnelParams{
+		TenantID:  tenantID,
+		ServiceID: serviceID,
+		AccountID: accountID,
+	}
+	aspp.Duration.Status = pgtype.Null
+	affected, err := queries.AssignServicePersonnel(ctx, aspp)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	if affected == 0 {
+		JsonError(w, http.StatusBadRequest, "not a member")
+		return
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	response := CreateServiceResponse{ServiceID: serviceID}
+	JsonResp(w, http.StatusCreated, response)
+}
+
+// CreateCatalogueService creates a service in the catalogue of the tenant,
+// without any personnel. The names of the services are unique for the tenant,
+// the members have their own prices and durations instead.
+func (a *API) CreateCatalogueService(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	request := ctx.Value(CtxJSON).(*CreateServiceRequest)
+
+	pricing, err := a.pricingFor(ctx, tenantID)
+	if err != nil {
+		JsonError(w, http.StatusNotFound, "invalid tenant")
+		return
+	}
+
+	params, msg := createServiceParams(tenantID, pricing, request)
+	if msg != "" {
+		JsonError(w, http.StatusBadRequest, msg)
+		return
 	}
-	params.Price.Set(request.Price)
-	params.Duration.Set(request.Duration)
 
 	serviceID, err := a.db.CreateService(ctx, params)
+	if isUniqueViolation(err) {
+		JsonError(w, http.StatusConflict, "duplicate service name")
+		return
+	}
 	if err != nil {
 		JsonError(w, http.StatusInternalServerError, "not implemented")
 		return
@@ -67,11 +271,91 @@ func (a *API) CreateService(w http.ResponseWriter, r *http.Request) {
 	JsonResp(w, http.StatusCreated, response)
 }
 
+// AssignServicePersonnel lets a member do a service of the catalogue, with
+// their own price or duration.
+func (a *API) AssignServicePersonnel(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	serviceID := ctx.Value(CtxServiceID).(uuid.UUID)
+	accountID := ctx.Value(CtxAccountID).(uuid.UUID)
+	request := ctx.Value(CtxJSON).(*AssignServicePersonnelRequest)
+
+	pricing, err := a.pricingFor(ctx, tenantID)
+	if err != nil {
+		JsonError(w, http.StatusNotFound, "invalid tenant")
+		return
+	}
+
+	msg := pricing.validService(request.Price, request.Duration)
+	if msg != "" {
+		JsonError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	aspp := database.AssignServicePersonnelParams{
+		TenantID:  tenantID,
+		ServiceID: serviceID,
+		AccountID: accountID,
+	}
+	aspp.Price = sql.NullInt64{
+		Int64: request.Price.Amount,
+		Valid: request.Price.Amount != 0,
+	}
+	aspp.Duration.Status = pgtype.Null
+	if request.Duration != 0 {
+		aspp.Duration.Set(request.Duration)
+	}
+
+	affected, err := a.db.AssignServicePersonnel(ctx, aspp)
+	if err != nil {
+		JsonError(w, http.StatusBadRequest, "couldn't assign service")
+		return
+	}
+	if affected == 0 {
+		JsonError(w, http.StatusNotFound, "invalid service or member")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// UnassignServicePersonnel stops a member from doing a service, their booked
+// appointments are kept.
+func (a *API) UnassignServicePersonnel(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	serviceID := ctx.Value(CtxServiceID).(uuid.UUID)
+	accountID := ctx.Value(CtxAccountID).(uuid.UUID)
+
+	usp := database.UnassignServicePersonnelParams{
+		TenantID:  tenantID,
+		ServiceID: serviceID,
+		AccountID: accountID,
+	}
+	affected, err := a.db.UnassignServicePersonnel(ctx, usp)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	if affected == 0 {
+		JsonError(w, http.StatusNotFound, "not assigned")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
 func (a *API) ServicesForPersonnel(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
 	personnelID := ctx.Value(CtxAccountID).(uuid.UUID)
 
+	pricing, err := a.pricingFor(ctx, tenantID)
+	if err != nil {
+		JsonError(w, http.StatusNotFound, "invalid tenant")
+		return
+	}
+
 	params := database.GetServicesParams{
 		TenantID:  tenantID,
 		AccountID: personnelID,
@@ -90,13 +374,10 @@ func (a *API) ServicesForPersonnel(w http.ResponseWriter, r *http.Request) {
 			PersonnelID: personnelID,
 			ServiceName: row.ServiceName,
 			ServiceID: row.ServiceID,
+			Price:       pricing.price(row.Price),
+			Total:       pricing.total(row.Price),
 		}
-		err := row.Price.AssignTo(&service.Price)
-		if err != nil {
-			JsonError(w, http.StatusInternalServerError, "not implemented")
-			return
-		}
-		err = row.Duration.AssignTo(&service.Duration)
+		err := row.Duration.AssignTo(&service.Duration)
 		if err != nil {
 			JsonError(w, http.StatusInternalServerError, "not implemented")
 			return
@@ -107,36 +388,306 @@ func (a *API) ServicesForPersonnel(w http.ResponseWriter, r *http.Request) {
 	JsonResp(w, http.StatusOK, response)
 }
 
+// ServicesForTenant lists the catalogue of the tenant grouped by category, with
+// the personnel doing each service. The names and descriptions are in the
+// translation that best matches the lang query parameter or the
+// Accept-Language header, if there's one.
 func (a *API) ServicesForTenant(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
 
-	rows, err := a.db.GetServicesForTenant(ctx, tenantID)
+	pricing, err := a.pricingFor(ctx, tenantID)
+	if err != nil {
+		JsonError(w, http.StatusNotFound, "invalid tenant")
+		return
+	}
+
+	languages, err := a.db.GetTenantTranslationLanguages(ctx, tenantID)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	lang := requestLanguage(r, languages)
+	gsftp := database.GetServicesForTenantParams{
+		Language: lang,
+		TenantID: tenantID,
+	}
+	rows, err := a.db.GetServicesForTenant(ctx, gsftp)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	personnelRows, err := a.db.GetServicePersonnelForTenant(ctx, tenantID)
 	if err != nil {
 		JsonError(w, http.StatusInternalServerError, "not implemented")
 		return
 	}
 
-	var response ServicesResponse
-	response.Services = make([]serviceResponse, 0, len(rows))
+	personnel := make(map[uuid.UUID][]servicePersonnelEntry)
+	for i := range personnelRows {
+		row := &personnelRows[i]
+		entry := servicePersonnelEntry{
+			PersonnelID: row.AccountID,
+			Price:       pricing.price(row.Price),
+			Total:       pricing.total(row.Price),
+		}
+		err := row.Duration.AssignTo(&entry.Duration)
+		if err != nil {
+			JsonError(w, http.StatusInternalServerError, "not implemented")
+			return
+		}
+		personnel[row.ServiceID] = append(personnel[row.ServiceID], entry)
+	}
+
+	optionRows, err := a.db.GetServiceOptionsForTenant(ctx, tenantID)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	variants := make(map[uuid.UUID][]serviceOptionEntry)
+	addOns := make(map[uuid.UUID][]serviceOptionEntry)
+	for i := range optionRows {
+		row := &optionRows[i]
+		option := serviceOptionEntry{
+			OptionID:   row.OptionID,
+			OptionName: row.OptionName,
+			Price:      pricing.price(row.Price),
+			Total:      pricing.total(row.Price),
+		}
+		err := row.Duration.AssignTo(&option.Duration)
+		if err != nil {
+			JsonError(w, http.StatusInternalServerError, "not implemented")
+			return
+		}
+		if row.Kind == database.ServiceOptionKindVariant {
+			variants[row.ServiceID] = append(variants[row.ServiceID], option)
+		} else {
+			addOns[row.ServiceID] = append(addOns[row.ServiceID], option)
+		}
+	}
+
+	gscp := database.GetServiceCategoriesParams{
+		Language: lang,
+		TenantID: tenantID,
+	}
+	categoryRows, err := a.db.GetServiceCategories(ctx, gscp)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	services := make(map[uuid.UUID][]catalogueServiceEntry)
 	for i := range rows {
 		row := &rows[i]
-		service := serviceResponse{
-			PersonnelID: row.AccountID,
+		service := catalogueServiceEntry{
+			ServiceID:   row.ServiceID,
 			ServiceName: row.ServiceName,
-			ServiceID: row.ServiceID,
+			Description: row.Description,
+			Personnel:   personnel[row.ServiceID],
+			Variants:    variants[row.ServiceID],
+			AddOns:      addOns[row.ServiceID],
+			Price:       pricing.price(row.Price),
+			Total:       pricing.total(row.Price),
 		}
-		err := row.Price.AssignTo(&service.Price)
+		if service.Personnel == nil {
+			service.Personnel = []servicePersonnelEntry{}
+		}
+		if service.Variants == nil {
+			service.Variants = []serviceOptionEntry{}
+		}
+		if service.AddOns == nil {
+			service.AddOns = []serviceOptionEntry{}
+		}
+		err := row.Duration.AssignTo(&service.Duration)
 		if err != nil {
 			JsonError(w, http.StatusInternalServerError, "not implemented")
 			return
 		}
-		err = row.Duration.AssignTo(&service.Duration)
+		err = row.BufferBefore.AssignTo(&service.BufferBefore)
 		if err != nil {
 			JsonError(w, http.StatusInternalServerError, "not implemented")
 			return
 		}
-		response.Services = append(response.Services, service)
+		err = row.BufferAfter.AssignTo(&service.BufferAfter)
+		if err != nil {
+			JsonError(w, http.StatusInternalServerError, "not implemented")
+			return
+		}
+		// the services without a category are grouped under the nil UUID
+		categoryID := row.CategoryID.UUID
+		services[categoryID] = append(services[categoryID], service)
+	}
+
+	response := ServicesForTenantResponse{Language: lang}
+	response.Categories = make([]serviceCategoryEntry, 0, len(categoryRows)+1)
+	for _, row := range categoryRows {
+		category := serviceCategoryEntry{
+			CategoryID:   row.CategoryID,
+			CategoryName: row.CategoryName,
+			Position:     int(row.Position),
+			Services:     services[row.CategoryID],
+		}
+		if category.Services == nil {
+			category.Services = []catalogueServiceEntry{}
+		}
+		response.Categories = append(response.Categories, category)
+	}
+	if uncategorised, ok := services[uuid.Nil]; ok {
+		response.Categories = append(response.Categories, serviceCategoryEntry{
+			Services: uncategorised,
+		})
+	}
+
+	JsonResp(w, http.StatusOK, response)
+}
+
+// UpdateService changes the name, price, duration and buffers of a service.
+// Booked appointments keep the old ones, the previous versions are kept in the
+// history of the service.
+func (a *API) UpdateService(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	serviceID := ctx.Value(CtxServiceID).(uuid.UUID)
+	request := ctx.Value(CtxJSON).(*UpdateServiceRequest)
+
+	pricing, err := a.pricingFor(ctx, tenantID)
+	if err != nil {
+		JsonError(w, http.StatusNotFound, "invalid tenant")
+		return
+	}
+
+	msg := pricing.validService(request.Price, request.Duration)
+	if msg == "" {
+		msg = validBuffers(request.BufferBefore, request.BufferAfter)
+	}
+	if msg != "" {
+		JsonError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	usp := database.UpdateServiceParams{
+		ServiceName: request.ServiceName,
+		Price:       request.Price.Amount,
+		Description: request.Description,
+		CategoryID: uuid.NullUUID{
+			UUID:  request.CategoryID,
+			Valid: request.CategoryID != uuid.Nil,
+		},
+		TenantID:  tenantID,
+		ServiceID: serviceID,
+	}
+	usp.Duration.Set(request.Duration)
+	usp.BufferBefore.Set(request.BufferBefore)
+	usp.BufferAfter.Set(request.BufferAfter)
+
+	affected, err := a.db.UpdateService(ctx, usp)
+	if isUniqueViolation(err) {
+		JsonError(w, http.StatusConflict, "duplicate service name")
+		return
+	}
+	if err != nil {
+		JsonError(w, http.StatusBadRequest, "couldn't update service")
+		return
+	}
+	if affected == 0 {
+		JsonError(w, http.StatusNotFound, "invalid service")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// ArchiveService hides a service from the listings and stops new bookings,
+// the existing appointments are kept. The archived services can't be booked
+// with credits either, so archiving is a conflict while the credits of the
+// purchases aren't used up or the service is in a package that can be bought.
+func (a *API) ArchiveService(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	serviceID := ctx.Value(CtxServiceID).(uuid.UUID)
+
+	tx, err := a.txlike.Begin(ctx)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	defer tx.Rollback(ctx)
+	queries := database.New(tx)
+
+	hscp := database.HasServiceCreditsParams{
+		TenantID:  tenantID,
+		ServiceID: serviceID,
+	}
+	owed, err := queries.HasServiceCredits(ctx, hscp)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	if owed {
+		JsonError(w, http.StatusConflict, "service has credits")
+		return
+	}
+
+	asp := database.ArchiveServiceParams{
+		TenantID:  tenantID,
+		ServiceID: serviceID,
+	}
+	affected, err := queries.ArchiveService(ctx, asp)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	if affected == 0 {
+		JsonError(w, http.StatusNotFound, "invalid service")
+		return
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "couldn't archive service")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// ServiceHistory lists the versions of a service, oldest first.
+func (a *API) ServiceHistory(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	serviceID := ctx.Value(CtxServiceID).(uuid.UUID)
+
+	gsvp := database.GetServiceVersionsParams{
+		TenantID:  tenantID,
+		ServiceID: serviceID,
+	}
+	rows, err := a.db.GetServiceVersions(ctx, gsvp)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	if len(rows) == 0 {
+		JsonError(w, http.StatusNotFound, "invalid service")
+		return
+	}
+
+	var response ServiceHistoryResponse
+	response.Versions = make([]serviceVersionEntry, 0, len(rows))
+	for i := range rows {
+		row := &rows[i]
+		version := serviceVersionEntry{
+			Version:     int(row.Version),
+			ServiceName: row.ServiceName,
+			Price:       Money{Amount: row.Price, Currency: row.Currency},
+			ValidFrom:   row.ValidFrom,
+		}
+		err := row.Duration.AssignTo(&version.Duration)
+		if err != nil {
+			JsonError(w, http.StatusInternalServerError, "not implemented")
+			return
+		}
+		response.Versions = append(response.Versions, version)
 	}
 
 	JsonResp(w, http.StatusOK, response)
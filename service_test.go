@@ -8,6 +8,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/google/uuid"
 	"gitlab.com/vlad.anghel/schedder-api"
 )
 
@@ -29,7 +30,7 @@ func TestCreateService(t *testing.T) {
 	serviceName := "control_rutina"
 	request := schedder.CreateServiceRequest{
 		ServiceName: serviceName,
-		Price: 4.20,
+		Price: schedder.Money{Amount: 420},
 		Duration: 1 * time.Hour,
 	}
 
@@ -72,8 +73,9 @@ func TestServicesForPersonnel(t *testing.T) {
 	api.forceBusiness(email, true)
 	token := api.generateToken(email, password)
 	tenantID := api.createTenant(token, tenantName)
+	api.publishTenant(tenantID)
 
-	serviceID := api.createService(token, tenantID, accountID, "service1", 4.20, 1 * time.Hour)
+	serviceID := api.createService(token, tenantID, accountID, "service1", 420, 1 * time.Hour)
 
 	endpoint := fmt.Sprintf("/tenants/%s/personnel/%s/services", tenantID, accountID)
 	req := httptest.NewRequest(http.MethodGet, endpoint, nil)
@@ -109,3 +111,205 @@ func TestServicesForPersonnel(t *testing.T) {
 	}
 	
 }
+
+func TestUpdateAndArchiveService(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "example@example.com"
+	password := "hackmenow"
+	tenantName := "Zâna Măseluță"
+
+	accountID := api.registerUserByEmail(email, password)
+	api.activateUserByEmail(email)
+	api.forceBusiness(email, true)
+	token := api.generateToken(email, password)
+	tenantID := api.createTenant(token, tenantName)
+	api.publishTenant(tenantID)
+	serviceID := api.createService(token, tenantID, accountID, "control", 420, time.Hour)
+
+	today := localDate(time.Now())
+	starting := hourOn(today, 10)
+	api.setSchedule(token, accountID, tenantID, starting, starting.Add(8*time.Hour), today.Weekday())
+
+	serviceEndpoint := fmt.Sprintf("/tenants/%s/services/%s", tenantID, serviceID)
+	resp := api.send(
+		token, http.MethodPost, serviceEndpoint+"/schedule",
+		schedder.CreateAppointmentRequest{Starting: starting.Add(2 * time.Hour)},
+	)
+	expect(t, http.StatusCreated, resp.StatusCode)
+
+	update := schedder.UpdateServiceRequest{
+		ServiceName: "control complet",
+		Price:       schedder.Money{Amount: 690},
+		Duration:    90 * time.Minute,
+	}
+	resp = api.send(token, http.MethodPut, serviceEndpoint, update)
+	expect(t, http.StatusOK, resp.StatusCode)
+
+	resp = api.send(token, http.MethodGet, serviceEndpoint+"/history", nil)
+	expect(t, http.StatusOK, resp.StatusCode)
+	var history schedder.ServiceHistoryResponse
+	err := json.NewDecoder(resp.Body).Decode(&history)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, 2, len(history.Versions))
+	expect(t, int64(420), history.Versions[0].Price.Amount)
+	expect(t, "control complet", history.Versions[1].ServiceName)
+	expect(t, 90*time.Minute, history.Versions[1].Duration)
+
+	resp = api.send(token, http.MethodDelete, serviceEndpoint, nil)
+	expect(t, http.StatusOK, resp.StatusCode)
+
+	resp = api.send(token, http.MethodGet, fmt.Sprintf("/tenants/%s/services", tenantID), nil)
+	var services schedder.ServicesForTenantResponse
+	err = json.NewDecoder(resp.Body).Decode(&services)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, category := range services.Categories {
+		for _, service := range category.Services {
+			if service.ServiceID == serviceID {
+				t.Fatal("archived service is still listed")
+			}
+		}
+	}
+
+	// archived services can't be booked or changed
+	resp = api.send(
+		token, http.MethodPost, serviceEndpoint+"/schedule",
+		schedder.CreateAppointmentRequest{Starting: starting.Add(5 * time.Hour)},
+	)
+	expect(t, http.StatusBadRequest, resp.StatusCode)
+	resp = api.send(token, http.MethodPut, serviceEndpoint, update)
+	expect(t, http.StatusNotFound, resp.StatusCode)
+
+	// the appointment keeps the service as it was booked
+	resp = api.send(token, http.MethodGet, "/accounts/self/appointments", nil)
+	expect(t, http.StatusOK, resp.StatusCode)
+	var appointments schedder.AppointmentsResponse
+	err = json.NewDecoder(resp.Body).Decode(&appointments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, 1, len(appointments.Appointments))
+	expect(t, "control", appointments.Appointments[0].ServiceName)
+	expect(t, int64(420), appointments.Appointments[0].Price.Amount)
+	expect(t, time.Hour, appointments.Appointments[0].Duration)
+}
+
+func TestServiceCatalogue(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantName := "Zâna Măseluță"
+
+	tenantID := api.createTenantAndAccount(email, password, tenantName)
+	accountID := api.findAccountByEmail(email)
+	token := api.generateToken(email, password)
+	api.publishTenant(tenantID)
+
+	otherEmail := "other@example.com"
+	otherAccountID := api.registerUserByEmail(otherEmail, password)
+	api.activateUserByEmail(otherEmail)
+	api.addTenantMember(token, tenantID, otherAccountID)
+
+	today := localDate(time.Now())
+	starting := hourOn(today, 10)
+	for _, personnelID := range []uuid.UUID{accountID, otherAccountID} {
+		api.setSchedule(token, personnelID, tenantID, starting, starting.Add(8*time.Hour), today.Weekday())
+	}
+
+	servicesEndpoint := fmt.Sprintf("/tenants/%s/services", tenantID)
+	resp := api.send(token, http.MethodPost, servicesEndpoint, schedder.CreateServiceRequest{
+		ServiceName: "Tuns",
+		Price:       schedder.Money{Amount: 5000},
+		Duration:    time.Hour,
+	})
+	expect(t, http.StatusCreated, resp.StatusCode)
+	var created schedder.CreateServiceResponse
+	err := json.NewDecoder(resp.Body).Decode(&created)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serviceEndpoint := fmt.Sprintf("%s/%s", servicesEndpoint, created.ServiceID)
+
+	// the names are unique for the tenant, the members have their own prices
+	resp = api.send(token, http.MethodPost, servicesEndpoint, schedder.CreateServiceRequest{
+		ServiceName: "Tuns",
+		Price:       schedder.Money{Amount: 8000},
+		Duration:    time.Hour,
+	})
+	expect(t, http.StatusConflict, resp.StatusCode)
+
+	resp = api.send(
+		token, http.MethodPut, serviceEndpoint+"/personnel/"+accountID.String(),
+		schedder.AssignServicePersonnelRequest{},
+	)
+	expect(t, http.StatusOK, resp.StatusCode)
+	resp = api.send(
+		token, http.MethodPut, serviceEndpoint+"/personnel/"+otherAccountID.String(),
+		schedder.AssignServicePersonnelRequest{
+			Price: schedder.Money{Amount: 8000},
+		},
+	)
+	expect(t, http.StatusOK, resp.StatusCode)
+
+	resp = api.send(token, http.MethodGet, servicesEndpoint, nil)
+	var catalogue schedder.ServicesForTenantResponse
+	err = json.NewDecoder(resp.Body).Decode(&catalogue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, 1, len(catalogue.Categories))
+	expect(t, 1, len(catalogue.Categories[0].Services))
+	expect(t, 2, len(catalogue.Categories[0].Services[0].Personnel))
+	for _, personnel := range catalogue.Categories[0].Services[0].Personnel {
+		expect(t, time.Hour, personnel.Duration)
+		if personnel.PersonnelID == otherAccountID {
+			expect(t, int64(8000), personnel.Price.Amount)
+		} else {
+			expect(t, int64(5000), personnel.Price.Amount)
+		}
+	}
+
+	// any available member is picked, until everyone is busy
+	desired := starting.Add(2 * time.Hour)
+	booked := map[uuid.UUID]bool{}
+	for i := 0; i < 2; i++ {
+		resp = api.send(
+			token, http.MethodPost, serviceEndpoint+"/schedule",
+			schedder.CreateAppointmentRequest{Starting: desired},
+		)
+		expect(t, http.StatusCreated, resp.StatusCode)
+		var response schedder.CreateAppointmentResponse
+		err = json.NewDecoder(resp.Body).Decode(&response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		booked[response.PersonnelID] = true
+	}
+	expect(t, true, booked[accountID])
+	expect(t, true, booked[otherAccountID])
+
+	resp = api.send(
+		token, http.MethodPost, serviceEndpoint+"/schedule",
+		schedder.CreateAppointmentRequest{Starting: desired},
+	)
+	expect(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = api.send(
+		token, http.MethodDelete, serviceEndpoint+"/personnel/"+otherAccountID.String(), nil,
+	)
+	expect(t, http.StatusOK, resp.StatusCode)
+	resp = api.send(
+		token, http.MethodGet, serviceEndpoint+"/timetable",
+		schedder.TimetableRequest{Date: desired, PersonnelID: otherAccountID},
+	)
+	expect(t, http.StatusBadRequest, resp.StatusCode)
+}
@@ -0,0 +1,262 @@
+package schedder
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"gitlab.com/vlad.anghel/schedder-api/database"
+)
+
+// Kinds of service options. Exactly one variant must be chosen if the service
+// has any, add-ons are optional.
+const (
+	ServiceOptionVariant = string(database.ServiceOptionKindVariant)
+	ServiceOptionAddOn   = string(database.ServiceOptionKindAddon)
+)
+
+var (
+	// errVariantRequired is returned when the service has variants and none
+	// was chosen.
+	errVariantRequired = errors.New("variant required")
+	// errInvalidVariant is returned when the variant isn't one of the
+	// service.
+	errInvalidVariant = errors.New("invalid variant")
+	// errInvalidAddOn is returned when an add-on isn't one of the service or
+	// is chosen twice.
+	errInvalidAddOn = errors.New("invalid add-on")
+)
+
+// CreateServiceOptionRequest represents a new variant or add-on of a service.
+type CreateServiceOptionRequest struct {
+	// Kind represents the kind of the option, "variant" or "addon".
+	Kind string `json:"kind"`
+	// OptionName represents the name of the option, like "long hair".
+	OptionName string `json:"option_name"`
+	// Price represents the price added to the one of the service, before VAT.
+	Price Money `json:"price"`
+	// Duration represents the duration added to the one of the service.
+	Duration time.Duration `json:"duration"`
+}
+
+// CreateServiceOptionResponse represents the response of the service option
+// creation endpoint.
+type CreateServiceOptionResponse struct {
+	Response
+	OptionID uuid.UUID `json:"option_id"`
+}
+
+// serviceOptionEntry represents a variant or an add-on of a service.
+type serviceOptionEntry struct {
+	OptionID   uuid.UUID `json:"option_id"`
+	OptionName string    `json:"option_name"`
+	Price      Money     `json:"price"`
+	// Total represents the price with VAT.
+	Total    Money         `json:"total"`
+	Duration time.Duration `json:"duration"`
+}
+
+// ServiceOptionsResponse represents the variants and add-ons of a service.
+type ServiceOptionsResponse struct {
+	Response
+	Variants []serviceOptionEntry `json:"variants"`
+	AddOns   []serviceOptionEntry `json:"addons"`
+}
+
+// optionSelection represents the options chosen when booking a service.
+type optionSelection struct {
+	optionIDs []uuid.UUID
+	price     int64
+	duration  time.Duration
+}
+
+// selectOptions checks the variant and the add-ons chosen for the service and
+// returns what they add to it.
+func (a *API) selectOptions(
+	ctx context.Context,
+	tenantID, serviceID, variantID uuid.UUID,
+	addOnIDs []uuid.UUID,
+) (optionSelection, error) {
+	selection := optionSelection{optionIDs: []uuid.UUID{}}
+
+	gsop := database.GetServiceOptionsParams{
+		TenantID:  tenantID,
+		ServiceID: serviceID,
+	}
+	rows, err := a.db.GetServiceOptions(ctx, gsop)
+	if err != nil {
+		return selection, err
+	}
+
+	options := make(map[uuid.UUID]*database.GetServiceOptionsRow, len(rows))
+	hasVariants := false
+	for i := range rows {
+		options[rows[i].OptionID] = &rows[i]
+		if rows[i].Kind == database.ServiceOptionKindVariant {
+			hasVariants = true
+		}
+	}
+
+	chosen := func(option *database.GetServiceOptionsRow) error {
+		var duration time.Duration
+		err := option.Duration.AssignTo(&duration)
+		if err != nil {
+			return err
+		}
+		selection.optionIDs = append(selection.optionIDs, option.OptionID)
+		selection.price += option.Price
+		selection.duration += duration
+		return nil
+	}
+
+	if variantID == uuid.Nil {
+		if hasVariants {
+			return selection, errVariantRequired
+		}
+	} else {
+		variant, ok := options[variantID]
+		if !ok || variant.Kind != database.ServiceOptionKindVariant {
+			return selection, errInvalidVariant
+		}
+		err := chosen(variant)
+		if err != nil {
+			return selection, err
+		}
+	}
+
+	seen := make(map[uuid.UUID]bool, len(addOnIDs))
+	for _, addOnID := range addOnIDs {
+		addOn, ok := options[addOnID]
+		if !ok || addOn.Kind != database.ServiceOptionKindAddon || seen[addOnID] {
+			return selection, errInvalidAddOn
+		}
+		seen[addOnID] = true
+		err := chosen(addOn)
+		if err != nil {
+			return selection, err
+		}
+	}
+
+	return selection, nil
+}
+
+// CreateServiceOption adds a variant or an add-on to a service.
+func (a *API) CreateServiceOption(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	serviceID := ctx.Value(CtxServiceID).(uuid.UUID)
+	request := ctx.Value(CtxJSON).(*CreateServiceOptionRequest)
+
+	if request.Kind != ServiceOptionVariant && request.Kind != ServiceOptionAddOn {
+		JsonError(w, http.StatusBadRequest, "invalid kind")
+		return
+	}
+	if request.OptionName == "" {
+		JsonError(w, http.StatusBadRequest, "invalid option name")
+		return
+	}
+	pricing, err := a.pricingFor(ctx, tenantID)
+	if err != nil {
+		JsonError(w, http.StatusNotFound, "invalid tenant")
+		return
+	}
+	msg := pricing.validService(request.Price, request.Duration)
+	if msg != "" {
+		JsonError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	csop := database.CreateServiceOptionParams{
+		Kind:       database.ServiceOptionKind(request.Kind),
+		OptionName: request.OptionName,
+		Price:      request.Price.Amount,
+		TenantID:   tenantID,
+		ServiceID:  serviceID,
+	}
+	csop.Duration.Set(request.Duration)
+
+	optionID, err := a.db.CreateServiceOption(ctx, csop)
+	if err != nil {
+		JsonError(w, http.StatusBadRequest, "couldn't create option")
+		return
+	}
+
+	response := CreateServiceOptionResponse{OptionID: optionID}
+	JsonResp(w, http.StatusCreated, response)
+}
+
+// DeleteServiceOption deletes a variant or an add-on, the appointments keep
+// it as it was booked.
+func (a *API) DeleteServiceOption(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	serviceID := ctx.Value(CtxServiceID).(uuid.UUID)
+	optionID := ctx.Value(CtxOptionID).(uuid.UUID)
+
+	dsop := database.DeleteServiceOptionParams{
+		TenantID:  tenantID,
+		ServiceID: serviceID,
+		OptionID:  optionID,
+	}
+	affected, err := a.db.DeleteServiceOption(ctx, dsop)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	if affected == 0 {
+		JsonError(w, http.StatusNotFound, "invalid option")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// ServiceOptions lists the variants and the add-ons of a service.
+func (a *API) ServiceOptions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	serviceID := ctx.Value(CtxServiceID).(uuid.UUID)
+
+	gsop := database.GetServiceOptionsParams{
+		TenantID:  tenantID,
+		ServiceID: serviceID,
+	}
+	rows, err := a.db.GetServiceOptions(ctx, gsop)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	pricing, err := a.pricingFor(ctx, tenantID)
+	if err != nil {
+		JsonError(w, http.StatusNotFound, "invalid tenant")
+		return
+	}
+
+	response := ServiceOptionsResponse{
+		Variants: []serviceOptionEntry{},
+		AddOns:   []serviceOptionEntry{},
+	}
+	for i := range rows {
+		row := &rows[i]
+		option := serviceOptionEntry{
+			OptionID:   row.OptionID,
+			OptionName: row.OptionName,
+			Price:      pricing.price(row.Price),
+			Total:      pricing.total(row.Price),
+		}
+		err := row.Duration.AssignTo(&option.Duration)
+		if err != nil {
+			JsonError(w, http.StatusInternalServerError, "not implemented")
+			return
+		}
+		if row.Kind == database.ServiceOptionKindVariant {
+			response.Variants = append(response.Variants, option)
+		} else {
+			response.AddOns = append(response.AddOns, option)
+		}
+	}
+
+	JsonResp(w, http.StatusOK, response)
+}
@@ -0,0 +1,130 @@
+package schedder_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gitlab.com/vlad.anghel/schedder-api"
+)
+
+func TestServiceOptions(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantName := "Zâna Măseluță"
+
+	tenantID := api.createTenantAndAccount(email, password, tenantName)
+	accountID := api.findAccountByEmail(email)
+	token := api.generateToken(email, password)
+	api.publishTenant(tenantID)
+	serviceID := api.createService(token, tenantID, accountID, "Tuns", 5000, time.Hour)
+
+	today := localDate(time.Now())
+	starting := hourOn(today, 10)
+	api.setSchedule(token, accountID, tenantID, starting, starting.Add(8*time.Hour), today.Weekday())
+
+	serviceEndpoint := fmt.Sprintf("/tenants/%s/services/%s", tenantID, serviceID)
+	createOption := func(request schedder.CreateServiceOptionRequest) uuid.UUID {
+		resp := api.send(token, http.MethodPost, serviceEndpoint+"/options", request)
+		var response schedder.CreateServiceOptionResponse
+		err := json.NewDecoder(resp.Body).Decode(&response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect(t, "", response.Error)
+		expect(t, http.StatusCreated, resp.StatusCode)
+		return response.OptionID
+	}
+	longHair := createOption(schedder.CreateServiceOptionRequest{
+		Kind:       schedder.ServiceOptionVariant,
+		OptionName: "par lung",
+		Price:      schedder.Money{Amount: 2000},
+		Duration:   30 * time.Minute,
+	})
+	wash := createOption(schedder.CreateServiceOptionRequest{
+		Kind:       schedder.ServiceOptionAddOn,
+		OptionName: "spalat",
+		Price:      schedder.Money{Amount: 1000},
+		Duration:   30 * time.Minute,
+	})
+
+	resp := api.send(token, http.MethodGet, serviceEndpoint+"/options", nil)
+	var options schedder.ServiceOptionsResponse
+	err := json.NewDecoder(resp.Body).Decode(&options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, 1, len(options.Variants))
+	expect(t, longHair, options.Variants[0].OptionID)
+	expect(t, 1, len(options.AddOns))
+	expect(t, wash, options.AddOns[0].OptionID)
+
+	desired := starting.Add(2 * time.Hour)
+	resp = api.send(
+		token, http.MethodPost, serviceEndpoint+"/schedule",
+		schedder.CreateAppointmentRequest{Starting: desired},
+	)
+	expect(t, http.StatusBadRequest, resp.StatusCode)
+
+	// an add-on can't be used as a variant
+	resp = api.send(
+		token, http.MethodPost, serviceEndpoint+"/schedule",
+		schedder.CreateAppointmentRequest{Starting: desired, VariantID: wash},
+	)
+	expect(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = api.send(
+		token, http.MethodPost, serviceEndpoint+"/schedule",
+		schedder.CreateAppointmentRequest{
+			Starting:  desired,
+			VariantID: longHair,
+			AddOnIDs:  []uuid.UUID{wash},
+		},
+	)
+	expect(t, http.StatusCreated, resp.StatusCode)
+
+	resp = api.send(token, http.MethodGet, "/accounts/self/appointments", nil)
+	var appointments schedder.AppointmentsResponse
+	err = json.NewDecoder(resp.Body).Decode(&appointments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, 1, len(appointments.Appointments))
+	expect(t, int64(8000), appointments.Appointments[0].Price.Amount)
+	expect(t, 2*time.Hour, appointments.Appointments[0].Duration)
+	expect(t, 2, len(appointments.Appointments[0].Options))
+
+	// the booked appointment blocks its combined duration
+	resp = api.send(
+		token, http.MethodGet, serviceEndpoint+"/timetable",
+		schedder.TimetableRequest{
+			Date:      desired,
+			VariantID: longHair,
+			AddOnIDs:  []uuid.UUID{wash},
+		},
+	)
+	var timetable schedder.TimetableResponse
+	err = json.NewDecoder(resp.Body).Decode(&timetable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, "", timetable.Error)
+	for _, slot := range timetable.Times {
+		minutes := slot.Hour()*60 + slot.Minute()
+		// slots of 2 hours starting after 10:00 overlap 12:00 to 14:00
+		if minutes > 10*60 && minutes < 14*60 {
+			t.Fatalf("found time %s overlapping the appointment", slot)
+		}
+		// and they must end by 18:00
+		if minutes > 16*60 {
+			t.Fatalf("found time %s after the schedule", slot)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package schedder
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// minSigningKeySize represents the minimum size of a signing key, in bytes.
+const minSigningKeySize = 32
+
+// defaultSignedURLTTL represents the default validity of a signed photo URL.
+const defaultSignedURLTTL = 24 * time.Hour
+
+var (
+	errInvalidSignature = errors.New("invalid signature")
+	errExpiredSignature = errors.New("expired signature")
+)
+
+// SigningKey represents a key used to sign the photo URLs.
+type SigningKey struct {
+	// ID represents the name of the key, it's part of the signed URLs so
+	// that the key can be found when verifying them.
+	ID string
+	// Secret represents the HMAC key, at least 32 bytes.
+	Secret []byte
+}
+
+// ParseSigningKeys parses keys in the "id:base64secret,id:base64secret"
+// format, as found in SCHEDDER_PHOTO_URL_KEYS.
+func ParseSigningKeys(s string) ([]SigningKey, error) {
+	var keys []SigningKey
+	for _, entry := range strings.Split(s, ",") {
+		id, encoded, found := strings.Cut(strings.TrimSpace(entry), ":")
+		if !found || id == "" {
+			return nil, fmt.Errorf("invalid signing key %q", entry)
+		}
+		secret, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signing key %q: %w", id, err)
+		}
+		keys = append(keys, SigningKey{ID: id, Secret: secret})
+	}
+	return keys, nil
+}
+
+// PhotoSigner signs photo URLs that are served without a database lookup, so
+// they can be offloaded to a CDN. The first key signs, all the keys verify:
+// to rotate the keys, prepend the new key and drop the old one after the TTL.
+type PhotoSigner struct {
+	keys map[string][]byte
+	// current represents the ID of the key used for signing.
+	current string
+	ttl     time.Duration
+
+	// Now returns the current time, it can be replaced by tests.
+	Now func() time.Time
+}
+
+// NewPhotoSigner returns a signer whose URLs are valid for at least ttl.
+func NewPhotoSigner(keys []SigningKey, ttl time.Duration) (*PhotoSigner, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no signing keys")
+	}
+	if ttl <= 0 {
+		return nil, errors.New("invalid signed URL TTL")
+	}
+
+	signer := &PhotoSigner{
+		keys:    make(map[string][]byte, len(keys)),
+		current: keys[0].ID,
+		ttl:     ttl,
+		Now:     time.Now,
+	}
+	for _, key := range keys {
+		if len(key.Secret) < minSigningKeySize {
+			return nil, fmt.Errorf("signing key %q is too short", key.ID)
+		}
+		if _, ok := signer.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate signing key %q", key.ID)
+		}
+		signer.keys[key.ID] = key.Secret
+	}
+	return signer, nil
+}
+
+// signature returns the signature of the variant of the photo with the hex
+// encoded hash.
+func signature(secret []byte, hash, size string, expires int64) string {
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%s\n%s\n%d", hash, size, expires)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// URL returns the signed path of the variant of the photo with the hash. The
+// expiry is rounded up to a multiple of the TTL, so the URL doesn't change for
+// a while and can be cached by the clients and the CDN.
+func (s *PhotoSigner) URL(hash []byte, size string) string {
+	ttl := int64(s.ttl / time.Second)
+	if ttl == 0 {
+		ttl = 1
+	}
+	expires := (s.Now().Unix()/ttl + 2) * ttl
+	encoded := hex.EncodeToString(hash)
+
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("kid", s.current)
+	query.Set(
+		"sig", signature(s.keys[s.current], encoded, size, expires),
+	)
+	return fmt.Sprintf("/photos/%s/%s?%s", encoded, size, query.Encode())
+}
+
+// verify checks the signature of the URL of the variant, returning when it
+// expires.
+func (s *PhotoSigner) verify(
+	hash, size string, query url.Values,
+) (time.Time, error) {
+	secret, ok := s.keys[query.Get("kid")]
+	if !ok {
+		return time.Time{}, errInvalidSignature
+	}
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil {
+		return time.Time{}, errInvalidSignature
+	}
+
+	expected := signature(secret, hash, size, expires)
+	if !hmac.Equal([]byte(expected), []byte(query.Get("sig"))) {
+		return time.Time{}, errInvalidSignature
+	}
+
+	expiration := time.Unix(expires, 0)
+	if !s.Now().Before(expiration) {
+		return time.Time{}, errExpiredSignature
+	}
+	return expiration, nil
+}
+
+// SetPhotoSigner makes the listing endpoints return signed photo URLs, which
+// are served by DownloadSignedPhoto. Unsigned URLs are returned if it's nil.
+func (a *API) SetPhotoSigner(signer *PhotoSigner) {
+	a.signer = signer
+}
+
+// signerFromEnv returns the signer configured by SCHEDDER_PHOTO_URL_KEYS and
+// SCHEDDER_PHOTO_URL_TTL, or nil if there are no keys.
+func signerFromEnv() *PhotoSigner {
+	encoded, found := os.LookupEnv("SCHEDDER_PHOTO_URL_KEYS")
+	if !found {
+		return nil
+	}
+	keys, err := ParseSigningKeys(encoded)
+	if err != nil {
+		panic(err)
+	}
+
+	ttl := defaultSignedURLTTL
+	if value, found := os.LookupEnv("SCHEDDER_PHOTO_URL_TTL"); found {
+		ttl, err = time.ParseDuration(value)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	signer, err := NewPhotoSigner(keys, ttl)
+	if err != nil {
+		panic(err)
+	}
+	return signer
+}
+
+// DownloadSignedPhoto writes the variant of the photo from a signed URL
+// without looking it up in the database, only the signature is checked. The
+// URLs are signed only for the approved photos, so a photo rejected or deleted
+// later stays reachable until its URL expires. The responses can be cached by
+// a CDN until then.
+func (a *API) DownloadSignedPhoto(w http.ResponseWriter, r *http.Request) {
+	if a.signer == nil {
+		JsonError(w, http.StatusNotFound, "signed URLs are disabled")
+		return
+	}
+
+	encoded := chi.URLParam(r, "hash")
+	size := chi.URLParam(r, "size")
+	expiration, err := a.signer.verify(encoded, size, r.URL.Query())
+	if errors.Is(err, errExpiredSignature) {
+		JsonError(w, http.StatusForbidden, "expired URL")
+		return
+	}
+	if err != nil {
+		JsonError(w, http.StatusForbidden, "invalid signature")
+		return
+	}
+
+	hash, err := hex.DecodeString(encoded)
+	if err != nil || len(hash) != sha256.Size {
+		JsonError(w, http.StatusNotFound, "invalid photo hash")
+		return
+	}
+	if _, ok := photoSizes[size]; !ok {
+		JsonError(w, http.StatusBadRequest, "invalid size")
+		return
+	}
+
+	// The content behind the URL never changes, it can be cached until the
+	// URL expires.
+	maxAge := expiration.Sub(a.signer.Now())
+	cacheControl := fmt.Sprintf(
+		"public, max-age=%d, immutable", int(maxAge/time.Second),
+	)
+
+	// The type isn't known without the database, it's detected by
+	// http.ServeContent.
+	a.serveVariant(w, r, hash, size, sql.NullString{}, cacheControl)
+}
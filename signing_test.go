@@ -0,0 +1,136 @@
+package schedder_test
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.com/vlad.anghel/schedder-api"
+)
+
+func newPhotoSigner(t *testing.T, ids ...string) *schedder.PhotoSigner {
+	var keys []schedder.SigningKey
+	for _, id := range ids {
+		secret := bytes.Repeat([]byte(id), 32)
+		keys = append(keys, schedder.SigningKey{ID: id, Secret: secret})
+	}
+	signer, err := schedder.NewPhotoSigner(keys, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+func TestParseSigningKeys(t *testing.T) {
+	t.Parallel()
+
+	keys, err := schedder.ParseSigningKeys("new:AAAA,old:AQID")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, 2, len(keys))
+	expect(t, "new", keys[0].ID)
+	expect(t, "old", keys[1].ID)
+	expect(t, 3, len(keys[1].Secret))
+
+	_, err = schedder.ParseSigningKeys("missing-secret")
+	if err == nil {
+		t.Fatal("expected an error for a key without a secret")
+	}
+	_, err = schedder.NewPhotoSigner(
+		[]schedder.SigningKey{{ID: "short", Secret: []byte("short")}}, time.Hour,
+	)
+	if err == nil {
+		t.Fatal("expected an error for a short key")
+	}
+}
+
+func TestSignedPhotoURLs(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantName := "Zâna Măseluță"
+
+	tenantID := api.createTenantAndAccount(email, password, tenantName)
+	api.publishTenant(tenantID)
+	token := api.generateToken(email, password)
+	api.addTenantPhoto(token, tenantID, bytes.NewReader(testImage(t, 10, 10)))
+
+	old := newPhotoSigner(t, "old")
+	api.SetPhotoSigner(old)
+
+	response := api.listTenantPhotos(tenantID)
+	expect(t, 1, len(response.Photos))
+	signed := response.Photos[0].ThumbnailURL
+	if !strings.HasPrefix(signed, "/photos/") {
+		t.Fatalf("expected a signed URL, got %s", signed)
+	}
+
+	get := func(target string) *http.Response {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, r)
+		return w.Result()
+	}
+
+	resp := get(signed)
+	expect(t, http.StatusOK, resp.StatusCode)
+	expect(t, "image/jpeg", resp.Header.Get("Content-Type"))
+	cacheControl := resp.Header.Get("Cache-Control")
+	if !strings.HasPrefix(cacheControl, "public, max-age=") ||
+		!strings.HasSuffix(cacheControl, ", immutable") {
+		t.Fatalf("unexpected Cache-Control %s", resp.Header.Get("Cache-Control"))
+	}
+
+	// another size can't be fetched with the same signature
+	tampered := strings.Replace(
+		signed, schedder.PhotoSizeThumbnail, schedder.PhotoSizeOriginal, 1,
+	)
+	expect(t, http.StatusForbidden, get(tampered).StatusCode)
+
+	// URLs signed with the old key stay valid after a rotation
+	rotated := newPhotoSigner(t, "new", "old")
+	api.SetPhotoSigner(rotated)
+	expect(t, http.StatusOK, get(signed).StatusCode)
+	response = api.listTenantPhotos(tenantID)
+	if !strings.Contains(response.Photos[0].ThumbnailURL, "kid=new") {
+		t.Fatalf("expected the new key, got %s", response.Photos[0].ThumbnailURL)
+	}
+
+	// until the old key is dropped
+	api.SetPhotoSigner(newPhotoSigner(t, "new"))
+	expect(t, http.StatusForbidden, get(signed).StatusCode)
+
+	expired := newPhotoSigner(t, "old")
+	expired.Now = func() time.Time { return time.Now().Add(3 * time.Hour) }
+	api.SetPhotoSigner(expired)
+	expect(t, http.StatusForbidden, get(signed).StatusCode)
+
+	// a rejected photo isn't signed anymore, the URLs signed before stay
+	// valid until they expire
+	api.SetPhotoSigner(old)
+	expect(t, http.StatusOK, get(signed).StatusCode)
+	api.forceAdmin(email, true)
+	r, err := NewJSONRequest(
+		http.MethodPost,
+		fmt.Sprintf("/moderation/photos/%s", response.Photos[0].PhotoID),
+		schedder.ModeratePhotoRequest{Status: schedder.PhotoStatusRejected},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, r)
+	expect(t, http.StatusOK, w.Result().StatusCode)
+	response = api.listTenantPhotos(tenantID)
+	expect(t, 0, len(response.Photos))
+	expect(t, http.StatusOK, get(signed).StatusCode)
+}
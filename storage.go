@@ -0,0 +1,190 @@
+package schedder
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrBlobNotFound is returned by a BlobStorage when there is no blob with the
+// key.
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobInfo represents the metadata of a stored blob.
+type BlobInfo struct {
+	// Size represents the size of the blob, in bytes.
+	Size int64
+	// Modified represents when the blob was last written.
+	Modified time.Time
+}
+
+// BlobStorage stores the photos. Blobs are content-addressed, the keys are
+// the hex encoded sha256 sums of the originals, optionally followed by the
+// size of the variant, like "<hash>-thumbnail".
+type BlobStorage interface {
+	// Put stores size bytes read from r under the key, replacing the
+	// existing blob.
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	// Get opens the blob with the key. The caller must close it.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob with the key. Deleting a missing blob isn't an
+	// error.
+	Delete(ctx context.Context, key string) error
+	// Stat returns the metadata of the blob with the key.
+	Stat(ctx context.Context, key string) (BlobInfo, error)
+	// List calls fn for every stored blob, in no particular order, stopping
+	// at the first error.
+	List(ctx context.Context, fn func(key string, info BlobInfo) error) error
+}
+
+// validBlobKey reports whether the key can be used as a file name or as an
+// object name without escaping.
+func validBlobKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	return strings.Trim(key, "0123456789abcdefghijklmnopqrstuvwxyz-") == ""
+}
+
+var errInvalidBlobKey = errors.New("invalid blob key")
+
+// FSStorage is a BlobStorage that keeps the blobs as files in a directory.
+type FSStorage struct {
+	dir string
+}
+
+// NewFSStorage creates the directory if needed and returns a storage that
+// keeps the blobs in it.
+func NewFSStorage(dir string) (*FSStorage, error) {
+	err := os.MkdirAll(dir, 0777)
+	if err != nil {
+		return nil, err
+	}
+	return &FSStorage{dir: dir}, nil
+}
+
+// path returns the path of the file of the blob with the key.
+func (s *FSStorage) path(key string) (string, error) {
+	if !validBlobKey(key) {
+		return "", errInvalidBlobKey
+	}
+	return filepath.Join(s.dir, key), nil
+}
+
+// Put implements BlobStorage. The blob is written to a temporary file which is
+// then renamed, so readers never see a partial blob.
+func (s *FSStorage) Put(
+	ctx context.Context, key string, r io.Reader, size int64,
+) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(s.dir, ".tmp-"+key+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	written, err := io.Copy(file, r)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	if written != size {
+		file.Close()
+		return io.ErrUnexpectedEOF
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
+
+// Get implements BlobStorage.
+func (s *FSStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrBlobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// Delete implements BlobStorage.
+func (s *FSStorage) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Stat implements BlobStorage.
+func (s *FSStorage) Stat(ctx context.Context, key string) (BlobInfo, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return BlobInfo{}, err
+	}
+
+	stat, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return BlobInfo{}, ErrBlobNotFound
+	}
+	if err != nil {
+		return BlobInfo{}, err
+	}
+	return BlobInfo{Size: stat.Size(), Modified: stat.ModTime()}, nil
+}
+
+// List implements BlobStorage. Temporary files of unfinished writes are
+// skipped.
+func (s *FSStorage) List(
+	ctx context.Context, fn func(key string, info BlobInfo) error,
+) error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !validBlobKey(entry.Name()) {
+			continue
+		}
+		stat, err := entry.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			// deleted meanwhile
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		info := BlobInfo{Size: stat.Size(), Modified: stat.ModTime()}
+		err = fn(entry.Name(), info)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
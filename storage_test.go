@@ -0,0 +1,228 @@
+package schedder_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gitlab.com/vlad.anghel/schedder-api"
+)
+
+// fakeS3 is an in-memory S3-compatible service, it checks only that requests
+// are signed.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=access/") ||
+		r.Header.Get("X-Amz-Date") == "" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2" {
+		f.list(w, r)
+		return
+	}
+
+	data, found := f.objects[r.URL.Path]
+	switch r.Method {
+	case http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.objects[r.URL.Path] = body
+	case http.MethodGet, http.MethodHead:
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Header().Set("Last-Modified", time.Now().Format(http.TimeFormat))
+		w.Write(data)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// list answers ListObjectsV2, one object per page.
+func (f *fakeS3) list(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Path + "/"
+	var keys []string
+	for path := range f.objects {
+		if strings.HasPrefix(path, prefix) {
+			keys = append(keys, strings.TrimPrefix(path, prefix))
+		}
+	}
+	sort.Strings(keys)
+
+	start := 0
+	if token := r.URL.Query().Get("continuation-token"); token != "" {
+		start = sort.SearchStrings(keys, token)
+	}
+
+	var b strings.Builder
+	b.WriteString("<ListBucketResult>")
+	if start < len(keys) {
+		key := keys[start]
+		fmt.Fprintf(&b,
+			"<Contents><Key>%s</Key><Size>%d</Size>"+
+				"<LastModified>%s</LastModified></Contents>",
+			key, len(f.objects[prefix+key]),
+			time.Now().UTC().Format(time.RFC3339),
+		)
+	}
+	if start+1 < len(keys) {
+		fmt.Fprintf(&b,
+			"<IsTruncated>true</IsTruncated>"+
+				"<NextContinuationToken>%s</NextContinuationToken>",
+			keys[start+1],
+		)
+	}
+	b.WriteString("</ListBucketResult>")
+
+	w.Header().Set("Content-Type", "application/xml")
+	io.WriteString(w, b.String())
+}
+
+func testBlobStorage(t *testing.T, storage schedder.BlobStorage) {
+	ctx := context.Background()
+	key := "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	data := []byte("test")
+
+	_, err := storage.Stat(ctx, key)
+	expect(t, schedder.ErrBlobNotFound, err)
+
+	err = storage.Put(ctx, key, bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := storage.Stat(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, int64(len(data)), info.Size)
+
+	other := strings.Repeat("0", 64) + "-" + schedder.PhotoSizeThumbnail
+	err = storage.Put(ctx, other, bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	listed := make(map[string]int64)
+	err = storage.List(ctx, func(key string, info schedder.BlobInfo) error {
+		listed[key] = info.Size
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, 2, len(listed))
+	expect(t, int64(len(data)), listed[other])
+	err = storage.Delete(ctx, other)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blob, err := storage.Get(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(blob)
+	blob.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, string(data), string(got))
+
+	err = storage.Delete(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = storage.Delete(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = storage.Get(ctx, key)
+	if !errors.Is(err, schedder.ErrBlobNotFound) {
+		t.Fatalf("expected ErrBlobNotFound, got %v", err)
+	}
+
+	err = storage.Put(ctx, "../escape", bytes.NewReader(data), int64(len(data)))
+	if err == nil {
+		t.Fatal("expected an error for an invalid key")
+	}
+}
+
+func TestFSStorage(t *testing.T) {
+	t.Parallel()
+
+	storage, err := schedder.NewFSStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testBlobStorage(t, storage)
+}
+
+func TestS3Storage(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(&fakeS3{objects: make(map[string][]byte)})
+	defer server.Close()
+
+	storage, err := schedder.NewS3Storage(
+		server.URL, "us-east-1", "photos", "access", "secret",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testBlobStorage(t, storage)
+}
+
+// TestMinIOStorage runs against a real S3-compatible service, like a local
+// MinIO, if SCHEDDER_TEST_S3_ENDPOINT is defined. The bucket must exist and
+// be empty.
+func TestMinIOStorage(t *testing.T) {
+	t.Parallel()
+
+	endpoint, found := os.LookupEnv("SCHEDDER_TEST_S3_ENDPOINT")
+	if !found {
+		t.Skip("SCHEDDER_TEST_S3_ENDPOINT not defined")
+	}
+
+	storage, err := schedder.NewS3Storage(
+		endpoint,
+		os.Getenv("SCHEDDER_TEST_S3_REGION"),
+		os.Getenv("SCHEDDER_TEST_S3_BUCKET"),
+		os.Getenv("SCHEDDER_TEST_S3_ACCESS_KEY"),
+		os.Getenv("SCHEDDER_TEST_S3_SECRET_KEY"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testBlobStorage(t, storage)
+}
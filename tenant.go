@@ -1,11 +1,15 @@
 package schedder
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"time"
 	"unicode/utf8"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgconn"
 	"gitlab.com/vlad.anghel/schedder-api/database"
 )
 
@@ -32,6 +36,8 @@ type tenantsResponseEntry struct {
 
 	Rating float64 `json:"rating"`
 	ReviewCount int `json:"review_count"`
+	// Verified represents whether the tenant was verified by the admins.
+	Verified bool `json:"verified"`
 }
 
 // TenantsResponse represents the response of the tenant listing endpoint.
@@ -60,6 +66,8 @@ type memberResponse struct {
 	Phone string `json:"phone,omitempty"`
 	// IsManager represents whether the member is a manager.
 	IsManager bool `json:"is_manager"`
+	// IsOwner represents whether the member is the owner of the tenant.
+	IsOwner bool `json:"is_owner"`
 }
 
 // TenantMembersResponse represents a response for the tenant member listing
@@ -70,6 +78,68 @@ type TenantMembersResponse struct {
 	Members []memberResponse `json:"members,omitempty"`
 }
 
+// Policies for what happens with the services and future appointments of a
+// removed member, given by the appointments query parameter.
+const (
+	// RemovalPolicyCancel cancels the future appointments of the member, their
+	// services are kept only for the history of past appointments.
+	RemovalPolicyCancel = "cancel"
+	// RemovalPolicyReassign moves the services, along with their future
+	// appointments, to another member.
+	RemovalPolicyReassign = "reassign"
+)
+
+// RemoveTenantMemberResponse represents the response of the member removal
+// endpoint.
+type RemoveTenantMemberResponse struct {
+	Response
+	// CancelledAppointments represents the number of future appointments that
+	// were cancelled, with the "reassign" policy the ones nobody else was
+	// free for.
+	CancelledAppointments int `json:"cancelled_appointments"`
+	// ReassignedAppointments represents the number of future appointments
+	// that were reassigned to other members.
+	ReassignedAppointments int `json:"reassigned_appointments"`
+}
+
+// SetTenantManagerRequest represents a request to promote or demote a member.
+type SetTenantManagerRequest struct {
+	// IsManager represents whether the member should be a manager.
+	IsManager bool `json:"is_manager"`
+}
+
+// TransferOwnershipRequest represents a request to transfer the ownership of a
+// tenant to another member.
+type TransferOwnershipRequest struct {
+	// AccountID represents the ID of the member that becomes the owner.
+	AccountID uuid.UUID `json:"account_id"`
+}
+
+// isCheckViolation reports whether the error was caused by a CHECK constraint,
+// or by a trigger enforcing an invariant in the same manner.
+func isCheckViolation(err error) bool {
+	// See https://www.postgresql.org/docs/current/errcodes-appendix.html
+	const checkViolation = "23514"
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == checkViolation
+}
+
+// isUniqueViolation reports whether the error was caused by a UNIQUE
+// constraint or index.
+func isUniqueViolation(err error) bool {
+	const uniqueViolation = "23505"
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
+}
+
+// isForeignKeyViolation reports whether the error was caused by a FOREIGN KEY
+// constraint, like deleting a row that is still referenced.
+func isForeignKeyViolation(err error) bool {
+	const foreignKeyViolation = "23503"
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolation
+}
+
 // CreateTenant creates a new tenant.
 func (a *API) CreateTenant(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -83,8 +153,17 @@ func (a *API) CreateTenant(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	unpublished, err := a.db.CountUnpublishedTenants(ctx, accountID)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	if unpublished >= maxUnpublishedTenants {
+		JsonError(w, http.StatusBadRequest, "too many unpublished tenants")
+		return
+	}
+
 	var response CreateTenantResponse
-	var err error
 	ctwap := database.CreateTenantWithAccountParams{
 		AccountID:  accountID,
 		TenantName: request.Name,
@@ -100,7 +179,7 @@ func (a *API) CreateTenant(w http.ResponseWriter, r *http.Request) {
 	JsonResp(w, http.StatusCreated, response)
 }
 
-// Tenants lists all tenants.
+// Tenants lists all published tenants.
 func (a *API) Tenants(w http.ResponseWriter, r *http.Request) {
 	tenants, err := a.db.GetTenantsWithRating(r.Context())
 	if err != nil {
@@ -127,6 +206,7 @@ func (a *API) Tenants(w http.ResponseWriter, r *http.Request) {
 				Name: t.TenantName,
 				Rating: rating,
 				ReviewCount: reviewCount,
+				Verified: t.Verified,
 			},
 		)
 	}
@@ -180,8 +260,288 @@ func (a *API) TenantMembers(w http.ResponseWriter, r *http.Request) {
 			member.Phone = row.Phone.String
 		}
 		member.IsManager = row.IsManager
+		member.IsOwner = row.IsOwner
 		response.Members = append(response.Members, member)
 	}
 
 	JsonResp(w, http.StatusOK, response)
 }
+
+// reassignFutureAppointments gives every future appointment of the member to
+// another member doing the service who is free at that time, preferably to
+// preferredID, returning how many were reassigned. The appointments nobody is
+// free for are left to the member.
+func reassignFutureAppointments(
+	ctx context.Context, queries *database.Queries,
+	tenantID, accountID, preferredID uuid.UUID,
+) (int, error) {
+	gfafpp := database.GetFutureAppointmentsForPersonnelParams{
+		TenantID:  tenantID,
+		AccountID: accountID,
+	}
+	appointments, err := queries.GetFutureAppointmentsForPersonnel(ctx, gfafpp)
+	if err != nil {
+		return 0, err
+	}
+
+	reassigned := 0
+	for _, appointment := range appointments {
+		var extra time.Duration
+		err := appointment.OptionsDuration.AssignTo(&extra)
+		if err != nil {
+			return 0, err
+		}
+		starting := appointment.Starting
+		// the free slots see the appointments reassigned so far
+		slots, err := freeSlots(
+			ctx, queries, tenantID, appointment.ServiceID, uuid.Nil,
+			appointment.LocationID.UUID, starting, extra,
+		)
+		if isBookingError(err) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		chosen := uuid.Nil
+		for _, ps := range slots {
+			if ps.personnelID == accountID ||
+				(chosen != uuid.Nil && ps.personnelID != preferredID) {
+				continue
+			}
+			if ps.freeAt(starting) {
+				chosen = ps.personnelID
+			}
+		}
+		if chosen == uuid.Nil {
+			continue
+		}
+
+		rap := database.ReassignAppointmentParams{
+			PersonnelID:   chosen,
+			AppointmentID: appointment.AppointmentID,
+		}
+		err = queries.ReassignAppointment(ctx, rap)
+		if err != nil {
+			return 0, err
+		}
+		reassigned++
+	}
+	return reassigned, nil
+}
+
+// RemoveTenantMember removes a member from the tenant. Their future
+// appointments are either cancelled or reassigned together with their
+// services, depending on the appointments query parameter, with the member
+// taking over the services given by reassign_to. An appointment is reassigned
+// to a member who is free at that time, preferably the one taking over the
+// services, and it's cancelled if nobody is. The owner can't be removed, the
+// ownership has to be transferred first.
+func (a *API) RemoveTenantMember(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	accountID := ctx.Value(CtxAccountID).(uuid.UUID)
+	policy := r.URL.Query().Get("appointments")
+
+	var reassignTo uuid.UUID
+	switch policy {
+	case RemovalPolicyCancel:
+	case RemovalPolicyReassign:
+		var err error
+		reassignTo, err = uuid.Parse(r.URL.Query().Get("reassign_to"))
+		if err != nil {
+			JsonError(w, http.StatusBadRequest, "invalid reassign_to")
+			return
+		}
+	default:
+		JsonError(w, http.StatusBadRequest, "invalid policy")
+		return
+	}
+
+	tx, err := a.txlike.Begin(ctx)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	defer tx.Rollback(ctx)
+	queries := database.New(tx)
+
+	var response RemoveTenantMemberResponse
+
+	if policy == RemovalPolicyReassign {
+		if reassignTo == accountID {
+			JsonError(w, http.StatusBadRequest, "invalid reassign_to")
+			return
+		}
+		itmp := database.IsTenantMemberParams{
+			TenantID:  tenantID,
+			AccountID: reassignTo,
+		}
+		isMember, err := queries.IsTenantMember(ctx, itmp)
+		if err != nil {
+			JsonError(w, http.StatusInternalServerError, "not implemented")
+			return
+		}
+		if !isMember {
+			JsonError(w, http.StatusBadRequest, "invalid reassign_to")
+			return
+		}
+
+		rsp := database.ReassignServicesParams{
+			NewAccountID: reassignTo,
+			TenantID:     tenantID,
+			AccountID:    accountID,
+		}
+		err = queries.ReassignServices(ctx, rsp)
+		if err != nil {
+			JsonError(w, http.StatusBadRequest, "couldn't reassign services")
+			return
+		}
+
+		response.ReassignedAppointments, err = reassignFutureAppointments(
+			ctx, queries, tenantID, accountID, reassignTo,
+		)
+		if err != nil {
+			JsonError(w, http.StatusInternalServerError, "not implemented")
+			return
+		}
+	}
+
+	cfafpp := database.CancelFutureAppointmentsForPersonnelParams{
+		TenantID:  tenantID,
+		AccountID: accountID,
+	}
+	cancelled, err := queries.CancelFutureAppointmentsForPersonnel(ctx, cfafpp)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	response.CancelledAppointments = int(cancelled)
+
+	// the schedules at the other tenants of the account are kept
+	dsofmp := database.DeleteSchedulesOfFormerMemberParams{
+		TenantID:  tenantID,
+		AccountID: accountID,
+	}
+	err = queries.DeleteSchedulesOfFormerMember(ctx, dsofmp)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	rtmp := database.RemoveTenantMemberParams{
+		TenantID:  tenantID,
+		AccountID: accountID,
+	}
+	affected, err := queries.RemoveTenantMember(ctx, rtmp)
+	if isCheckViolation(err) {
+		JsonError(w, http.StatusBadRequest, "tenant needs a manager")
+		return
+	}
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "couldn't remove member")
+		return
+	}
+	if affected != 1 {
+		JsonError(w, http.StatusBadRequest, "not a member or owner")
+		return
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "couldn't remove member")
+		return
+	}
+
+	JsonResp(w, http.StatusOK, response)
+}
+
+// SetTenantManager promotes a member to manager or demotes a manager. The
+// owner can't be demoted and the tenant always keeps at least one manager.
+func (a *API) SetTenantManager(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	accountID := ctx.Value(CtxAccountID).(uuid.UUID)
+	request := ctx.Value(CtxJSON).(*SetTenantManagerRequest)
+
+	params := database.SetTenantManagerParams{
+		IsManager: request.IsManager,
+		TenantID:  tenantID,
+		AccountID: accountID,
+	}
+	affected, err := a.db.SetTenantManager(ctx, params)
+	if isCheckViolation(err) {
+		JsonError(w, http.StatusBadRequest, "tenant needs a manager")
+		return
+	}
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "couldn't set manager")
+		return
+	}
+	if affected != 1 {
+		JsonError(w, http.StatusBadRequest, "not a member or owner")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// TransferOwnership transfers the ownership of the tenant to another member,
+// who also becomes a manager. Only the current owner can do this, and they
+// remain a manager afterwards.
+func (a *API) TransferOwnership(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	authenticatedID := ctx.Value(CtxAuthenticatedID).(uuid.UUID)
+	request := ctx.Value(CtxJSON).(*TransferOwnershipRequest)
+
+	if request.AccountID == authenticatedID {
+		JsonError(w, http.StatusBadRequest, "already owner")
+		return
+	}
+
+	tx, err := a.txlike.Begin(ctx)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	defer tx.Rollback(ctx)
+	queries := database.New(tx)
+
+	ctop := database.ClearTenantOwnerParams{
+		TenantID:  tenantID,
+		AccountID: authenticatedID,
+	}
+	affected, err := queries.ClearTenantOwner(ctx, ctop)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	if affected != 1 {
+		JsonError(w, http.StatusForbidden, "not owner")
+		return
+	}
+
+	stop := database.SetTenantOwnerParams{
+		TenantID:  tenantID,
+		AccountID: request.AccountID,
+	}
+	affected, err = queries.SetTenantOwner(ctx, stop)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	if affected != 1 {
+		JsonError(w, http.StatusBadRequest, "not a member")
+		return
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "couldn't transfer")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
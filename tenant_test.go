@@ -2,11 +2,14 @@ package schedder_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"gitlab.com/vlad.anghel/schedder-api"
 )
@@ -88,7 +91,8 @@ func TestGetTenants(t *testing.T) {
 	password := "hackmenow"
 	tenantName := "Zâna Măseluță"
 
-	api.createTenantAndAccount(email, password, tenantName)
+	tenantID := api.createTenantAndAccount(email, password, tenantName)
+	api.publishTenant(tenantID)
 
 	r := httptest.NewRequest(http.MethodGet, "/tenants", nil)
 	w := httptest.NewRecorder()
@@ -280,3 +284,277 @@ func TestGetTenantMembers(t *testing.T) {
 	expect(t, 2, len(response.Members))
 	expect(t, 2, members)
 }
+
+func TestRemoveTenantMember(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantName := "Zâna Măseluță"
+	tenantID := api.createTenantAndAccount(email, password, tenantName)
+	token := api.generateToken(email, password)
+
+	otherEmail := "other@example.com"
+	otherPassword := "some_password"
+	otherAccountID := api.registerUserByEmail(otherEmail, otherPassword)
+	api.activateUserByEmail(otherEmail)
+	api.addTenantMember(token, tenantID, otherAccountID)
+
+	endpoint := fmt.Sprintf(
+		"/tenants/%s/members/%s?appointments=cancel", tenantID, otherAccountID,
+	)
+	r := httptest.NewRequest(http.MethodDelete, endpoint, nil)
+	r.Header.Add("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+
+	api.ServeHTTP(w, r)
+
+	resp := w.Result()
+	var response schedder.RemoveTenantMemberResponse
+	err := json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect(t, "", response.Error)
+	expect(t, http.StatusOK, resp.StatusCode)
+	expect(t, 1, len(api.tenantMembers(token, tenantID).Members))
+}
+
+func TestRemoveTenantMemberWithReassign(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantName := "Zâna Măseluță"
+	tenantID := api.createTenantAndAccount(email, password, tenantName)
+	api.publishTenant(tenantID)
+	accountID := api.findAccountByEmail(email)
+	token := api.generateToken(email, password)
+
+	otherEmail := "other@example.com"
+	otherPassword := "some_password"
+	otherAccountID := api.registerUserByEmail(otherEmail, otherPassword)
+	api.activateUserByEmail(otherEmail)
+	api.addTenantMember(token, tenantID, otherAccountID)
+	serviceID := api.createService(
+		token, tenantID, otherAccountID, "control", 420, time.Hour,
+	)
+
+	// the owner is free only for the first of the two appointments
+	tomorrow := localDate(time.Now()).AddDate(0, 0, 1)
+	starting := hourOn(tomorrow, 10)
+	api.setSchedule(token, otherAccountID, tenantID, starting, starting.Add(4*time.Hour), tomorrow.Weekday())
+	api.setSchedule(token, accountID, tenantID, starting, starting.Add(time.Hour), tomorrow.Weekday())
+	for _, at := range []time.Time{starting, starting.Add(2 * time.Hour)} {
+		r, err := NewJSONRequest(
+			http.MethodPost,
+			fmt.Sprintf("/tenants/%s/services/%s/schedule", tenantID, serviceID),
+			schedder.CreateAppointmentRequest{Starting: at},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Header.Add("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, r)
+		expect(t, http.StatusCreated, w.Result().StatusCode)
+	}
+
+	endpoint := fmt.Sprintf(
+		"/tenants/%s/members/%s?appointments=reassign&reassign_to=%s",
+		tenantID, otherAccountID, accountID,
+	)
+	r := httptest.NewRequest(http.MethodDelete, endpoint, nil)
+	r.Header.Add("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+
+	api.ServeHTTP(w, r)
+	var removed schedder.RemoveTenantMemberResponse
+	err := json.NewDecoder(w.Result().Body).Decode(&removed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, http.StatusOK, w.Result().StatusCode)
+	expect(t, 1, removed.ReassignedAppointments)
+	expect(t, 1, removed.CancelledAppointments)
+
+	r = httptest.NewRequest(http.MethodGet, "/accounts/self/appointments", nil)
+	r.Header.Add("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	api.ServeHTTP(w, r)
+	var appointments schedder.AppointmentsResponse
+	err = json.NewDecoder(w.Result().Body).Decode(&appointments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// newest first
+	expect(t, 2, len(appointments.Appointments))
+	expect(t, "cancelled", appointments.Appointments[0].Status)
+	expect(t, "pending", appointments.Appointments[1].Status)
+	expect(t, accountID, appointments.Appointments[1].PersonnelID)
+
+	endpoint = fmt.Sprintf("/tenants/%s/services", tenantID)
+	r = httptest.NewRequest(http.MethodGet, endpoint, nil)
+	w = httptest.NewRecorder()
+
+	api.ServeHTTP(w, r)
+
+	var response schedder.ServicesForTenantResponse
+	err = json.NewDecoder(w.Result().Body).Decode(&response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, 1, len(response.Categories))
+	services := response.Categories[0].Services
+	expect(t, 1, len(services))
+	expect(t, serviceID, services[0].ServiceID)
+	expect(t, 1, len(services[0].Personnel))
+	expect(t, accountID, services[0].Personnel[0].PersonnelID)
+}
+
+func TestRemoveTenantOwner(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantName := "Zâna Măseluță"
+	tenantID := api.createTenantAndAccount(email, password, tenantName)
+	accountID := api.findAccountByEmail(email)
+	token := api.generateToken(email, password)
+
+	endpoint := fmt.Sprintf(
+		"/tenants/%s/members/%s?appointments=cancel", tenantID, accountID,
+	)
+	r := httptest.NewRequest(http.MethodDelete, endpoint, nil)
+	r.Header.Add("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+
+	api.ServeHTTP(w, r)
+
+	resp := w.Result()
+	var response schedder.Response
+	err := json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect(t, "not a member or owner", response.Error)
+	expect(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestSetTenantManager(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantName := "Zâna Măseluță"
+	tenantID := api.createTenantAndAccount(email, password, tenantName)
+	token := api.generateToken(email, password)
+
+	otherEmail := "other@example.com"
+	otherPassword := "some_password"
+	otherAccountID := api.registerUserByEmail(otherEmail, otherPassword)
+	api.activateUserByEmail(otherEmail)
+	api.addTenantMember(token, tenantID, otherAccountID)
+
+	endpoint := fmt.Sprintf(
+		"/tenants/%s/members/%s/manager", tenantID, otherAccountID,
+	)
+	request := schedder.SetTenantManagerRequest{IsManager: true}
+	r, err := NewJSONRequest(http.MethodPut, endpoint, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Add("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+
+	api.ServeHTTP(w, r)
+	expect(t, http.StatusOK, w.Result().StatusCode)
+
+	for _, member := range api.tenantMembers(token, tenantID).Members {
+		expect(t, true, member.IsManager)
+	}
+}
+
+func TestTransferOwnership(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantName := "Zâna Măseluță"
+	tenantID := api.createTenantAndAccount(email, password, tenantName)
+	accountID := api.findAccountByEmail(email)
+	token := api.generateToken(email, password)
+
+	otherEmail := "other@example.com"
+	otherPassword := "some_password"
+	otherAccountID := api.registerUserByEmail(otherEmail, otherPassword)
+	api.activateUserByEmail(otherEmail)
+	api.addTenantMember(token, tenantID, otherAccountID)
+
+	endpoint := fmt.Sprintf("/tenants/%s/owner", tenantID)
+	request := schedder.TransferOwnershipRequest{AccountID: otherAccountID}
+	r, err := NewJSONRequest(http.MethodPost, endpoint, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Add("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+
+	api.ServeHTTP(w, r)
+	expect(t, http.StatusOK, w.Result().StatusCode)
+
+	for _, member := range api.tenantMembers(token, tenantID).Members {
+		expect(t, true, member.IsManager)
+		expect(t, member.AccountID == otherAccountID, member.IsOwner)
+	}
+
+	// the previous owner is now just a manager, so it can be demoted
+	endpoint = fmt.Sprintf(
+		"/tenants/%s/members/%s/manager", tenantID, accountID,
+	)
+	r, err = NewJSONRequest(
+		http.MethodPut, endpoint, schedder.SetTenantManagerRequest{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Add("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+
+	api.ServeHTTP(w, r)
+	expect(t, http.StatusOK, w.Result().StatusCode)
+}
+
+func TestTenantKeepsAManager(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantName := "Zâna Măseluță"
+	tenantID := api.createTenantAndAccount(email, password, tenantName)
+
+	// bypass the handlers, the invariant is also enforced by the database
+	_, err := api.tx.Exec(
+		context.Background(),
+		"UPDATE tenant_accounts SET is_owner = false, is_manager = false "+
+			"WHERE tenant_id = $1",
+		tenantID,
+	)
+	if err == nil {
+		t.Fatal("expected the tenant to keep at least one manager")
+	}
+}
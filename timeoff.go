@@ -0,0 +1,278 @@
+package schedder
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"gitlab.com/vlad.anghel/schedder-api/database"
+)
+
+// CreateTimeOffRequest represents a time off requested for a member, like a
+// vacation. It's a day off for every date once a manager approves it.
+type CreateTimeOffRequest struct {
+	// StartingDate and EndingDate represent the first and the last day off,
+	// only the dates in the time zone of the tenant are used, like for the
+	// exceptions.
+	StartingDate time.Time `json:"starting_date"`
+	EndingDate   time.Time `json:"ending_date"`
+	// Reason represents the reason shown to the managers, like "vacation".
+	Reason string `json:"reason"`
+}
+
+// CreateTimeOffResponse represents the response of the time off request
+// endpoint.
+type CreateTimeOffResponse struct {
+	Response
+	TimeOffID uuid.UUID `json:"time_off_id"`
+}
+
+// timeOffEntry represents a time off request.
+type timeOffEntry struct {
+	TimeOffID    uuid.UUID `json:"time_off_id"`
+	AccountID    uuid.UUID `json:"account_id"`
+	StartingDate time.Time `json:"starting_date"`
+	EndingDate   time.Time `json:"ending_date"`
+	Reason       string    `json:"reason"`
+	// Status represents the status, "pending", "approved" or "rejected".
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	// DecidedAt represents when a manager approved or rejected the request,
+	// it's the zero time if it's pending.
+	DecidedAt time.Time `json:"decided_at"`
+}
+
+// TimeOffResponse represents a list of time off requests.
+type TimeOffResponse struct {
+	Response
+	TimeOff []timeOffEntry `json:"time_off"`
+}
+
+// DecideTimeOffRequest represents the decision of a manager on a pending time
+// off request. An approved request can also be rejected, which cancels it.
+type DecideTimeOffRequest struct {
+	Approved bool `json:"approved"`
+}
+
+// DecideTimeOffResponse represents the response of the time off decision
+// endpoint.
+type DecideTimeOffResponse struct {
+	Response
+	// ConflictingAppointments represents the pending appointments of the
+	// dates of the request that are outside the working hours of the member
+	// after the decision, they're flagged as conflicting.
+	ConflictingAppointments []uuid.UUID `json:"conflicting_appointments"`
+}
+
+// isSelfOrManager reports whether the authenticated account is the member
+// itself or a manager of the tenant.
+func (a *API) isSelfOrManager(
+	ctx context.Context, tenantID, accountID, authenticatedID uuid.UUID,
+) bool {
+	if authenticatedID == accountID {
+		return true
+	}
+	itmp := database.IsTenantManagerParams{
+		TenantID: tenantID, AccountID: authenticatedID,
+	}
+	isManager, err := a.db.IsTenantManager(ctx, itmp)
+	return err == nil && isManager
+}
+
+// RequestTimeOff creates a pending time off request for a member, it can be
+// made by the member or by a manager.
+func (a *API) RequestTimeOff(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	accountID := ctx.Value(CtxAccountID).(uuid.UUID)
+	authenticatedID := ctx.Value(CtxAuthenticatedID).(uuid.UUID)
+	request := ctx.Value(CtxJSON).(*CreateTimeOffRequest)
+
+	if !a.isSelfOrManager(ctx, tenantID, accountID, authenticatedID) {
+		JsonError(w, http.StatusForbidden, "not manager")
+		return
+	}
+
+	tz, err := tenantTimezone(ctx, a.db, tenantID)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	starting := dateOf(request.StartingDate, tz)
+	ending := dateOf(request.EndingDate, tz)
+	if request.StartingDate.IsZero() || request.EndingDate.IsZero() ||
+		ending.Before(starting) || ending.Before(dateOf(time.Now(), tz)) {
+		JsonError(w, http.StatusBadRequest, "invalid dates")
+		return
+	}
+
+	ctop := database.CreateTimeOffParams{
+		TenantID:     tenantID,
+		AccountID:    accountID,
+		StartingDate: starting,
+		EndingDate:   ending,
+		Reason:       request.Reason,
+	}
+	timeOffID, err := a.db.CreateTimeOff(ctx, ctop)
+	if err != nil {
+		JsonError(w, http.StatusBadRequest, "not a member")
+		return
+	}
+
+	response := CreateTimeOffResponse{TimeOffID: timeOffID}
+	JsonResp(w, http.StatusCreated, response)
+}
+
+// timeOffEntries converts the time off rows of the database.
+func timeOffEntries(rows []database.GetTimeOffForAccountRow) []timeOffEntry {
+	entries := make([]timeOffEntry, 0, len(rows))
+	for _, row := range rows {
+		entries = append(entries, timeOffEntry{
+			TimeOffID:    row.TimeOffID,
+			AccountID:    row.AccountID,
+			StartingDate: row.StartingDate,
+			EndingDate:   row.EndingDate,
+			Reason:       row.Reason,
+			Status:       string(row.Status),
+			CreatedAt:    row.CreatedAt,
+			DecidedAt:    row.DecidedAt.Time,
+		})
+	}
+	return entries
+}
+
+// TimeOffForPersonnel lists the time off requests of a member, newest first,
+// to the member or to a manager.
+func (a *API) TimeOffForPersonnel(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	accountID := ctx.Value(CtxAccountID).(uuid.UUID)
+	authenticatedID := ctx.Value(CtxAuthenticatedID).(uuid.UUID)
+
+	if !a.isSelfOrManager(ctx, tenantID, accountID, authenticatedID) {
+		JsonError(w, http.StatusForbidden, "not manager")
+		return
+	}
+
+	gtofap := database.GetTimeOffForAccountParams{
+		TenantID:  tenantID,
+		AccountID: accountID,
+	}
+	rows, err := a.db.GetTimeOffForAccount(ctx, gtofap)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	JsonResp(w, http.StatusOK, TimeOffResponse{TimeOff: timeOffEntries(rows)})
+}
+
+// PendingTimeOff lists the time off requests of the tenant waiting for a
+// decision, by starting date.
+func (a *API) PendingTimeOff(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+
+	rows, err := a.db.GetPendingTimeOff(ctx, tenantID)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	entries := make([]database.GetTimeOffForAccountRow, 0, len(rows))
+	for _, row := range rows {
+		entries = append(entries, database.GetTimeOffForAccountRow(row))
+	}
+	JsonResp(w, http.StatusOK, TimeOffResponse{TimeOff: timeOffEntries(entries)})
+}
+
+// WithdrawTimeOff deletes a pending time off request of a member.
+func (a *API) WithdrawTimeOff(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	accountID := ctx.Value(CtxAccountID).(uuid.UUID)
+	authenticatedID := ctx.Value(CtxAuthenticatedID).(uuid.UUID)
+	timeOffID := ctx.Value(CtxTimeOffID).(uuid.UUID)
+
+	if !a.isSelfOrManager(ctx, tenantID, accountID, authenticatedID) {
+		JsonError(w, http.StatusForbidden, "not manager")
+		return
+	}
+
+	wtop := database.WithdrawTimeOffParams{
+		TenantID:  tenantID,
+		AccountID: accountID,
+		TimeOffID: timeOffID,
+	}
+	affected, err := a.db.WithdrawTimeOff(ctx, wtop)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	if affected == 0 {
+		JsonError(w, http.StatusNotFound, "invalid time off")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// DecideTimeOff approves or rejects a pending time off request, or cancels an
+// approved one. The conflicts of the pending appointments of the dates are
+// recomputed, the ones during an approved time off are flagged as
+// conflicting, they aren't cancelled.
+func (a *API) DecideTimeOff(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tenantID := ctx.Value(CtxTenantID).(uuid.UUID)
+	authenticatedID := ctx.Value(CtxAuthenticatedID).(uuid.UUID)
+	timeOffID := ctx.Value(CtxTimeOffID).(uuid.UUID)
+	request := ctx.Value(CtxJSON).(*DecideTimeOffRequest)
+
+	tx, err := a.txlike.Begin(ctx)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	defer tx.Rollback(ctx)
+	queries := database.New(tx)
+
+	dtop := database.DecideTimeOffParams{
+		Status:    database.TimeOffStatusRejected,
+		DecidedBy: uuid.NullUUID{UUID: authenticatedID, Valid: true},
+		TenantID:  tenantID,
+		TimeOffID: timeOffID,
+	}
+	if request.Approved {
+		dtop.Status = database.TimeOffStatusApproved
+	}
+	timeOff, err := queries.DecideTimeOff(ctx, dtop)
+	if errors.Is(err, pgx.ErrNoRows) {
+		JsonError(w, http.StatusNotFound, "invalid time off")
+		return
+	}
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+
+	conflicting, err := updateConflicts(
+		ctx, queries, tenantID, timeOff.AccountID,
+		timeOff.StartingDate, timeOff.EndingDate,
+	)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return
+	}
+	response := DecideTimeOffResponse{ConflictingAppointments: conflicting}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "couldn't decide time off")
+		return
+	}
+
+	JsonResp(w, http.StatusOK, response)
+}
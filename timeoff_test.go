@@ -0,0 +1,177 @@
+package schedder_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"gitlab.com/vlad.anghel/schedder-api"
+)
+
+func TestTimeOff(t *testing.T) {
+	t.Parallel()
+	api := BeginTx(t)
+	defer api.Rollback()
+
+	email := "tester@example.com"
+	password := "hackmenow"
+	tenantName := "Zâna Măseluță"
+
+	tenantID := api.createTenantAndAccount(email, password, tenantName)
+	managerToken := api.generateToken(email, password)
+	api.publishTenant(tenantID)
+
+	stylistEmail := "stylist@example.com"
+	stylistID := api.registerUserByEmail(stylistEmail, password)
+	api.activateUserByEmail(stylistEmail)
+	stylistToken := api.generateToken(stylistEmail, password)
+	api.addTenantMember(managerToken, tenantID, stylistID)
+
+	strangerEmail := "stranger@example.com"
+	api.registerUserByEmail(strangerEmail, password)
+	api.activateUserByEmail(strangerEmail)
+	strangerToken := api.generateToken(strangerEmail, password)
+
+	serviceID := api.createService(managerToken, tenantID, stylistID, "Tuns", 5000, time.Hour)
+	today := localDate(time.Now())
+	starting := hourOn(today, 10)
+	api.setSchedule(managerToken, stylistID, tenantID, starting, starting.Add(3*time.Hour), today.Weekday())
+
+	tenantEndpoint := fmt.Sprintf("/tenants/%s", tenantID)
+	resp := api.send(
+		strangerToken, http.MethodPost,
+		fmt.Sprintf("%s/services/%s/schedule", tenantEndpoint, serviceID),
+		schedder.CreateAppointmentRequest{Starting: starting},
+	)
+	var booked schedder.CreateAppointmentResponse
+	err := json.NewDecoder(resp.Body).Decode(&booked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, http.StatusCreated, resp.StatusCode)
+
+	timetable := func() int {
+		resp := api.send(
+			strangerToken, http.MethodGet,
+			fmt.Sprintf("%s/services/%s/timetable", tenantEndpoint, serviceID),
+			schedder.TimetableRequest{Date: starting},
+		)
+		var response schedder.TimetableResponse
+		err := json.NewDecoder(resp.Body).Decode(&response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect(t, "", response.Error)
+		return len(response.Times)
+	}
+
+	endpoint := fmt.Sprintf("%s/personnel/%s/time-off", tenantEndpoint, stylistID)
+	request := schedder.CreateTimeOffRequest{
+		StartingDate: today,
+		EndingDate:   today.AddDate(0, 0, 6),
+		Reason:       "concediu",
+	}
+	resp = api.send(strangerToken, http.MethodPost, endpoint, request)
+	expect(t, http.StatusForbidden, resp.StatusCode)
+	resp = api.send(stylistToken, http.MethodPost, endpoint, schedder.CreateTimeOffRequest{
+		StartingDate: today,
+		EndingDate:   today.AddDate(0, 0, -1),
+	})
+	expect(t, http.StatusBadRequest, resp.StatusCode)
+
+	requestTimeOff := func() schedder.CreateTimeOffResponse {
+		resp := api.send(stylistToken, http.MethodPost, endpoint, request)
+		var response schedder.CreateTimeOffResponse
+		err := json.NewDecoder(resp.Body).Decode(&response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect(t, "", response.Error)
+		expect(t, http.StatusCreated, resp.StatusCode)
+		return response
+	}
+
+	// a pending request can be withdrawn
+	withdrawn := requestTimeOff()
+	resp = api.send(
+		stylistToken, http.MethodDelete,
+		fmt.Sprintf("%s/%s", endpoint, withdrawn.TimeOffID), nil,
+	)
+	expect(t, http.StatusOK, resp.StatusCode)
+
+	// the pending requests don't change the timetable
+	created := requestTimeOff()
+	expect(t, 2, timetable())
+
+	resp = api.send(managerToken, http.MethodGet, tenantEndpoint+"/time-off", nil)
+	var pending schedder.TimeOffResponse
+	err = json.NewDecoder(resp.Body).Decode(&pending)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, 1, len(pending.TimeOff))
+	expect(t, created.TimeOffID, pending.TimeOff[0].TimeOffID)
+	expect(t, "pending", pending.TimeOff[0].Status)
+
+	// only the managers decide
+	decisionEndpoint := fmt.Sprintf("%s/time-off/%s", tenantEndpoint, created.TimeOffID)
+	resp = api.send(
+		stylistToken, http.MethodPut, decisionEndpoint,
+		schedder.DecideTimeOffRequest{Approved: true},
+	)
+	expect(t, http.StatusForbidden, resp.StatusCode)
+	resp = api.send(
+		managerToken, http.MethodPut, decisionEndpoint,
+		schedder.DecideTimeOffRequest{Approved: true},
+	)
+	var decision schedder.DecideTimeOffResponse
+	err = json.NewDecoder(resp.Body).Decode(&decision)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, http.StatusOK, resp.StatusCode)
+	expect(t, 1, len(decision.ConflictingAppointments))
+	expect(t, booked.AppointmentID, decision.ConflictingAppointments[0])
+	expect(t, 0, timetable())
+
+	resp = api.send(
+		managerToken, http.MethodPut, decisionEndpoint,
+		schedder.DecideTimeOffRequest{Approved: true},
+	)
+	expect(t, http.StatusNotFound, resp.StatusCode)
+
+	resp = api.send(stylistToken, http.MethodGet, endpoint, nil)
+	var history schedder.TimeOffResponse
+	err = json.NewDecoder(resp.Body).Decode(&history)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, 1, len(history.TimeOff))
+	expect(t, "approved", history.TimeOff[0].Status)
+	expect(t, false, history.TimeOff[0].DecidedAt.IsZero())
+
+	// rejecting the approved request cancels it, the appointment is kept
+	resp = api.send(
+		managerToken, http.MethodPut, decisionEndpoint,
+		schedder.DecideTimeOffRequest{Approved: false},
+	)
+	decision = schedder.DecideTimeOffResponse{}
+	err = json.NewDecoder(resp.Body).Decode(&decision)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, http.StatusOK, resp.StatusCode)
+	expect(t, 0, len(decision.ConflictingAppointments))
+	expect(t, 2, timetable())
+
+	resp = api.send(strangerToken, http.MethodGet, "/accounts/self/appointments", nil)
+	var appointments schedder.AppointmentsResponse
+	err = json.NewDecoder(resp.Body).Decode(&appointments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, 1, len(appointments.Appointments))
+	expect(t, false, appointments.Appointments[0].Conflicting)
+}
@@ -15,6 +15,10 @@ class {{.Name}} {
 	final List<{{$arr.Name}}> {{$name}};
 	{{- end}}
 
+	{{- range $name, $obj := .Objects}}
+	final {{$obj.Name}} {{$name}};
+	{{- end}}
+
 
 	{{.Name}}({
 		{{- range .Fields}}
@@ -23,6 +27,9 @@ class {{.Name}} {
 		{{- range $name, $arr := .Arrays}}
 		required this.{{$name}},
 		{{- end}}
+		{{- range $name, $obj := .Objects}}
+		required this.{{$name}},
+		{{- end}}
 	});
 
 	factory {{.Name}}.fromJson(Map<String, dynamic> json) {
@@ -33,6 +40,9 @@ class {{.Name}} {
 			{{- range $name, $arr := .Arrays}}
 			{{$name}}: (json['{{$name}}'] as List<dynamic>).map((i) => {{$arr.Name}}.fromJson(e as Map<String, dynamic>)).toList()
 			{{- end}}
+			{{- range $name, $obj := .Objects}}
+			{{$name}}: {{$obj.Name}}.fromJson(json['{{$name}}'] as Map<String, dynamic>),
+			{{- end}}
 		);
 	}
 
@@ -43,6 +53,9 @@ class {{.Name}} {
 		{{- range $name, $arr := .Arrays}}
 		'{{$name}}': this.{{$name}}.map((i) => i.toJson()).toList(),
 		{{- end}}
+		{{- range $name, $obj := .Objects}}
+		'{{$name}}': this.{{$name}}.toJson(),
+		{{- end}}
 	};
 }
 `
@@ -93,7 +93,7 @@ func findJSONRequestType(middleware func(http.Handler) http.Handler) string {
 }
 
 func main() {
-	api := schedder.New(nil, nil, nil, "")
+	api := schedder.New(nil, nil, nil, nil)
 
 	b := bytes.Buffer{}
 
@@ -177,6 +177,8 @@ func main() {
 	objects := make(ObjectStore)
 	objects["UUID"] = &Object{Name: "UUID", Fields: nil, Arrays: nil, Objects: nil}
 	objects["Time"] = &Object{Name: "Time", Fields: nil, Arrays: nil, Objects: nil}
+	objects["string"] = &Object{Name: "string", Fields: nil, Arrays: nil, Objects: nil}
+	objects["Weekday"] = &Object{Name: "Weekday", Fields: nil, Arrays: nil, Objects: nil}
 
 	for _, file := range pkg.Files {
 		for _, declaration := range file.Decls {
@@ -238,7 +240,7 @@ func main() {
 		}
 
 		// TODO: add typealiasing support instead of hard coding this
-		if ep.Name == "ServicesForTenant" {
+		if ep.Name == "LocationServices" {
 			base := "Services"
 			ep.Output = objects[base+"Response"]
 		} else if ep.Input != nil {
@@ -274,6 +276,8 @@ func main() {
 
 	objects["UUID"].used = false
 	objects["Time"].used = false
+	objects["string"].used = false
+	objects["Weekday"].used = false
 	objects["API"].used = false
 
 	fmt.Println(strings.Repeat("*", 80))
@@ -20,6 +20,9 @@ export class {{.Name}}{{if .IsResponse}} extends ApiResponse{{end}} {
 {{- range $name, $arr := .Arrays}}
 	{{$name}}: {{$arr.AsTypeScriptArray}}[] = [];
 {{- end}}
+{{- range $name, $obj := .Objects}}
+	{{$name}}: {{$obj.Name}} = new {{$obj.Name}}();
+{{- end}}
 }
 `
 
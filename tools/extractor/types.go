@@ -42,8 +42,32 @@ func (m *Middleware) HTMLRequirement() string {
 		return "Required URL parameter: <code>photoID</code>"
 	case "WithServiceID":
 		return "Required URL parameter: <code>serviceID</code>"
+	case "WithInvitationID":
+		return "Required URL parameter: <code>invitationID</code>"
+	case "WithLocationID":
+		return "Required URL parameter: <code>locationID</code>"
+	case "WithClosureID":
+		return "Required URL parameter: <code>closureID</code>"
+	case "WithOptionID":
+		return "Required URL parameter: <code>optionID</code>"
+	case "WithCategoryID":
+		return "Required URL parameter: <code>categoryID</code>"
+	case "WithLanguage":
+		return "Required URL parameter: <code>language</code>"
+	case "WithPackageID":
+		return "Required URL parameter: <code>packageID</code>"
+	case "WithRuleID":
+		return "Required URL parameter: <code>ruleID</code>"
+	case "WithIntervalID":
+		return "Required URL parameter: <code>intervalID</code>"
+	case "WithExceptionID":
+		return "Required URL parameter: <code>exceptionID</code>"
+	case "WithTimeOffID":
+		return "Required URL parameter: <code>timeOffID</code>"
 	case "AdminEndpoint":
 		return "Requires the authenticated user to be an <strong>Admin</strong>"
+	case "VisibleTenantEndpoint":
+		return "Requires <strong>tenantID</strong> from the URL parameter to be published, unless the authenticated user is a member or an <strong>Admin</strong>"
 	case "TenantManagerEndpoint":
 		return "Requires the authenticated user to be an <strong>Manager</strong> of <strong>tenantID</strong> from the URL parameter"
 	case "CorsHandler":
@@ -68,6 +92,28 @@ func (m *Middleware) HTMLFormInput() string {
 		value = "photoID"
 	case "WithServiceID":
 		value = "serviceID"
+	case "WithInvitationID":
+		value = "invitationID"
+	case "WithLocationID":
+		value = "locationID"
+	case "WithClosureID":
+		value = "closureID"
+	case "WithOptionID":
+		value = "optionID"
+	case "WithCategoryID":
+		value = "categoryID"
+	case "WithLanguage":
+		value = "language"
+	case "WithPackageID":
+		value = "packageID"
+	case "WithRuleID":
+		value = "ruleID"
+	case "WithIntervalID":
+		value = "intervalID"
+	case "WithExceptionID":
+		value = "exceptionID"
+	case "WithTimeOffID":
+		value = "timeOffID"
 	case "AuthenticatedEndpoint":
 		value = "token"
 	}
@@ -339,8 +385,10 @@ func (f Field) Example() string {
 		value = "4.20"
 	case "Duration":
 		value = "30"
-	case "int":
+	case "int", "int64":
 		value = "0"
+	case "Weekday":
+		value = "1"
 
 	default:
 		panic("unimplemented" + f.TypeName)
@@ -389,7 +437,7 @@ func (f Field) DartType() string {
 		return "float"
 	case "Duration":
 		return "int"
-	case "int":
+	case "int", "int64", "Weekday":
 		return "int"
 	default:
 		panic("don't know how to dartify " + f.TypeName)
@@ -409,7 +457,7 @@ func (f Field) TypeScriptType() string {
 		typename = "number"
 	case "float64":
 		typename = "number"
-	case "int":
+	case "int", "int64", "Weekday":
 		typename = "number"
 	default:
 		panic("don't know how to typescriptify " + f.TypeName)
@@ -430,7 +478,7 @@ func (f Field) TypeScriptDefault() string {
 		return "0"
 	case "Duration":
 		return "0"
-	case "int":
+	case "int", "int64", "Weekday":
 		return "0"
 	default:
 		panic("don't know what the default in TypeScript should be for " + f.TypeName)
@@ -513,6 +561,12 @@ func (o *Object) Sample(level int, showOmitEmpty bool) string {
 		if a.Name == "UUID" {
 			sb.WriteString(Indent(level + 2))
 			sb.WriteString(Quote(uuid.NewString()))
+		} else if a.Name == "string" {
+			sb.WriteString(Indent(level + 2))
+			sb.WriteString(Quote("example"))
+		} else if a.Name == "Weekday" {
+			sb.WriteString(Indent(level + 2))
+			sb.WriteString("1")
 		} else {
 			s := a.Sample(level+2, showOmitEmpty)
 			sb.WriteString(s)
@@ -522,6 +576,13 @@ func (o *Object) Sample(level int, showOmitEmpty bool) string {
 		sb.WriteString(Indent(level + 1))
 		sb.WriteString("]\n")
 	}
+	for k, obj := range o.Objects {
+		sb.WriteString(Indent(level + 1))
+		sb.WriteString(Quote(k))
+		sb.WriteString(":\n")
+		sb.WriteString(obj.Sample(level+2, showOmitEmpty))
+		sb.WriteString("\n")
+	}
 	sb.WriteString(Indent(level))
 	sb.WriteString("}")
 	return sb.String()
@@ -551,6 +612,9 @@ func (o *Object) AsTypeScriptArray() string {
 	if o.Name == "Time" {
 		return "Date"
 	}
+	if o.Name == "Weekday" {
+		return "number"
+	}
 	return o.Name
 }
 
@@ -560,6 +624,7 @@ type ObjectStore map[string]*Object
 func (os ObjectStore) extractStruct(name string, st *ast.StructType) {
 	fields := make([]Field, 0)
 	arrays := make(map[string]*Object)
+	objects := make(map[string]*Object)
 	for _, field := range st.Fields.List {
 		if field.Names != nil && field.Tag != nil {
 			tag, omitempty := fieldFromTag(field.Tag.Value)
@@ -567,7 +632,13 @@ func (os ObjectStore) extractStruct(name string, st *ast.StructType) {
 
 			switch t := field.Type.(type) {
 			case *ast.Ident:
-				fields = append(fields, Field{Name: tag, TypeName: t.Name, OmitEmpty: omitempty, Doc: doc})
+				// structs of the package, like Money, are nested objects
+				obj, ok := os[t.Name]
+				if ok && t.Name != "UUID" && t.Name != "Time" && t.Name != "string" {
+					objects[tag] = obj
+				} else {
+					fields = append(fields, Field{Name: tag, TypeName: t.Name, OmitEmpty: omitempty, Doc: doc})
+				}
 			case *ast.SelectorExpr:
 				fields = append(fields, Field{Name: tag, TypeName: t.Sel.Name, OmitEmpty: omitempty, Doc: doc})
 			case *ast.ArrayType:
@@ -597,6 +668,7 @@ func (os ObjectStore) extractStruct(name string, st *ast.StructType) {
 
 	os[name].Name = name
 	os[name].Arrays = arrays
+	os[name].Objects = objects
 	os[name].Fields = fields
 	os[name].used = false
 
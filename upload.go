@@ -0,0 +1,218 @@
+package schedder
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"hash"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+
+	"gitlab.com/vlad.anghel/schedder-api/database"
+)
+
+// defaultMaxPhotoSize represents the default maximum size of an uploaded
+// photo, in bytes.
+const defaultMaxPhotoSize = 10 << 20
+
+// photoFormField represents the name of the form field with the photo, for
+// multipart uploads.
+const photoFormField = "photo"
+
+var errMissingPhoto = errors.New("missing photo")
+
+// uploadedPhoto represents an uploaded image, processed into its variants.
+// The variants are kept in temporary files until they are stored, Close
+// removes them.
+type uploadedPhoto struct {
+	variants map[string]*os.File
+	mimeType string
+	// checksum represents the sha256 of the original variant.
+	checksum [sha256.Size]byte
+	// status, reason and perceptualHash represent the result of the
+	// moderation, see moderatePhoto.
+	status         database.PhotoStatus
+	reason         sql.NullString
+	perceptualHash uint64
+}
+
+// Close removes the temporary files of the variants.
+func (p *uploadedPhoto) Close() {
+	for _, file := range p.variants {
+		file.Close()
+		os.Remove(file.Name())
+	}
+}
+
+// SetMaxPhotoSize sets the maximum size of an uploaded photo, in bytes.
+func (a *API) SetMaxPhotoSize(size int64) {
+	a.maxPhotoSize = size
+}
+
+// uploadBody returns the uploaded file, which is either the whole body or the
+// photo field of a multipart/form-data body, as sent by browsers.
+func uploadBody(r *http.Request) (io.Reader, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return r.Body, nil
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, errMissingPhoto
+	}
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil, errMissingPhoto
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == photoFormField {
+			return part, nil
+		}
+	}
+}
+
+// spool copies the upload to a temporary file, so that it isn't kept in
+// memory.
+func spool(upload io.Reader) (*os.File, error) {
+	file, err := os.CreateTemp("", "schedder-upload-*")
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = io.Copy(file, upload)
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// readPhoto streams the uploaded image to a temporary file and processes it
+// into its variants, hashing the original while it's written. It returns an
+// error message for the client if the image is too large or isn't valid.
+func (a *API) readPhoto(
+	w http.ResponseWriter, r *http.Request,
+) (*uploadedPhoto, int, string) {
+	r.Body = http.MaxBytesReader(w, r.Body, a.maxPhotoSize)
+
+	var maxBytesError *http.MaxBytesError
+	upload, err := uploadBody(r)
+	if errors.As(err, &maxBytesError) {
+		return nil, http.StatusRequestEntityTooLarge, "photo too large"
+	}
+	if errors.Is(err, errMissingPhoto) {
+		return nil, http.StatusBadRequest, "missing photo"
+	}
+	if err != nil {
+		return nil, http.StatusBadRequest, "invalid upload"
+	}
+
+	src, err := spool(upload)
+	if errors.As(err, &maxBytesError) {
+		return nil, http.StatusRequestEntityTooLarge, "photo too large"
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, "couldn't read photo"
+	}
+	defer os.Remove(src.Name())
+	defer src.Close()
+
+	photo := &uploadedPhoto{variants: make(map[string]*os.File)}
+	var writeErr error
+	var checksum hash.Hash
+	dst := func(size string) io.Writer {
+		file, err := os.CreateTemp("", "schedder-variant-*")
+		if err != nil {
+			writeErr = err
+			return io.Discard
+		}
+		photo.variants[size] = file
+		if size != PhotoSizeOriginal {
+			return file
+		}
+		checksum = sha256.New()
+		return io.MultiWriter(file, checksum)
+	}
+
+	photo.mimeType, err = processImage(src, dst)
+	if err == nil {
+		err = writeErr
+	}
+	if errors.Is(err, errInvalidImage) {
+		photo.Close()
+		return nil, http.StatusBadRequest, "invalid image"
+	}
+	if err != nil {
+		photo.Close()
+		return nil, http.StatusInternalServerError, "couldn't process image"
+	}
+	copy(photo.checksum[:], checksum.Sum(nil))
+
+	return photo, 0, ""
+}
+
+// uploadPhoto is the code path shared by all the photo uploads. It reads the
+// photo, calls insert to reference it from the database and stores its blob,
+// all in one transaction. It writes the error and returns false if the upload
+// failed, otherwise the caller writes the response.
+func (a *API) uploadPhoto(
+	w http.ResponseWriter, r *http.Request,
+	insert func(
+		ctx context.Context, queries *database.Queries, photo *uploadedPhoto,
+	) error,
+) bool {
+	ctx := r.Context()
+
+	photo, status, msg := a.readPhoto(w, r)
+	if msg != "" {
+		JsonError(w, status, msg)
+		return false
+	}
+	defer photo.Close()
+
+	err := a.moderatePhoto(ctx, photo)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "couldn't moderate photo")
+		return false
+	}
+
+	tx, err := a.txlike.Begin(ctx)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return false
+	}
+	defer tx.Rollback(ctx)
+	queries := database.New(tx)
+
+	err = insert(ctx, queries, photo)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "not implemented")
+		return false
+	}
+
+	err = a.storePhotoBlob(ctx, queries, photo)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "couldn't store photo")
+		return false
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		JsonError(w, http.StatusInternalServerError, "couldn't store photo")
+		return false
+	}
+
+	return true
+}